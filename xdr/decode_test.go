@@ -17,7 +17,9 @@
 package xdr
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"math"
 	"reflect"
 	"testing"
@@ -472,3 +474,28 @@ func TestDecoder(t *testing.T) {
 		}
 	}
 }
+
+// TestErrorsIs ensures errors.Is works with the error types and error codes as
+// expected.
+func TestErrorsIs(t *testing.T) {
+	_, err := Unmarshal([]byte{0x00, 0x00}, new(uint32))
+	if !errors.Is(err, ErrUnexpectedEnd) {
+		t.Errorf("Unmarshal truncated error code got: %v want: %v", err,
+			ErrUnexpectedEnd)
+	}
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("Unmarshal truncated got: %v want: %v", err,
+			io.ErrUnexpectedEOF)
+	}
+
+	_, err = Unmarshal([]byte{0x00, 0x00, 0x00, 0x02}, new(bool))
+	if !errors.Is(err, ErrBadEnumValue) || errors.Is(err, ErrUnexpectedEnd) {
+		t.Errorf("Unmarshal corrupt got: %v want: %v", err,
+			ErrBadEnumValue)
+	}
+
+	_, err = Marshal(make(chan int))
+	if !errors.Is(err, ErrUnsupportedType) {
+		t.Errorf("Marshal got: %v want: %v", err, ErrUnsupportedType)
+	}
+}
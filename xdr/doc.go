@@ -29,6 +29,13 @@ automatically generate Go code by parsing an XDR data specification file
 package is fairly minor since it is largely unnecessary due to the reflection
 capabilities of Go as described below.
 
+This package is version 1 of the XDR package and is kept for compatibility with
+existing clients.  Its encoding and decoding are now provided by the unified
+github.com/davecgh/go-xdr/xdr3 package, which offers the same byte slice based
+functions as AppendMarshal and UnmarshalBytes alongside functions that work with
+the standard io.Reader and io.Writer interfaces.  New clients should use the
+xdr3 package instead.
+
 This package provides two approaches for encoding and decoding XDR data:
 
 	1) Marshal/Unmarshal functions which automatically map between XDR and Go types
@@ -78,7 +85,8 @@ Notes and Limitations:
 	  alias for uint8 thus indistinguishable under reflection
 	* Channel, complex, and function types cannot be encoded
 	* Interfaces without a concrete value cannot be encoded
-	* Cyclic data structures are not supported and will result in infinite loops
+	* Cyclic data structures cannot be encoded and result in a MarshalError
+	  with an error code of ErrCycle
 	* Strings are marshalled and unmarshalled with UTF-8 character encoding
 	  which differs from the XDR specification of ASCII, however UTF-8 is
 	  backwards compatible with ASCII so this should rarely cause issues
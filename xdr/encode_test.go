@@ -37,6 +37,11 @@ func TestMarshal(t *testing.T) {
 	// interface.
 	testInterface := interface{}(17)
 
+	// cyclicList is used to test Marshal with a cyclic data structure.
+	type cyclic struct{ Next *cyclic }
+	cyclicList := &cyclic{}
+	cyclicList.Next = cyclicList
+
 	// structMarshalTestIn is input data for the big struct test of all
 	// supported types.
 	structMarshalTestIn := allTypesTest{
@@ -223,6 +228,7 @@ func TestMarshal(t *testing.T) {
 		{&testComplex64, nil, &MarshalError{ErrorCode: ErrUnsupportedType}},
 		{testComplex128, nil, &MarshalError{ErrorCode: ErrUnsupportedType}},
 		{&testComplex128, nil, &MarshalError{ErrorCode: ErrUnsupportedType}},
+		{cyclicList, nil, &MarshalError{ErrorCode: ErrCycle}},
 	}
 
 	for i, test := range tests {
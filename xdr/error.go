@@ -18,6 +18,8 @@ package xdr
 
 import (
 	"fmt"
+
+	"github.com/davecgh/go-xdr/xdr3"
 )
 
 // ErrorCode identifies a kind of error.
@@ -56,6 +58,10 @@ const (
 	// was encountered.  Type information is necessary to perform mapping
 	// between XDR and Go types.
 	ErrNilInterface
+
+	// ErrCycle indicates a value being encoded contains itself, such as a
+	// struct with a pointer field which points back to it.
+	ErrCycle
 )
 
 // Map of ErrorCode values back to their constant names for pretty printing.
@@ -67,6 +73,7 @@ var errorCodeStrings = map[ErrorCode]string{
 	ErrNotSettable:     "ErrNotSettable",
 	ErrOverflow:        "ErrOverflow",
 	ErrNilInterface:    "ErrNilInterface",
+	ErrCycle:           "ErrCycle",
 }
 
 // String returns the ErrorCode as a human-readable name.
@@ -77,6 +84,14 @@ func (e ErrorCode) String() string {
 	return fmt.Sprintf("Unknown ErrorCode (%d)", e)
 }
 
+// Error returns the ErrorCode as a human-readable name.  It satisfies the
+// error interface so an ErrorCode can be used as the target of errors.Is to
+// test whether an error is an UnmarshalError or MarshalError with that error
+// code.
+func (e ErrorCode) Error() string {
+	return e.String()
+}
+
 // UnmarshalError describes a problem encountered while unmarshaling data.
 // Some potential issues are unsupported Go types, attempting to decode a value
 // which is too large to fit into a specified Go type, and exceeding max slice
@@ -86,6 +101,7 @@ type UnmarshalError struct {
 	Func        string      // Function name
 	Value       interface{} // Value actually parsed where appropriate
 	Description string      // Human readable description of the issue
+	Err         error       // The underlying error, if any
 }
 
 // Error satisfies the error interface and prints human-readable errors.
@@ -97,21 +113,74 @@ func (e *UnmarshalError) Error() string {
 	return fmt.Sprintf("xdr:%s: %s", e.Func, e.Description)
 }
 
-// unmarshalError creates an error given a set of arguments and will copy byte
-// slices into the Value field since they might otherwise be changed from from
-// the original value.
-func unmarshalError(f string, c ErrorCode, desc string, v interface{}) *UnmarshalError {
-	e := &UnmarshalError{ErrorCode: c, Func: f, Description: desc}
-	switch t := v.(type) {
-	case []byte:
-		slice := make([]byte, len(t))
-		copy(slice, t)
-		e.Value = slice
-	default:
-		e.Value = v
+// Unwrap returns the underlying error, if any, so errors.Is and errors.As can
+// examine it.
+func (e *UnmarshalError) Unwrap() error {
+	return e.Err
+}
+
+// Is returns whether the passed target is an ErrorCode equal to the error code
+// of the error.  It allows errors.Is to test the error code.
+func (e *UnmarshalError) Is(target error) bool {
+	code, ok := target.(ErrorCode)
+	return ok && e.ErrorCode == code
+}
+
+// errorCodes maps the error codes of the xdr3 package to those of this package.
+// Every code is mapped even though some only occur with features this package
+// does not expose.  Exceeding a resource limit means the data is too large, so
+// it is reported as ErrOverflow, and data which is not in canonical form is
+// improperly structured, so it is reported as ErrUnexpectedEnd.
+var errorCodes = map[xdr.ErrorCode]ErrorCode{
+	xdr.ErrBadArguments:    ErrBadArguments,
+	xdr.ErrUnsupportedType: ErrUnsupportedType,
+	xdr.ErrBadEnumValue:    ErrBadEnumValue,
+	xdr.ErrNotSettable:     ErrNotSettable,
+	xdr.ErrOverflow:        ErrOverflow,
+	xdr.ErrNilInterface:    ErrNilInterface,
+	xdr.ErrIO:              ErrUnexpectedEnd,
+	xdr.ErrParseTime:       ErrBadArguments,
+	xdr.ErrBadDiscriminant: ErrBadEnumValue,
+	xdr.ErrMaxTotalBytes:   ErrOverflow,
+	xdr.ErrMaxElements:     ErrOverflow,
+	xdr.ErrMaxDepth:        ErrOverflow,
+	xdr.ErrMaxAllocBytes:   ErrOverflow,
+	xdr.ErrNonzeroPadding:  ErrUnexpectedEnd,
+	xdr.ErrTrailingBytes:   ErrUnexpectedEnd,
+	xdr.ErrNonCanonicalNaN: ErrUnexpectedEnd,
+	xdr.ErrDuplicateKey:    ErrUnexpectedEnd,
+	xdr.ErrCycle:           ErrCycle,
+}
+
+// errorCode returns the error code of this package which corresponds to the
+// passed error code of the xdr3 package.  Unknown codes are reported as
+// ErrBadArguments.
+func errorCode(c xdr.ErrorCode) ErrorCode {
+	if code, ok := errorCodes[c]; ok {
+		return code
 	}
+	return ErrBadArguments
+}
 
-	return e
+// unmarshalErrorFrom converts an UnmarshalError of the xdr3 package to an
+// UnmarshalError of this package.  Errors parsing time values are returned as
+// is since this package has always done so and any other errors are returned
+// unchanged.
+func unmarshalErrorFrom(err error) error {
+	e, ok := err.(*xdr.UnmarshalError)
+	if !ok {
+		return err
+	}
+	if e.ErrorCode == xdr.ErrParseTime {
+		return e.Err
+	}
+	return &UnmarshalError{
+		ErrorCode:   errorCode(e.ErrorCode),
+		Func:        e.Func,
+		Value:       e.Value,
+		Description: e.Description,
+		Err:         e.Err,
+	}
 }
 
 // MarshalError describes a problem encountered while marshaling data.
@@ -123,6 +192,7 @@ type MarshalError struct {
 	Func        string      // Function name
 	Value       interface{} // Value actually parsed where appropriate
 	Description string      // Human readable description of the issue
+	Err         error       // The underlying error, if any
 }
 
 // Error satisfies the error interface and prints human-readable errors.
@@ -134,19 +204,31 @@ func (e *MarshalError) Error() string {
 	return fmt.Sprintf("xdr:%s: %s", e.Func, e.Description)
 }
 
-// marshalError creates an error given a set of arguments and will copy byte
-// slices into the Value field since they might otherwise be changed from from
-// the original value.
-func marshalError(f string, c ErrorCode, desc string, v interface{}) *MarshalError {
-	e := &MarshalError{ErrorCode: c, Func: f, Description: desc}
-	switch t := v.(type) {
-	case []byte:
-		slice := make([]byte, len(t))
-		copy(slice, t)
-		e.Value = slice
-	default:
-		e.Value = v
-	}
+// Unwrap returns the underlying error, if any, so errors.Is and errors.As can
+// examine it.
+func (e *MarshalError) Unwrap() error {
+	return e.Err
+}
+
+// Is returns whether the passed target is an ErrorCode equal to the error code
+// of the error.  It allows errors.Is to test the error code.
+func (e *MarshalError) Is(target error) bool {
+	code, ok := target.(ErrorCode)
+	return ok && e.ErrorCode == code
+}
 
-	return e
+// marshalErrorFrom converts a MarshalError of the xdr3 package to a
+// MarshalError of this package.  Any other errors are returned unchanged.
+func marshalErrorFrom(err error) error {
+	e, ok := err.(*xdr.MarshalError)
+	if !ok {
+		return err
+	}
+	return &MarshalError{
+		ErrorCode:   errorCode(e.ErrorCode),
+		Func:        e.Func,
+		Value:       e.Value,
+		Description: e.Description,
+		Err:         e.Err,
+	}
 }
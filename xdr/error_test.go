@@ -0,0 +1,123 @@
+/*
+ * Copyright (c) 2014 Dave Collins <dave@davec.name>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package xdr
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	xdr3 "github.com/davecgh/go-xdr/xdr3"
+)
+
+// TestErrorCodes ensures every error code of the xdr3 package is mapped to the
+// expected error code of this package.
+func TestErrorCodes(t *testing.T) {
+	tests := []struct {
+		in   xdr3.ErrorCode
+		want ErrorCode
+	}{
+		{xdr3.ErrBadArguments, ErrBadArguments},
+		{xdr3.ErrUnsupportedType, ErrUnsupportedType},
+		{xdr3.ErrBadEnumValue, ErrBadEnumValue},
+		{xdr3.ErrNotSettable, ErrNotSettable},
+		{xdr3.ErrOverflow, ErrOverflow},
+		{xdr3.ErrNilInterface, ErrNilInterface},
+		{xdr3.ErrIO, ErrUnexpectedEnd},
+		{xdr3.ErrParseTime, ErrBadArguments},
+		{xdr3.ErrBadDiscriminant, ErrBadEnumValue},
+		{xdr3.ErrMaxTotalBytes, ErrOverflow},
+		{xdr3.ErrMaxElements, ErrOverflow},
+		{xdr3.ErrMaxDepth, ErrOverflow},
+		{xdr3.ErrMaxAllocBytes, ErrOverflow},
+		{xdr3.ErrNonzeroPadding, ErrUnexpectedEnd},
+		{xdr3.ErrTrailingBytes, ErrUnexpectedEnd},
+		{xdr3.ErrNonCanonicalNaN, ErrUnexpectedEnd},
+		{xdr3.ErrDuplicateKey, ErrUnexpectedEnd},
+		{xdr3.ErrCycle, ErrCycle},
+	}
+
+	for i, test := range tests {
+		if _, ok := errorCodes[test.in]; !ok {
+			t.Errorf("errorCodes #%d (%v) is not mapped", i, test.in)
+			continue
+		}
+		if got := errorCode(test.in); got != test.want {
+			t.Errorf("errorCode #%d (%v) got: %v want: %v", i,
+				test.in, got, test.want)
+		}
+	}
+
+	// Ensure the codes above are all of the codes of the xdr3 package so
+	// new codes must be mapped.
+	next := xdr3.ErrorCode(len(tests))
+	if !strings.HasPrefix(next.String(), "Unknown") {
+		t.Errorf("errorCodes does not map %v", next)
+	}
+	if len(errorCodes) != len(tests) {
+		t.Errorf("errorCodes has %d codes want: %d", len(errorCodes),
+			len(tests))
+	}
+}
+
+// TestOversizedLength ensures lengths of opaque data, strings, and arrays which
+// exceed the max length of a slice result in ErrOverflow.
+func TestOversizedLength(t *testing.T) {
+	var opaque []byte
+	var str string
+	var array []uint32
+	tests := []struct {
+		name   string
+		decode func(data []byte) error
+	}{
+		{"DecodeOpaque", func(data []byte) error {
+			_, err := NewDecoder(data).DecodeOpaque()
+			return err
+		}},
+		{"DecodeString", func(data []byte) error {
+			_, err := NewDecoder(data).DecodeString()
+			return err
+		}},
+		{"Unmarshal []byte", func(data []byte) error {
+			_, err := Unmarshal(data, &opaque)
+			return err
+		}},
+		{"Unmarshal string", func(data []byte) error {
+			_, err := Unmarshal(data, &str)
+			return err
+		}},
+		{"Unmarshal []uint32", func(data []byte) error {
+			_, err := Unmarshal(data, &array)
+			return err
+		}},
+	}
+
+	lengths := [][]byte{
+		{0x80, 0x00, 0x00, 0x00},
+		{0xff, 0xff, 0xff, 0xff},
+	}
+	for _, test := range tests {
+		for _, data := range lengths {
+			err := test.decode(data)
+			var uerr *UnmarshalError
+			if !errors.As(err, &uerr) || uerr.ErrorCode != ErrOverflow {
+				t.Errorf("%s (%x) error got: %v want: %v",
+					test.name, data, err, ErrOverflow)
+			}
+		}
+	}
+}
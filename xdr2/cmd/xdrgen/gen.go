@@ -0,0 +1,1255 @@
+/*
+ * Copyright (c) 2014 Dave Collins <dave@davec.name>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/davecgh/go-xdr/xdr2/xdrlang"
+)
+
+// defaultImportPath is the import path of the xdr package the generated code
+// is built on.
+const defaultImportPath = "github.com/davecgh/go-xdr/xdr2"
+
+// unboundedPrealloc is the maximum number of elements preallocated for
+// variable-length arrays without a declared maximum size when decoding so a
+// bogus length can't cause a huge allocation before any elements are read.
+const unboundedPrealloc = 256
+
+// Map of built-in XDR types to their Go types.
+var basicGoTypes = map[xdrlang.BasicKind]string{
+	xdrlang.Int:           "int32",
+	xdrlang.UnsignedInt:   "uint32",
+	xdrlang.Hyper:         "int64",
+	xdrlang.UnsignedHyper: "uint64",
+	xdrlang.Float:         "float32",
+	xdrlang.Double:        "float64",
+	xdrlang.Quadruple:     "xdr.Quadruple",
+	xdrlang.Bool:          "bool",
+}
+
+// Map of built-in XDR types to the suffix of the Encoder and Decoder methods
+// which handle them.
+var basicMethods = map[xdrlang.BasicKind]string{
+	xdrlang.Int:           "Int",
+	xdrlang.UnsignedInt:   "Uint",
+	xdrlang.Hyper:         "Hyper",
+	xdrlang.UnsignedHyper: "Uhyper",
+	xdrlang.Float:         "Float",
+	xdrlang.Double:        "Double",
+	xdrlang.Quadruple:     "Quadruple",
+	xdrlang.Bool:          "Bool",
+}
+
+// errorAt returns an *xdrlang.Error at the passed position so errors found
+// while generating code are reported the same way as syntax errors.
+func errorAt(pos xdrlang.Pos, format string, args ...interface{}) error {
+	return &xdrlang.Error{Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+// goName converts an XDR identifier to an exported Go identifier by removing
+// underscores and capitalizing the first letter of each word they separate.
+// For example, "nfs_fh3" becomes "NfsFh3".
+func goName(name string) string {
+	var parts []string
+	for _, part := range strings.Split(name, "_") {
+		if part == "" {
+			continue
+		}
+		parts = append(parts, strings.ToUpper(part[:1])+part[1:])
+	}
+	return strings.Join(parts, "")
+}
+
+// generator produces Go source code from the syntax tree of a specification.
+type generator struct {
+	order   []xdrlang.Definition        // Definitions in output order
+	types   map[string]*xdrlang.TypeDef // Type definitions by XDR name
+	consts  map[string]xdrlang.Value    // Constants by XDR name
+	goNames map[string]xdrlang.Pos      // Declared Go identifiers
+
+	buf     bytes.Buffer
+	usesFmt bool
+	usesXDR bool
+	fn      string // Name of the method being generated for errors
+	tmp     int    // Counter for unique temporary variable names
+}
+
+// generate returns the gofmt formatted Go source code for the passed
+// specification.
+func generate(spec *xdrlang.Specification, source, pkg, importPath string) ([]byte, error) {
+	g := generator{
+		types:   make(map[string]*xdrlang.TypeDef),
+		consts:  make(map[string]xdrlang.Value),
+		goNames: make(map[string]xdrlang.Pos),
+	}
+	for _, def := range spec.Definitions {
+		if err := g.collect(def); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, def := range g.order {
+		var err error
+		switch d := def.(type) {
+		case *xdrlang.ConstDef:
+			err = g.genConst(d)
+		case *xdrlang.TypeDef:
+			err = g.genType(d)
+		case *xdrlang.ProgramDef:
+			err = g.genProgram(d)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by xdrgen from %s. DO NOT EDIT.\n\n",
+		source)
+	fmt.Fprintf(&out, "package %s\n\n", pkg)
+	if g.usesFmt || g.usesXDR {
+		out.WriteString("import (\n")
+		if g.usesFmt {
+			out.WriteString("\"fmt\"\n\n")
+		}
+		if g.usesXDR {
+			fmt.Fprintf(&out, "xdr %q\n", importPath)
+		}
+		out.WriteString(")\n\n")
+	}
+	out.Write(g.buf.Bytes())
+
+	src, err := format.Source(out.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("formatting generated code: %v", err)
+	}
+	return src, nil
+}
+
+// printf writes formatted output to the body of the generated code.
+func (g *generator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+// declare registers the Go identifier for the passed XDR identifier and
+// returns it.  An error is returned when it collides with the Go identifier of
+// another definition.
+func (g *generator) declare(name string, pos xdrlang.Pos) (string, error) {
+	gn := goName(name)
+	if gn == "" {
+		return "", errorAt(pos, "'%s' can't be converted to a Go "+
+			"identifier", name)
+	}
+	if prev, ok := g.goNames[gn]; ok {
+		return "", errorAt(pos, "'%s' maps to the Go identifier %s "+
+			"which is already declared at %s", name, gn, prev)
+	}
+	g.goNames[gn] = pos
+	return gn, nil
+}
+
+// collect registers the names declared by the passed definition and adds it
+// to the output order.
+func (g *generator) collect(def xdrlang.Definition) error {
+	switch d := def.(type) {
+	case *xdrlang.ConstDef:
+		if _, err := g.declare(d.Name, d.Pos); err != nil {
+			return err
+		}
+		g.consts[d.Name] = d.Value
+		g.order = append(g.order, d)
+
+	case *xdrlang.TypeDef:
+		return g.collectType(d)
+
+	case *xdrlang.ProgramDef:
+		if _, err := g.declare(d.Name, d.Pos); err != nil {
+			return err
+		}
+		for _, vers := range d.Versions {
+			if _, err := g.declare(vers.Name, vers.Pos); err != nil {
+				return err
+			}
+			for _, proc := range vers.Procedures {
+				_, err := g.declare(proc.Name, proc.Pos)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		g.order = append(g.order, d)
+	}
+	return nil
+}
+
+// collectType registers the passed type definition along with any enum
+// members it declares.  Enums, structs, and unions which are declared inline
+// within it are hoisted into type definitions of their own since Go methods
+// can only be defined on named types.
+func (g *generator) collectType(d *xdrlang.TypeDef) error {
+	name := d.Name()
+	if _, ok := g.types[name]; ok {
+		return errorAt(d.Pos, "type '%s' redeclared", name)
+	}
+	if _, err := g.declare(name, d.Decl.Pos); err != nil {
+		return err
+	}
+	g.types[name] = d
+	g.order = append(g.order, d)
+
+	decl := d.Decl
+	if decl.Kind != xdrlang.DeclScalar {
+		return g.hoist(name+"_elem", decl)
+	}
+	switch t := decl.Type.(type) {
+	case *xdrlang.EnumType:
+		for _, m := range t.Members {
+			if _, err := g.declare(m.Name, m.Pos); err != nil {
+				return err
+			}
+			g.consts[m.Name] = m.Value
+		}
+
+	case *xdrlang.StructType:
+		for _, f := range t.Fields {
+			if err := g.hoist(name+"_"+f.Name, f); err != nil {
+				return err
+			}
+		}
+
+	case *xdrlang.UnionType:
+		decls := []*xdrlang.Decl{t.Discriminant}
+		for _, c := range t.Cases {
+			decls = append(decls, c.Decl)
+		}
+		if t.Default != nil {
+			decls = append(decls, t.Default)
+		}
+		for _, f := range decls {
+			if err := g.hoist(name+"_"+f.Name, f); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// hoist replaces an inline enum, struct, or union type of the passed
+// declaration with a reference to a new type definition of the passed name.
+func (g *generator) hoist(name string, decl *xdrlang.Decl) error {
+	switch decl.Type.(type) {
+	case *xdrlang.EnumType, *xdrlang.StructType, *xdrlang.UnionType:
+	default:
+		return nil
+	}
+
+	pos := decl.Type.Position()
+	def := &xdrlang.TypeDef{Pos: pos, Decl: &xdrlang.Decl{Pos: pos,
+		Kind: xdrlang.DeclScalar, Name: name, Type: decl.Type}}
+	decl.Type = &xdrlang.NamedType{Pos: pos, Name: name}
+	return g.collectType(def)
+}
+
+// resolve returns the integer value of the passed value by following any
+// references to named constants.
+func (g *generator) resolve(v xdrlang.Value) (int64, error) {
+	orig := v
+	for depth := 0; v.Name != ""; depth++ {
+		switch v.Name {
+		case "TRUE":
+			return 1, nil
+		case "FALSE":
+			return 0, nil
+		}
+		next, ok := g.consts[v.Name]
+		if !ok {
+			return 0, errorAt(orig.Pos, "undefined constant '%s'",
+				v.Name)
+		}
+		if depth > len(g.consts) {
+			return 0, errorAt(orig.Pos, "constant '%s' is defined "+
+				"in terms of itself", orig.Name)
+		}
+		v = next
+	}
+	return v.Int, nil
+}
+
+// valueExpr returns the Go expression for the passed value.  References to
+// named constants are kept by name.
+func (g *generator) valueExpr(v xdrlang.Value) (string, error) {
+	i, err := g.resolve(v)
+	if err != nil {
+		return "", err
+	}
+	if v.Name == "" || v.Name == "TRUE" || v.Name == "FALSE" {
+		return strconv.FormatInt(i, 10), nil
+	}
+	return goName(v.Name), nil
+}
+
+// size returns the resolved size of an array declaration which must fit into
+// a signed 32-bit integer since that is the limit of the Decoder.
+func (g *generator) size(decl *xdrlang.Decl) (int64, error) {
+	size, err := g.resolve(*decl.Size)
+	if err != nil {
+		return 0, err
+	}
+	if size < 0 || size > math.MaxInt32 {
+		return 0, errorAt(decl.Size.Pos, "size %d of '%s' is out of "+
+			"range", size, decl.Name)
+	}
+	return size, nil
+}
+
+// lookupType returns the type definition of the passed named type.
+func (g *generator) lookupType(t *xdrlang.NamedType) (*xdrlang.TypeDef, error) {
+	def, ok := g.types[t.Name]
+	if !ok {
+		return nil, errorAt(t.Pos, "undefined type '%s'", t.Name)
+	}
+	return def, nil
+}
+
+// isPointer returns whether or not the passed type definition results in a
+// named Go pointer type.  Go does not allow methods on such types, so values
+// of them are encoded and decoded inline instead.
+func (g *generator) isPointer(def *xdrlang.TypeDef) bool {
+	for depth := 0; depth <= len(g.types); depth++ {
+		decl := def.Decl
+		if decl.Kind == xdrlang.DeclOptional {
+			return true
+		}
+		named, ok := decl.Type.(*xdrlang.NamedType)
+		if decl.Kind != xdrlang.DeclScalar || !ok {
+			return false
+		}
+		if def, ok = g.types[named.Name]; !ok {
+			return false
+		}
+	}
+	return false
+}
+
+// typeName returns the Go type for the passed type specifier.
+func (g *generator) typeName(t xdrlang.Type) (string, error) {
+	switch t := t.(type) {
+	case *xdrlang.BasicType:
+		if s, ok := basicGoTypes[t.Kind]; ok {
+			return s, nil
+		}
+		return "", errorAt(t.Pos, "%s is not supported", t.Kind)
+
+	case *xdrlang.NamedType:
+		if _, err := g.lookupType(t); err != nil {
+			return "", err
+		}
+		return goName(t.Name), nil
+	}
+	return "", errorAt(t.Position(), "unexpected inline type")
+}
+
+// goType returns the Go type for the value described by the passed
+// declaration.
+func (g *generator) goType(decl *xdrlang.Decl) (string, error) {
+	if basic, ok := decl.Type.(*xdrlang.BasicType); ok {
+		switch {
+		case basic.Kind == xdrlang.String:
+			return "string", nil
+		case basic.Kind == xdrlang.Opaque &&
+			decl.Kind == xdrlang.DeclVarArray:
+			return "[]byte", nil
+		case basic.Kind == xdrlang.Opaque:
+			size, err := g.valueExpr(*decl.Size)
+			if err != nil {
+				return "", err
+			}
+			return "[" + size + "]byte", nil
+		}
+	}
+
+	elem, err := g.typeName(decl.Type)
+	if err != nil {
+		return "", err
+	}
+	switch decl.Kind {
+	case xdrlang.DeclOptional:
+		return "*" + elem, nil
+	case xdrlang.DeclFixedArray:
+		size, err := g.valueExpr(*decl.Size)
+		if err != nil {
+			return "", err
+		}
+		return "[" + size + "]" + elem, nil
+	case xdrlang.DeclVarArray:
+		return "[]" + elem, nil
+	}
+	return elem, nil
+}
+
+// isOpaque returns whether or not the passed declaration is of opaque data or
+// a string which are handled as a whole rather than per element.
+func isOpaque(decl *xdrlang.Decl) bool {
+	basic, ok := decl.Type.(*xdrlang.BasicType)
+	return ok && (basic.Kind == xdrlang.Opaque || basic.Kind == xdrlang.String)
+}
+
+// unparen removes the parentheses around a dereference such as (*v) for use
+// in contexts where they are not needed.
+func unparen(x string) string {
+	if strings.HasPrefix(x, "(*") && strings.HasSuffix(x, ")") &&
+		strings.Count(x, "(") == 1 {
+
+		return x[1 : len(x)-1]
+	}
+	return x
+}
+
+// newTemp returns a unique name for a temporary variable.
+func (g *generator) newTemp(prefix string) string {
+	g.tmp++
+	return fmt.Sprintf("%s%d", prefix, g.tmp)
+}
+
+// beginMethod writes the start of an EncodeTo or DecodeFrom method for the
+// passed Go type.
+func (g *generator) beginMethod(typeName string, encode bool) {
+	g.usesXDR = true
+	g.tmp = 0
+	if encode {
+		g.fn = typeName + ".EncodeTo"
+		g.printf("// EncodeTo writes the XDR encoded representation of v " +
+			"to enc and returns\n// the number of bytes written.\n")
+		g.printf("func (v *%s) EncodeTo(enc *xdr.Encoder) (int, "+
+			"error) {\n", typeName)
+		return
+	}
+	g.fn = typeName + ".DecodeFrom"
+	g.printf("// DecodeFrom reads the XDR encoded representation of v " +
+		"from dec and returns\n// the number of bytes read.\n")
+	g.printf("func (v *%s) DecodeFrom(dec *xdr.Decoder) (int, error) "+
+		"{\n", typeName)
+}
+
+// genXDRMethods writes the EncodeXDR and DecodeXDR methods which implement the
+// xdr.Marshaler and xdr.Unmarshaler interfaces by calling the generated
+// EncodeTo and DecodeFrom methods.  This allows the reflection based Marshal
+// and Unmarshal functions to use the generated code for values of the type,
+// including when they are nested within other types.
+func (g *generator) genXDRMethods(typeName string) {
+	g.printf("// EncodeXDR writes the XDR encoded representation of v to " +
+		"enc and returns\n// the number of bytes written.  It is part " +
+		"of the xdr.Marshaler interface\n// implementation.\n")
+	g.printf("func (v %s) EncodeXDR(enc *xdr.Encoder) (int, error) {\n",
+		typeName)
+	g.printf("return v.EncodeTo(enc)\n}\n\n")
+
+	g.printf("// DecodeXDR reads the XDR encoded representation of v " +
+		"from dec and returns\n// the number of bytes read.  It is part " +
+		"of the xdr.Unmarshaler interface\n// implementation.\n")
+	g.printf("func (v *%s) DecodeXDR(dec *xdr.Decoder) (int, error) {\n",
+		typeName)
+	g.printf("return v.DecodeFrom(dec)\n}\n\n")
+}
+
+// printCheck writes the statements which accumulate the number of bytes and
+// return on error after a call to an Encoder or Decoder method.
+func (g *generator) printCheck() {
+	g.printf("n += nn\nif err != nil {\nreturn n, err\n}\n")
+}
+
+// printError writes a statement which returns a MarshalError or
+// UnmarshalError.
+func (g *generator) printError(encode bool, code, desc, value string) {
+	kind := "UnmarshalError"
+	if encode {
+		kind = "MarshalError"
+	}
+	g.printf("return n, &xdr.%s{\nErrorCode: xdr.%s,\nFunc: %q,\n"+
+		"Description: %q,\nValue: %s,\n}\n", kind, code, g.fn, desc,
+		value)
+}
+
+// encodeDecl writes the statements which encode the passed Go expression of
+// the passed Go type as described by the declaration.
+func (g *generator) encodeDecl(x, typ string, decl *xdrlang.Decl) error {
+	switch decl.Kind {
+	case xdrlang.DeclVoid:
+		return nil
+
+	case xdrlang.DeclOptional:
+		elem, err := g.typeName(decl.Type)
+		if err != nil {
+			return err
+		}
+		g.printf("{\nnn, err := enc.EncodeBool(%s != nil)\n", x)
+		g.printCheck()
+		g.printf("}\nif %s != nil {\n", x)
+		if err := g.encodeScalar("(*"+x+")", elem, decl.Type); err != nil {
+			return err
+		}
+		g.printf("}\n")
+		return nil
+
+	case xdrlang.DeclFixedArray:
+		if isOpaque(decl) {
+			g.printf("{\nnn, err := enc.EncodeFixedOpaque(%s[:])\n", x)
+			g.printCheck()
+			g.printf("}\n")
+			return nil
+		}
+		elem, err := g.typeName(decl.Type)
+		if err != nil {
+			return err
+		}
+		i := g.newTemp("i")
+		g.printf("for %s := range %s {\n", i, x)
+		err = g.encodeScalar(x+"["+i+"]", elem, decl.Type)
+		if err != nil {
+			return err
+		}
+		g.printf("}\n")
+		return nil
+
+	case xdrlang.DeclVarArray:
+		if decl.Size != nil {
+			size, err := g.size(decl)
+			if err != nil {
+				return err
+			}
+			g.printf("if len(%s) > %d {\n", unparen(x), size)
+			desc := fmt.Sprintf("'%s' exceeds the maximum length "+
+				"of %d", decl.Name, size)
+			g.printError(true, "ErrOverflow", desc,
+				"len("+unparen(x)+")")
+			g.printf("}\n")
+		}
+		if basic, ok := decl.Type.(*xdrlang.BasicType); ok {
+			switch basic.Kind {
+			case xdrlang.Opaque:
+				g.printf("{\nnn, err := enc.EncodeOpaque(%s)\n",
+					unparen(x))
+				g.printCheck()
+				g.printf("}\n")
+				return nil
+			case xdrlang.String:
+				arg := unparen(x)
+				if typ != "string" {
+					arg = "string(" + arg + ")"
+				}
+				g.printf("{\nnn, err := enc.EncodeString(%s)\n",
+					arg)
+				g.printCheck()
+				g.printf("}\n")
+				return nil
+			}
+		}
+		elem, err := g.typeName(decl.Type)
+		if err != nil {
+			return err
+		}
+		g.printf("{\nnn, err := enc.EncodeUint(uint32(len(%s)))\n",
+			unparen(x))
+		g.printCheck()
+		g.printf("}\n")
+		i := g.newTemp("i")
+		g.printf("for %s := range %s {\n", i, x)
+		err = g.encodeScalar(x+"["+i+"]", elem, decl.Type)
+		if err != nil {
+			return err
+		}
+		g.printf("}\n")
+		return nil
+	}
+
+	return g.encodeScalar(x, typ, decl.Type)
+}
+
+// encodeScalar writes the statements which encode the passed Go expression of
+// the passed Go type as a single value of the type specifier.
+func (g *generator) encodeScalar(x, typ string, t xdrlang.Type) error {
+	switch t := t.(type) {
+	case *xdrlang.BasicType:
+		method, ok := basicMethods[t.Kind]
+		if !ok {
+			return errorAt(t.Pos, "%s is not supported", t.Kind)
+		}
+		x = unparen(x)
+		if goTyp := basicGoTypes[t.Kind]; typ != goTyp {
+			x = goTyp + "(" + x + ")"
+		}
+		g.printf("{\nnn, err := enc.Encode%s(%s)\n", method, x)
+		g.printCheck()
+		g.printf("}\n")
+		return nil
+
+	case *xdrlang.NamedType:
+		def, err := g.lookupType(t)
+		if err != nil {
+			return err
+		}
+		if g.isPointer(def) {
+			return g.encodeDecl(x, typ, def.Decl)
+		}
+		name := goName(t.Name)
+		if typ != name {
+			x = "(*" + name + ")(&" + x + ")"
+		}
+		g.printf("{\nnn, err := %s.EncodeTo(enc)\n", x)
+		g.printCheck()
+		g.printf("}\n")
+		return nil
+	}
+	return errorAt(t.Position(), "unexpected inline type")
+}
+
+// decodeDecl writes the statements which decode into the passed Go expression
+// of the passed Go type as described by the declaration.
+func (g *generator) decodeDecl(x, typ string, decl *xdrlang.Decl) error {
+	switch decl.Kind {
+	case xdrlang.DeclVoid:
+		return nil
+
+	case xdrlang.DeclOptional:
+		elem, err := g.typeName(decl.Type)
+		if err != nil {
+			return err
+		}
+		g.printf("{\npresent, nn, err := dec.DecodeBool()\n")
+		g.printCheck()
+		g.printf("if !present {\n%s = nil\n} else {\n", unparen(x))
+		g.printf("%s = new(%s)\n", unparen(x), elem)
+		if err := g.decodeScalar("(*"+x+")", elem, decl.Type); err != nil {
+			return err
+		}
+		g.printf("}\n}\n")
+		return nil
+
+	case xdrlang.DeclFixedArray:
+		if isOpaque(decl) {
+			size, err := g.size(decl)
+			if err != nil {
+				return err
+			}
+			g.printf("{\nb, nn, err := dec.DecodeFixedOpaque(%d)\n",
+				size)
+			g.printCheck()
+			g.printf("copy(%s[:], b)\n}\n", x)
+			return nil
+		}
+		elem, err := g.typeName(decl.Type)
+		if err != nil {
+			return err
+		}
+		i := g.newTemp("i")
+		g.printf("for %s := range %s {\n", i, x)
+		err = g.decodeScalar(x+"["+i+"]", elem, decl.Type)
+		if err != nil {
+			return err
+		}
+		g.printf("}\n")
+		return nil
+
+	case xdrlang.DeclVarArray:
+		return g.decodeVarArray(x, typ, decl)
+	}
+
+	return g.decodeScalar(x, typ, decl.Type)
+}
+
+// decodeVarArray writes the statements which decode a variable-length array,
+// variable-length opaque data, or a string into the passed Go expression of
+// the passed Go type while enforcing the declared maximum size.
+func (g *generator) decodeVarArray(x, typ string, decl *xdrlang.Decl) error {
+	var size int64 = -1
+	if decl.Size != nil {
+		var err error
+		if size, err = g.size(decl); err != nil {
+			return err
+		}
+	}
+
+	// Variable-length opaque data and strings without a maximum size are
+	// handled by the Decoder directly.
+	opaque := isOpaque(decl)
+	if opaque && size < 0 {
+		method, val := "DecodeOpaque", "b"
+		if typ != "[]byte" {
+			val = typ + "(b)"
+		}
+		if decl.Type.(*xdrlang.BasicType).Kind == xdrlang.String {
+			method, val = "DecodeString", "b"
+			if typ != "string" {
+				val = typ + "(b)"
+			}
+		}
+		g.printf("{\nb, nn, err := dec.%s()\n", method)
+		g.printCheck()
+		g.printf("%s = %s\n}\n", unparen(x), val)
+		return nil
+	}
+
+	// Read the length and enforce the maximum before allocating.
+	g.printf("{\nl, nn, err := dec.DecodeUint()\n")
+	g.printCheck()
+	if size >= 0 {
+		g.printf("if l > %d {\n", size)
+		desc := fmt.Sprintf("'%s' exceeds the maximum length of %d",
+			decl.Name, size)
+		g.printError(false, "ErrOverflow", desc, "l")
+		g.printf("}\n")
+	}
+	if opaque {
+		g.printf("b, nn, err := dec.DecodeFixedOpaque(int32(l))\n")
+		g.printCheck()
+		g.printf("%s = %s(b)\n}\n", unparen(x), typ)
+		return nil
+	}
+
+	elem, err := g.typeName(decl.Type)
+	if err != nil {
+		return err
+	}
+	if size < 0 {
+		g.printf("c := l\nif c > %d {\nc = %d\n}\n", unboundedPrealloc,
+			unboundedPrealloc)
+		g.printf("%s = make(%s, 0, c)\n", unparen(x), typ)
+	} else {
+		g.printf("%s = make(%s, 0, l)\n", unparen(x), typ)
+	}
+	i := g.newTemp("i")
+	e := g.newTemp("e")
+	g.printf("for %s := uint32(0); %s < l; %s++ {\nvar %s %s\n", i, i, i,
+		e, elem)
+	if err := g.decodeScalar(e, elem, decl.Type); err != nil {
+		return err
+	}
+	g.printf("%s = append(%s, %s)\n}\n}\n", unparen(x), unparen(x), e)
+	return nil
+}
+
+// decodeScalar writes the statements which decode a single value of the type
+// specifier into the passed Go expression of the passed Go type.
+func (g *generator) decodeScalar(x, typ string, t xdrlang.Type) error {
+	switch t := t.(type) {
+	case *xdrlang.BasicType:
+		method, ok := basicMethods[t.Kind]
+		if !ok {
+			return errorAt(t.Pos, "%s is not supported", t.Kind)
+		}
+		val := "val"
+		if goTyp := basicGoTypes[t.Kind]; typ != goTyp {
+			val = typ + "(val)"
+		}
+		g.printf("{\nval, nn, err := dec.Decode%s()\n", method)
+		g.printCheck()
+		g.printf("%s = %s\n}\n", unparen(x), val)
+		return nil
+
+	case *xdrlang.NamedType:
+		def, err := g.lookupType(t)
+		if err != nil {
+			return err
+		}
+		if g.isPointer(def) {
+			return g.decodeDecl(x, typ, def.Decl)
+		}
+		name := goName(t.Name)
+		if typ != name {
+			x = "(*" + name + ")(&" + x + ")"
+		}
+		g.printf("{\nnn, err := %s.DecodeFrom(dec)\n", x)
+		g.printCheck()
+		g.printf("}\n")
+		return nil
+	}
+	return errorAt(t.Position(), "unexpected inline type")
+}
+
+// genConst writes the Go constant for the passed constant definition.
+func (g *generator) genConst(d *xdrlang.ConstDef) error {
+	val, err := g.valueExpr(d.Value)
+	if err != nil {
+		return err
+	}
+	name := goName(d.Name)
+	g.printf("// %s is the XDR constant %s.\nconst %s = %s\n\n", name,
+		d.Name, name, val)
+	return nil
+}
+
+// genType writes the Go type and methods for the passed type definition.
+func (g *generator) genType(d *xdrlang.TypeDef) error {
+	name := goName(d.Name())
+	decl := d.Decl
+	if decl.Kind == xdrlang.DeclScalar {
+		switch t := decl.Type.(type) {
+		case *xdrlang.EnumType:
+			return g.genEnum(name, d.Name(), t)
+		case *xdrlang.StructType:
+			return g.genStruct(name, d.Name(), t)
+		case *xdrlang.UnionType:
+			return g.genUnion(name, d.Name(), t)
+		}
+	}
+
+	typ, err := g.goType(decl)
+	if err != nil {
+		return err
+	}
+	g.printf("// %s is the XDR type %s.\ntype %s %s\n\n", name, d.Name(),
+		name, typ)
+	if g.isPointer(d) {
+		return nil
+	}
+
+	g.beginMethod(name, true)
+	g.printf("n := 0\n")
+	if err := g.encodeDecl("(*v)", name, decl); err != nil {
+		return err
+	}
+	g.printf("return n, nil\n}\n\n")
+
+	g.beginMethod(name, false)
+	g.printf("n := 0\n")
+	if err := g.decodeDecl("(*v)", name, decl); err != nil {
+		return err
+	}
+	g.printf("return n, nil\n}\n\n")
+	g.genXDRMethods(name)
+	return nil
+}
+
+// genEnum writes the Go type, constants, and methods for an enum.
+func (g *generator) genEnum(name, xdrName string, t *xdrlang.EnumType) error {
+	g.usesFmt = true
+	g.printf("// %s is the XDR enum %s.\ntype %s int32\n\n", name, xdrName,
+		name)
+
+	var names, valid bytes.Buffer
+	seen := make(map[int64]bool)
+	g.printf("const (\n")
+	for _, m := range t.Members {
+		val, err := g.resolve(m.Value)
+		if err != nil {
+			return err
+		}
+		if val < math.MinInt32 || val > math.MaxInt32 {
+			return errorAt(m.Value.Pos, "value %d of '%s' is out of "+
+				"range", val, m.Name)
+		}
+		expr, err := g.valueExpr(m.Value)
+		if err != nil {
+			return err
+		}
+		g.printf("%s %s = %s\n", goName(m.Name), name, expr)
+
+		// Enums may have several names for the same value, so only the
+		// first is used as its string.
+		if seen[val] {
+			continue
+		}
+		seen[val] = true
+		fmt.Fprintf(&names, "%s: %q,\n", goName(m.Name), m.Name)
+		fmt.Fprintf(&valid, "%d: true,\n", val)
+	}
+	g.printf(")\n\n")
+
+	g.printf("var xdrNames%s = map[%s]string{\n%s}\n\n", name, name,
+		names.String())
+	g.printf("var xdrValid%s = map[int32]bool{\n%s}\n\n", name,
+		valid.String())
+
+	g.printf("// String returns the name of the %s value.\n", name)
+	g.printf("func (v %s) String() string {\n", name)
+	g.printf("if s, ok := xdrNames%s[v]; ok {\nreturn s\n}\n", name)
+	g.printf("return fmt.Sprintf(\"%s(%%d)\", int32(v))\n}\n\n", name)
+
+	g.beginMethod(name, true)
+	g.printf("return enc.EncodeEnum(int32(*v), xdrValid%s)\n}\n\n", name)
+
+	g.beginMethod(name, false)
+	g.printf("val, n, err := dec.DecodeEnum(xdrValid%s)\n", name)
+	g.printf("if err != nil {\nreturn n, err\n}\n")
+	g.printf("*v = %s(val)\nreturn n, nil\n}\n\n", name)
+	g.genXDRMethods(name)
+	return nil
+}
+
+// field is a field of a generated struct.
+type field struct {
+	name string
+	typ  string
+	decl *xdrlang.Decl
+	opts []string // Options of the xdr struct tag
+}
+
+// printField writes the declaration of the passed field within a struct along
+// with an xdr struct tag for its options, if any.
+func (g *generator) printField(f field) {
+	if len(f.opts) == 0 {
+		g.printf("%s %s\n", f.name, f.typ)
+		return
+	}
+	g.printf("%s %s `xdr:\"%s\"`\n", f.name, f.typ,
+		strings.Join(f.opts, ","))
+}
+
+// fieldOptions returns the xdr struct tag options which make the reflection
+// based encoding of the passed declaration match the generated methods.
+func (g *generator) fieldOptions(decl *xdrlang.Decl) ([]string, error) {
+	switch decl.Kind {
+	case xdrlang.DeclOptional:
+		return []string{"optional"}, nil
+
+	case xdrlang.DeclVarArray:
+		if decl.Size == nil {
+			return nil, nil
+		}
+		size, err := g.size(decl)
+		if err != nil {
+			return nil, err
+		}
+		return []string{"max=" + strconv.FormatInt(size, 10)}, nil
+
+	case xdrlang.DeclScalar:
+		// Named pointer types are optional-data.
+		named, ok := decl.Type.(*xdrlang.NamedType)
+		if !ok {
+			return nil, nil
+		}
+		def, err := g.lookupType(named)
+		if err != nil {
+			return nil, err
+		}
+		if g.isPointer(def) {
+			return []string{"optional"}, nil
+		}
+	}
+	return nil, nil
+}
+
+// structFields returns the fields of a generated struct for the passed
+// declarations skipping void ones.
+func (g *generator) structFields(decls []*xdrlang.Decl) ([]field, error) {
+	var fields []field
+	seen := make(map[string]bool)
+	for _, decl := range decls {
+		if decl.Kind == xdrlang.DeclVoid {
+			continue
+		}
+		name := goName(decl.Name)
+		if name == "" {
+			return nil, errorAt(decl.Pos, "'%s' can't be converted "+
+				"to a Go identifier", decl.Name)
+		}
+		if seen[name] {
+			return nil, errorAt(decl.Pos, "'%s' maps to the Go "+
+				"field %s which is already declared", decl.Name,
+				name)
+		}
+		seen[name] = true
+
+		typ, err := g.goType(decl)
+		if err != nil {
+			return nil, err
+		}
+		opts, err := g.fieldOptions(decl)
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, field{name, typ, decl, opts})
+	}
+	return fields, nil
+}
+
+// genStruct writes the Go type and methods for a struct.
+func (g *generator) genStruct(name, xdrName string, t *xdrlang.StructType) error {
+	fields, err := g.structFields(t.Fields)
+	if err != nil {
+		return err
+	}
+
+	g.printf("// %s is the XDR struct %s.\ntype %s struct {\n", name,
+		xdrName, name)
+	for _, f := range fields {
+		g.printField(f)
+	}
+	g.printf("}\n\n")
+
+	g.beginMethod(name, true)
+	g.printf("n := 0\n")
+	for _, f := range fields {
+		if err := g.encodeDecl("v."+f.name, f.typ, f.decl); err != nil {
+			return err
+		}
+	}
+	g.printf("return n, nil\n}\n\n")
+
+	g.beginMethod(name, false)
+	g.printf("n := 0\n")
+	for _, f := range fields {
+		if err := g.decodeDecl("v."+f.name, f.typ, f.decl); err != nil {
+			return err
+		}
+	}
+	g.printf("return n, nil\n}\n\n")
+	g.genXDRMethods(name)
+	return nil
+}
+
+// discriminantKind returns the kind of the integer type of the passed union
+// discriminant following any typedefs.  Enums are reported as Int.
+func (g *generator) discriminantKind(decl *xdrlang.Decl) (xdrlang.BasicKind, error) {
+	t := decl.Type
+	for depth := 0; depth <= len(g.types); depth++ {
+		switch tt := t.(type) {
+		case *xdrlang.BasicType:
+			switch tt.Kind {
+			case xdrlang.Int, xdrlang.UnsignedInt, xdrlang.Bool:
+				return tt.Kind, nil
+			}
+
+		case *xdrlang.NamedType:
+			def, err := g.lookupType(tt)
+			if err != nil {
+				return 0, err
+			}
+			if def.Decl.Kind != xdrlang.DeclScalar {
+				break
+			}
+			if _, ok := def.Decl.Type.(*xdrlang.EnumType); ok {
+				return xdrlang.Int, nil
+			}
+			t = def.Decl.Type
+			continue
+		}
+		break
+	}
+	return 0, errorAt(decl.Pos, "union discriminant '%s' must be int, "+
+		"unsigned int, bool, or an enum", decl.Name)
+}
+
+// unionCase is a case of a generated union.
+type unionCase struct {
+	exprs []string // Go expressions of the values, nil for default
+	arm   *field   // Field of the arm, nil for void
+}
+
+// genUnion writes the Go type and methods for a union.  The union is
+// represented by a struct which holds the discriminant followed by a field
+// for each non-void arm.
+func (g *generator) genUnion(name, xdrName string, t *xdrlang.UnionType) error {
+	kind, err := g.discriminantKind(t.Discriminant)
+	if err != nil {
+		return err
+	}
+
+	decls := []*xdrlang.Decl{t.Discriminant}
+	for _, c := range t.Cases {
+		decls = append(decls, c.Decl)
+	}
+	if t.Default != nil {
+		decls = append(decls, t.Default)
+	}
+	fields, err := g.structFields(decls)
+	if err != nil {
+		return err
+	}
+	disc := fields[0]
+	armOf := make(map[*xdrlang.Decl]*field)
+	for i := range fields[1:] {
+		armOf[fields[i+1].decl] = &fields[i+1]
+	}
+
+	// Determine the Go expression for each case value while rejecting
+	// duplicates and values that can't be represented by the discriminant.
+	// The values are also collected into the struct tag options of the arms
+	// so the reflection based encoding selects the same arm.  Void arms
+	// share a single empty struct field.
+	var cases []unionCase
+	var voidVals []string
+	seen := make(map[int64]bool)
+	for _, c := range t.Cases {
+		uc := unionCase{arm: armOf[c.Decl]}
+		var vals []string
+		for _, v := range c.Values {
+			val, err := g.resolve(v)
+			if err != nil {
+				return err
+			}
+			if seen[val] {
+				return errorAt(v.Pos, "duplicate case value %d",
+					val)
+			}
+			seen[val] = true
+			vals = append(vals, strconv.FormatInt(val, 10))
+
+			expr, err := g.valueExpr(v)
+			if err != nil {
+				return err
+			}
+			switch {
+			case kind == xdrlang.Bool && (val == 0 || val == 1):
+				expr = strconv.FormatBool(val == 1)
+			case kind == xdrlang.Bool,
+				kind == xdrlang.UnsignedInt &&
+					(val < 0 || val > math.MaxUint32),
+				kind == xdrlang.Int &&
+					(val < math.MinInt32 || val > math.MaxInt32):
+
+				return errorAt(v.Pos, "case value %d is out of "+
+					"range of discriminant '%s'", val,
+					t.Discriminant.Name)
+			}
+			uc.exprs = append(uc.exprs, expr)
+		}
+		cases = append(cases, uc)
+
+		if uc.arm == nil {
+			voidVals = append(voidVals, vals...)
+			continue
+		}
+		opt := "unioncase=" + strings.Join(vals, ",")
+		uc.arm.opts = append([]string{opt}, uc.arm.opts...)
+	}
+	var voidOpts []string
+	if voidVals != nil {
+		voidOpts = append(voidOpts, "unioncase="+strings.Join(voidVals, ","))
+	}
+	if t.Default != nil {
+		arm := armOf[t.Default]
+		cases = append(cases, unionCase{arm: arm})
+		if arm == nil {
+			voidOpts = append(voidOpts, "default")
+		} else {
+			arm.opts = append([]string{"default"}, arm.opts...)
+		}
+	}
+	fields[0].opts = append([]string{"union"}, fields[0].opts...)
+	if voidOpts != nil {
+		fields = append(fields, field{
+			name: voidFieldName(fields),
+			typ:  "struct{}",
+			opts: voidOpts,
+		})
+	}
+
+	g.printf("// %s is the XDR union %s.\n//\n// Only the field of "+
+		"the arm selected by the %s field is encoded and\n// "+
+		"decoded.\n", name, xdrName, disc.name)
+	g.printf("type %s struct {\n", name)
+	for _, f := range fields {
+		g.printField(f)
+	}
+	g.printf("}\n\n")
+
+	for _, encode := range []bool{true, false} {
+		g.beginMethod(name, encode)
+		g.printf("n := 0\n")
+		if !encode {
+			g.printf("*v = %s{}\n", name)
+		}
+		if err := g.codeDecl(encode, "v."+disc.name, disc); err != nil {
+			return err
+		}
+
+		g.printf("switch v.%s {\n", disc.name)
+		for _, c := range cases {
+			if c.exprs == nil {
+				g.printf("default:\n")
+			} else {
+				g.printf("case %s:\n", strings.Join(c.exprs, ", "))
+			}
+			if c.arm == nil {
+				continue
+			}
+			err := g.codeDecl(encode, "v."+c.arm.name, *c.arm)
+			if err != nil {
+				return err
+			}
+		}
+		if t.Default == nil {
+			g.printf("default:\n")
+			g.printError(encode, "ErrBadDiscriminant", "discriminant "+
+				"does not select a union arm", "v."+disc.name)
+		}
+		g.printf("}\nreturn n, nil\n}\n\n")
+	}
+	g.genXDRMethods(name)
+	return nil
+}
+
+// voidFieldName returns the name of the empty struct field which represents
+// the void arms of a union.  It is Void unless an arm already has that name in
+// which case a number is appended.
+func voidFieldName(fields []field) string {
+	taken := make(map[string]bool)
+	for _, f := range fields {
+		taken[f.name] = true
+	}
+	name := "Void"
+	for i := 2; taken[name]; i++ {
+		name = "Void" + strconv.Itoa(i)
+	}
+	return name
+}
+
+// codeDecl writes the statements which either encode or decode the passed
+// field.
+func (g *generator) codeDecl(encode bool, x string, f field) error {
+	if encode {
+		return g.encodeDecl(x, f.typ, f.decl)
+	}
+	return g.decodeDecl(x, f.typ, f.decl)
+}
+
+// genProgram writes the Go constants for the program, version, and procedure
+// numbers of the passed program definition.
+func (g *generator) genProgram(d *xdrlang.ProgramDef) error {
+	g.printf("// Program, version, and procedure numbers of the XDR "+
+		"program %s.\n", d.Name)
+	g.printf("const (\n")
+	val, err := g.valueExpr(d.Number)
+	if err != nil {
+		return err
+	}
+	g.printf("%s = %s\n", goName(d.Name), val)
+	for _, vers := range d.Versions {
+		val, err := g.valueExpr(vers.Number)
+		if err != nil {
+			return err
+		}
+		g.printf("%s = %s\n", goName(vers.Name), val)
+		for _, proc := range vers.Procedures {
+			val, err := g.valueExpr(proc.Number)
+			if err != nil {
+				return err
+			}
+			g.printf("%s = %s\n", goName(proc.Name), val)
+		}
+	}
+	g.printf(")\n\n")
+	return nil
+}
@@ -0,0 +1,156 @@
+/*
+ * Copyright (c) 2014 Dave Collins <dave@davec.name>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/davecgh/go-xdr/xdr2/xdrlang"
+)
+
+// TestGenerateUpToDate ensures the checked in generated code of the gentest
+// package matches the output of the generator so it can't silently drift.
+func TestGenerateUpToDate(t *testing.T) {
+	dir := filepath.Join("internal", "gentest")
+	src, err := ioutil.ReadFile(filepath.Join(dir, "gentest.x"))
+	if err != nil {
+		t.Fatalf("ReadFile unexpected error: %v", err)
+	}
+	want, err := ioutil.ReadFile(filepath.Join(dir, "gentest.go"))
+	if err != nil {
+		t.Fatalf("ReadFile unexpected error: %v", err)
+	}
+
+	spec, err := xdrlang.Parse(src)
+	if err != nil {
+		t.Fatalf("Parse unexpected error: %v", err)
+	}
+	got, err := generate(spec, "gentest.x", "gentest", defaultImportPath)
+	if err != nil {
+		t.Fatalf("generate unexpected error: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("generated code for gentest.x differs from gentest.go " +
+			"- run go generate in internal/gentest")
+	}
+}
+
+// TestGenerateErrors ensures specifications which can't be represented in Go
+// are rejected with an error which identifies the position of the issue.
+func TestGenerateErrors(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"typedef foo bar;", "xdrlang:1:9: undefined type 'foo'"},
+		{"typedef int x<MAX>;", "xdrlang:1:15: undefined constant 'MAX'"},
+		{"const A = B; const B = A; typedef int x<A>;",
+			"xdrlang:1:11: constant 'B' is defined in terms of itself"},
+		{"typedef int x<-1>;", "xdrlang:1:15: size -1 of 'x' is out of " +
+			"range"},
+		{"const a_b = 1; const A_b = 2;", "xdrlang:1:16: 'A_b' maps " +
+			"to the Go identifier AB which is already declared at 1:1"},
+		{"struct s { int a; }; struct s { int b; };",
+			"xdrlang:1:22: type 's' redeclared"},
+		{"struct s { int a_b; int A_b; };", "xdrlang:1:21: 'A_b' maps " +
+			"to the Go field AB which is already declared"},
+		{"enum e { A = 0x100000000 };", "xdrlang:1:14: value 4294967296 " +
+			"of 'A' is out of range"},
+		{"union u switch (hyper d) { case 0: void; };",
+			"xdrlang:1:17: union discriminant 'd' must be int, " +
+				"unsigned int, bool, or an enum"},
+		{"union u switch (int d) { case 0: void; case 0: void; };",
+			"xdrlang:1:45: duplicate case value 0"},
+		{"union u switch (unsigned d) { case -1: void; };",
+			"xdrlang:1:36: case value -1 is out of range of " +
+				"discriminant 'd'"},
+		{"union u switch (bool d) { case 2: void; };",
+			"xdrlang:1:32: case value 2 is out of range of " +
+				"discriminant 'd'"},
+	}
+
+	for i, test := range tests {
+		spec, err := xdrlang.Parse([]byte(test.in))
+		if err != nil {
+			t.Errorf("Parse #%d (%s) unexpected error: %v", i, test.in,
+				err)
+			continue
+		}
+		_, err = generate(spec, "test.x", "test", defaultImportPath)
+		if _, ok := err.(*xdrlang.Error); !ok {
+			t.Errorf("generate #%d (%s) got error %v (%T) want "+
+				"*xdrlang.Error", i, test.in, err, err)
+			continue
+		}
+		if err.Error() != test.want {
+			t.Errorf("generate #%d (%s)\n got: %s\nwant: %s", i,
+				test.in, err, test.want)
+			continue
+		}
+	}
+}
+
+// TestGoName ensures XDR identifiers are converted to the expected Go
+// identifiers.
+func TestGoName(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"entry", "Entry"},
+		{"nfs_fh3", "NfsFh3"},
+		{"MAXNAMELEN", "MAXNAMELEN"},
+		{"DIRPROC_NULL", "DIRPROCNULL"},
+		{"_x__y_", "XY"},
+		{"_", ""},
+	}
+
+	for i, test := range tests {
+		result := goName(test.in)
+		if result != test.want {
+			t.Errorf("goName #%d\n got: %s want: %s", i, result,
+				test.want)
+			continue
+		}
+	}
+}
+
+// TestPackageName ensures package names are derived from specification file
+// names as expected.
+func TestPackageName(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"dir.x", "dir"},
+		{"/a/b/NFS_prot.x", "nfsprot"},
+		{"9p.x", "xdr9p"},
+		{"_.x", "xdr"},
+	}
+
+	for i, test := range tests {
+		result := packageName(test.in)
+		if result != test.want {
+			t.Errorf("packageName #%d\n got: %s want: %s", i, result,
+				test.want)
+			continue
+		}
+	}
+}
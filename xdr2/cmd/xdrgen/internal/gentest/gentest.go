@@ -0,0 +1,1382 @@
+// Code generated by xdrgen from gentest.x. DO NOT EDIT.
+
+package gentest
+
+import (
+	"fmt"
+
+	xdr "github.com/davecgh/go-xdr/xdr2"
+)
+
+// MAXNAMELEN is the XDR constant MAXNAMELEN.
+const MAXNAMELEN = 16
+
+// MAXENTRIES is the XDR constant MAXENTRIES.
+const MAXENTRIES = 4
+
+// Filename is the XDR type filename.
+type Filename string
+
+// EncodeTo writes the XDR encoded representation of v to enc and returns
+// the number of bytes written.
+func (v *Filename) EncodeTo(enc *xdr.Encoder) (int, error) {
+	n := 0
+	if len(*v) > 16 {
+		return n, &xdr.MarshalError{
+			ErrorCode:   xdr.ErrOverflow,
+			Func:        "Filename.EncodeTo",
+			Description: "'filename' exceeds the maximum length of 16",
+			Value:       len(*v),
+		}
+	}
+	{
+		nn, err := enc.EncodeString(string(*v))
+		n += nn
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// DecodeFrom reads the XDR encoded representation of v from dec and returns
+// the number of bytes read.
+func (v *Filename) DecodeFrom(dec *xdr.Decoder) (int, error) {
+	n := 0
+	{
+		l, nn, err := dec.DecodeUint()
+		n += nn
+		if err != nil {
+			return n, err
+		}
+		if l > 16 {
+			return n, &xdr.UnmarshalError{
+				ErrorCode:   xdr.ErrOverflow,
+				Func:        "Filename.DecodeFrom",
+				Description: "'filename' exceeds the maximum length of 16",
+				Value:       l,
+			}
+		}
+		b, nn, err := dec.DecodeFixedOpaque(int32(l))
+		n += nn
+		if err != nil {
+			return n, err
+		}
+		*v = Filename(b)
+	}
+	return n, nil
+}
+
+// EncodeXDR writes the XDR encoded representation of v to enc and returns
+// the number of bytes written.  It is part of the xdr.Marshaler interface
+// implementation.
+func (v Filename) EncodeXDR(enc *xdr.Encoder) (int, error) {
+	return v.EncodeTo(enc)
+}
+
+// DecodeXDR reads the XDR encoded representation of v from dec and returns
+// the number of bytes read.  It is part of the xdr.Unmarshaler interface
+// implementation.
+func (v *Filename) DecodeXDR(dec *xdr.Decoder) (int, error) {
+	return v.DecodeFrom(dec)
+}
+
+// Cookie is the XDR type cookie.
+type Cookie [8]byte
+
+// EncodeTo writes the XDR encoded representation of v to enc and returns
+// the number of bytes written.
+func (v *Cookie) EncodeTo(enc *xdr.Encoder) (int, error) {
+	n := 0
+	{
+		nn, err := enc.EncodeFixedOpaque((*v)[:])
+		n += nn
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// DecodeFrom reads the XDR encoded representation of v from dec and returns
+// the number of bytes read.
+func (v *Cookie) DecodeFrom(dec *xdr.Decoder) (int, error) {
+	n := 0
+	{
+		b, nn, err := dec.DecodeFixedOpaque(8)
+		n += nn
+		if err != nil {
+			return n, err
+		}
+		copy((*v)[:], b)
+	}
+	return n, nil
+}
+
+// EncodeXDR writes the XDR encoded representation of v to enc and returns
+// the number of bytes written.  It is part of the xdr.Marshaler interface
+// implementation.
+func (v Cookie) EncodeXDR(enc *xdr.Encoder) (int, error) {
+	return v.EncodeTo(enc)
+}
+
+// DecodeXDR reads the XDR encoded representation of v from dec and returns
+// the number of bytes read.  It is part of the xdr.Unmarshaler interface
+// implementation.
+func (v *Cookie) DecodeXDR(dec *xdr.Decoder) (int, error) {
+	return v.DecodeFrom(dec)
+}
+
+// Blob is the XDR type blob.
+type Blob []byte
+
+// EncodeTo writes the XDR encoded representation of v to enc and returns
+// the number of bytes written.
+func (v *Blob) EncodeTo(enc *xdr.Encoder) (int, error) {
+	n := 0
+	{
+		nn, err := enc.EncodeOpaque(*v)
+		n += nn
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// DecodeFrom reads the XDR encoded representation of v from dec and returns
+// the number of bytes read.
+func (v *Blob) DecodeFrom(dec *xdr.Decoder) (int, error) {
+	n := 0
+	{
+		b, nn, err := dec.DecodeOpaque()
+		n += nn
+		if err != nil {
+			return n, err
+		}
+		*v = Blob(b)
+	}
+	return n, nil
+}
+
+// EncodeXDR writes the XDR encoded representation of v to enc and returns
+// the number of bytes written.  It is part of the xdr.Marshaler interface
+// implementation.
+func (v Blob) EncodeXDR(enc *xdr.Encoder) (int, error) {
+	return v.EncodeTo(enc)
+}
+
+// DecodeXDR reads the XDR encoded representation of v from dec and returns
+// the number of bytes read.  It is part of the xdr.Unmarshaler interface
+// implementation.
+func (v *Blob) DecodeXDR(dec *xdr.Decoder) (int, error) {
+	return v.DecodeFrom(dec)
+}
+
+// Ids is the XDR type ids.
+type Ids []uint32
+
+// EncodeTo writes the XDR encoded representation of v to enc and returns
+// the number of bytes written.
+func (v *Ids) EncodeTo(enc *xdr.Encoder) (int, error) {
+	n := 0
+	if len(*v) > 4 {
+		return n, &xdr.MarshalError{
+			ErrorCode:   xdr.ErrOverflow,
+			Func:        "Ids.EncodeTo",
+			Description: "'ids' exceeds the maximum length of 4",
+			Value:       len(*v),
+		}
+	}
+	{
+		nn, err := enc.EncodeUint(uint32(len(*v)))
+		n += nn
+		if err != nil {
+			return n, err
+		}
+	}
+	for i1 := range *v {
+		{
+			nn, err := enc.EncodeUint((*v)[i1])
+			n += nn
+			if err != nil {
+				return n, err
+			}
+		}
+	}
+	return n, nil
+}
+
+// DecodeFrom reads the XDR encoded representation of v from dec and returns
+// the number of bytes read.
+func (v *Ids) DecodeFrom(dec *xdr.Decoder) (int, error) {
+	n := 0
+	{
+		l, nn, err := dec.DecodeUint()
+		n += nn
+		if err != nil {
+			return n, err
+		}
+		if l > 4 {
+			return n, &xdr.UnmarshalError{
+				ErrorCode:   xdr.ErrOverflow,
+				Func:        "Ids.DecodeFrom",
+				Description: "'ids' exceeds the maximum length of 4",
+				Value:       l,
+			}
+		}
+		*v = make(Ids, 0, l)
+		for i1 := uint32(0); i1 < l; i1++ {
+			var e2 uint32
+			{
+				val, nn, err := dec.DecodeUint()
+				n += nn
+				if err != nil {
+					return n, err
+				}
+				e2 = val
+			}
+			*v = append(*v, e2)
+		}
+	}
+	return n, nil
+}
+
+// EncodeXDR writes the XDR encoded representation of v to enc and returns
+// the number of bytes written.  It is part of the xdr.Marshaler interface
+// implementation.
+func (v Ids) EncodeXDR(enc *xdr.Encoder) (int, error) {
+	return v.EncodeTo(enc)
+}
+
+// DecodeXDR reads the XDR encoded representation of v from dec and returns
+// the number of bytes read.  It is part of the xdr.Unmarshaler interface
+// implementation.
+func (v *Ids) DecodeXDR(dec *xdr.Decoder) (int, error) {
+	return v.DecodeFrom(dec)
+}
+
+// Scores is the XDR type scores.
+type Scores []int32
+
+// EncodeTo writes the XDR encoded representation of v to enc and returns
+// the number of bytes written.
+func (v *Scores) EncodeTo(enc *xdr.Encoder) (int, error) {
+	n := 0
+	{
+		nn, err := enc.EncodeUint(uint32(len(*v)))
+		n += nn
+		if err != nil {
+			return n, err
+		}
+	}
+	for i1 := range *v {
+		{
+			nn, err := enc.EncodeInt((*v)[i1])
+			n += nn
+			if err != nil {
+				return n, err
+			}
+		}
+	}
+	return n, nil
+}
+
+// DecodeFrom reads the XDR encoded representation of v from dec and returns
+// the number of bytes read.
+func (v *Scores) DecodeFrom(dec *xdr.Decoder) (int, error) {
+	n := 0
+	{
+		l, nn, err := dec.DecodeUint()
+		n += nn
+		if err != nil {
+			return n, err
+		}
+		c := l
+		if c > 256 {
+			c = 256
+		}
+		*v = make(Scores, 0, c)
+		for i1 := uint32(0); i1 < l; i1++ {
+			var e2 int32
+			{
+				val, nn, err := dec.DecodeInt()
+				n += nn
+				if err != nil {
+					return n, err
+				}
+				e2 = val
+			}
+			*v = append(*v, e2)
+		}
+	}
+	return n, nil
+}
+
+// EncodeXDR writes the XDR encoded representation of v to enc and returns
+// the number of bytes written.  It is part of the xdr.Marshaler interface
+// implementation.
+func (v Scores) EncodeXDR(enc *xdr.Encoder) (int, error) {
+	return v.EncodeTo(enc)
+}
+
+// DecodeXDR reads the XDR encoded representation of v from dec and returns
+// the number of bytes read.  It is part of the xdr.Unmarshaler interface
+// implementation.
+func (v *Scores) DecodeXDR(dec *xdr.Decoder) (int, error) {
+	return v.DecodeFrom(dec)
+}
+
+// Stamp is the XDR type stamp.
+type Stamp int64
+
+// EncodeTo writes the XDR encoded representation of v to enc and returns
+// the number of bytes written.
+func (v *Stamp) EncodeTo(enc *xdr.Encoder) (int, error) {
+	n := 0
+	{
+		nn, err := enc.EncodeHyper(int64(*v))
+		n += nn
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// DecodeFrom reads the XDR encoded representation of v from dec and returns
+// the number of bytes read.
+func (v *Stamp) DecodeFrom(dec *xdr.Decoder) (int, error) {
+	n := 0
+	{
+		val, nn, err := dec.DecodeHyper()
+		n += nn
+		if err != nil {
+			return n, err
+		}
+		*v = Stamp(val)
+	}
+	return n, nil
+}
+
+// EncodeXDR writes the XDR encoded representation of v to enc and returns
+// the number of bytes written.  It is part of the xdr.Marshaler interface
+// implementation.
+func (v Stamp) EncodeXDR(enc *xdr.Encoder) (int, error) {
+	return v.EncodeTo(enc)
+}
+
+// DecodeXDR reads the XDR encoded representation of v from dec and returns
+// the number of bytes read.  It is part of the xdr.Unmarshaler interface
+// implementation.
+func (v *Stamp) DecodeXDR(dec *xdr.Decoder) (int, error) {
+	return v.DecodeFrom(dec)
+}
+
+// Precise is the XDR type precise.
+type Precise xdr.Quadruple
+
+// EncodeTo writes the XDR encoded representation of v to enc and returns
+// the number of bytes written.
+func (v *Precise) EncodeTo(enc *xdr.Encoder) (int, error) {
+	n := 0
+	{
+		nn, err := enc.EncodeQuadruple(xdr.Quadruple(*v))
+		n += nn
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// DecodeFrom reads the XDR encoded representation of v from dec and returns
+// the number of bytes read.
+func (v *Precise) DecodeFrom(dec *xdr.Decoder) (int, error) {
+	n := 0
+	{
+		val, nn, err := dec.DecodeQuadruple()
+		n += nn
+		if err != nil {
+			return n, err
+		}
+		*v = Precise(val)
+	}
+	return n, nil
+}
+
+// EncodeXDR writes the XDR encoded representation of v to enc and returns
+// the number of bytes written.  It is part of the xdr.Marshaler interface
+// implementation.
+func (v Precise) EncodeXDR(enc *xdr.Encoder) (int, error) {
+	return v.EncodeTo(enc)
+}
+
+// DecodeXDR reads the XDR encoded representation of v from dec and returns
+// the number of bytes read.  It is part of the xdr.Unmarshaler interface
+// implementation.
+func (v *Precise) DecodeXDR(dec *xdr.Decoder) (int, error) {
+	return v.DecodeFrom(dec)
+}
+
+// Ftype is the XDR enum ftype.
+type Ftype int32
+
+const (
+	REG Ftype = 1
+	DIR Ftype = 2
+	LNK Ftype = 5
+)
+
+var xdrNamesFtype = map[Ftype]string{
+	REG: "REG",
+	DIR: "DIR",
+	LNK: "LNK",
+}
+
+var xdrValidFtype = map[int32]bool{
+	1: true,
+	2: true,
+	5: true,
+}
+
+// String returns the name of the Ftype value.
+func (v Ftype) String() string {
+	if s, ok := xdrNamesFtype[v]; ok {
+		return s
+	}
+	return fmt.Sprintf("Ftype(%d)", int32(v))
+}
+
+// EncodeTo writes the XDR encoded representation of v to enc and returns
+// the number of bytes written.
+func (v *Ftype) EncodeTo(enc *xdr.Encoder) (int, error) {
+	return enc.EncodeEnum(int32(*v), xdrValidFtype)
+}
+
+// DecodeFrom reads the XDR encoded representation of v from dec and returns
+// the number of bytes read.
+func (v *Ftype) DecodeFrom(dec *xdr.Decoder) (int, error) {
+	val, n, err := dec.DecodeEnum(xdrValidFtype)
+	if err != nil {
+		return n, err
+	}
+	*v = Ftype(val)
+	return n, nil
+}
+
+// EncodeXDR writes the XDR encoded representation of v to enc and returns
+// the number of bytes written.  It is part of the xdr.Marshaler interface
+// implementation.
+func (v Ftype) EncodeXDR(enc *xdr.Encoder) (int, error) {
+	return v.EncodeTo(enc)
+}
+
+// DecodeXDR reads the XDR encoded representation of v from dec and returns
+// the number of bytes read.  It is part of the xdr.Unmarshaler interface
+// implementation.
+func (v *Ftype) DecodeXDR(dec *xdr.Decoder) (int, error) {
+	return v.DecodeFrom(dec)
+}
+
+// Entry is the XDR struct entry.
+type Entry struct {
+	Fileid    uint64
+	Name      Filename
+	Type      Ftype
+	Nextentry *Entry `xdr:"optional"`
+}
+
+// EncodeTo writes the XDR encoded representation of v to enc and returns
+// the number of bytes written.
+func (v *Entry) EncodeTo(enc *xdr.Encoder) (int, error) {
+	n := 0
+	{
+		nn, err := enc.EncodeUhyper(v.Fileid)
+		n += nn
+		if err != nil {
+			return n, err
+		}
+	}
+	{
+		nn, err := v.Name.EncodeTo(enc)
+		n += nn
+		if err != nil {
+			return n, err
+		}
+	}
+	{
+		nn, err := v.Type.EncodeTo(enc)
+		n += nn
+		if err != nil {
+			return n, err
+		}
+	}
+	{
+		nn, err := enc.EncodeBool(v.Nextentry != nil)
+		n += nn
+		if err != nil {
+			return n, err
+		}
+	}
+	if v.Nextentry != nil {
+		{
+			nn, err := (*v.Nextentry).EncodeTo(enc)
+			n += nn
+			if err != nil {
+				return n, err
+			}
+		}
+	}
+	return n, nil
+}
+
+// DecodeFrom reads the XDR encoded representation of v from dec and returns
+// the number of bytes read.
+func (v *Entry) DecodeFrom(dec *xdr.Decoder) (int, error) {
+	n := 0
+	{
+		val, nn, err := dec.DecodeUhyper()
+		n += nn
+		if err != nil {
+			return n, err
+		}
+		v.Fileid = val
+	}
+	{
+		nn, err := v.Name.DecodeFrom(dec)
+		n += nn
+		if err != nil {
+			return n, err
+		}
+	}
+	{
+		nn, err := v.Type.DecodeFrom(dec)
+		n += nn
+		if err != nil {
+			return n, err
+		}
+	}
+	{
+		present, nn, err := dec.DecodeBool()
+		n += nn
+		if err != nil {
+			return n, err
+		}
+		if !present {
+			v.Nextentry = nil
+		} else {
+			v.Nextentry = new(Entry)
+			{
+				nn, err := (*v.Nextentry).DecodeFrom(dec)
+				n += nn
+				if err != nil {
+					return n, err
+				}
+			}
+		}
+	}
+	return n, nil
+}
+
+// EncodeXDR writes the XDR encoded representation of v to enc and returns
+// the number of bytes written.  It is part of the xdr.Marshaler interface
+// implementation.
+func (v Entry) EncodeXDR(enc *xdr.Encoder) (int, error) {
+	return v.EncodeTo(enc)
+}
+
+// DecodeXDR reads the XDR encoded representation of v from dec and returns
+// the number of bytes read.  It is part of the xdr.Unmarshaler interface
+// implementation.
+func (v *Entry) DecodeXDR(dec *xdr.Decoder) (int, error) {
+	return v.DecodeFrom(dec)
+}
+
+// Entrylist is the XDR type entrylist.
+type Entrylist *Entry
+
+// Dirlist is the XDR struct dirlist.
+type Dirlist struct {
+	Entries  Entrylist `xdr:"optional"`
+	Eof      bool
+	Weight   float32
+	Ratio    float64
+	Matrix   [2]int32
+	Names    []Filename `xdr:"max=4"`
+	Release  DirlistRelease
+	Modified Stamp
+}
+
+// EncodeTo writes the XDR encoded representation of v to enc and returns
+// the number of bytes written.
+func (v *Dirlist) EncodeTo(enc *xdr.Encoder) (int, error) {
+	n := 0
+	{
+		nn, err := enc.EncodeBool(v.Entries != nil)
+		n += nn
+		if err != nil {
+			return n, err
+		}
+	}
+	if v.Entries != nil {
+		{
+			nn, err := (*v.Entries).EncodeTo(enc)
+			n += nn
+			if err != nil {
+				return n, err
+			}
+		}
+	}
+	{
+		nn, err := enc.EncodeBool(v.Eof)
+		n += nn
+		if err != nil {
+			return n, err
+		}
+	}
+	{
+		nn, err := enc.EncodeFloat(v.Weight)
+		n += nn
+		if err != nil {
+			return n, err
+		}
+	}
+	{
+		nn, err := enc.EncodeDouble(v.Ratio)
+		n += nn
+		if err != nil {
+			return n, err
+		}
+	}
+	for i1 := range v.Matrix {
+		{
+			nn, err := enc.EncodeInt(v.Matrix[i1])
+			n += nn
+			if err != nil {
+				return n, err
+			}
+		}
+	}
+	if len(v.Names) > 4 {
+		return n, &xdr.MarshalError{
+			ErrorCode:   xdr.ErrOverflow,
+			Func:        "Dirlist.EncodeTo",
+			Description: "'names' exceeds the maximum length of 4",
+			Value:       len(v.Names),
+		}
+	}
+	{
+		nn, err := enc.EncodeUint(uint32(len(v.Names)))
+		n += nn
+		if err != nil {
+			return n, err
+		}
+	}
+	for i2 := range v.Names {
+		{
+			nn, err := v.Names[i2].EncodeTo(enc)
+			n += nn
+			if err != nil {
+				return n, err
+			}
+		}
+	}
+	{
+		nn, err := v.Release.EncodeTo(enc)
+		n += nn
+		if err != nil {
+			return n, err
+		}
+	}
+	{
+		nn, err := v.Modified.EncodeTo(enc)
+		n += nn
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// DecodeFrom reads the XDR encoded representation of v from dec and returns
+// the number of bytes read.
+func (v *Dirlist) DecodeFrom(dec *xdr.Decoder) (int, error) {
+	n := 0
+	{
+		present, nn, err := dec.DecodeBool()
+		n += nn
+		if err != nil {
+			return n, err
+		}
+		if !present {
+			v.Entries = nil
+		} else {
+			v.Entries = new(Entry)
+			{
+				nn, err := (*v.Entries).DecodeFrom(dec)
+				n += nn
+				if err != nil {
+					return n, err
+				}
+			}
+		}
+	}
+	{
+		val, nn, err := dec.DecodeBool()
+		n += nn
+		if err != nil {
+			return n, err
+		}
+		v.Eof = val
+	}
+	{
+		val, nn, err := dec.DecodeFloat()
+		n += nn
+		if err != nil {
+			return n, err
+		}
+		v.Weight = val
+	}
+	{
+		val, nn, err := dec.DecodeDouble()
+		n += nn
+		if err != nil {
+			return n, err
+		}
+		v.Ratio = val
+	}
+	for i1 := range v.Matrix {
+		{
+			val, nn, err := dec.DecodeInt()
+			n += nn
+			if err != nil {
+				return n, err
+			}
+			v.Matrix[i1] = val
+		}
+	}
+	{
+		l, nn, err := dec.DecodeUint()
+		n += nn
+		if err != nil {
+			return n, err
+		}
+		if l > 4 {
+			return n, &xdr.UnmarshalError{
+				ErrorCode:   xdr.ErrOverflow,
+				Func:        "Dirlist.DecodeFrom",
+				Description: "'names' exceeds the maximum length of 4",
+				Value:       l,
+			}
+		}
+		v.Names = make([]Filename, 0, l)
+		for i2 := uint32(0); i2 < l; i2++ {
+			var e3 Filename
+			{
+				nn, err := e3.DecodeFrom(dec)
+				n += nn
+				if err != nil {
+					return n, err
+				}
+			}
+			v.Names = append(v.Names, e3)
+		}
+	}
+	{
+		nn, err := v.Release.DecodeFrom(dec)
+		n += nn
+		if err != nil {
+			return n, err
+		}
+	}
+	{
+		nn, err := v.Modified.DecodeFrom(dec)
+		n += nn
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// EncodeXDR writes the XDR encoded representation of v to enc and returns
+// the number of bytes written.  It is part of the xdr.Marshaler interface
+// implementation.
+func (v Dirlist) EncodeXDR(enc *xdr.Encoder) (int, error) {
+	return v.EncodeTo(enc)
+}
+
+// DecodeXDR reads the XDR encoded representation of v from dec and returns
+// the number of bytes read.  It is part of the xdr.Unmarshaler interface
+// implementation.
+func (v *Dirlist) DecodeXDR(dec *xdr.Decoder) (int, error) {
+	return v.DecodeFrom(dec)
+}
+
+// DirlistRelease is the XDR struct dirlist_release.
+type DirlistRelease struct {
+	Major int32
+	Minor int32
+}
+
+// EncodeTo writes the XDR encoded representation of v to enc and returns
+// the number of bytes written.
+func (v *DirlistRelease) EncodeTo(enc *xdr.Encoder) (int, error) {
+	n := 0
+	{
+		nn, err := enc.EncodeInt(v.Major)
+		n += nn
+		if err != nil {
+			return n, err
+		}
+	}
+	{
+		nn, err := enc.EncodeInt(v.Minor)
+		n += nn
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// DecodeFrom reads the XDR encoded representation of v from dec and returns
+// the number of bytes read.
+func (v *DirlistRelease) DecodeFrom(dec *xdr.Decoder) (int, error) {
+	n := 0
+	{
+		val, nn, err := dec.DecodeInt()
+		n += nn
+		if err != nil {
+			return n, err
+		}
+		v.Major = val
+	}
+	{
+		val, nn, err := dec.DecodeInt()
+		n += nn
+		if err != nil {
+			return n, err
+		}
+		v.Minor = val
+	}
+	return n, nil
+}
+
+// EncodeXDR writes the XDR encoded representation of v to enc and returns
+// the number of bytes written.  It is part of the xdr.Marshaler interface
+// implementation.
+func (v DirlistRelease) EncodeXDR(enc *xdr.Encoder) (int, error) {
+	return v.EncodeTo(enc)
+}
+
+// DecodeXDR reads the XDR encoded representation of v from dec and returns
+// the number of bytes read.  It is part of the xdr.Unmarshaler interface
+// implementation.
+func (v *DirlistRelease) DecodeXDR(dec *xdr.Decoder) (int, error) {
+	return v.DecodeFrom(dec)
+}
+
+// Status is the XDR enum status.
+type Status int32
+
+const (
+	OK    Status = 0
+	NOENT Status = 2
+	IO    Status = 5
+)
+
+var xdrNamesStatus = map[Status]string{
+	OK:    "OK",
+	NOENT: "NOENT",
+	IO:    "IO",
+}
+
+var xdrValidStatus = map[int32]bool{
+	0: true,
+	2: true,
+	5: true,
+}
+
+// String returns the name of the Status value.
+func (v Status) String() string {
+	if s, ok := xdrNamesStatus[v]; ok {
+		return s
+	}
+	return fmt.Sprintf("Status(%d)", int32(v))
+}
+
+// EncodeTo writes the XDR encoded representation of v to enc and returns
+// the number of bytes written.
+func (v *Status) EncodeTo(enc *xdr.Encoder) (int, error) {
+	return enc.EncodeEnum(int32(*v), xdrValidStatus)
+}
+
+// DecodeFrom reads the XDR encoded representation of v from dec and returns
+// the number of bytes read.
+func (v *Status) DecodeFrom(dec *xdr.Decoder) (int, error) {
+	val, n, err := dec.DecodeEnum(xdrValidStatus)
+	if err != nil {
+		return n, err
+	}
+	*v = Status(val)
+	return n, nil
+}
+
+// EncodeXDR writes the XDR encoded representation of v to enc and returns
+// the number of bytes written.  It is part of the xdr.Marshaler interface
+// implementation.
+func (v Status) EncodeXDR(enc *xdr.Encoder) (int, error) {
+	return v.EncodeTo(enc)
+}
+
+// DecodeXDR reads the XDR encoded representation of v from dec and returns
+// the number of bytes read.  It is part of the xdr.Unmarshaler interface
+// implementation.
+func (v *Status) DecodeXDR(dec *xdr.Decoder) (int, error) {
+	return v.DecodeFrom(dec)
+}
+
+// Readdirres is the XDR union readdirres.
+//
+// Only the field of the arm selected by the Stat field is encoded and
+// decoded.
+type Readdirres struct {
+	Stat Status   `xdr:"union"`
+	List Dirlist  `xdr:"unioncase=0"`
+	Void struct{} `xdr:"unioncase=2,5"`
+}
+
+// EncodeTo writes the XDR encoded representation of v to enc and returns
+// the number of bytes written.
+func (v *Readdirres) EncodeTo(enc *xdr.Encoder) (int, error) {
+	n := 0
+	{
+		nn, err := v.Stat.EncodeTo(enc)
+		n += nn
+		if err != nil {
+			return n, err
+		}
+	}
+	switch v.Stat {
+	case OK:
+		{
+			nn, err := v.List.EncodeTo(enc)
+			n += nn
+			if err != nil {
+				return n, err
+			}
+		}
+	case NOENT, IO:
+	default:
+		return n, &xdr.MarshalError{
+			ErrorCode:   xdr.ErrBadDiscriminant,
+			Func:        "Readdirres.EncodeTo",
+			Description: "discriminant does not select a union arm",
+			Value:       v.Stat,
+		}
+	}
+	return n, nil
+}
+
+// DecodeFrom reads the XDR encoded representation of v from dec and returns
+// the number of bytes read.
+func (v *Readdirres) DecodeFrom(dec *xdr.Decoder) (int, error) {
+	n := 0
+	*v = Readdirres{}
+	{
+		nn, err := v.Stat.DecodeFrom(dec)
+		n += nn
+		if err != nil {
+			return n, err
+		}
+	}
+	switch v.Stat {
+	case OK:
+		{
+			nn, err := v.List.DecodeFrom(dec)
+			n += nn
+			if err != nil {
+				return n, err
+			}
+		}
+	case NOENT, IO:
+	default:
+		return n, &xdr.UnmarshalError{
+			ErrorCode:   xdr.ErrBadDiscriminant,
+			Func:        "Readdirres.DecodeFrom",
+			Description: "discriminant does not select a union arm",
+			Value:       v.Stat,
+		}
+	}
+	return n, nil
+}
+
+// EncodeXDR writes the XDR encoded representation of v to enc and returns
+// the number of bytes written.  It is part of the xdr.Marshaler interface
+// implementation.
+func (v Readdirres) EncodeXDR(enc *xdr.Encoder) (int, error) {
+	return v.EncodeTo(enc)
+}
+
+// DecodeXDR reads the XDR encoded representation of v from dec and returns
+// the number of bytes read.  It is part of the xdr.Unmarshaler interface
+// implementation.
+func (v *Readdirres) DecodeXDR(dec *xdr.Decoder) (int, error) {
+	return v.DecodeFrom(dec)
+}
+
+// Lookupres is the XDR union lookupres.
+//
+// Only the field of the arm selected by the Code field is encoded and
+// decoded.
+type Lookupres struct {
+	Code uint32 `xdr:"union"`
+	Verf Cookie `xdr:"unioncase=0"`
+	Msg  string `xdr:"unioncase=1,max=16"`
+	Data Blob   `xdr:"default"`
+}
+
+// EncodeTo writes the XDR encoded representation of v to enc and returns
+// the number of bytes written.
+func (v *Lookupres) EncodeTo(enc *xdr.Encoder) (int, error) {
+	n := 0
+	{
+		nn, err := enc.EncodeUint(v.Code)
+		n += nn
+		if err != nil {
+			return n, err
+		}
+	}
+	switch v.Code {
+	case 0:
+		{
+			nn, err := v.Verf.EncodeTo(enc)
+			n += nn
+			if err != nil {
+				return n, err
+			}
+		}
+	case 1:
+		if len(v.Msg) > 16 {
+			return n, &xdr.MarshalError{
+				ErrorCode:   xdr.ErrOverflow,
+				Func:        "Lookupres.EncodeTo",
+				Description: "'msg' exceeds the maximum length of 16",
+				Value:       len(v.Msg),
+			}
+		}
+		{
+			nn, err := enc.EncodeString(v.Msg)
+			n += nn
+			if err != nil {
+				return n, err
+			}
+		}
+	default:
+		{
+			nn, err := v.Data.EncodeTo(enc)
+			n += nn
+			if err != nil {
+				return n, err
+			}
+		}
+	}
+	return n, nil
+}
+
+// DecodeFrom reads the XDR encoded representation of v from dec and returns
+// the number of bytes read.
+func (v *Lookupres) DecodeFrom(dec *xdr.Decoder) (int, error) {
+	n := 0
+	*v = Lookupres{}
+	{
+		val, nn, err := dec.DecodeUint()
+		n += nn
+		if err != nil {
+			return n, err
+		}
+		v.Code = val
+	}
+	switch v.Code {
+	case 0:
+		{
+			nn, err := v.Verf.DecodeFrom(dec)
+			n += nn
+			if err != nil {
+				return n, err
+			}
+		}
+	case 1:
+		{
+			l, nn, err := dec.DecodeUint()
+			n += nn
+			if err != nil {
+				return n, err
+			}
+			if l > 16 {
+				return n, &xdr.UnmarshalError{
+					ErrorCode:   xdr.ErrOverflow,
+					Func:        "Lookupres.DecodeFrom",
+					Description: "'msg' exceeds the maximum length of 16",
+					Value:       l,
+				}
+			}
+			b, nn, err := dec.DecodeFixedOpaque(int32(l))
+			n += nn
+			if err != nil {
+				return n, err
+			}
+			v.Msg = string(b)
+		}
+	default:
+		{
+			nn, err := v.Data.DecodeFrom(dec)
+			n += nn
+			if err != nil {
+				return n, err
+			}
+		}
+	}
+	return n, nil
+}
+
+// EncodeXDR writes the XDR encoded representation of v to enc and returns
+// the number of bytes written.  It is part of the xdr.Marshaler interface
+// implementation.
+func (v Lookupres) EncodeXDR(enc *xdr.Encoder) (int, error) {
+	return v.EncodeTo(enc)
+}
+
+// DecodeXDR reads the XDR encoded representation of v from dec and returns
+// the number of bytes read.  It is part of the xdr.Unmarshaler interface
+// implementation.
+func (v *Lookupres) DecodeXDR(dec *xdr.Decoder) (int, error) {
+	return v.DecodeFrom(dec)
+}
+
+// Optbool is the XDR union optbool.
+//
+// Only the field of the arm selected by the Present field is encoded and
+// decoded.
+type Optbool struct {
+	Present bool     `xdr:"union"`
+	Value   int64    `xdr:"unioncase=1"`
+	Void    struct{} `xdr:"unioncase=0"`
+}
+
+// EncodeTo writes the XDR encoded representation of v to enc and returns
+// the number of bytes written.
+func (v *Optbool) EncodeTo(enc *xdr.Encoder) (int, error) {
+	n := 0
+	{
+		nn, err := enc.EncodeBool(v.Present)
+		n += nn
+		if err != nil {
+			return n, err
+		}
+	}
+	switch v.Present {
+	case true:
+		{
+			nn, err := enc.EncodeHyper(v.Value)
+			n += nn
+			if err != nil {
+				return n, err
+			}
+		}
+	case false:
+	default:
+		return n, &xdr.MarshalError{
+			ErrorCode:   xdr.ErrBadDiscriminant,
+			Func:        "Optbool.EncodeTo",
+			Description: "discriminant does not select a union arm",
+			Value:       v.Present,
+		}
+	}
+	return n, nil
+}
+
+// DecodeFrom reads the XDR encoded representation of v from dec and returns
+// the number of bytes read.
+func (v *Optbool) DecodeFrom(dec *xdr.Decoder) (int, error) {
+	n := 0
+	*v = Optbool{}
+	{
+		val, nn, err := dec.DecodeBool()
+		n += nn
+		if err != nil {
+			return n, err
+		}
+		v.Present = val
+	}
+	switch v.Present {
+	case true:
+		{
+			val, nn, err := dec.DecodeHyper()
+			n += nn
+			if err != nil {
+				return n, err
+			}
+			v.Value = val
+		}
+	case false:
+	default:
+		return n, &xdr.UnmarshalError{
+			ErrorCode:   xdr.ErrBadDiscriminant,
+			Func:        "Optbool.DecodeFrom",
+			Description: "discriminant does not select a union arm",
+			Value:       v.Present,
+		}
+	}
+	return n, nil
+}
+
+// EncodeXDR writes the XDR encoded representation of v to enc and returns
+// the number of bytes written.  It is part of the xdr.Marshaler interface
+// implementation.
+func (v Optbool) EncodeXDR(enc *xdr.Encoder) (int, error) {
+	return v.EncodeTo(enc)
+}
+
+// DecodeXDR reads the XDR encoded representation of v from dec and returns
+// the number of bytes read.  It is part of the xdr.Unmarshaler interface
+// implementation.
+func (v *Optbool) DecodeXDR(dec *xdr.Decoder) (int, error) {
+	return v.DecodeFrom(dec)
+}
+
+// Intres is the XDR union intres.
+//
+// Only the field of the arm selected by the Kind field is encoded and
+// decoded.
+type Intres struct {
+	Kind    int32     `xdr:"union"`
+	Value   int32     `xdr:"unioncase=-1"`
+	Entries Entrylist `xdr:"unioncase=1,optional"`
+}
+
+// EncodeTo writes the XDR encoded representation of v to enc and returns
+// the number of bytes written.
+func (v *Intres) EncodeTo(enc *xdr.Encoder) (int, error) {
+	n := 0
+	{
+		nn, err := enc.EncodeInt(v.Kind)
+		n += nn
+		if err != nil {
+			return n, err
+		}
+	}
+	switch v.Kind {
+	case -1:
+		{
+			nn, err := enc.EncodeInt(v.Value)
+			n += nn
+			if err != nil {
+				return n, err
+			}
+		}
+	case 1:
+		{
+			nn, err := enc.EncodeBool(v.Entries != nil)
+			n += nn
+			if err != nil {
+				return n, err
+			}
+		}
+		if v.Entries != nil {
+			{
+				nn, err := (*v.Entries).EncodeTo(enc)
+				n += nn
+				if err != nil {
+					return n, err
+				}
+			}
+		}
+	default:
+		return n, &xdr.MarshalError{
+			ErrorCode:   xdr.ErrBadDiscriminant,
+			Func:        "Intres.EncodeTo",
+			Description: "discriminant does not select a union arm",
+			Value:       v.Kind,
+		}
+	}
+	return n, nil
+}
+
+// DecodeFrom reads the XDR encoded representation of v from dec and returns
+// the number of bytes read.
+func (v *Intres) DecodeFrom(dec *xdr.Decoder) (int, error) {
+	n := 0
+	*v = Intres{}
+	{
+		val, nn, err := dec.DecodeInt()
+		n += nn
+		if err != nil {
+			return n, err
+		}
+		v.Kind = val
+	}
+	switch v.Kind {
+	case -1:
+		{
+			val, nn, err := dec.DecodeInt()
+			n += nn
+			if err != nil {
+				return n, err
+			}
+			v.Value = val
+		}
+	case 1:
+		{
+			present, nn, err := dec.DecodeBool()
+			n += nn
+			if err != nil {
+				return n, err
+			}
+			if !present {
+				v.Entries = nil
+			} else {
+				v.Entries = new(Entry)
+				{
+					nn, err := (*v.Entries).DecodeFrom(dec)
+					n += nn
+					if err != nil {
+						return n, err
+					}
+				}
+			}
+		}
+	default:
+		return n, &xdr.UnmarshalError{
+			ErrorCode:   xdr.ErrBadDiscriminant,
+			Func:        "Intres.DecodeFrom",
+			Description: "discriminant does not select a union arm",
+			Value:       v.Kind,
+		}
+	}
+	return n, nil
+}
+
+// EncodeXDR writes the XDR encoded representation of v to enc and returns
+// the number of bytes written.  It is part of the xdr.Marshaler interface
+// implementation.
+func (v Intres) EncodeXDR(enc *xdr.Encoder) (int, error) {
+	return v.EncodeTo(enc)
+}
+
+// DecodeXDR reads the XDR encoded representation of v from dec and returns
+// the number of bytes read.  It is part of the xdr.Unmarshaler interface
+// implementation.
+func (v *Intres) DecodeXDR(dec *xdr.Decoder) (int, error) {
+	return v.DecodeFrom(dec)
+}
+
+// Program, version, and procedure numbers of the XDR program DIRPROG.
+const (
+	DIRPROG     = 536871030
+	DIRVERS     = 1
+	DIRPROCNULL = 0
+	READDIR     = 1
+)
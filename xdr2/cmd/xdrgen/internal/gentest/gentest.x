@@ -0,0 +1,88 @@
+/*
+ * Specification exercising each construct supported by xdrgen.  The Go code
+ * in gentest.go is generated from it.
+ */
+
+const MAXNAMELEN = 16;
+const MAXENTRIES = 4;
+
+typedef string filename<MAXNAMELEN>;
+typedef opaque cookie[8];
+typedef opaque blob<>;
+typedef unsigned int ids<MAXENTRIES>;
+typedef int scores<>;
+typedef hyper stamp;
+typedef quadruple precise;
+
+enum ftype {
+	REG = 1,
+	DIR = 2,
+	LNK = 5
+};
+
+struct entry {
+	unsigned hyper fileid;
+	filename name;
+	ftype type;
+	entry *nextentry;
+};
+
+typedef entry *entrylist;
+
+struct dirlist {
+	entrylist entries;
+	bool eof;
+	float weight;
+	double ratio;
+	int matrix[2];
+	filename names<MAXENTRIES>;
+	struct {
+		int major;
+		int minor;
+	} release;
+	stamp modified;
+};
+
+enum status {
+	OK = 0,
+	NOENT = 2,
+	IO = 5
+};
+
+union readdirres switch (status stat) {
+case OK:
+	dirlist list;
+case NOENT:
+case IO:
+	void;
+};
+
+union lookupres switch (unsigned int code) {
+case 0:
+	cookie verf;
+case 1:
+	string msg<MAXNAMELEN>;
+default:
+	blob data;
+};
+
+union optbool switch (bool present) {
+case TRUE:
+	hyper value;
+case FALSE:
+	void;
+};
+
+union intres switch (int kind) {
+case -1:
+	int value;
+case 1:
+	entrylist entries;
+};
+
+program DIRPROG {
+	version DIRVERS {
+		void DIRPROC_NULL(void) = 0;
+		readdirres READDIR(filename, cookie) = 1;
+	} = 1;
+} = 0x20000076;
@@ -0,0 +1,406 @@
+/*
+ * Copyright (c) 2014 Dave Collins <dave@davec.name>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package gentest_test
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	. "github.com/davecgh/go-xdr/xdr2/cmd/xdrgen/internal/gentest"
+
+	"github.com/davecgh/go-xdr/xdr2"
+)
+
+// codec is implemented by all types generated by xdrgen other than named
+// pointer types.
+type codec interface {
+	EncodeTo(*xdr.Encoder) (int, error)
+	DecodeFrom(*xdr.Decoder) (int, error)
+}
+
+// TestGenerated ensures the code generated for each kind of definition encodes
+// and decodes the expected bytes.
+func TestGenerated(t *testing.T) {
+	tests := []struct {
+		in        codec
+		wantBytes []byte
+	}{
+		{func() codec { v := Filename("ab"); return &v }(),
+			[]byte{0x00, 0x00, 0x00, 0x02, 'a', 'b', 0x00, 0x00}},
+		{&Cookie{1, 2, 3, 4, 5, 6, 7, 8},
+			[]byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08}},
+		{&Blob{0xff},
+			[]byte{0x00, 0x00, 0x00, 0x01, 0xff, 0x00, 0x00, 0x00}},
+		{&Ids{1, 2},
+			[]byte{0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00, 0x01,
+				0x00, 0x00, 0x00, 0x02}},
+		{&Scores{-1},
+			[]byte{0x00, 0x00, 0x00, 0x01, 0xff, 0xff, 0xff, 0xff}},
+		{func() codec { v := Stamp(-2); return &v }(),
+			[]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xfe}},
+		{func() codec {
+			v := Precise(xdr.QuadrupleFromFloat64(1))
+			return &v
+		}(),
+			[]byte{0x3f, 0xff, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+				0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}},
+		{func() codec { v := LNK; return &v }(),
+			[]byte{0x00, 0x00, 0x00, 0x05}},
+		{&Entry{Fileid: 1, Name: "a", Type: REG,
+			Nextentry: &Entry{Fileid: 2, Name: "b", Type: DIR}},
+			[]byte{
+				0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01,
+				0x00, 0x00, 0x00, 0x01, 'a', 0x00, 0x00, 0x00,
+				0x00, 0x00, 0x00, 0x01, // REG
+				0x00, 0x00, 0x00, 0x01, // Next entry present
+				0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02,
+				0x00, 0x00, 0x00, 0x01, 'b', 0x00, 0x00, 0x00,
+				0x00, 0x00, 0x00, 0x02, // DIR
+				0x00, 0x00, 0x00, 0x00, // No next entry
+			}},
+		{&Readdirres{Stat: OK, List: Dirlist{
+			Eof:      true,
+			Weight:   1,
+			Ratio:    1,
+			Matrix:   [2]int32{3, 4},
+			Names:    []Filename{"x"},
+			Release:  DirlistRelease{Major: 5, Minor: 6},
+			Modified: 7}},
+			[]byte{
+				0x00, 0x00, 0x00, 0x00, // OK
+				0x00, 0x00, 0x00, 0x00, // No entries
+				0x00, 0x00, 0x00, 0x01, // Eof
+				0x3f, 0x80, 0x00, 0x00, // Weight
+				0x3f, 0xf0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+				0x00, 0x00, 0x00, 0x03, 0x00, 0x00, 0x00, 0x04,
+				0x00, 0x00, 0x00, 0x01, // Names length
+				0x00, 0x00, 0x00, 0x01, 'x', 0x00, 0x00, 0x00,
+				0x00, 0x00, 0x00, 0x05, 0x00, 0x00, 0x00, 0x06,
+				0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x07,
+			}},
+		{&Readdirres{Stat: NOENT}, []byte{0x00, 0x00, 0x00, 0x02}},
+		{&Lookupres{Code: 0, Verf: Cookie{1}},
+			[]byte{0x00, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00,
+				0x00, 0x00, 0x00, 0x00}},
+		{&Lookupres{Code: 1, Msg: "hi"},
+			[]byte{0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x02,
+				'h', 'i', 0x00, 0x00}},
+		{&Lookupres{Code: 9},
+			[]byte{0x00, 0x00, 0x00, 0x09, 0x00, 0x00, 0x00, 0x00}},
+		{&Optbool{Present: true, Value: 1},
+			[]byte{0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00,
+				0x00, 0x00, 0x00, 0x01}},
+		{&Optbool{}, []byte{0x00, 0x00, 0x00, 0x00}},
+		{&Intres{Kind: -1, Value: 2},
+			[]byte{0xff, 0xff, 0xff, 0xff, 0x00, 0x00, 0x00, 0x02}},
+		{&Intres{Kind: 1, Entries: &Entry{Name: "e", Type: REG}},
+			[]byte{
+				0x00, 0x00, 0x00, 0x01, // Kind
+				0x00, 0x00, 0x00, 0x01, // Entries present
+				0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+				0x00, 0x00, 0x00, 0x01, 'e', 0x00, 0x00, 0x00,
+				0x00, 0x00, 0x00, 0x01, // REG
+				0x00, 0x00, 0x00, 0x00, // No next entry
+			}},
+	}
+
+	for i, test := range tests {
+		var buf bytes.Buffer
+		n, err := test.in.EncodeTo(xdr.NewEncoder(&buf))
+		if err != nil {
+			t.Errorf("EncodeTo #%d (%T) unexpected error: %v", i,
+				test.in, err)
+			continue
+		}
+		if n != len(test.wantBytes) {
+			t.Errorf("EncodeTo #%d (%T) got %d bytes want %d", i,
+				test.in, n, len(test.wantBytes))
+			continue
+		}
+		if !bytes.Equal(buf.Bytes(), test.wantBytes) {
+			t.Errorf("EncodeTo #%d (%T)\n got: %x\nwant: %x", i,
+				test.in, buf.Bytes(), test.wantBytes)
+			continue
+		}
+
+		out := reflect.New(reflect.TypeOf(test.in).Elem()).Interface()
+		dec := xdr.NewDecoder(bytes.NewReader(test.wantBytes))
+		n, err = out.(codec).DecodeFrom(dec)
+		if err != nil {
+			t.Errorf("DecodeFrom #%d (%T) unexpected error: %v", i,
+				test.in, err)
+			continue
+		}
+		if n != len(test.wantBytes) {
+			t.Errorf("DecodeFrom #%d (%T) got %d bytes want %d", i,
+				test.in, n, len(test.wantBytes))
+			continue
+		}
+		if !reflect.DeepEqual(out, test.in) {
+			t.Errorf("DecodeFrom #%d (%T)\n got: %+v\nwant: %+v", i,
+				test.in, out, test.in)
+			continue
+		}
+	}
+}
+
+// TestGeneratedErrors ensures the code generated for each kind of definition
+// enforces declared bounds, enum values, and union discriminants.
+func TestGeneratedErrors(t *testing.T) {
+	tests := []struct {
+		in   codec
+		code xdr.ErrorCode
+	}{
+		{func() codec { v := Filename("0123456789abcdefg"); return &v }(),
+			xdr.ErrOverflow},
+		{&Ids{1, 2, 3, 4, 5}, xdr.ErrOverflow},
+		{&Dirlist{Names: make([]Filename, MAXENTRIES+1)},
+			xdr.ErrOverflow},
+		{&Entry{Type: REG, Nextentry: &Entry{Name: "0123456789abcdefg"}},
+			xdr.ErrOverflow},
+		{&Lookupres{Code: 1, Msg: "0123456789abcdefg"}, xdr.ErrOverflow},
+		{func() codec { v := Ftype(3); return &v }(), xdr.ErrBadEnumValue},
+		{&Readdirres{Stat: 3}, xdr.ErrBadEnumValue},
+		{&Intres{Kind: 2}, xdr.ErrBadDiscriminant},
+	}
+
+	for i, test := range tests {
+		var buf bytes.Buffer
+		_, err := test.in.EncodeTo(xdr.NewEncoder(&buf))
+		merr, ok := err.(*xdr.MarshalError)
+		if !ok || merr.ErrorCode != test.code {
+			t.Errorf("EncodeTo #%d (%T) got error %v want %v", i,
+				test.in, err, test.code)
+			continue
+		}
+	}
+
+	// Encode values which violate the declared bounds with the primitive
+	// methods and ensure decoding them is rejected.
+	decodeTests := []struct {
+		in   []byte
+		out  codec
+		code xdr.ErrorCode
+	}{
+		{[]byte{0x00, 0x00, 0x00, 0x11}, new(Filename), xdr.ErrOverflow},
+		{[]byte{0x00, 0x00, 0x00, 0x05}, new(Ids), xdr.ErrOverflow},
+		{[]byte{0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x11},
+			new(Lookupres), xdr.ErrOverflow},
+		{[]byte{0x00, 0x00, 0x00, 0x03}, new(Ftype),
+			xdr.ErrBadEnumValue},
+		{[]byte{0x00, 0x00, 0x00, 0x03}, new(Readdirres),
+			xdr.ErrBadEnumValue},
+		{[]byte{0x00, 0x00, 0x00, 0x02}, new(Intres),
+			xdr.ErrBadDiscriminant},
+	}
+
+	for i, test := range decodeTests {
+		dec := xdr.NewDecoder(bytes.NewReader(test.in))
+		_, err := test.out.DecodeFrom(dec)
+		uerr, ok := err.(*xdr.UnmarshalError)
+		if !ok || uerr.ErrorCode != test.code {
+			t.Errorf("DecodeFrom #%d (%T) got error %v want %v", i,
+				test.out, err, test.code)
+			continue
+		}
+	}
+}
+
+// TestGeneratedEnumStringer ensures the generated String methods of enums
+// return the XDR names of their values.
+func TestGeneratedEnumStringer(t *testing.T) {
+	tests := []struct {
+		in   interface{}
+		want string
+	}{
+		{REG, "REG"},
+		{LNK, "LNK"},
+		{Ftype(3), "Ftype(3)"},
+		{NOENT, "NOENT"},
+	}
+
+	for i, test := range tests {
+		result := test.in.(interface {
+			String() string
+		}).String()
+		if result != test.want {
+			t.Errorf("String #%d\n got: %s want: %s", i, result,
+				test.want)
+			continue
+		}
+	}
+}
+
+// TestGeneratedMarshal ensures values of generated types, including when they
+// are nested within other types, are encoded and decoded by the reflection
+// based Marshal and Unmarshal functions via the generated methods.
+func TestGeneratedMarshal(t *testing.T) {
+	list := &Entry{Fileid: 1, Name: "a", Type: REG,
+		Nextentry: &Entry{Fileid: 2, Name: "b", Type: DIR}}
+	listBytes := []byte{
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01,
+		0x00, 0x00, 0x00, 0x01, 'a', 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x01, // REG
+		0x00, 0x00, 0x00, 0x01, // Next entry present
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02,
+		0x00, 0x00, 0x00, 0x01, 'b', 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x02, // DIR
+		0x00, 0x00, 0x00, 0x00, // No next entry
+	}
+	type nested struct {
+		Res  Readdirres
+		List Entrylist `xdr:"optional"`
+	}
+
+	tests := []struct {
+		in        interface{}
+		wantBytes []byte // Encoding of the generated methods when nil
+	}{
+		{&Readdirres{Stat: NOENT}, []byte{0x00, 0x00, 0x00, 0x02}},
+		{&Readdirres{Stat: OK, List: Dirlist{Entries: list,
+			Names: []Filename{"x"}}}, nil},
+		{&Intres{Kind: 1, Entries: list},
+			append([]byte{0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00,
+				0x01}, listBytes...)},
+		{&nested{Res: Readdirres{Stat: IO}, List: list},
+			append([]byte{0x00, 0x00, 0x00, 0x05, 0x00, 0x00, 0x00,
+				0x01}, listBytes...)},
+	}
+
+	for i, test := range tests {
+		want := test.wantBytes
+		if want == nil {
+			var buf bytes.Buffer
+			_, err := test.in.(codec).EncodeTo(xdr.NewEncoder(&buf))
+			if err != nil {
+				t.Errorf("EncodeTo #%d (%T) unexpected error: %v",
+					i, test.in, err)
+				continue
+			}
+			want = buf.Bytes()
+		}
+
+		var buf bytes.Buffer
+		n, err := xdr.Marshal(&buf, test.in)
+		if err != nil {
+			t.Errorf("Marshal #%d (%T) unexpected error: %v", i,
+				test.in, err)
+			continue
+		}
+		if n != len(want) || !bytes.Equal(buf.Bytes(), want) {
+			t.Errorf("Marshal #%d (%T)\n got: %x\nwant: %x", i,
+				test.in, buf.Bytes(), want)
+			continue
+		}
+
+		out := reflect.New(reflect.TypeOf(test.in).Elem()).Interface()
+		n, err = xdr.Unmarshal(bytes.NewReader(want), out)
+		if err != nil {
+			t.Errorf("Unmarshal #%d (%T) unexpected error: %v", i,
+				test.in, err)
+			continue
+		}
+		if n != len(want) {
+			t.Errorf("Unmarshal #%d (%T) got %d bytes want %d", i,
+				test.in, n, len(want))
+			continue
+		}
+		if !reflect.DeepEqual(out, test.in) {
+			t.Errorf("Unmarshal #%d (%T)\n got: %+v\nwant: %+v", i,
+				test.in, out, test.in)
+			continue
+		}
+	}
+}
+
+// withoutMethods returns a copy of the passed struct value converted to an
+// unnamed struct type with the same fields and tags so it is encoded and
+// decoded via reflection rather than any methods of its type.
+func withoutMethods(v reflect.Value) reflect.Value {
+	t := v.Type()
+	fields := make([]reflect.StructField, t.NumField())
+	for i := range fields {
+		fields[i] = t.Field(i)
+	}
+	return v.Convert(reflect.StructOf(fields))
+}
+
+// TestGeneratedMatchesReflection ensures the struct tags of the generated
+// structs and unions cause the reflection based Marshal and Unmarshal
+// functions to produce and accept the same encoding as the generated methods.
+func TestGeneratedMatchesReflection(t *testing.T) {
+	tests := []codec{
+		&Entry{Fileid: 10, Name: "file", Type: LNK,
+			Nextentry: &Entry{Fileid: 11, Name: "next", Type: DIR}},
+		&Dirlist{
+			Entries: &Entry{Fileid: 1, Name: "a", Type: REG},
+			Eof:     true,
+			Names:   []Filename{"x", "y"},
+			Release: DirlistRelease{Major: 1, Minor: 2},
+		},
+		&Readdirres{Stat: OK, List: Dirlist{Names: []Filename{"z"}}},
+		&Readdirres{Stat: NOENT},
+		&Readdirres{Stat: IO},
+		&Lookupres{Code: 0, Verf: Cookie{1, 2}},
+		&Lookupres{Code: 1, Msg: "hi"},
+		&Lookupres{Code: 9, Data: Blob{0xff}},
+		&Optbool{Present: true, Value: -1},
+		&Optbool{},
+		&Intres{Kind: -1, Value: 2},
+		&Intres{Kind: 1, Entries: &Entry{Name: "e", Type: REG}},
+	}
+
+	for i, test := range tests {
+		var want bytes.Buffer
+		if _, err := test.EncodeTo(xdr.NewEncoder(&want)); err != nil {
+			t.Errorf("EncodeTo #%d (%T) unexpected error: %v", i,
+				test, err)
+			continue
+		}
+
+		in := withoutMethods(reflect.ValueOf(test).Elem())
+		var got bytes.Buffer
+		if _, err := xdr.Marshal(&got, in.Interface()); err != nil {
+			t.Errorf("Marshal #%d (%T) unexpected error: %v", i,
+				test, err)
+			continue
+		}
+		if !bytes.Equal(got.Bytes(), want.Bytes()) {
+			t.Errorf("Marshal #%d (%T)\n got: %x\nwant: %x", i,
+				test, got.Bytes(), want.Bytes())
+			continue
+		}
+
+		out := reflect.New(in.Type())
+		_, err := xdr.Unmarshal(bytes.NewReader(want.Bytes()),
+			out.Interface())
+		if err != nil {
+			t.Errorf("Unmarshal #%d (%T) unexpected error: %v", i,
+				test, err)
+			continue
+		}
+		result := out.Elem().Convert(reflect.TypeOf(test).Elem())
+		if !reflect.DeepEqual(result.Interface(),
+			reflect.ValueOf(test).Elem().Interface()) {
+
+			t.Errorf("Unmarshal #%d (%T)\n got: %+v\nwant: %+v", i,
+				test, result.Interface(), test)
+			continue
+		}
+	}
+}
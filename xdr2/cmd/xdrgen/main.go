@@ -0,0 +1,159 @@
+/*
+ * Copyright (c) 2014 Dave Collins <dave@davec.name>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+/*
+Xdrgen generates Go types along with reflection-free methods to encode and
+decode them from an XDR specification file (typically .x extension).
+
+Usage:
+
+	xdrgen [flags] file.x
+
+The flags are:
+
+	-o file
+		write the generated code to file instead of standard output
+	-package name
+		package name of the generated code (default: the base name of the
+		specification file)
+	-xdr path
+		import path of the xdr package the generated code is built on
+		(default: github.com/davecgh/go-xdr/xdr2)
+
+The specification is mapped to Go as follows:
+
+	* Constants become untyped Go constants
+	* Enums become named int32 types with a constant for each member and a
+	  String method
+	* Structs become Go structs with a field for each declaration
+	* Unions become Go structs with a field for the discriminant followed by
+	  a field for each non-void arm of which only the one selected by the
+	  discriminant is encoded or decoded.  Void arms share a single field
+	  named Void of type struct{}
+	* Typedefs become named Go types
+	* Programs become constants for the program, version, and procedure
+	  numbers
+
+Each generated type other than named pointer types has EncodeTo and DecodeFrom
+methods which are built on the primitive methods of xdr.Encoder and xdr.Decoder.
+The maximum sizes declared for variable-length arrays, opaque data, and strings
+are enforced by both methods which return a MarshalError or UnmarshalError with
+an error code of ErrOverflow when they are exceeded.  The types also have
+EncodeXDR and DecodeXDR methods which call EncodeTo and DecodeFrom so they
+implement the xdr.Marshaler and xdr.Unmarshaler interfaces and are encoded by
+the generated code when passed to, or nested within values passed to, the
+Marshal and Unmarshal functions of the xdr package.
+
+The fields of generated structs and unions carry xdr struct tags with the
+union, unioncase, default, optional, and max options so the reflection based
+Marshal and Unmarshal functions of the xdr package produce and accept the same
+encoding as the generated methods.
+
+XDR identifiers are converted to Go identifiers by removing underscores and
+capitalizing the first letter of each word they separate.  For example,
+nfs_fh3 becomes NfsFh3.  Inline enum, struct, and union types are given the
+name of the enclosing type followed by the name of their field.  The quadruple
+type becomes xdr.Quadruple since Go has no quadruple-precision floating point
+type.
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/davecgh/go-xdr/xdr2/xdrlang"
+)
+
+// packageName returns a package name derived from the base name of the passed
+// specification file.
+func packageName(path string) string {
+	base := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + 'a' - 'A'
+		}
+		return -1
+	}, base)
+	if name == "" || name[0] >= '0' && name[0] <= '9' {
+		name = "xdr" + name
+	}
+	return name
+}
+
+// fileError prefixes the position of an *xdrlang.Error with the passed path
+// so it can be located by editors.
+func fileError(path string, err error) error {
+	if e, ok := err.(*xdrlang.Error); ok {
+		return fmt.Errorf("%s:%s: %s", path, e.Pos, e.Msg)
+	}
+	return err
+}
+
+// run generates code for the specification at the passed path and writes it
+// to the passed output path or standard output when it is empty.
+func run(path, output, pkg, importPath string) error {
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	spec, err := xdrlang.Parse(src)
+	if err != nil {
+		return fileError(path, err)
+	}
+
+	if pkg == "" {
+		pkg = packageName(path)
+	}
+	code, err := generate(spec, filepath.Base(path), pkg, importPath)
+	if err != nil {
+		return fileError(path, err)
+	}
+
+	if output == "" {
+		_, err := os.Stdout.Write(code)
+		return err
+	}
+	return ioutil.WriteFile(output, code, 0644)
+}
+
+func main() {
+	output := flag.String("o", "", "write the generated code to `file`")
+	pkg := flag.String("package", "", "package `name` of the generated code")
+	importPath := flag.String("xdr", defaultImportPath, "import `path` of "+
+		"the xdr package")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: xdrgen [flags] file.x\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := run(flag.Arg(0), *output, *pkg, *importPath); err != nil {
+		fmt.Fprintf(os.Stderr, "xdrgen: %v\n", err)
+		os.Exit(1)
+	}
+}
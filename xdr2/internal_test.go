@@ -19,6 +19,9 @@ This test file is part of the xdr package rather than than the xdr_test package
 so it can bridge access to the internals to properly test cases which are either
 not possible or can't reliably be tested via the public interface. The functions
 are only exported while the tests are being run.
+
+The package is a shim over the xdr3 package, so the internals are those of the
+xdr3 package.  They are linked to by name since they are unexported there.
 */
 
 package xdr
@@ -26,18 +29,29 @@ package xdr
 import (
 	"io"
 	"reflect"
+	_ "unsafe" // Required for go:linkname.
 )
 
+//go:linkname encode github.com/davecgh/go-xdr/xdr3.(*Encoder).encode
+func encode(enc *Encoder, v reflect.Value) (int, error)
+
+//go:linkname decode github.com/davecgh/go-xdr/xdr3.(*Decoder).decode
+func decode(dec *Decoder, v reflect.Value) (int, error)
+
 // TstEncode creates a new Encoder to the passed writer and returns the internal
 // encode function on the Encoder.
 func TstEncode(w io.Writer) func(v reflect.Value) (int, error) {
 	enc := NewEncoder(w)
-	return enc.encode
+	return func(v reflect.Value) (int, error) {
+		return encode(enc, v)
+	}
 }
 
 // TstDecode creates a new Decoder for the passed reader and returns the
 // internal decode function on the Decoder.
 func TstDecode(r io.Reader) func(v reflect.Value) (int, error) {
 	dec := NewDecoder(r)
-	return dec.decode
+	return func(v reflect.Value) (int, error) {
+		return decode(dec, v)
+	}
 }
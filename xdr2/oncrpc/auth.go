@@ -0,0 +1,315 @@
+/*
+ * Copyright (c) 2014 Dave Collins <dave@davec.name>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package oncrpc
+
+import (
+	"bytes"
+	"container/list"
+	"encoding/binary"
+	"sync"
+
+	"github.com/davecgh/go-xdr/xdr2"
+)
+
+const (
+	// MaxMachineNameLen is the maximum length of the machine name of
+	// AUTH_SYS credentials.
+	MaxMachineNameLen = 255
+
+	// MaxGIDs is the maximum number of supplementary group IDs of AUTH_SYS
+	// credentials.
+	MaxGIDs = 16
+)
+
+// AuthSysParms is the body of AUTH_SYS credentials which identify the caller
+// by the user and group IDs it has on the machine it runs on.
+//
+// Encoding or decoding a machine name which exceeds MaxMachineNameLen bytes or
+// more than MaxGIDs group IDs fails with an error code of ErrOverflow.
+//
+// Reference:
+// 	RFC 5531 Appendix A - System Authentication
+// 	authsys_parms
+type AuthSysParms struct {
+	Stamp       uint32
+	MachineName string `xdr:"max=255"`
+	UID         uint32
+	GID         uint32
+	GIDs        []uint32 `xdr:"max=16"`
+}
+
+// clone returns a copy of the parameters which shares no memory with them.
+func (p *AuthSysParms) clone() *AuthSysParms {
+	c := *p
+	c.GIDs = append([]uint32(nil), p.GIDs...)
+	return &c
+}
+
+// Authenticator provides the credentials a Client sends with its calls and
+// checks the verifiers the server replies with.
+type Authenticator interface {
+	// Credentials returns the credential and verifier to send with a
+	// call.
+	Credentials() (cred, verf OpaqueAuth, err error)
+
+	// Validate checks the verifier of a reply to a call which the server
+	// accepted.  The error it returns is returned by the call.
+	Validate(verf OpaqueAuth) error
+
+	// Refresh is called when a call is denied because authentication
+	// failed with the passed status.  It returns whether or not the call
+	// should be retried with new credentials.
+	Refresh(stat AuthStat) bool
+}
+
+// NoneAuthenticator is an Authenticator which uses the AUTH_NONE flavor for
+// calls which do not require authentication.
+type NoneAuthenticator struct{}
+
+// Credentials returns AUTH_NONE credentials.
+//
+// This is part of the Authenticator interface.
+func (NoneAuthenticator) Credentials() (cred, verf OpaqueAuth, err error) {
+	return OpaqueAuth{}, OpaqueAuth{}, nil
+}
+
+// Validate accepts any verifier.
+//
+// This is part of the Authenticator interface.
+func (NoneAuthenticator) Validate(verf OpaqueAuth) error {
+	return nil
+}
+
+// Refresh never retries a call.
+//
+// This is part of the Authenticator interface.
+func (NoneAuthenticator) Refresh(stat AuthStat) bool {
+	return false
+}
+
+// SysAuthenticator is an Authenticator which uses the AUTH_SYS flavor to
+// identify the caller with the credentials in Parms.
+//
+// When a server replies with an AUTH_SHORT verifier, its body is used as the
+// credential of subsequent calls in place of the full credentials until the
+// server rejects it.
+type SysAuthenticator struct {
+	Parms AuthSysParms
+
+	mu        sync.Mutex
+	shorthand []byte
+}
+
+// Credentials returns the AUTH_SHORT shorthand credential when the server has
+// issued one and AUTH_SYS credentials otherwise.
+//
+// A MarshalError with an error code of ErrOverflow is returned if the
+// credentials exceed the AUTH_SYS limits.
+//
+// This is part of the Authenticator interface.
+func (a *SysAuthenticator) Credentials() (cred, verf OpaqueAuth, err error) {
+	a.mu.Lock()
+	shorthand := a.shorthand
+	a.mu.Unlock()
+	if shorthand != nil {
+		return OpaqueAuth{AuthShort, shorthand}, OpaqueAuth{}, nil
+	}
+
+	var buf bytes.Buffer
+	if _, err := xdr.Marshal(&buf, &a.Parms); err != nil {
+		return OpaqueAuth{}, OpaqueAuth{}, err
+	}
+	return OpaqueAuth{AuthSys, buf.Bytes()}, OpaqueAuth{}, nil
+}
+
+// Validate records the shorthand credential of AUTH_SHORT verifiers.
+//
+// This is part of the Authenticator interface.
+func (a *SysAuthenticator) Validate(verf OpaqueAuth) error {
+	if verf.Flavor == AuthShort {
+		a.mu.Lock()
+		a.shorthand = verf.Body
+		a.mu.Unlock()
+	}
+	return nil
+}
+
+// Refresh discards the shorthand credential so the call is retried with the
+// full AUTH_SYS credentials when it was the one rejected.
+//
+// This is part of the Authenticator interface.
+func (a *SysAuthenticator) Refresh(stat AuthStat) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.shorthand == nil {
+		return false
+	}
+	a.shorthand = nil
+	return true
+}
+
+// ServerAuthenticator authenticates the credentials of calls received by a
+// Server.
+type ServerAuthenticator interface {
+	// Authenticate checks the passed credential and verifier of a call.
+	// It returns a value describing the authenticated caller, which is
+	// made available to handlers via the Auth field of the Request, along
+	// with the verifier to reply with.  The call is denied with the
+	// returned status when it is not AuthOK.
+	Authenticate(cred, verf OpaqueAuth) (caller interface{}, replyVerf OpaqueAuth, stat AuthStat)
+}
+
+// DefaultMaxShorthands is the maximum number of shorthand credentials a
+// SysServerAuthenticator remembers when its MaxShorthands field is not set.
+const DefaultMaxShorthands = 1024
+
+// SysServerAuthenticator is a ServerAuthenticator which accepts the AUTH_NONE,
+// AUTH_SYS, and AUTH_SHORT flavors.  The caller of AUTH_NONE calls is nil and
+// the caller of AUTH_SYS and AUTH_SHORT calls is the *AuthSysParms of their
+// credentials.  Every call is given its own *AuthSysParms, so handlers may
+// modify them without affecting other calls.
+//
+// When IssueShort is set, the verifier of replies to AUTH_SYS calls is an
+// AUTH_SHORT shorthand credential which the client may use in place of its
+// full credentials.  Only the MaxShorthands most recently used shorthands are
+// remembered.  Calls with a shorthand which has been forgotten are denied with
+// AUTH_REJECTEDCRED, which causes clients such as SysAuthenticator to send
+// their full credentials again.
+//
+// The zero value of a SysServerAuthenticator is ready to use.
+type SysServerAuthenticator struct {
+	IssueShort bool
+
+	// MaxShorthands is the maximum number of shorthand credentials which
+	// are remembered.  A zero value uses DefaultMaxShorthands.
+	MaxShorthands int
+
+	mu sync.Mutex
+
+	// shorthands and issued house the issued shorthands keyed by the
+	// shorthand and by the encoded credentials they were issued for,
+	// respectively.  Each distinct set of credentials is issued a single
+	// shorthand.  lru orders the shorthands from the most to the least
+	// recently used so the least recently used is forgotten first.
+	shorthands map[string]*list.Element
+	issued     map[string]*list.Element
+	lru        *list.List
+	next       uint64
+}
+
+// shorthandEntry is a shorthand issued by a SysServerAuthenticator.
+type shorthandEntry struct {
+	shorthand string
+	body      string // Encoded credentials the shorthand was issued for
+	parms     AuthSysParms
+}
+
+// shorthand returns the shorthand credential for the passed encoded AUTH_SYS
+// credentials issuing a new one when needed.  A copy of the passed decoded
+// credentials is remembered, so the caller may continue to modify them.
+// Issuing a shorthand forgets the least recently used one once MaxShorthands
+// are remembered.
+func (a *SysServerAuthenticator) shorthand(body []byte, parms *AuthSysParms) []byte {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if e, ok := a.issued[string(body)]; ok {
+		a.lru.MoveToFront(e)
+		return []byte(e.Value.(*shorthandEntry).shorthand)
+	}
+	if a.lru == nil {
+		a.shorthands = make(map[string]*list.Element)
+		a.issued = make(map[string]*list.Element)
+		a.lru = list.New()
+	}
+	max := a.MaxShorthands
+	if max <= 0 {
+		max = DefaultMaxShorthands
+	}
+	for a.lru.Len() >= max {
+		old := a.lru.Remove(a.lru.Back()).(*shorthandEntry)
+		delete(a.shorthands, old.shorthand)
+		delete(a.issued, old.body)
+	}
+
+	a.next++
+	var s [8]byte
+	binary.BigEndian.PutUint64(s[:], a.next)
+	e := a.lru.PushFront(&shorthandEntry{
+		shorthand: string(s[:]),
+		body:      string(body),
+		parms:     *parms.clone(),
+	})
+	a.shorthands[string(s[:])] = e
+	a.issued[string(body)] = e
+	return s[:]
+}
+
+// lookupShorthand returns a copy of the credentials the passed shorthand was
+// issued for and marks it as the most recently used.  Each call returns a new
+// copy since handlers of concurrent calls may modify them.
+func (a *SysServerAuthenticator) lookupShorthand(shorthand []byte) (*AuthSysParms, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	e, ok := a.shorthands[string(shorthand)]
+	if !ok {
+		return nil, false
+	}
+	a.lru.MoveToFront(e)
+	return e.Value.(*shorthandEntry).parms.clone(), true
+}
+
+// Authenticate accepts AUTH_NONE calls, AUTH_SYS calls whose credentials can
+// be decoded, and AUTH_SHORT calls with a shorthand it issued.  The verifier of
+// AUTH_SYS and AUTH_SHORT calls must be AUTH_NONE.
+//
+// This is part of the ServerAuthenticator interface.
+func (a *SysServerAuthenticator) Authenticate(cred, verf OpaqueAuth) (interface{}, OpaqueAuth, AuthStat) {
+	switch cred.Flavor {
+	case AuthNone:
+		return nil, OpaqueAuth{}, AuthOK
+
+	case AuthSys:
+		if verf.Flavor != AuthNone {
+			return nil, OpaqueAuth{}, AuthBadVerf
+		}
+		var parms AuthSysParms
+		r := bytes.NewReader(cred.Body)
+		if _, err := xdr.Unmarshal(r, &parms); err != nil || r.Len() != 0 {
+			return nil, OpaqueAuth{}, AuthBadCred
+		}
+		if !a.IssueShort {
+			return &parms, OpaqueAuth{}, AuthOK
+		}
+		s := a.shorthand(cred.Body, &parms)
+		return &parms, OpaqueAuth{AuthShort, s}, AuthOK
+
+	case AuthShort:
+		if verf.Flavor != AuthNone {
+			return nil, OpaqueAuth{}, AuthBadVerf
+		}
+		parms, ok := a.lookupShorthand(cred.Body)
+		if !ok {
+			return nil, OpaqueAuth{}, AuthRejectedCred
+		}
+		return parms, OpaqueAuth{}, AuthOK
+	}
+
+	return nil, OpaqueAuth{}, AuthRejectedCred
+}
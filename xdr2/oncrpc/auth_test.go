@@ -0,0 +1,292 @@
+/*
+ * Copyright (c) 2014 Dave Collins <dave@davec.name>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package oncrpc_test
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/davecgh/go-xdr/xdr2"
+	. "github.com/davecgh/go-xdr/xdr2/oncrpc"
+)
+
+// TestAuthSysParms ensures AUTH_SYS credentials are encoded and decoded as
+// expected and that their limits are enforced.
+func TestAuthSysParms(t *testing.T) {
+	tests := []struct {
+		in   AuthSysParms
+		want []byte
+	}{
+		{
+			AuthSysParms{},
+			[]byte{
+				0x00, 0x00, 0x00, 0x00, // Stamp
+				0x00, 0x00, 0x00, 0x00, // MachineName
+				0x00, 0x00, 0x00, 0x00, // UID
+				0x00, 0x00, 0x00, 0x00, // GID
+				0x00, 0x00, 0x00, 0x00, // GIDs
+			},
+		},
+		{
+			AuthSysParms{1, "host", 1000, 100, []uint32{4, 24}},
+			[]byte{
+				0x00, 0x00, 0x00, 0x01, // Stamp
+				0x00, 0x00, 0x00, 0x04, 'h', 'o', 's', 't',
+				0x00, 0x00, 0x03, 0xE8, // UID
+				0x00, 0x00, 0x00, 0x64, // GID
+				0x00, 0x00, 0x00, 0x02, // GIDs
+				0x00, 0x00, 0x00, 0x04,
+				0x00, 0x00, 0x00, 0x18,
+			},
+		},
+	}
+
+	for i, test := range tests {
+		var buf bytes.Buffer
+		n, err := xdr.Marshal(&buf, &test.in)
+		if err != nil {
+			t.Errorf("Marshal #%d unexpected error: %v", i, err)
+			continue
+		}
+		if n != len(test.want) || !bytes.Equal(buf.Bytes(), test.want) {
+			t.Errorf("Marshal #%d\n got: %x\nwant: %x", i,
+				buf.Bytes(), test.want)
+			continue
+		}
+
+		var parms AuthSysParms
+		n, err = xdr.Unmarshal(bytes.NewReader(test.want), &parms)
+		if err != nil {
+			t.Errorf("Unmarshal #%d unexpected error: %v", i, err)
+			continue
+		}
+		if n != len(test.want) || !reflect.DeepEqual(parms, test.in) {
+			t.Errorf("Unmarshal #%d\n got: %+v\nwant: %+v", i,
+				parms, test.in)
+			continue
+		}
+	}
+
+	// Credentials which exceed the limits.
+	over := []AuthSysParms{
+		{MachineName: strings.Repeat("a", MaxMachineNameLen+1)},
+		{GIDs: make([]uint32, MaxGIDs+1)},
+	}
+	for i, parms := range over {
+		_, err := xdr.Marshal(&bytes.Buffer{}, &parms)
+		merr, ok := err.(*xdr.MarshalError)
+		if !ok || merr.ErrorCode != xdr.ErrOverflow {
+			t.Errorf("Marshal #%d got error %v want ErrOverflow", i,
+				err)
+			continue
+		}
+	}
+	overEncoded := [][]byte{
+		{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01, 0x00},
+		{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x11},
+	}
+	for i, in := range overEncoded {
+		var parms AuthSysParms
+		_, err := xdr.Unmarshal(bytes.NewReader(in), &parms)
+		uerr, ok := err.(*xdr.UnmarshalError)
+		if !ok || uerr.ErrorCode != xdr.ErrOverflow {
+			t.Errorf("Unmarshal #%d got error %v want ErrOverflow",
+				i, err)
+			continue
+		}
+	}
+}
+
+// encodeCred returns the passed AUTH_SYS credentials as an OpaqueAuth.
+func encodeCred(t *testing.T, parms *AuthSysParms) OpaqueAuth {
+	var buf bytes.Buffer
+	if _, err := xdr.Marshal(&buf, parms); err != nil {
+		t.Fatalf("Marshal unexpected error: %v", err)
+	}
+	return OpaqueAuth{AuthSys, buf.Bytes()}
+}
+
+// TestSysServerAuthenticator ensures a SysServerAuthenticator accepts and
+// rejects credentials as expected.
+func TestSysServerAuthenticator(t *testing.T) {
+	parms := AuthSysParms{1, "host", 1000, 100, []uint32{4}}
+	cred := encodeCred(t, &parms)
+	a := SysServerAuthenticator{IssueShort: true}
+
+	// The shorthand issued for AUTH_SYS credentials identifies the same
+	// caller and the same shorthand is issued for the same credentials.
+	caller, verf, stat := a.Authenticate(cred, OpaqueAuth{})
+	if stat != AuthOK || !reflect.DeepEqual(caller, &parms) {
+		t.Fatalf("Authenticate got %v, %v want %v, %v", caller, stat,
+			&parms, AuthOK)
+	}
+	if verf.Flavor != AuthShort {
+		t.Fatalf("Authenticate got verifier %v want %v", verf.Flavor,
+			AuthShort)
+	}
+	caller.(*AuthSysParms).GIDs[0] = 6
+	_, verf2, _ := a.Authenticate(cred, OpaqueAuth{})
+	if !reflect.DeepEqual(verf2, verf) {
+		t.Errorf("Authenticate got verifier %v want %v", verf2, verf)
+	}
+	caller, _, stat = a.Authenticate(verf, OpaqueAuth{})
+	if stat != AuthOK || !reflect.DeepEqual(caller, &parms) {
+		t.Errorf("Authenticate got %v, %v want %v, %v", caller, stat,
+			&parms, AuthOK)
+	}
+
+	// Modifying the credentials given to one call must not affect those
+	// given to later calls with the shorthand.
+	caller.(*AuthSysParms).GIDs[0] = 5
+	caller.(*AuthSysParms).UID = 0
+	caller, _, _ = a.Authenticate(verf, OpaqueAuth{})
+	if !reflect.DeepEqual(caller, &parms) {
+		t.Errorf("Authenticate after modification got %v want %v",
+			caller, &parms)
+	}
+
+	tests := []struct {
+		cred OpaqueAuth
+		verf OpaqueAuth
+		want AuthStat
+	}{
+		{OpaqueAuth{}, OpaqueAuth{}, AuthOK},
+		{cred, OpaqueAuth{AuthSys, nil}, AuthBadVerf},
+		{OpaqueAuth{AuthSys, []byte{0x00}}, OpaqueAuth{}, AuthBadCred},
+		{OpaqueAuth{AuthSys, append(cred.Body, 0, 0, 0, 0)},
+			OpaqueAuth{}, AuthBadCred},
+		{OpaqueAuth{AuthShort, []byte{0x01}}, OpaqueAuth{},
+			AuthRejectedCred},
+		{verf, OpaqueAuth{AuthSys, nil}, AuthBadVerf},
+		{OpaqueAuth{AuthDH, nil}, OpaqueAuth{}, AuthRejectedCred},
+	}
+	for i, test := range tests {
+		_, _, stat := a.Authenticate(test.cred, test.verf)
+		if stat != test.want {
+			t.Errorf("Authenticate #%d got %v want %v", i, stat,
+				test.want)
+			continue
+		}
+	}
+}
+
+// TestSysServerAuthenticatorLimit ensures a SysServerAuthenticator forgets the
+// least recently used shorthand once it remembers MaxShorthands of them.
+func TestSysServerAuthenticatorLimit(t *testing.T) {
+	a := SysServerAuthenticator{IssueShort: true, MaxShorthands: 2}
+	var creds, shorthands []OpaqueAuth
+	for uid := uint32(0); uid < 3; uid++ {
+		creds = append(creds, encodeCred(t, &AuthSysParms{UID: uid}))
+		_, verf, stat := a.Authenticate(creds[uid], OpaqueAuth{})
+		if stat != AuthOK {
+			t.Fatalf("Authenticate #%d got %v want %v", uid, stat,
+				AuthOK)
+		}
+		shorthands = append(shorthands, verf)
+
+		// Use the first shorthand so the second is the least recently
+		// used when the third is issued.
+		if uid == 1 {
+			a.Authenticate(shorthands[0], OpaqueAuth{})
+		}
+	}
+
+	wants := []AuthStat{AuthOK, AuthRejectedCred, AuthOK}
+	for i, want := range wants {
+		caller, _, stat := a.Authenticate(shorthands[i], OpaqueAuth{})
+		if stat != want {
+			t.Errorf("Authenticate #%d got %v want %v", i, stat, want)
+			continue
+		}
+		if stat == AuthOK && caller.(*AuthSysParms).UID != uint32(i) {
+			t.Errorf("Authenticate #%d got UID %d want %d", i,
+				caller.(*AuthSysParms).UID, i)
+			continue
+		}
+	}
+
+	// The forgotten credentials are issued a new shorthand.
+	_, verf, _ := a.Authenticate(creds[1], OpaqueAuth{})
+	if reflect.DeepEqual(verf, shorthands[1]) {
+		t.Errorf("Authenticate got forgotten shorthand %v", verf)
+	}
+	if _, _, stat := a.Authenticate(verf, OpaqueAuth{}); stat != AuthOK {
+		t.Errorf("Authenticate got %v want %v", stat, AuthOK)
+	}
+}
+
+// sysOnly is a ServerAuthenticator which only accepts AUTH_SYS credentials.
+type sysOnly struct {
+	SysServerAuthenticator
+}
+
+func (a *sysOnly) Authenticate(cred, verf OpaqueAuth) (interface{}, OpaqueAuth, AuthStat) {
+	if cred.Flavor == AuthNone {
+		return nil, OpaqueAuth{}, AuthTooWeak
+	}
+	return a.SysServerAuthenticator.Authenticate(cred, verf)
+}
+
+// TestAuth ensures clients and servers authenticate calls with their
+// authenticators including the use of AUTH_SHORT shorthand credentials.
+func TestAuth(t *testing.T) {
+	s := &Server{Auth: &sysOnly{SysServerAuthenticator{IssueShort: true}}}
+	s.Handle(testProg, 1, 1, func(r *Request) (interface{}, error) {
+		parms := r.Auth.(*AuthSysParms)
+		return [2]uint32{uint32(r.Cred.Flavor), parms.UID}, nil
+	})
+	clients, done := serverClients(t, s)
+	defer done()
+
+	for i, c := range clients {
+		// Calls without credentials are denied.
+		err := c.Call(testProg, 1, 1, nil, nil)
+		want := &RejectError{Stat: AuthError, AuthStat: AuthTooWeak}
+		if !reflect.DeepEqual(err, want) {
+			t.Errorf("Call #%d got error %v want %v", i, err, want)
+			continue
+		}
+
+		// The first call uses the full credentials and later calls use
+		// the shorthand issued by the server.  The client falls back
+		// to the full credentials when the shorthand is rejected.
+		auth := &SysAuthenticator{Parms: AuthSysParms{UID: 1000}}
+		c.Auth = auth
+		flavors := []AuthFlavor{AuthSys, AuthShort, AuthSys}
+		for j, flavor := range flavors {
+			if j == 2 {
+				auth.Validate(OpaqueAuth{AuthShort, []byte{0x01}})
+			}
+			var got [2]uint32
+			if err := c.Call(testProg, 1, 1, nil, &got); err != nil {
+				t.Errorf("Call #%d.%d unexpected error: %v", i,
+					j, err)
+				continue
+			}
+			want := [2]uint32{uint32(flavor), 1000}
+			if got != want {
+				t.Errorf("Call #%d.%d got %v want %v", i, j,
+					got, want)
+				continue
+			}
+		}
+	}
+}
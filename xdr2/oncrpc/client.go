@@ -0,0 +1,361 @@
+/*
+ * Copyright (c) 2014 Dave Collins <dave@davec.name>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package oncrpc
+
+import (
+	"bytes"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/davecgh/go-xdr/xdr2"
+)
+
+const (
+	// MaxRecordSize is the maximum number of bytes a message received over
+	// a stream transport may contain.
+	MaxRecordSize = 16 * 1024 * 1024
+
+	// MaxDatagramSize is the maximum number of bytes a message received
+	// over a datagram transport may contain.
+	MaxDatagramSize = 64 * 1024
+
+	// DefaultRetransmit is the interval after which a call made over a
+	// datagram transport is retransmitted when the Retransmit field of the
+	// Client is not set.
+	DefaultRetransmit = time.Second
+)
+
+// isDatagram returns whether or not the passed connection is a datagram
+// transport on which each read and write is a single message.
+func isDatagram(conn net.Conn) bool {
+	switch conn.LocalAddr().Network() {
+	case "udp", "udp4", "udp6", "unixgram":
+		return true
+	}
+	return false
+}
+
+// callReply is the reply to a call or the error which prevented one from
+// being received.
+type callReply struct {
+	reply ReplyBody
+	body  []byte // Encoded procedure results
+	err   error
+}
+
+// Client makes remote procedure calls over a connection.  Calls may be made
+// concurrently from multiple goroutines and are matched with their replies by
+// transaction ID (XID), so replies may arrive in any order.
+//
+// Messages are framed with record marking over stream transports such as TCP
+// and sent as individual datagrams over datagram transports such as UDP.
+// Calls over datagram transports are retransmitted until a reply is received
+// since datagrams may be lost, so the Timeout field should be set for them.
+type Client struct {
+	// Timeout is the maximum amount of time a call waits for its reply.  A
+	// zero value waits indefinitely.
+	Timeout time.Duration
+
+	// Retransmit is the interval after which a call over a datagram
+	// transport is sent again while waiting for its reply.  A zero value
+	// uses DefaultRetransmit.
+	Retransmit time.Duration
+
+	// Auth provides the credentials of calls and validates the verifiers
+	// of their replies.  A nil value uses AUTH_NONE.
+	Auth Authenticator
+
+	conn     net.Conn
+	datagram bool
+
+	// writeMu serializes writes of messages to the connection.
+	writeMu sync.Mutex
+	rw      *xdr.RecordWriter
+
+	// mu protects the fields which follow.  pending houses the channel the
+	// reply of each outstanding call is delivered on keyed by XID.  err is
+	// set once the client can no longer be used.
+	mu      sync.Mutex
+	xid     uint32
+	pending map[uint32]chan *callReply
+	err     error
+}
+
+// send writes the passed encoded message to the connection.  The error which
+// stopped the client is returned in place of write errors once it is set.
+func (c *Client) send(msg []byte) error {
+	c.writeMu.Lock()
+	var err error
+	if c.datagram {
+		_, err = c.conn.Write(msg)
+	} else if _, err = c.rw.Write(msg); err == nil {
+		err = c.rw.Flush()
+	}
+	c.writeMu.Unlock()
+	if err == nil {
+		return nil
+	}
+
+	c.mu.Lock()
+	if c.err != nil {
+		err = c.err
+	}
+	c.mu.Unlock()
+	return err
+}
+
+// forget removes the passed XID from the outstanding calls.
+func (c *Client) forget(xid uint32) {
+	c.mu.Lock()
+	delete(c.pending, xid)
+	c.mu.Unlock()
+}
+
+// readReplies reads replies from the connection and delivers each of them to
+// the outstanding call with the same XID until the connection fails or is
+// closed.  Messages which can't be decoded or do not match an outstanding
+// call, such as duplicate replies to retransmitted calls, are discarded.  Any
+// error reading from the connection, including errors such as ICMP port
+// unreachable reported for datagram transports, stops the client and fails
+// all outstanding calls with it.
+func (c *Client) readReplies() {
+	var rr *xdr.RecordReader
+	var buf []byte
+	if c.datagram {
+		buf = make([]byte, MaxDatagramSize)
+	} else {
+		rr = xdr.NewRecordReader(c.conn, MaxRecordSize)
+	}
+
+	var err error
+	for {
+		var data []byte
+		if c.datagram {
+			var n int
+			n, err = c.conn.Read(buf)
+			if err != nil {
+				break
+			}
+			data = append([]byte(nil), buf[:n]...)
+		} else {
+			data, err = rr.ReadRecord()
+			if err != nil {
+				break
+			}
+		}
+
+		var msg Message
+		r := bytes.NewReader(data)
+		if _, err := xdr.Unmarshal(r, &msg); err != nil {
+			continue
+		}
+		if msg.Type != Reply {
+			continue
+		}
+
+		c.mu.Lock()
+		ch, ok := c.pending[msg.XID]
+		delete(c.pending, msg.XID)
+		c.mu.Unlock()
+		if ok {
+			body := data[len(data)-r.Len():]
+			ch <- &callReply{reply: msg.Reply, body: body}
+		}
+	}
+
+	// Fail all outstanding calls.
+	c.mu.Lock()
+	if c.err == nil {
+		c.err = err
+	}
+	for xid, ch := range c.pending {
+		ch <- &callReply{err: c.err}
+		delete(c.pending, xid)
+	}
+	c.mu.Unlock()
+}
+
+// auth returns the Authenticator used for calls.
+func (c *Client) auth() Authenticator {
+	if c.Auth == nil {
+		return NoneAuthenticator{}
+	}
+	return c.Auth
+}
+
+// roundTrip sends a call with the passed header fields and arguments and
+// waits for its reply.
+func (c *Client) roundTrip(call *CallBody, args interface{}) (*callReply, error) {
+	c.mu.Lock()
+	if c.err != nil {
+		err := c.err
+		c.mu.Unlock()
+		return nil, err
+	}
+	c.xid++
+	xid := c.xid
+	ch := make(chan *callReply, 1)
+	c.pending[xid] = ch
+	c.mu.Unlock()
+
+	// Encode the call header followed by the arguments.
+	var buf bytes.Buffer
+	msg := Message{XID: xid, Type: Call, Call: *call}
+	if _, err := xdr.Marshal(&buf, &msg); err != nil {
+		c.forget(xid)
+		return nil, err
+	}
+	if args != nil {
+		if _, err := xdr.Marshal(&buf, args); err != nil {
+			c.forget(xid)
+			return nil, err
+		}
+	}
+	if err := c.send(buf.Bytes()); err != nil {
+		c.forget(xid)
+		return nil, err
+	}
+
+	var timeout, retransmit <-chan time.Time
+	if c.Timeout > 0 {
+		timer := time.NewTimer(c.Timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	if c.datagram {
+		interval := c.Retransmit
+		if interval <= 0 {
+			interval = DefaultRetransmit
+		}
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		retransmit = ticker.C
+	}
+
+	for {
+		select {
+		case r := <-ch:
+			if r.err != nil {
+				return nil, r.err
+			}
+			return r, nil
+
+		case <-retransmit:
+			if err := c.send(buf.Bytes()); err != nil {
+				c.forget(xid)
+				return nil, err
+			}
+
+		case <-timeout:
+			c.forget(xid)
+			return nil, ErrTimeout
+		}
+	}
+}
+
+// Call calls the passed procedure of the passed program and version with the
+// passed arguments and waits for its reply.  The arguments are encoded and
+// the results are decoded into result with xdr.Marshal and xdr.Unmarshal,
+// respectively, so result must be a pointer.  Either may be nil for
+// procedures which do not take arguments or return results.
+//
+// The credentials of the call are provided by the Authenticator of the Client
+// which also validates the verifier of the reply.  A call denied because
+// authentication failed is retried once when the Authenticator refreshes its
+// credentials.
+//
+// An *AcceptError or *RejectError is returned when the server replies that
+// the call was not executed successfully.  ErrTimeout is returned when no
+// reply is received within the timeout of the Client.
+func (c *Client) Call(prog, vers, proc uint32, args, result interface{}) error {
+	auth := c.auth()
+	for refreshed := false; ; refreshed = true {
+		cred, verf, err := auth.Credentials()
+		if err != nil {
+			return err
+		}
+		call := CallBody{
+			RPCVers: RPCVersion,
+			Prog:    prog,
+			Vers:    vers,
+			Proc:    proc,
+			Cred:    cred,
+			Verf:    verf,
+		}
+		r, err := c.roundTrip(&call, args)
+		if err != nil {
+			return err
+		}
+		if err := replyError(&r.reply); err != nil {
+			rej, ok := err.(*RejectError)
+			if ok && rej.Stat == AuthError && !refreshed &&
+				auth.Refresh(rej.AuthStat) {
+
+				continue
+			}
+			return err
+		}
+		if err := auth.Validate(r.reply.Accepted.Verf); err != nil {
+			return err
+		}
+		if result != nil {
+			_, err := xdr.Unmarshal(bytes.NewReader(r.body), result)
+			return err
+		}
+		return nil
+	}
+}
+
+// Close closes the underlying connection.  Outstanding and future calls fail
+// with ErrClientClosed.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	if c.err == nil {
+		c.err = ErrClientClosed
+	}
+	c.mu.Unlock()
+	return c.conn.Close()
+}
+
+// NewClient returns a Client which makes calls over the passed connection.
+// Whether the connection is a stream or datagram transport is determined by
+// the network of its local address.  The Client takes ownership of the
+// connection and closes it when the Client is closed.
+func NewClient(conn net.Conn) *Client {
+	c := &Client{
+		conn:     conn,
+		datagram: isDatagram(conn),
+		xid:      uint32(time.Now().UnixNano()),
+		pending:  make(map[uint32]chan *callReply),
+	}
+	if !c.datagram {
+		c.rw = xdr.NewRecordWriter(conn)
+	}
+	go c.readReplies()
+	return c
+}
+
+// Dial connects to the passed address on the passed network, such as "tcp"
+// or "udp", and returns a Client which makes calls over the connection.
+func Dial(network, address string) (*Client, error) {
+	conn, err := net.Dial(network, address)
+	if err != nil {
+		return nil, err
+	}
+	return NewClient(conn), nil
+}
@@ -0,0 +1,324 @@
+/*
+ * Copyright (c) 2014 Dave Collins <dave@davec.name>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package oncrpc_test
+
+import (
+	"bytes"
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/davecgh/go-xdr/xdr2"
+	. "github.com/davecgh/go-xdr/xdr2/oncrpc"
+)
+
+// Procedures of the test program handled by testReply.
+const (
+	testProg      = 0x20000001
+	testVers      = 1
+	procAdd       = 1 // Replies with the sum of two unsigned integers
+	procSlow      = 2 // Replies with its argument after a delay
+	procUnavail   = 3 // Replies with PROC_UNAVAIL
+	procNoReply   = 4 // Never replies
+	procAuthError = 5 // Denies the call
+)
+
+// testReply returns the encoded reply to the passed encoded call or nil when
+// the call should not be replied to.
+func testReply(call []byte) []byte {
+	var msg Message
+	r := bytes.NewReader(call)
+	if _, err := xdr.Unmarshal(r, &msg); err != nil {
+		return nil
+	}
+
+	reply := Message{XID: msg.XID, Type: Reply}
+	var results interface{}
+	switch msg.Call.Proc {
+	case procAdd:
+		var args [2]uint32
+		if _, err := xdr.Unmarshal(r, &args); err != nil {
+			return nil
+		}
+		results = args[0] + args[1]
+
+	case procSlow:
+		var arg uint32
+		if _, err := xdr.Unmarshal(r, &arg); err != nil {
+			return nil
+		}
+		time.Sleep(50 * time.Millisecond)
+		results = arg
+
+	case procUnavail:
+		reply.Reply.Accepted.Stat = ProcUnavail
+
+	case procAuthError:
+		reply.Reply.Stat = MsgDenied
+		reply.Reply.Rejected.Stat = AuthError
+		reply.Reply.Rejected.AuthStat = AuthTooWeak
+
+	default:
+		return nil
+	}
+
+	var buf bytes.Buffer
+	if _, err := xdr.Marshal(&buf, &reply); err != nil {
+		return nil
+	}
+	if results != nil {
+		if _, err := xdr.Marshal(&buf, results); err != nil {
+			return nil
+		}
+	}
+	return buf.Bytes()
+}
+
+// serveStream accepts connections from the passed listener and replies to the
+// calls on each connection with testReply concurrently.
+func serveStream(ln net.Listener) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			var mu sync.Mutex
+			rr := xdr.NewRecordReader(conn, MaxRecordSize)
+			rw := xdr.NewRecordWriter(conn)
+			for {
+				call, err := rr.ReadRecord()
+				if err != nil {
+					return
+				}
+				go func() {
+					reply := testReply(call)
+					if reply == nil {
+						return
+					}
+					mu.Lock()
+					rw.Write(reply)
+					rw.Flush()
+					mu.Unlock()
+				}()
+			}
+		}()
+	}
+}
+
+// serveDatagram replies to the calls received on the passed connection with
+// testReply concurrently.  The first datagram of each call is dropped to
+// exercise retransmission when the passed flag is set.
+func serveDatagram(pc net.PacketConn, drop bool) {
+	seen := make(map[string]bool)
+	buf := make([]byte, MaxDatagramSize)
+	for {
+		n, addr, err := pc.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		call := append([]byte(nil), buf[:n]...)
+		if drop && !seen[string(call)] {
+			seen[string(call)] = true
+			continue
+		}
+		go func() {
+			if reply := testReply(call); reply != nil {
+				pc.WriteTo(reply, addr)
+			}
+		}()
+	}
+}
+
+// testClients returns clients connected to test servers over TCP and UDP
+// along with a function which shuts them down.
+func testClients(t *testing.T, drop bool) ([]*Client, func()) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen unexpected error: %v", err)
+	}
+	go serveStream(ln)
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		ln.Close()
+		t.Fatalf("ListenPacket unexpected error: %v", err)
+	}
+	go serveDatagram(pc, drop)
+
+	var clients []*Client
+	for _, addr := range []net.Addr{ln.Addr(), pc.LocalAddr()} {
+		c, err := Dial(addr.Network(), addr.String())
+		if err != nil {
+			t.Fatalf("Dial unexpected error: %v", err)
+		}
+		c.Timeout = 2 * time.Second
+		c.Retransmit = 20 * time.Millisecond
+		clients = append(clients, c)
+	}
+
+	return clients, func() {
+		for _, c := range clients {
+			c.Close()
+		}
+		ln.Close()
+		pc.Close()
+	}
+}
+
+// TestClientCall ensures calls made by a Client over stream and datagram
+// transports are encoded and their replies decoded as expected.
+func TestClientCall(t *testing.T) {
+	clients, done := testClients(t, true)
+	defer done()
+
+	tests := []struct {
+		proc uint32
+		args interface{}
+		want interface{} // Expected results or error
+	}{
+		{procAdd, [2]uint32{1, 2}, uint32(3)},
+		{procUnavail, nil, &AcceptError{Stat: ProcUnavail}},
+		{procAuthError, nil, &RejectError{Stat: AuthError,
+			AuthStat: AuthTooWeak}},
+	}
+
+	for _, c := range clients {
+		for i, test := range tests {
+			var result uint32
+			err := c.Call(testProg, testVers, test.proc, test.args,
+				&result)
+			if wantErr, ok := test.want.(error); ok {
+				if !reflect.DeepEqual(err, wantErr) {
+					t.Errorf("Call #%d got error %v want %v",
+						i, err, wantErr)
+				}
+				continue
+			}
+			if err != nil {
+				t.Errorf("Call #%d unexpected error: %v", i, err)
+				continue
+			}
+			if result != test.want {
+				t.Errorf("Call #%d got %v want %v", i, result,
+					test.want)
+				continue
+			}
+		}
+	}
+}
+
+// TestClientMultiplex ensures concurrent calls are matched with their replies
+// when the replies arrive out of order.
+func TestClientMultiplex(t *testing.T) {
+	clients, done := testClients(t, false)
+	defer done()
+
+	for _, c := range clients {
+		var wg sync.WaitGroup
+		for i := uint32(0); i < 20; i++ {
+			wg.Add(1)
+			go func(i uint32) {
+				defer wg.Done()
+				proc := uint32(procSlow)
+				args := interface{}(i)
+				if i%2 == 0 {
+					proc = procAdd
+					args = [2]uint32{i, 0}
+				}
+				var result uint32
+				err := c.Call(testProg, testVers, proc, args,
+					&result)
+				if err != nil {
+					t.Errorf("Call #%d unexpected error: %v",
+						i, err)
+					return
+				}
+				if result != i {
+					t.Errorf("Call #%d got %d want %d", i,
+						result, i)
+				}
+			}(i)
+		}
+		wg.Wait()
+	}
+}
+
+// TestClientErrors ensures calls which time out or are outstanding when the
+// Client is closed fail with the expected errors.
+func TestClientErrors(t *testing.T) {
+	clients, done := testClients(t, false)
+	defer done()
+
+	for _, c := range clients {
+		c.Timeout = 50 * time.Millisecond
+		err := c.Call(testProg, testVers, procNoReply, nil, nil)
+		if err != ErrTimeout {
+			t.Errorf("Call got error %v want %v", err, ErrTimeout)
+		}
+
+		c.Timeout = 0
+		errs := make(chan error)
+		go func() {
+			errs <- c.Call(testProg, testVers, procNoReply, nil, nil)
+		}()
+		time.Sleep(20 * time.Millisecond)
+		c.Close()
+		if err := <-errs; err != ErrClientClosed {
+			t.Errorf("Call got error %v want %v", err,
+				ErrClientClosed)
+		}
+		err = c.Call(testProg, testVers, procAdd, [2]uint32{}, nil)
+		if err != ErrClientClosed {
+			t.Errorf("Call got error %v want %v", err,
+				ErrClientClosed)
+		}
+	}
+}
+
+// TestClientReadError ensures a datagram Client stops and fails its calls when
+// reading from the connection fails rather than retrying the read.
+func TestClientReadError(t *testing.T) {
+	// Dial an address nothing listens on so the calls are answered with
+	// ICMP port unreachable.
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("ListenPacket unexpected error: %v", err)
+	}
+	addr := pc.LocalAddr()
+	pc.Close()
+
+	c, err := Dial(addr.Network(), addr.String())
+	if err != nil {
+		t.Fatalf("Dial unexpected error: %v", err)
+	}
+	defer c.Close()
+	c.Timeout = 2 * time.Second
+	c.Retransmit = 20 * time.Millisecond
+
+	err = c.Call(testProg, testVers, procAdd, [2]uint32{}, nil)
+	if err == nil || err == ErrTimeout {
+		t.Fatalf("Call got error %v want read error", err)
+	}
+	err2 := c.Call(testProg, testVers, procAdd, [2]uint32{}, nil)
+	if err2 != err {
+		t.Errorf("Call got error %v want %v", err2, err)
+	}
+}
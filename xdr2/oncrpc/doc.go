@@ -0,0 +1,104 @@
+/*
+ * Copyright (c) 2014 Dave Collins <dave@davec.name>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+/*
+Package oncrpc implements ONC RPC version 2 as specified by RFC 5531 on top of
+the xdr package.
+
+The RPC message headers are modeled by the Message type and the types it is
+composed of.  Their unions are described with the union, unioncase, and default
+struct tags and their size limits with the max struct tag, so they are encoded
+and decoded with the xdr package the same as any other value.  The arguments of a call and the results of a reply immediately follow
+their message header.
+
+Client
+
+A Client makes calls over a stream transport such as TCP, where each message is
+framed with record marking, or over a datagram transport such as UDP, where each
+message is a single datagram.  The arguments are encoded with xdr.Marshal and
+the results are decoded into a caller provided value with xdr.Unmarshal:
+
+	c, err := oncrpc.Dial("tcp", "server:2049")
+	// Error check elided
+	defer c.Close()
+
+	var res ReadDirResult
+	err = c.Call(prog, vers, proc, &ReadDirArgs{Dir: "/"}, &res)
+	// Error check elided
+
+Calls may be made concurrently from multiple goroutines.  Each call is assigned
+a unique transaction ID (XID) which the Client uses to match it with its reply.
+
+Server
+
+A Server dispatches calls to the handlers registered for their program, version,
+and procedure numbers.  Handlers decode the arguments of the call with the Args
+method of the Request and return the results to reply with:
+
+	var s oncrpc.Server
+	s.Handle(prog, vers, proc, func(r *oncrpc.Request) (interface{}, error) {
+		var args ReadDirArgs
+		if err := r.Args(&args); err != nil {
+			return nil, err
+		}
+		return readDir(&args), nil
+	})
+
+	ln, err := net.Listen("tcp", ":2049")
+	// Error check elided
+	go s.Serve(ln)
+
+Calls to programs, versions, and procedures without a registered handler and
+calls whose arguments can't be decoded are replied to with the appropriate
+status automatically, and handlers which panic are replied to with SYSTEM_ERR.
+The arguments are decoded with the resource limits in the ArgsOptions field of
+the Server, which by default limit the memory the decoded arguments may use to
+DefaultArgsAllocFactor times the size of the call, and the number of calls
+received over a connection that are handled at once is limited by its
+MaxConcurrentCalls field.
+
+Authentication
+
+The credentials sent with calls are provided by the Authenticator in the Auth
+field of the Client, which also validates the verifiers of replies.  Calls use
+AUTH_NONE when it is nil.  A SysAuthenticator identifies the caller with
+AUTH_SYS credentials and switches to the AUTH_SHORT shorthand credential when
+the server issues one:
+
+	c.Auth = &oncrpc.SysAuthenticator{Parms: oncrpc.AuthSysParms{
+		MachineName: hostname,
+		UID:         uint32(os.Getuid()),
+		GID:         uint32(os.Getgid()),
+	}}
+
+Likewise, the ServerAuthenticator in the Auth field of the Server checks the
+credentials of calls before they are dispatched.  Calls it rejects are denied
+with AUTH_ERROR and the caller it authenticates is available to handlers in the
+Auth field of the Request.  A SysServerAuthenticator accepts AUTH_NONE,
+AUTH_SYS, and AUTH_SHORT credentials.
+
+The machine name of AUTH_SYS credentials is limited to 255 bytes and the group
+IDs to 16 entries.  These limits are enforced when encoding and decoding the
+AuthSysParms type.
+
+Errors
+
+A call which the server accepted but did not execute successfully returns an
+*AcceptError and a call which the server denied returns a *RejectError.  Errors
+encoding or decoding messages are the MarshalError and UnmarshalError types of
+the xdr package.
+*/
+package oncrpc
@@ -0,0 +1,90 @@
+/*
+ * Copyright (c) 2014 Dave Collins <dave@davec.name>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package oncrpc
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrClientClosed is returned by calls made on a Client which has been
+	// closed.
+	ErrClientClosed = errors.New("oncrpc: client is closed")
+
+	// ErrTimeout is returned by calls which did not receive a reply within
+	// the timeout of the Client.
+	ErrTimeout = errors.New("oncrpc: call timed out")
+
+	// ErrNoReply may be returned by a Handler to prevent the call from
+	// being replied to.
+	ErrNoReply = errors.New("oncrpc: no reply")
+)
+
+// AcceptError describes a call which the server accepted but did not execute
+// successfully.
+type AcceptError struct {
+	Stat     AcceptStat   // Status of the call
+	Mismatch MismatchInfo // Supported versions for ProgMismatch
+}
+
+// Error satisfies the error interface and prints human-readable errors.
+func (e *AcceptError) Error() string {
+	if e.Stat == ProgMismatch {
+		return fmt.Sprintf("oncrpc: call failed with %v - supported "+
+			"versions %d to %d", e.Stat, e.Mismatch.Low,
+			e.Mismatch.High)
+	}
+	return fmt.Sprintf("oncrpc: call failed with %v", e.Stat)
+}
+
+// RejectError describes a call which the server denied.
+type RejectError struct {
+	Stat     RejectStat   // Reason the call was denied
+	Mismatch MismatchInfo // Supported RPC versions for RPCMismatch
+	AuthStat AuthStat     // Reason authentication failed for AuthError
+}
+
+// Error satisfies the error interface and prints human-readable errors.
+func (e *RejectError) Error() string {
+	if e.Stat == RPCMismatch {
+		return fmt.Sprintf("oncrpc: call denied with %v - supported "+
+			"versions %d to %d", e.Stat, e.Mismatch.Low,
+			e.Mismatch.High)
+	}
+	return fmt.Sprintf("oncrpc: call denied with %v - %v", e.Stat,
+		e.AuthStat)
+}
+
+// replyError returns the error described by the passed reply or nil when the
+// call succeeded.
+func replyError(r *ReplyBody) error {
+	if r.Stat == MsgDenied {
+		return &RejectError{
+			Stat:     r.Rejected.Stat,
+			Mismatch: r.Rejected.Mismatch,
+			AuthStat: r.Rejected.AuthStat,
+		}
+	}
+	if r.Accepted.Stat != Success {
+		return &AcceptError{
+			Stat:     r.Accepted.Stat,
+			Mismatch: r.Accepted.Mismatch,
+		}
+	}
+	return nil
+}
@@ -0,0 +1,288 @@
+/*
+ * Copyright (c) 2014 Dave Collins <dave@davec.name>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package oncrpc
+
+import "fmt"
+
+const (
+	// RPCVersion is the version of the RPC protocol implemented by this
+	// package.
+	RPCVersion = 2
+
+	// MaxAuthBytes is the maximum number of bytes the body of an
+	// authentication credential or verifier may contain.
+	MaxAuthBytes = 400
+)
+
+// AuthFlavor identifies an authentication mechanism.
+type AuthFlavor int32
+
+// Authentication flavors defined by RFC 5531.
+const (
+	AuthNone  AuthFlavor = 0
+	AuthSys   AuthFlavor = 1
+	AuthShort AuthFlavor = 2
+	AuthDH    AuthFlavor = 3
+	RPCSECGSS AuthFlavor = 6
+)
+
+var authFlavorNames = map[AuthFlavor]string{
+	AuthNone:  "AUTH_NONE",
+	AuthSys:   "AUTH_SYS",
+	AuthShort: "AUTH_SHORT",
+	AuthDH:    "AUTH_DH",
+	RPCSECGSS: "RPCSEC_GSS",
+}
+
+// String returns the AuthFlavor as its name in RFC 5531.
+func (f AuthFlavor) String() string {
+	if s, ok := authFlavorNames[f]; ok {
+		return s
+	}
+	return fmt.Sprintf("AuthFlavor(%d)", int32(f))
+}
+
+// MsgType identifies whether a message is a call or a reply.
+type MsgType int32
+
+// Message types defined by RFC 5531.
+const (
+	Call  MsgType = 0
+	Reply MsgType = 1
+)
+
+var msgTypeNames = map[MsgType]string{
+	Call:  "CALL",
+	Reply: "REPLY",
+}
+
+// String returns the MsgType as its name in RFC 5531.
+func (t MsgType) String() string {
+	if s, ok := msgTypeNames[t]; ok {
+		return s
+	}
+	return fmt.Sprintf("MsgType(%d)", int32(t))
+}
+
+// ReplyStat identifies whether a call was accepted or denied.
+type ReplyStat int32
+
+// Reply statuses defined by RFC 5531.
+const (
+	MsgAccepted ReplyStat = 0
+	MsgDenied   ReplyStat = 1
+)
+
+var replyStatNames = map[ReplyStat]string{
+	MsgAccepted: "MSG_ACCEPTED",
+	MsgDenied:   "MSG_DENIED",
+}
+
+// String returns the ReplyStat as its name in RFC 5531.
+func (s ReplyStat) String() string {
+	if name, ok := replyStatNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("ReplyStat(%d)", int32(s))
+}
+
+// AcceptStat is the status of a call which was accepted.
+type AcceptStat int32
+
+// Accepted call statuses defined by RFC 5531.
+const (
+	Success      AcceptStat = 0
+	ProgUnavail  AcceptStat = 1
+	ProgMismatch AcceptStat = 2
+	ProcUnavail  AcceptStat = 3
+	GarbageArgs  AcceptStat = 4
+	SystemErr    AcceptStat = 5
+)
+
+var acceptStatNames = map[AcceptStat]string{
+	Success:      "SUCCESS",
+	ProgUnavail:  "PROG_UNAVAIL",
+	ProgMismatch: "PROG_MISMATCH",
+	ProcUnavail:  "PROC_UNAVAIL",
+	GarbageArgs:  "GARBAGE_ARGS",
+	SystemErr:    "SYSTEM_ERR",
+}
+
+// String returns the AcceptStat as its name in RFC 5531.
+func (s AcceptStat) String() string {
+	if name, ok := acceptStatNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("AcceptStat(%d)", int32(s))
+}
+
+// RejectStat is the reason a call was denied.
+type RejectStat int32
+
+// Denied call reasons defined by RFC 5531.
+const (
+	RPCMismatch RejectStat = 0
+	AuthError   RejectStat = 1
+)
+
+var rejectStatNames = map[RejectStat]string{
+	RPCMismatch: "RPC_MISMATCH",
+	AuthError:   "AUTH_ERROR",
+}
+
+// String returns the RejectStat as its name in RFC 5531.
+func (s RejectStat) String() string {
+	if name, ok := rejectStatNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("RejectStat(%d)", int32(s))
+}
+
+// AuthStat is the reason authentication of a call failed.
+type AuthStat int32
+
+// Authentication failure reasons defined by RFC 5531.
+const (
+	AuthOK           AuthStat = 0
+	AuthBadCred      AuthStat = 1
+	AuthRejectedCred AuthStat = 2
+	AuthBadVerf      AuthStat = 3
+	AuthRejectedVerf AuthStat = 4
+	AuthTooWeak      AuthStat = 5
+	AuthInvalidResp  AuthStat = 6
+	AuthFailed       AuthStat = 7
+)
+
+var authStatNames = map[AuthStat]string{
+	AuthOK:           "AUTH_OK",
+	AuthBadCred:      "AUTH_BADCRED",
+	AuthRejectedCred: "AUTH_REJECTEDCRED",
+	AuthBadVerf:      "AUTH_BADVERF",
+	AuthRejectedVerf: "AUTH_REJECTEDVERF",
+	AuthTooWeak:      "AUTH_TOOWEAK",
+	AuthInvalidResp:  "AUTH_INVALIDRESP",
+	AuthFailed:       "AUTH_FAILED",
+}
+
+// String returns the AuthStat as its name in RFC 5531.
+func (s AuthStat) String() string {
+	if name, ok := authStatNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("AuthStat(%d)", int32(s))
+}
+
+// OpaqueAuth is an authentication credential or verifier.  The contents of
+// the body depend on the flavor.
+//
+// Encoding or decoding a body which exceeds MaxAuthBytes fails with an error
+// code of ErrOverflow.
+//
+// Reference:
+// 	RFC 5531 Section 8.2 - Authentication
+// 	Flavor followed by opaque body of at most 400 bytes
+type OpaqueAuth struct {
+	Flavor AuthFlavor
+	Body   []byte `xdr:"max=400"`
+}
+
+// CallBody is the header of a call message which identifies the remote
+// procedure to call.  The arguments of the procedure follow it.
+//
+// Reference:
+// 	RFC 5531 Section 9 - The RPC Message Protocol
+// 	call_body
+type CallBody struct {
+	RPCVers uint32
+	Prog    uint32
+	Vers    uint32
+	Proc    uint32
+	Cred    OpaqueAuth
+	Verf    OpaqueAuth
+}
+
+// MismatchInfo is the range of versions supported by a server when a call
+// requests a version it does not support.
+type MismatchInfo struct {
+	Low  uint32
+	High uint32
+}
+
+// AcceptedReply is the reply to a call which passed authentication.  The
+// results of the procedure follow it when the status is Success.  Mismatch is
+// only encoded when the status is ProgMismatch.
+//
+// Reference:
+// 	RFC 5531 Section 9 - The RPC Message Protocol
+// 	accepted_reply
+type AcceptedReply struct {
+	Verf     OpaqueAuth
+	Stat     AcceptStat   `xdr:"union"`
+	Mismatch MismatchInfo `xdr:"unioncase=2"`
+
+	// Void is the arm of all other statuses which have no data.
+	Void struct{} `xdr:"default"`
+}
+
+// RejectedReply is the reply to a call which was denied.  Mismatch is only
+// encoded when the status is RPCMismatch and AuthStat is only encoded when the
+// status is AuthError.
+//
+// Encoding or decoding any other status fails with an error code of
+// ErrBadDiscriminant.
+//
+// Reference:
+// 	RFC 5531 Section 9 - The RPC Message Protocol
+// 	rejected_reply
+type RejectedReply struct {
+	Stat     RejectStat   `xdr:"union"`
+	Mismatch MismatchInfo `xdr:"unioncase=0"`
+	AuthStat AuthStat     `xdr:"unioncase=1"`
+}
+
+// ReplyBody is the header of a reply message.  Accepted is only encoded when
+// the status is MsgAccepted and Rejected is only encoded when the status is
+// MsgDenied.
+//
+// Encoding or decoding any other status fails with an error code of
+// ErrBadDiscriminant.
+//
+// Reference:
+// 	RFC 5531 Section 9 - The RPC Message Protocol
+// 	reply_body
+type ReplyBody struct {
+	Stat     ReplyStat     `xdr:"union"`
+	Accepted AcceptedReply `xdr:"unioncase=0"`
+	Rejected RejectedReply `xdr:"unioncase=1"`
+}
+
+// Message is the header of an RPC message.  Call is only encoded when the type
+// is Call and Reply is only encoded when the type is Reply.  The procedure
+// arguments or results follow the header.
+//
+// Encoding or decoding any other type fails with an error code of
+// ErrBadDiscriminant.
+//
+// Reference:
+// 	RFC 5531 Section 9 - The RPC Message Protocol
+// 	rpc_msg
+type Message struct {
+	XID   uint32
+	Type  MsgType   `xdr:"union"`
+	Call  CallBody  `xdr:"unioncase=0"`
+	Reply ReplyBody `xdr:"unioncase=1"`
+}
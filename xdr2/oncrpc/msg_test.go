@@ -0,0 +1,238 @@
+/*
+ * Copyright (c) 2014 Dave Collins <dave@davec.name>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package oncrpc_test
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/davecgh/go-xdr/xdr2"
+	. "github.com/davecgh/go-xdr/xdr2/oncrpc"
+)
+
+// TestMessage ensures RPC message headers encode to and decode from the
+// expected bytes.
+func TestMessage(t *testing.T) {
+	tests := []struct {
+		in   Message
+		want []byte
+	}{
+		// Call with AUTH_NONE credentials.
+		{
+			Message{XID: 1, Type: Call, Call: CallBody{
+				RPCVers: RPCVersion, Prog: 100000, Vers: 2,
+				Proc: 3,
+			}},
+			[]byte{
+				0x00, 0x00, 0x00, 0x01, // XID
+				0x00, 0x00, 0x00, 0x00, // CALL
+				0x00, 0x00, 0x00, 0x02, // RPC version
+				0x00, 0x01, 0x86, 0xa0, // Program
+				0x00, 0x00, 0x00, 0x02, // Version
+				0x00, 0x00, 0x00, 0x03, // Procedure
+				0x00, 0x00, 0x00, 0x00, // Cred flavor
+				0x00, 0x00, 0x00, 0x00, // Cred body length
+				0x00, 0x00, 0x00, 0x00, // Verf flavor
+				0x00, 0x00, 0x00, 0x00, // Verf body length
+			},
+		},
+		// Call with a credential body.
+		{
+			Message{XID: 2, Type: Call, Call: CallBody{
+				RPCVers: RPCVersion, Prog: 1, Vers: 1, Proc: 0,
+				Cred: OpaqueAuth{AuthSys, []byte{1, 2, 3}},
+				Verf: OpaqueAuth{AuthNone, nil},
+			}},
+			[]byte{
+				0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00, 0x00,
+				0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00, 0x01,
+				0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00,
+				0x00, 0x00, 0x00, 0x01, // Cred flavor
+				0x00, 0x00, 0x00, 0x03, 0x01, 0x02, 0x03, 0x00,
+				0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			},
+		},
+		// Successful reply.
+		{
+			Message{XID: 3, Type: Reply, Reply: ReplyBody{
+				Stat: MsgAccepted,
+				Accepted: AcceptedReply{
+					Stat: Success,
+				},
+			}},
+			[]byte{
+				0x00, 0x00, 0x00, 0x03, // XID
+				0x00, 0x00, 0x00, 0x01, // REPLY
+				0x00, 0x00, 0x00, 0x00, // MSG_ACCEPTED
+				0x00, 0x00, 0x00, 0x00, // Verf flavor
+				0x00, 0x00, 0x00, 0x00, // Verf body length
+				0x00, 0x00, 0x00, 0x00, // SUCCESS
+			},
+		},
+		// Program version mismatch.
+		{
+			Message{XID: 4, Type: Reply, Reply: ReplyBody{
+				Stat: MsgAccepted,
+				Accepted: AcceptedReply{
+					Stat:     ProgMismatch,
+					Mismatch: MismatchInfo{2, 4},
+				},
+			}},
+			[]byte{
+				0x00, 0x00, 0x00, 0x04, 0x00, 0x00, 0x00, 0x01,
+				0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+				0x00, 0x00, 0x00, 0x00,
+				0x00, 0x00, 0x00, 0x02, // PROG_MISMATCH
+				0x00, 0x00, 0x00, 0x02, // Low
+				0x00, 0x00, 0x00, 0x04, // High
+			},
+		},
+		// Procedure unavailable which has no reply data.
+		{
+			Message{XID: 7, Type: Reply, Reply: ReplyBody{
+				Stat: MsgAccepted,
+				Accepted: AcceptedReply{
+					Stat: ProcUnavail,
+				},
+			}},
+			[]byte{
+				0x00, 0x00, 0x00, 0x07, 0x00, 0x00, 0x00, 0x01,
+				0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+				0x00, 0x00, 0x00, 0x00,
+				0x00, 0x00, 0x00, 0x03, // PROC_UNAVAIL
+			},
+		},
+		// RPC version mismatch.
+		{
+			Message{XID: 5, Type: Reply, Reply: ReplyBody{
+				Stat: MsgDenied,
+				Rejected: RejectedReply{
+					Stat:     RPCMismatch,
+					Mismatch: MismatchInfo{2, 2},
+				},
+			}},
+			[]byte{
+				0x00, 0x00, 0x00, 0x05, 0x00, 0x00, 0x00, 0x01,
+				0x00, 0x00, 0x00, 0x01, // MSG_DENIED
+				0x00, 0x00, 0x00, 0x00, // RPC_MISMATCH
+				0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00, 0x02,
+			},
+		},
+		// Authentication error.
+		{
+			Message{XID: 6, Type: Reply, Reply: ReplyBody{
+				Stat: MsgDenied,
+				Rejected: RejectedReply{
+					Stat:     AuthError,
+					AuthStat: AuthTooWeak,
+				},
+			}},
+			[]byte{
+				0x00, 0x00, 0x00, 0x06, 0x00, 0x00, 0x00, 0x01,
+				0x00, 0x00, 0x00, 0x01, // MSG_DENIED
+				0x00, 0x00, 0x00, 0x01, // AUTH_ERROR
+				0x00, 0x00, 0x00, 0x05, // AUTH_TOOWEAK
+			},
+		},
+	}
+
+	for i, test := range tests {
+		var buf bytes.Buffer
+		n, err := xdr.Marshal(&buf, &test.in)
+		if err != nil {
+			t.Errorf("Marshal #%d unexpected error: %v", i, err)
+			continue
+		}
+		if n != len(test.want) || !bytes.Equal(buf.Bytes(), test.want) {
+			t.Errorf("Marshal #%d\n got: %x\nwant: %x", i,
+				buf.Bytes(), test.want)
+			continue
+		}
+
+		var msg Message
+		n, err = xdr.Unmarshal(bytes.NewReader(test.want), &msg)
+		if err != nil {
+			t.Errorf("Unmarshal #%d unexpected error: %v", i, err)
+			continue
+		}
+		if n != len(test.want) {
+			t.Errorf("Unmarshal #%d got %d bytes want %d", i, n,
+				len(test.want))
+			continue
+		}
+		if !reflect.DeepEqual(msg, test.in) {
+			t.Errorf("Unmarshal #%d\n got: %+v\nwant: %+v", i, msg,
+				test.in)
+			continue
+		}
+	}
+}
+
+// TestMessageErrors ensures invalid RPC message headers are rejected with the
+// expected error codes.
+func TestMessageErrors(t *testing.T) {
+	// Authentication bodies which are too long.
+	cred := OpaqueAuth{AuthSys, make([]byte, MaxAuthBytes+1)}
+	_, err := xdr.Marshal(&bytes.Buffer{}, &cred)
+	merr, ok := err.(*xdr.MarshalError)
+	if !ok || merr.ErrorCode != xdr.ErrOverflow {
+		t.Errorf("Marshal got error %v want ErrOverflow", err)
+	}
+	in := []byte{0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x01, 0x91}
+	_, err = xdr.Unmarshal(bytes.NewReader(in), &cred)
+	uerr, ok := err.(*xdr.UnmarshalError)
+	if !ok || uerr.ErrorCode != xdr.ErrOverflow {
+		t.Errorf("Unmarshal got error %v want ErrOverflow", err)
+	}
+
+	// Invalid discriminants.
+	tests := [][]byte{
+		{0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x02},
+		{0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00,
+			0x00, 0x02},
+		{0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00,
+			0x00, 0x01, 0x00, 0x00, 0x00, 0x02},
+	}
+	for i, test := range tests {
+		var msg Message
+		_, err := xdr.Unmarshal(bytes.NewReader(test), &msg)
+		e, ok := err.(*xdr.UnmarshalError)
+		if !ok || e.ErrorCode != xdr.ErrBadDiscriminant {
+			t.Errorf("Unmarshal #%d got error %v want "+
+				"ErrBadDiscriminant", i, err)
+			continue
+		}
+	}
+
+	// Invalid discriminants are also rejected when encoding.
+	msgs := []Message{
+		{XID: 1, Type: 2},
+		{XID: 1, Type: Reply, Reply: ReplyBody{Stat: 2}},
+		{XID: 1, Type: Reply, Reply: ReplyBody{Stat: MsgDenied,
+			Rejected: RejectedReply{Stat: 2}}},
+	}
+	for i, msg := range msgs {
+		_, err := xdr.Marshal(&bytes.Buffer{}, &msg)
+		e, ok := err.(*xdr.MarshalError)
+		if !ok || e.ErrorCode != xdr.ErrBadDiscriminant {
+			t.Errorf("Marshal #%d got error %v want "+
+				"ErrBadDiscriminant", i, err)
+			continue
+		}
+	}
+}
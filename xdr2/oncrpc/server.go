@@ -0,0 +1,406 @@
+/*
+ * Copyright (c) 2014 Dave Collins <dave@davec.name>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package oncrpc
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/davecgh/go-xdr/xdr2"
+)
+
+// DefaultMaxConcurrentCalls is the maximum number of calls received over a
+// single connection which are handled at once when the MaxConcurrentCalls
+// field of the Server is not set.
+const DefaultMaxConcurrentCalls = 64
+
+// DefaultArgsAllocFactor is the multiple of the size of a call which the
+// memory allocated for its decoded arguments may not exceed when the
+// MaxAllocBytes field of the ArgsOptions of the Server is not set.  Decoded
+// values are larger in memory than when encoded, such as an empty string which
+// is 4 bytes when encoded but 16 bytes in memory on 64-bit platforms, so the
+// factor is generous enough for any arguments which aren't mostly padding.
+const DefaultArgsAllocFactor = 16
+
+// Request is a call received by a Server.
+type Request struct {
+	Prog uint32
+	Vers uint32
+	Proc uint32
+	Cred OpaqueAuth
+	Verf OpaqueAuth
+
+	// Addr is the address of the client which made the call.
+	Addr net.Addr
+
+	// Auth describes the caller as authenticated by the ServerAuthenticator
+	// of the Server, such as the *AuthSysParms of AUTH_SYS credentials.  It
+	// is nil when the Server has no ServerAuthenticator.
+	Auth interface{}
+
+	args     *bytes.Reader
+	argsOpts xdr.DecoderOptions
+	garbage  bool
+}
+
+// Args decodes the arguments of the call into the passed value with
+// xdr.UnmarshalWithOptions, so it must be a pointer.  The resource limits are
+// the ArgsOptions of the Server.
+//
+// When the arguments can't be decoded, the error is returned and the call is
+// replied to with GARBAGE_ARGS regardless of what the handler returns.
+func (r *Request) Args(v interface{}) error {
+	_, err := xdr.UnmarshalWithOptions(r.args, v, r.argsOpts)
+	if err != nil {
+		r.garbage = true
+		return err
+	}
+	return nil
+}
+
+// Handler handles calls to a remote procedure.  The returned results are
+// encoded with xdr.Marshal and may be nil for procedures which do not return
+// results.
+//
+// Returning an *AcceptError or *RejectError replies to the call with its
+// status, returning ErrNoReply does not reply to the call at all, and returning
+// any other error replies with SYSTEM_ERR.  A handler which panics also
+// replies with SYSTEM_ERR.
+type Handler func(r *Request) (interface{}, error)
+
+// Server dispatches calls to the handlers registered for their program,
+// version, and procedure numbers.  The replies to calls which can't be
+// dispatched are generated automatically:
+//
+// 	* RPC_MISMATCH when the RPC version is not 2
+// 	* AUTH_ERROR when the ServerAuthenticator rejects the credentials
+// 	* PROG_UNAVAIL when no handlers are registered for the program
+// 	* PROG_MISMATCH when no handlers are registered for the version
+// 	* PROC_UNAVAIL when no handler is registered for the procedure
+// 	* GARBAGE_ARGS when the handler is unable to decode the arguments
+//
+// Procedure 0 of every registered version replies with no results unless a
+// handler is registered for it since by convention it is the null procedure
+// used to check whether a server is responding.
+//
+// The zero value of a Server is ready to use and accepts calls with any
+// credentials.
+type Server struct {
+	// Auth authenticates the credentials of calls before they are
+	// dispatched and provides the verifiers of their replies.  It must not
+	// be changed once the Server is serving calls.
+	Auth ServerAuthenticator
+
+	// MaxConcurrentCalls is the maximum number of calls received over a
+	// single connection which are handled at once.  Once it is reached, no
+	// more calls are read from the connection until one of them completes.
+	// A zero value uses DefaultMaxConcurrentCalls.
+	MaxConcurrentCalls int
+
+	// ArgsOptions are the resource limits enforced by Request.Args when
+	// decoding the arguments of calls.  When the MaxAllocBytes limit is
+	// zero, it defaults to DefaultArgsAllocFactor times the size of the
+	// record or datagram the call was received in.  When the MaxElements
+	// limit is zero, it defaults to the size of the call, which allows
+	// every element other than those which take no space when encoded.
+	ArgsOptions xdr.DecoderOptions
+
+	mu sync.RWMutex
+
+	// programs houses the registered handlers keyed by program, version,
+	// and then procedure.
+	programs map[uint32]map[uint32]map[uint32]Handler
+}
+
+// Handle registers the passed handler for the passed program, version, and
+// procedure replacing any handler already registered for it.
+func (s *Server) Handle(prog, vers, proc uint32, h Handler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.programs == nil {
+		s.programs = make(map[uint32]map[uint32]map[uint32]Handler)
+	}
+	versions := s.programs[prog]
+	if versions == nil {
+		versions = make(map[uint32]map[uint32]Handler)
+		s.programs[prog] = versions
+	}
+	procs := versions[vers]
+	if procs == nil {
+		procs = make(map[uint32]Handler)
+		versions[vers] = procs
+	}
+	procs[proc] = h
+}
+
+// lookup returns the handler registered for the passed call along with the
+// status to reply with when there is none.  The range of registered versions
+// is also returned for the ProgMismatch status.
+func (s *Server) lookup(prog, vers, proc uint32) (Handler, AcceptStat, MismatchInfo) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	versions, ok := s.programs[prog]
+	if !ok {
+		return nil, ProgUnavail, MismatchInfo{}
+	}
+	procs, ok := versions[vers]
+	if !ok {
+		var mismatch MismatchInfo
+		first := true
+		for v := range versions {
+			if first || v < mismatch.Low {
+				mismatch.Low = v
+			}
+			if first || v > mismatch.High {
+				mismatch.High = v
+			}
+			first = false
+		}
+		return nil, ProgMismatch, mismatch
+	}
+	h, ok := procs[proc]
+	if !ok {
+		if proc == 0 {
+			return nullProc, Success, MismatchInfo{}
+		}
+		return nil, ProcUnavail, MismatchInfo{}
+	}
+	return h, Success, MismatchInfo{}
+}
+
+// nullProc is the handler of procedure 0 when no handler is registered for it.
+func nullProc(r *Request) (interface{}, error) {
+	return nil, nil
+}
+
+// encodeReply returns the encoded reply with the passed header and results.
+// A reply with SYSTEM_ERR is returned in its place when the results can't be
+// encoded.
+func encodeReply(msg *Message, results interface{}) []byte {
+	var buf bytes.Buffer
+	_, err := xdr.Marshal(&buf, msg)
+	if err == nil && results != nil {
+		_, err = xdr.Marshal(&buf, results)
+	}
+	if err != nil {
+		buf.Reset()
+		reply := Message{XID: msg.XID, Type: Reply}
+		reply.Reply.Accepted.Verf = msg.Reply.Accepted.Verf
+		reply.Reply.Accepted.Stat = SystemErr
+		if _, err := xdr.Marshal(&buf, &reply); err != nil {
+			return nil
+		}
+	}
+	return buf.Bytes()
+}
+
+// argsOptions returns the resource limits for decoding the arguments of the
+// passed encoded call.
+func (s *Server) argsOptions(call []byte) xdr.DecoderOptions {
+	opts := s.ArgsOptions
+	if opts.MaxAllocBytes == 0 {
+		opts.MaxAllocBytes = DefaultArgsAllocFactor * uint(len(call))
+	}
+	if opts.MaxElements == 0 {
+		opts.MaxElements = uint(len(call))
+	}
+	return opts
+}
+
+// callHandler calls the passed handler with the passed request and returns its
+// results.  A panic in the handler is recovered and returned as an error so
+// the call is replied to with SYSTEM_ERR rather than bringing down the Server.
+func callHandler(h Handler, r *Request) (results interface{}, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			results = nil
+			err = fmt.Errorf("oncrpc: handler panic: %v", p)
+		}
+	}()
+	return h(r)
+}
+
+// dispatch handles the passed encoded call received from the passed address
+// and returns the encoded reply.  Nil is returned for messages which should
+// not be replied to because they are not calls or are too malformed to
+// reply to.
+func (s *Server) dispatch(data []byte, addr net.Addr) []byte {
+	var msg Message
+	r := bytes.NewReader(data)
+	if _, err := xdr.Unmarshal(r, &msg); err != nil || msg.Type != Call {
+		return nil
+	}
+
+	reply := Message{XID: msg.XID, Type: Reply}
+	if msg.Call.RPCVers != RPCVersion {
+		reply.Reply.Stat = MsgDenied
+		reply.Reply.Rejected.Stat = RPCMismatch
+		reply.Reply.Rejected.Mismatch = MismatchInfo{RPCVersion,
+			RPCVersion}
+		return encodeReply(&reply, nil)
+	}
+
+	var caller interface{}
+	if s.Auth != nil {
+		var stat AuthStat
+		caller, reply.Reply.Accepted.Verf, stat = s.Auth.Authenticate(
+			msg.Call.Cred, msg.Call.Verf)
+		if stat != AuthOK {
+			reply.Reply = ReplyBody{Stat: MsgDenied}
+			reply.Reply.Rejected.Stat = AuthError
+			reply.Reply.Rejected.AuthStat = stat
+			return encodeReply(&reply, nil)
+		}
+	}
+
+	h, stat, mismatch := s.lookup(msg.Call.Prog, msg.Call.Vers,
+		msg.Call.Proc)
+	if h == nil {
+		reply.Reply.Accepted.Stat = stat
+		reply.Reply.Accepted.Mismatch = mismatch
+		return encodeReply(&reply, nil)
+	}
+
+	req := Request{
+		Prog:     msg.Call.Prog,
+		Vers:     msg.Call.Vers,
+		Proc:     msg.Call.Proc,
+		Cred:     msg.Call.Cred,
+		Verf:     msg.Call.Verf,
+		Addr:     addr,
+		Auth:     caller,
+		args:     r,
+		argsOpts: s.argsOptions(data),
+	}
+	results, err := callHandler(h, &req)
+	if err == ErrNoReply {
+		return nil
+	}
+	switch e := err.(type) {
+	case nil:
+	case *AcceptError:
+		reply.Reply.Accepted.Stat = e.Stat
+		reply.Reply.Accepted.Mismatch = e.Mismatch
+	case *RejectError:
+		reply.Reply.Stat = MsgDenied
+		reply.Reply.Rejected = RejectedReply{
+			Stat:     e.Stat,
+			Mismatch: e.Mismatch,
+			AuthStat: e.AuthStat,
+		}
+	default:
+		reply.Reply.Accepted.Stat = SystemErr
+	}
+	if req.garbage {
+		verf := reply.Reply.Accepted.Verf
+		reply.Reply = ReplyBody{}
+		reply.Reply.Accepted.Verf = verf
+		reply.Reply.Accepted.Stat = GarbageArgs
+	}
+	if reply.Reply.Stat != MsgAccepted ||
+		reply.Reply.Accepted.Stat != Success {
+
+		results = nil
+	}
+	return encodeReply(&reply, results)
+}
+
+// callSlots returns a semaphore which limits the number of calls received over
+// a connection that are handled at once to the MaxConcurrentCalls of the
+// Server.
+func (s *Server) callSlots() chan struct{} {
+	max := s.MaxConcurrentCalls
+	if max <= 0 {
+		max = DefaultMaxConcurrentCalls
+	}
+	return make(chan struct{}, max)
+}
+
+// ServeConn serves the calls received over the passed stream connection,
+// which are framed with record marking, until the connection fails or is
+// closed.  Up to MaxConcurrentCalls calls are handled concurrently and their
+// replies are sent as they complete.  The connection is closed when ServeConn
+// returns.
+func (s *Server) ServeConn(conn net.Conn) {
+	defer conn.Close()
+
+	var mu sync.Mutex
+	slots := s.callSlots()
+	rw := xdr.NewRecordWriter(conn)
+	rr := xdr.NewRecordReader(conn, MaxRecordSize)
+	for {
+		slots <- struct{}{}
+		call, err := rr.ReadRecord()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer func() { <-slots }()
+			reply := s.dispatch(call, conn.RemoteAddr())
+			if reply == nil {
+				return
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if _, err := rw.Write(reply); err == nil {
+				rw.Flush()
+			}
+		}()
+	}
+}
+
+// Serve accepts stream connections from the passed listener and serves each
+// of them with ServeConn in a new goroutine.  It returns the error which
+// stopped the listener from accepting connections.
+func (s *Server) Serve(ln net.Listener) error {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return err
+		}
+		go s.ServeConn(conn)
+	}
+}
+
+// ServePacket serves the calls received over the passed datagram connection
+// where each datagram is a single call.  Up to MaxConcurrentCalls calls are
+// handled concurrently and their replies are sent to the address the call was
+// received from as they complete.  It returns the error which stopped the
+// connection from reading.
+func (s *Server) ServePacket(pc net.PacketConn) error {
+	slots := s.callSlots()
+	buf := make([]byte, MaxDatagramSize)
+	for {
+		slots <- struct{}{}
+		n, addr, err := pc.ReadFrom(buf)
+		if err != nil {
+			return err
+		}
+		call := append([]byte(nil), buf[:n]...)
+		go func() {
+			defer func() { <-slots }()
+			if reply := s.dispatch(call, addr); reply != nil {
+				pc.WriteTo(reply, addr)
+			}
+		}()
+	}
+}
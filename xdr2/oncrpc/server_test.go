@@ -0,0 +1,333 @@
+/*
+ * Copyright (c) 2014 Dave Collins <dave@davec.name>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package oncrpc_test
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/davecgh/go-xdr/xdr2"
+	. "github.com/davecgh/go-xdr/xdr2/oncrpc"
+)
+
+// echoArgs are the arguments of the echo procedure of the test server.
+type echoArgs struct {
+	Name  string
+	Count uint32
+}
+
+// testServer returns a Server with the test program registered.
+func testServer() *Server {
+	var s Server
+	s.Handle(testProg, 1, procAdd, func(r *Request) (interface{}, error) {
+		var args [2]uint32
+		if err := r.Args(&args); err != nil {
+			return nil, err
+		}
+		return args[0] + args[1], nil
+	})
+	s.Handle(testProg, 3, 1, func(r *Request) (interface{}, error) {
+		var args echoArgs
+		if err := r.Args(&args); err != nil {
+			return nil, err
+		}
+		return &args, nil
+	})
+	s.Handle(testProg, 3, 2, func(r *Request) (interface{}, error) {
+		return nil, errors.New("failed")
+	})
+	s.Handle(testProg, 3, 3, func(r *Request) (interface{}, error) {
+		return nil, &RejectError{Stat: AuthError, AuthStat: AuthTooWeak}
+	})
+	s.Handle(testProg, 3, 4, func(r *Request) (interface{}, error) {
+		// Results which can't be encoded.
+		return make(chan int), nil
+	})
+	s.Handle(testProg, 3, 5, func(r *Request) (interface{}, error) {
+		panic("handler failure")
+	})
+	return &s
+}
+
+// serverClients returns clients connected to the passed server over TCP and
+// UDP along with a function which shuts them down.
+func serverClients(t *testing.T, s *Server) ([]*Client, func()) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen unexpected error: %v", err)
+	}
+	go s.Serve(ln)
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		ln.Close()
+		t.Fatalf("ListenPacket unexpected error: %v", err)
+	}
+	go s.ServePacket(pc)
+
+	var clients []*Client
+	for _, addr := range []net.Addr{ln.Addr(), pc.LocalAddr()} {
+		c, err := Dial(addr.Network(), addr.String())
+		if err != nil {
+			t.Fatalf("Dial unexpected error: %v", err)
+		}
+		c.Timeout = 2 * time.Second
+		clients = append(clients, c)
+	}
+
+	return clients, func() {
+		for _, c := range clients {
+			c.Close()
+		}
+		ln.Close()
+		pc.Close()
+	}
+}
+
+// TestServer ensures a Server dispatches calls to the registered handlers and
+// replies to calls which can't be dispatched with the expected status.
+func TestServer(t *testing.T) {
+	clients, done := serverClients(t, testServer())
+	defer done()
+
+	tests := []struct {
+		prog, vers, proc uint32
+		args             interface{}
+		result           interface{} // Value to decode results into
+		want             interface{} // Expected results or error
+	}{
+		{testProg, 1, procAdd, [2]uint32{2, 3}, new(uint32), uint32(5)},
+		{testProg, 3, 1, echoArgs{"a", 2}, &echoArgs{}, echoArgs{"a", 2}},
+
+		// Null procedure.
+		{testProg, 1, 0, nil, nil, nil},
+
+		// Calls which can't be dispatched.
+		{testProg + 1, 1, 0, nil, nil, &AcceptError{Stat: ProgUnavail}},
+		{testProg, 2, 0, nil, nil, &AcceptError{Stat: ProgMismatch,
+			Mismatch: MismatchInfo{1, 3}}},
+		{testProg, 1, 9, nil, nil, &AcceptError{Stat: ProcUnavail}},
+		{testProg, 1, procAdd, uint32(1), new(uint32),
+			&AcceptError{Stat: GarbageArgs}},
+
+		// Handler errors.
+		{testProg, 3, 2, nil, nil, &AcceptError{Stat: SystemErr}},
+		{testProg, 3, 3, nil, nil, &RejectError{Stat: AuthError,
+			AuthStat: AuthTooWeak}},
+		{testProg, 3, 4, nil, nil, &AcceptError{Stat: SystemErr}},
+
+		// Handler panics, after which the server must still respond.
+		{testProg, 3, 5, nil, nil, &AcceptError{Stat: SystemErr}},
+		{testProg, 1, 0, nil, nil, nil},
+	}
+
+	for _, c := range clients {
+		for i, test := range tests {
+			err := c.Call(test.prog, test.vers, test.proc, test.args,
+				test.result)
+			if wantErr, ok := test.want.(error); ok {
+				if !reflect.DeepEqual(err, wantErr) {
+					t.Errorf("Call #%d got error %v want %v",
+						i, err, wantErr)
+				}
+				continue
+			}
+			if err != nil {
+				t.Errorf("Call #%d unexpected error: %v", i, err)
+				continue
+			}
+			if test.result == nil {
+				continue
+			}
+			got := reflect.ValueOf(test.result).Elem().Interface()
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("Call #%d got %v want %v", i, got,
+					test.want)
+				continue
+			}
+		}
+	}
+}
+
+// TestServerRPCMismatch ensures a Server denies calls with an unsupported RPC
+// version and ignores messages which are not calls.
+func TestServerRPCMismatch(t *testing.T) {
+	s := testServer()
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("ListenPacket unexpected error: %v", err)
+	}
+	defer pc.Close()
+	go s.ServePacket(pc)
+
+	conn, err := net.Dial("udp", pc.LocalAddr().String())
+	if err != nil {
+		t.Fatalf("Dial unexpected error: %v", err)
+	}
+	defer conn.Close()
+
+	msgs := []Message{
+		{XID: 1, Type: Reply},
+		{XID: 2, Type: Call, Call: CallBody{RPCVers: 3, Prog: testProg,
+			Vers: 1}},
+	}
+	for _, msg := range msgs {
+		var buf bytes.Buffer
+		if _, err := xdr.Marshal(&buf, &msg); err != nil {
+			t.Fatalf("Marshal unexpected error: %v", err)
+		}
+		if _, err := conn.Write(buf.Bytes()); err != nil {
+			t.Fatalf("Write unexpected error: %v", err)
+		}
+	}
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	buf := make([]byte, MaxDatagramSize)
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatalf("Read unexpected error: %v", err)
+	}
+	var reply Message
+	if _, err := xdr.Unmarshal(bytes.NewReader(buf[:n]), &reply); err != nil {
+		t.Fatalf("Unmarshal unexpected error: %v", err)
+	}
+	want := Message{XID: 2, Type: Reply, Reply: ReplyBody{
+		Stat: MsgDenied,
+		Rejected: RejectedReply{
+			Stat:     RPCMismatch,
+			Mismatch: MismatchInfo{RPCVersion, RPCVersion},
+		},
+	}}
+	if !reflect.DeepEqual(reply, want) {
+		t.Errorf("reply\n got: %+v\nwant: %+v", reply, want)
+	}
+}
+
+// TestServerConcurrency ensures a Server handles no more than
+// MaxConcurrentCalls calls received over a connection at once.
+func TestServerConcurrency(t *testing.T) {
+	const max = 2
+	for transport := 0; transport < 2; transport++ {
+		var mu sync.Mutex
+		var active, peak int
+		started := make(chan struct{}, 10)
+		release := make(chan struct{})
+
+		s := Server{MaxConcurrentCalls: max}
+		s.Handle(testProg, 1, 1, func(r *Request) (interface{}, error) {
+			mu.Lock()
+			active++
+			if active > peak {
+				peak = active
+			}
+			mu.Unlock()
+			started <- struct{}{}
+			<-release
+			mu.Lock()
+			active--
+			mu.Unlock()
+			return nil, nil
+		})
+		clients, done := serverClients(t, &s)
+		c := clients[transport]
+
+		var wg sync.WaitGroup
+		for i := 0; i < 5; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				err := c.Call(testProg, 1, 1, nil, nil)
+				if err != nil {
+					t.Errorf("Call unexpected error: %v", err)
+				}
+			}()
+		}
+
+		// Wait for the limit to be reached and ensure no other calls
+		// are handled until one of them completes.
+		for i := 0; i < max; i++ {
+			<-started
+		}
+		select {
+		case <-started:
+			t.Errorf("call handled beyond the limit of %d", max)
+		case <-time.After(50 * time.Millisecond):
+		}
+		close(release)
+		wg.Wait()
+		done()
+
+		mu.Lock()
+		if peak != max {
+			t.Errorf("got %d concurrent calls want %d", peak, max)
+		}
+		mu.Unlock()
+	}
+}
+
+// TestServerArgsOptions ensures the arguments of calls are decoded with the
+// resource limits of the Server which default to limits based on the size of
+// the call.
+func TestServerArgsOptions(t *testing.T) {
+	tests := []struct {
+		opts   xdr.DecoderOptions
+		args   interface{} // Arguments to send
+		decode interface{} // Value to decode the arguments into
+		want   error
+	}{
+		// The elements take no space when encoded, so the default
+		// limits reject them.
+		{xdr.DecoderOptions{}, uint32(1000), new([]struct{}),
+			&AcceptError{Stat: GarbageArgs}},
+		{xdr.DecoderOptions{MaxElements: 1000}, uint32(1000),
+			new([]struct{}), nil},
+		{xdr.DecoderOptions{MaxElements: 999}, uint32(1000),
+			new([]struct{}), &AcceptError{Stat: GarbageArgs}},
+
+		// Small strings and structs are much larger in memory than
+		// when encoded, but the default limits allow them.
+		{xdr.DecoderOptions{}, make([]string, 100), new([]string), nil},
+		{xdr.DecoderOptions{}, make([]echoArgs, 100), new([]echoArgs),
+			nil},
+		{xdr.DecoderOptions{MaxAllocBytes: 1000}, make([]string, 100),
+			new([]string), &AcceptError{Stat: GarbageArgs}},
+	}
+
+	for i, test := range tests {
+		// Each call decodes into a new value since handlers may run
+		// concurrently, such as for retransmitted datagrams.
+		decodeType := reflect.TypeOf(test.decode).Elem()
+		s := Server{ArgsOptions: test.opts}
+		s.Handle(testProg, 1, 1, func(r *Request) (interface{}, error) {
+			return nil, r.Args(reflect.New(decodeType).Interface())
+		})
+		clients, done := serverClients(t, &s)
+		for _, c := range clients {
+			err := c.Call(testProg, 1, 1, test.args, nil)
+			if !reflect.DeepEqual(err, test.want) {
+				t.Errorf("Call #%d got error %v want %v", i, err,
+					test.want)
+			}
+		}
+		done()
+	}
+}
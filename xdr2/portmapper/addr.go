@@ -0,0 +1,87 @@
+/*
+ * Copyright (c) 2014 Dave Collins <dave@davec.name>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package portmapper
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// UniversalAddr returns the universal address of the passed TCP or UDP
+// address, which is its IP address followed by the high and low octets of its
+// port separated by dots, such as "127.0.0.1.8.1" for 127.0.0.1:2049.
+//
+// Reference:
+// 	RFC 5665 Section 5.2.3 - Universal Address Format for TCP and UDP
+func UniversalAddr(addr net.Addr) (string, error) {
+	var ip net.IP
+	var port int
+	switch a := addr.(type) {
+	case *net.TCPAddr:
+		ip, port = a.IP, a.Port
+	case *net.UDPAddr:
+		ip, port = a.IP, a.Port
+	default:
+		return "", fmt.Errorf("portmapper: unsupported address type %T",
+			addr)
+	}
+	if ip == nil {
+		ip = net.IPv4zero
+	}
+	return fmt.Sprintf("%s.%d.%d", ip, port>>8, port&0xff), nil
+}
+
+// ParseUniversalAddr parses the passed universal address of the passed
+// network, such as "tcp" or "udp6", into a TCP or UDP address.
+func ParseUniversalAddr(network, uaddr string) (net.Addr, error) {
+	ip, port, err := splitUniversalAddr(uaddr)
+	if err != nil {
+		return nil, err
+	}
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+		return &net.TCPAddr{IP: ip, Port: port}, nil
+	case "udp", "udp4", "udp6":
+		return &net.UDPAddr{IP: ip, Port: port}, nil
+	}
+	return nil, fmt.Errorf("portmapper: unsupported network %q", network)
+}
+
+// splitUniversalAddr returns the IP address and port of the passed universal
+// address.
+func splitUniversalAddr(uaddr string) (net.IP, int, error) {
+	lo := strings.LastIndexByte(uaddr, '.')
+	if lo < 0 {
+		return nil, 0, fmt.Errorf("portmapper: invalid universal "+
+			"address %q", uaddr)
+	}
+	hi := strings.LastIndexByte(uaddr[:lo], '.')
+	if hi < 0 {
+		return nil, 0, fmt.Errorf("portmapper: invalid universal "+
+			"address %q", uaddr)
+	}
+	ip := net.ParseIP(uaddr[:hi])
+	p1, err1 := strconv.ParseUint(uaddr[hi+1:lo], 10, 8)
+	p2, err2 := strconv.ParseUint(uaddr[lo+1:], 10, 8)
+	if ip == nil || err1 != nil || err2 != nil {
+		return nil, 0, fmt.Errorf("portmapper: invalid universal "+
+			"address %q", uaddr)
+	}
+	return ip, int(p1<<8 | p2), nil
+}
@@ -0,0 +1,92 @@
+/*
+ * Copyright (c) 2014 Dave Collins <dave@davec.name>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package portmapper_test
+
+import (
+	"net"
+	"reflect"
+	"testing"
+
+	. "github.com/davecgh/go-xdr/xdr2/portmapper"
+)
+
+// TestUniversalAddr ensures addresses are converted to and from universal
+// addresses as expected.
+func TestUniversalAddr(t *testing.T) {
+	tests := []struct {
+		network string
+		addr    net.Addr
+		uaddr   string
+	}{
+		{"tcp", &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 2049},
+			"127.0.0.1.8.1"},
+		{"udp", &net.UDPAddr{IP: net.IPv4(10, 1, 2, 3), Port: 111},
+			"10.1.2.3.0.111"},
+		{"tcp6", &net.TCPAddr{IP: net.IPv6loopback, Port: 65535},
+			"::1.255.255"},
+	}
+
+	for i, test := range tests {
+		uaddr, err := UniversalAddr(test.addr)
+		if err != nil {
+			t.Errorf("UniversalAddr #%d unexpected error: %v", i, err)
+			continue
+		}
+		if uaddr != test.uaddr {
+			t.Errorf("UniversalAddr #%d got %q want %q", i, uaddr,
+				test.uaddr)
+			continue
+		}
+
+		addr, err := ParseUniversalAddr(test.network, test.uaddr)
+		if err != nil {
+			t.Errorf("ParseUniversalAddr #%d unexpected error: %v",
+				i, err)
+			continue
+		}
+		if addr.String() != test.addr.String() ||
+			reflect.TypeOf(addr) != reflect.TypeOf(test.addr) {
+
+			t.Errorf("ParseUniversalAddr #%d got %v want %v", i,
+				addr, test.addr)
+			continue
+		}
+	}
+
+	// Invalid universal addresses and networks.
+	invalid := []struct {
+		network string
+		uaddr   string
+	}{
+		{"tcp", "127.0.0.1"},
+		{"tcp", "8.1"},
+		{"tcp", "host.8.1"},
+		{"tcp", "127.0.0.1.256.1"},
+		{"tcp", "127.0.0.1.8.x"},
+		{"unix", "127.0.0.1.8.1"},
+	}
+	for i, test := range invalid {
+		_, err := ParseUniversalAddr(test.network, test.uaddr)
+		if err == nil {
+			t.Errorf("ParseUniversalAddr #%d did not fail", i)
+			continue
+		}
+	}
+	if _, err := UniversalAddr(&net.UnixAddr{}); err == nil {
+		t.Errorf("UniversalAddr did not fail for unix address")
+	}
+}
@@ -0,0 +1,158 @@
+/*
+ * Copyright (c) 2014 Dave Collins <dave@davec.name>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package portmapper
+
+import (
+	"bytes"
+
+	"github.com/davecgh/go-xdr/xdr2"
+	"github.com/davecgh/go-xdr/xdr2/oncrpc"
+)
+
+// Client makes calls to a portmapper or rpcbind server.
+type Client struct {
+	// RPCBVers is the version of the rpcbind protocol used by GetAddr.  A
+	// zero value uses Version4.
+	RPCBVers uint32
+
+	c *oncrpc.Client
+}
+
+// Set registers the passed mapping with the version 2 SET procedure.  It
+// returns false when a mapping for the program, version, and protocol is
+// already registered.
+func (c *Client) Set(m Mapping) (bool, error) {
+	var ok bool
+	err := c.c.Call(Prog, Version2, ProcSet, &m, &ok)
+	return ok, err
+}
+
+// Unset unregisters the mappings of the program and version of the passed
+// mapping for all protocols with the version 2 UNSET procedure.  It returns
+// whether or not any mappings were unregistered.
+func (c *Client) Unset(m Mapping) (bool, error) {
+	var ok bool
+	err := c.c.Call(Prog, Version2, ProcUnset, &m, &ok)
+	return ok, err
+}
+
+// GetPort returns the port the passed version of the passed program is
+// served on with the passed protocol using the version 2 GETPORT procedure.
+// Zero is returned when the program is not registered.
+func (c *Client) GetPort(prog, vers, prot uint32) (uint32, error) {
+	var port uint32
+	m := Mapping{Prog: prog, Vers: vers, Prot: prot}
+	err := c.c.Call(Prog, Version2, ProcGetPort, &m, &port)
+	return port, err
+}
+
+// Dump returns all registered mappings using the version 2 DUMP procedure.
+func (c *Client) Dump() ([]Mapping, error) {
+	var l pmapListPtr
+	if err := c.c.Call(Prog, Version2, ProcDump, nil, &l); err != nil {
+		return nil, err
+	}
+	return l.mappings(), nil
+}
+
+// CallIt calls the passed procedure through the portmapper using the version
+// 2 CALLIT procedure.  The arguments are encoded and the results are decoded
+// into result with xdr.Marshal and xdr.Unmarshal, respectively, so result must
+// be a pointer.  Either may be nil for procedures which do not take arguments
+// or return results.  The port the program is served on is returned.
+//
+// The portmapper does not reply when the call fails, so the Timeout field of
+// the underlying oncrpc.Client returned by RPC should be set.
+func (c *Client) CallIt(prog, vers, proc uint32, args, result interface{}) (uint32, error) {
+	callArgs := CallArgs{Prog: prog, Vers: vers, Proc: proc}
+	if args != nil {
+		var buf bytes.Buffer
+		if _, err := xdr.Marshal(&buf, args); err != nil {
+			return 0, err
+		}
+		callArgs.Args = buf.Bytes()
+	}
+
+	var res CallResult
+	err := c.c.Call(Prog, Version2, ProcCallIt, &callArgs, &res)
+	if err != nil {
+		return 0, err
+	}
+	if result != nil {
+		_, err := xdr.Unmarshal(bytes.NewReader(res.Res), result)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return res.Port, nil
+}
+
+// GetAddr returns the universal address the passed version of the passed
+// program is served on with the transport identified by the passed netid
+// using the rpcbind GETADDR procedure.  An empty netid selects the transport
+// the call is made over.  An empty address is returned when the program is
+// not registered.
+func (c *Client) GetAddr(prog, vers uint32, netid string) (string, error) {
+	rpcbVers := c.RPCBVers
+	if rpcbVers == 0 {
+		rpcbVers = Version4
+	}
+
+	var addr string
+	r := RPCB{Prog: prog, Vers: vers, NetID: netid}
+	err := c.c.Call(Prog, rpcbVers, RPCBProcGetAddr, &r, &addr)
+	return addr, err
+}
+
+// GetAddrList returns the addresses the passed version of the passed program
+// is served on with all transports using the version 4 GETADDRLIST procedure.
+func (c *Client) GetAddrList(prog, vers uint32) ([]RPCBEntry, error) {
+	var l entryListPtr
+	r := RPCB{Prog: prog, Vers: vers}
+	err := c.c.Call(Prog, Version4, RPCBProcGetAddrList, &r, &l)
+	if err != nil {
+		return nil, err
+	}
+	return l.entries(), nil
+}
+
+// RPC returns the underlying oncrpc.Client so its settings, such as the
+// Timeout, may be changed.
+func (c *Client) RPC() *oncrpc.Client {
+	return c.c
+}
+
+// Close closes the underlying oncrpc.Client.
+func (c *Client) Close() error {
+	return c.c.Close()
+}
+
+// NewClient returns a Client which makes calls with the passed oncrpc.Client.
+func NewClient(c *oncrpc.Client) *Client {
+	return &Client{c: c}
+}
+
+// Dial connects to the portmapper at the passed address on the passed
+// network, such as "tcp" or "udp", and returns a Client which makes calls over
+// the connection.
+func Dial(network, address string) (*Client, error) {
+	c, err := oncrpc.Dial(network, address)
+	if err != nil {
+		return nil, err
+	}
+	return NewClient(c), nil
+}
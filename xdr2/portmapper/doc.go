@@ -0,0 +1,65 @@
+/*
+ * Copyright (c) 2014 Dave Collins <dave@davec.name>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+/*
+Package portmapper implements version 2 of the portmapper protocol and versions
+3 and 4 of the rpcbind protocol as specified by RFC 1833 on top of the oncrpc
+package.
+
+The portmapper, or rpcbind, is the ONC RPC program which maps the program and
+version numbers of the RPC services on a host to the addresses they are served
+on.  Services register their addresses with it and clients look them up before
+calling the services.
+
+Client
+
+A Client makes calls to a portmapper.  The version 2 protocol identifies
+services by port and protocol number while the rpcbind protocols use universal
+addresses and netids, which are converted to and from TCP and UDP addresses with
+UniversalAddr and ParseUniversalAddr:
+
+	c, err := portmapper.Dial("udp", "server:111")
+	// Error check elided
+	defer c.Close()
+
+	port, err := c.GetPort(prog, vers, portmapper.IPProtoTCP)
+	// Error check elided
+
+	uaddr, err := c.GetAddr(prog, vers, "tcp")
+	// Error check elided
+	addr, err := portmapper.ParseUniversalAddr("tcp", uaddr)
+	// Error check elided
+
+Server
+
+A Server is an in-memory rpcbind server which is served by registering it with
+an oncrpc.Server.  This allows RPC services to be registered and discovered,
+such as in tests, without a system rpcbind daemon:
+
+	var pm portmapper.Server
+	var s oncrpc.Server
+	pm.Register(&s)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	// Error check elided
+	go s.Serve(ln)
+
+The version 2 SET, UNSET, GETPORT, DUMP, and CALLIT procedures, the version 3
+and 4 SET, UNSET, GETADDR, and DUMP procedures, and the version 4 GETADDRLIST
+procedure are implemented.  Registrations may also be made directly with the
+Set and Unset methods of the Server.
+*/
+package portmapper
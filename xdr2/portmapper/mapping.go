@@ -0,0 +1,224 @@
+/*
+ * Copyright (c) 2014 Dave Collins <dave@davec.name>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package portmapper
+
+const (
+	// Prog is the program number of the portmapper and rpcbind protocols.
+	Prog = 100000
+
+	// Port is the well-known port the portmapper listens on.
+	Port = 111
+
+	// Version2 is the version of the portmapper protocol.  Version3 and
+	// Version4 are the versions of the rpcbind protocol.
+	Version2 = 2
+	Version3 = 3
+	Version4 = 4
+)
+
+// Procedures of the version 2 portmapper protocol.
+const (
+	ProcNull    = 0
+	ProcSet     = 1
+	ProcUnset   = 2
+	ProcGetPort = 3
+	ProcDump    = 4
+	ProcCallIt  = 5
+)
+
+// Procedures of the version 3 and 4 rpcbind protocols.  RPCBProcGetAddrList
+// is only available in version 4.
+const (
+	RPCBProcNull        = 0
+	RPCBProcSet         = 1
+	RPCBProcUnset       = 2
+	RPCBProcGetAddr     = 3
+	RPCBProcDump        = 4
+	RPCBProcGetAddrList = 11
+)
+
+// Protocols of version 2 portmapper mappings.
+const (
+	IPProtoTCP = 6
+	IPProtoUDP = 17
+)
+
+// Transport semantics of rpcbind entries.
+const (
+	NCTPIClts    = 1 // Connectionless
+	NCTPICots    = 2 // Connection oriented
+	NCTPICotsOrd = 3 // Connection oriented with orderly release
+	NCTPIRaw     = 4 // Raw
+)
+
+// Mapping is the port a version of a program is served on with the protocol
+// of a version 2 portmapper.
+//
+// Reference:
+// 	RFC 1833 Section 3.1 - Port Mapper Protocol Specification
+// 	mapping
+type Mapping struct {
+	Prog uint32
+	Vers uint32
+	Prot uint32
+	Port uint32
+}
+
+// CallArgs are the arguments of the version 2 CALLIT procedure.  Args is the
+// encoded arguments of the procedure to call.
+//
+// Reference:
+// 	RFC 1833 Section 3.1 - Port Mapper Protocol Specification
+// 	call_args
+type CallArgs struct {
+	Prog uint32
+	Vers uint32
+	Proc uint32
+	Args []byte
+}
+
+// CallResult is the result of the version 2 CALLIT procedure.  Res is the
+// encoded results of the called procedure.
+//
+// Reference:
+// 	RFC 1833 Section 3.1 - Port Mapper Protocol Specification
+// 	call_result
+type CallResult struct {
+	Port uint32
+	Res  []byte
+}
+
+// RPCB is the universal address a version of a program is served on with the
+// transport identified by NetID of the version 3 and 4 rpcbind protocols.
+//
+// Reference:
+// 	RFC 1833 Section 2.1 - RPCBIND Protocol Specification
+// 	rpcb
+type RPCB struct {
+	Prog  uint32
+	Vers  uint32
+	NetID string
+	Addr  string
+	Owner string
+}
+
+// RPCBEntry is an address of a program returned by the version 4
+// GETADDRLIST procedure.
+//
+// Reference:
+// 	RFC 1833 Section 2.1 - RPCBIND Protocol Specification
+// 	rpcb_entry
+type RPCBEntry struct {
+	MAddr     string
+	NetID     string
+	Semantics uint32
+	ProtoFmly string
+	Proto     string
+}
+
+// pmapList is the linked list of mappings returned by the version 2 DUMP
+// procedure.  Its result is the optional head of the list.
+//
+// Reference:
+// 	RFC 1833 Section 3.1 - Port Mapper Protocol Specification
+// 	pmaplist
+type pmapList struct {
+	Map  Mapping
+	Next *pmapList `xdr:"optional"`
+}
+
+// pmapListPtr is the optional head of a pmapList.
+type pmapListPtr struct {
+	Head *pmapList `xdr:"optional"`
+}
+
+// newPmapListPtr returns the linked list of the passed mappings.
+func newPmapListPtr(ms []Mapping) pmapListPtr {
+	var l pmapListPtr
+	for i := len(ms) - 1; i >= 0; i-- {
+		l.Head = &pmapList{Map: ms[i], Next: l.Head}
+	}
+	return l
+}
+
+// mappings returns the mappings of the linked list in order.
+func (l pmapListPtr) mappings() []Mapping {
+	var ms []Mapping
+	for e := l.Head; e != nil; e = e.Next {
+		ms = append(ms, e.Map)
+	}
+	return ms
+}
+
+// rpcbList is the linked list of addresses returned by the version 3 and 4
+// DUMP procedure.  Its result is the optional head of the list.
+//
+// Reference:
+// 	RFC 1833 Section 2.1 - RPCBIND Protocol Specification
+// 	rp__list
+type rpcbList struct {
+	RPCB RPCB
+	Next *rpcbList `xdr:"optional"`
+}
+
+// rpcbListPtr is the optional head of an rpcbList.
+type rpcbListPtr struct {
+	Head *rpcbList `xdr:"optional"`
+}
+
+// newRPCBListPtr returns the linked list of the passed addresses.
+func newRPCBListPtr(rs []RPCB) rpcbListPtr {
+	var l rpcbListPtr
+	for i := len(rs) - 1; i >= 0; i-- {
+		l.Head = &rpcbList{RPCB: rs[i], Next: l.Head}
+	}
+	return l
+}
+
+// entryList is the linked list of addresses returned by the version 4
+// GETADDRLIST procedure.  Its result is the optional head of the list.
+//
+// Reference:
+// 	RFC 1833 Section 2.1 - RPCBIND Protocol Specification
+// 	rpcb_entry_list
+type entryList struct {
+	Entry RPCBEntry
+	Next  *entryList `xdr:"optional"`
+}
+
+// entryListPtr is the optional head of an entryList.
+type entryListPtr struct {
+	Head *entryList `xdr:"optional"`
+}
+
+// newEntryListPtr returns the linked list of the passed entries.
+func newEntryListPtr(es []RPCBEntry) entryListPtr {
+	var l entryListPtr
+	for i := len(es) - 1; i >= 0; i-- {
+		l.Head = &entryList{Entry: es[i], Next: l.Head}
+	}
+	return l
+}
+
+// entries returns the entries of the linked list in order.
+func (l entryListPtr) entries() []RPCBEntry {
+	var es []RPCBEntry
+	for e := l.Head; e != nil; e = e.Next {
+		es = append(es, e.Entry)
+	}
+	return es
+}
@@ -0,0 +1,349 @@
+/*
+ * Copyright (c) 2014 Dave Collins <dave@davec.name>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package portmapper
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/davecgh/go-xdr/xdr2"
+	"github.com/davecgh/go-xdr/xdr2/oncrpc"
+)
+
+// callItTimeout is the maximum amount of time a call forwarded by the CALLIT
+// procedure waits for its reply.
+const callItTimeout = 5 * time.Second
+
+// protoNetIDs maps the protocols of version 2 mappings to the netids of the
+// transports they use.
+var protoNetIDs = map[uint32]string{
+	IPProtoTCP: "tcp",
+	IPProtoUDP: "udp",
+}
+
+// netIDEntries maps the netids of transports to the rpcbind entries which
+// describe them.
+var netIDEntries = map[string]RPCBEntry{
+	"tcp":  {Semantics: NCTPICotsOrd, ProtoFmly: "inet", Proto: "tcp"},
+	"udp":  {Semantics: NCTPIClts, ProtoFmly: "inet", Proto: "udp"},
+	"tcp6": {Semantics: NCTPICotsOrd, ProtoFmly: "inet6", Proto: "tcp"},
+	"udp6": {Semantics: NCTPIClts, ProtoFmly: "inet6", Proto: "udp"},
+}
+
+// Server is an in-memory rpcbind server which implements version 2 of the
+// portmapper protocol along with versions 3 and 4 of the rpcbind protocol.  It
+// is served by registering it with an oncrpc.Server:
+//
+// 	var pm portmapper.Server
+// 	var s oncrpc.Server
+// 	pm.Register(&s)
+// 	go s.Serve(ln)
+//
+// Registrations made with either protocol are visible with the other as long
+// as they can be expressed by it.  Version 2 mappings are registered as
+// universal addresses with the unspecified IPv4 address and only registrations
+// for the "tcp" and "udp" netids are visible as version 2 mappings.
+//
+// The zero value of a Server is ready to use.
+type Server struct {
+	mu      sync.RWMutex
+	entries []RPCB
+
+	// xid is the transaction ID of the last call forwarded by CALLIT.
+	xid uint32
+}
+
+// Set registers the passed address.  It returns false when an address for the
+// program, version, and netid is already registered.
+func (s *Server) Set(r RPCB) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, e := range s.entries {
+		if e.Prog == r.Prog && e.Vers == r.Vers && e.NetID == r.NetID {
+			return false
+		}
+	}
+	s.entries = append(s.entries, r)
+	return true
+}
+
+// Unset unregisters the addresses of the program and version of the passed
+// address with its netid or with all netids when it is empty.  It returns
+// whether or not any addresses were unregistered.
+func (s *Server) Unset(r RPCB) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries := s.entries[:0]
+	for _, e := range s.entries {
+		if e.Prog == r.Prog && e.Vers == r.Vers &&
+			(r.NetID == "" || e.NetID == r.NetID) {
+
+			continue
+		}
+		entries = append(entries, e)
+	}
+	unset := len(entries) != len(s.entries)
+	s.entries = entries
+	return unset
+}
+
+// Dump returns all registered addresses.
+func (s *Server) Dump() []RPCB {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return append([]RPCB(nil), s.entries...)
+}
+
+// lookup returns the address registered for the passed program, version, and
+// netid and whether or not there is one.
+func (s *Server) lookup(prog, vers uint32, netid string) (RPCB, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, e := range s.entries {
+		if e.Prog == prog && e.Vers == vers && e.NetID == netid {
+			return e, true
+		}
+	}
+	return RPCB{}, false
+}
+
+// getPort returns the port registered for the passed program, version, and
+// protocol or zero when there is none.
+func (s *Server) getPort(prog, vers, prot uint32) uint32 {
+	e, ok := s.lookup(prog, vers, protoNetIDs[prot])
+	if !ok {
+		return 0
+	}
+	_, port, err := splitUniversalAddr(e.Addr)
+	if err != nil {
+		return 0
+	}
+	return uint32(port)
+}
+
+// forward calls the passed procedure of a program served over UDP at the
+// passed port of the local host with the credentials of the passed request
+// and returns the encoded results.
+func (s *Server) forward(r *oncrpc.Request, args *CallArgs, port uint32) ([]byte, error) {
+	addr := net.JoinHostPort("127.0.0.1", strconv.Itoa(int(port)))
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(callItTimeout))
+
+	var buf bytes.Buffer
+	call := oncrpc.Message{
+		XID:  atomic.AddUint32(&s.xid, 1),
+		Type: oncrpc.Call,
+		Call: oncrpc.CallBody{
+			RPCVers: oncrpc.RPCVersion,
+			Prog:    args.Prog,
+			Vers:    args.Vers,
+			Proc:    args.Proc,
+			Cred:    r.Cred,
+			Verf:    r.Verf,
+		},
+	}
+	if _, err := xdr.Marshal(&buf, &call); err != nil {
+		return nil, err
+	}
+	buf.Write(args.Args)
+	if _, err := conn.Write(buf.Bytes()); err != nil {
+		return nil, err
+	}
+
+	data := make([]byte, oncrpc.MaxDatagramSize)
+	for {
+		n, err := conn.Read(data)
+		if err != nil {
+			return nil, err
+		}
+		var reply oncrpc.Message
+		rr := bytes.NewReader(data[:n])
+		if _, err := xdr.Unmarshal(rr, &reply); err != nil {
+			continue
+		}
+		if reply.Type != oncrpc.Reply || reply.XID != call.XID {
+			continue
+		}
+		if reply.Reply.Stat != oncrpc.MsgAccepted ||
+			reply.Reply.Accepted.Stat != oncrpc.Success {
+
+			return nil, errors.New("portmapper: forwarded call " +
+				"failed")
+		}
+		return data[n-rr.Len() : n], nil
+	}
+}
+
+// pmapSet handles the version 2 SET procedure.  Mappings with an unknown
+// protocol or a port which does not fit in 16 bits are rejected.
+func (s *Server) pmapSet(r *oncrpc.Request) (interface{}, error) {
+	var m Mapping
+	if err := r.Args(&m); err != nil {
+		return nil, err
+	}
+	netid, ok := protoNetIDs[m.Prot]
+	if !ok || m.Port > 0xffff {
+		return false, nil
+	}
+	addr := fmt.Sprintf("0.0.0.0.%d.%d", m.Port>>8&0xff, m.Port&0xff)
+	return s.Set(RPCB{m.Prog, m.Vers, netid, addr, ""}), nil
+}
+
+// pmapUnset handles the version 2 UNSET procedure.
+func (s *Server) pmapUnset(r *oncrpc.Request) (interface{}, error) {
+	var m Mapping
+	if err := r.Args(&m); err != nil {
+		return nil, err
+	}
+	return s.Unset(RPCB{Prog: m.Prog, Vers: m.Vers}), nil
+}
+
+// pmapGetPort handles the version 2 GETPORT procedure.
+func (s *Server) pmapGetPort(r *oncrpc.Request) (interface{}, error) {
+	var m Mapping
+	if err := r.Args(&m); err != nil {
+		return nil, err
+	}
+	return s.getPort(m.Prog, m.Vers, m.Prot), nil
+}
+
+// pmapDump handles the version 2 DUMP procedure.
+func (s *Server) pmapDump(r *oncrpc.Request) (interface{}, error) {
+	var ms []Mapping
+	for _, e := range s.Dump() {
+		for prot, netid := range protoNetIDs {
+			if e.NetID != netid {
+				continue
+			}
+			_, port, err := splitUniversalAddr(e.Addr)
+			if err != nil {
+				continue
+			}
+			ms = append(ms, Mapping{e.Prog, e.Vers, prot,
+				uint32(port)})
+		}
+	}
+	return newPmapListPtr(ms), nil
+}
+
+// pmapCallIt handles the version 2 CALLIT procedure.  Calls to programs which
+// are not registered for UDP and calls which fail are not replied to.
+func (s *Server) pmapCallIt(r *oncrpc.Request) (interface{}, error) {
+	var args CallArgs
+	if err := r.Args(&args); err != nil {
+		return nil, err
+	}
+	port := s.getPort(args.Prog, args.Vers, IPProtoUDP)
+	if port == 0 {
+		return nil, oncrpc.ErrNoReply
+	}
+	res, err := s.forward(r, &args, port)
+	if err != nil {
+		return nil, oncrpc.ErrNoReply
+	}
+	return &CallResult{Port: port, Res: res}, nil
+}
+
+// rpcbSet handles the version 3 and 4 SET procedure.
+func (s *Server) rpcbSet(r *oncrpc.Request) (interface{}, error) {
+	var args RPCB
+	if err := r.Args(&args); err != nil {
+		return nil, err
+	}
+	return s.Set(args), nil
+}
+
+// rpcbUnset handles the version 3 and 4 UNSET procedure.
+func (s *Server) rpcbUnset(r *oncrpc.Request) (interface{}, error) {
+	var args RPCB
+	if err := r.Args(&args); err != nil {
+		return nil, err
+	}
+	return s.Unset(args), nil
+}
+
+// rpcbGetAddr handles the version 3 and 4 GETADDR procedure.  An empty netid
+// selects the transport the call was received over.
+func (s *Server) rpcbGetAddr(r *oncrpc.Request) (interface{}, error) {
+	var args RPCB
+	if err := r.Args(&args); err != nil {
+		return nil, err
+	}
+	if args.NetID == "" && r.Addr != nil {
+		args.NetID = r.Addr.Network()
+	}
+	e, _ := s.lookup(args.Prog, args.Vers, args.NetID)
+	return e.Addr, nil
+}
+
+// rpcbDump handles the version 3 and 4 DUMP procedure.
+func (s *Server) rpcbDump(r *oncrpc.Request) (interface{}, error) {
+	return newRPCBListPtr(s.Dump()), nil
+}
+
+// rpcbGetAddrList handles the version 4 GETADDRLIST procedure.
+func (s *Server) rpcbGetAddrList(r *oncrpc.Request) (interface{}, error) {
+	var args RPCB
+	if err := r.Args(&args); err != nil {
+		return nil, err
+	}
+	var es []RPCBEntry
+	for _, e := range s.Dump() {
+		if e.Prog != args.Prog || e.Vers != args.Vers {
+			continue
+		}
+		entry, ok := netIDEntries[e.NetID]
+		if !ok {
+			entry = RPCBEntry{ProtoFmly: "-", Proto: "-"}
+		}
+		entry.MAddr = e.Addr
+		entry.NetID = e.NetID
+		es = append(es, entry)
+	}
+	return newEntryListPtr(es), nil
+}
+
+// Register registers the procedures of the portmapper and rpcbind protocols
+// with the passed oncrpc.Server.
+func (s *Server) Register(rs *oncrpc.Server) {
+	rs.Handle(Prog, Version2, ProcSet, s.pmapSet)
+	rs.Handle(Prog, Version2, ProcUnset, s.pmapUnset)
+	rs.Handle(Prog, Version2, ProcGetPort, s.pmapGetPort)
+	rs.Handle(Prog, Version2, ProcDump, s.pmapDump)
+	rs.Handle(Prog, Version2, ProcCallIt, s.pmapCallIt)
+	for _, vers := range []uint32{Version3, Version4} {
+		rs.Handle(Prog, vers, RPCBProcSet, s.rpcbSet)
+		rs.Handle(Prog, vers, RPCBProcUnset, s.rpcbUnset)
+		rs.Handle(Prog, vers, RPCBProcGetAddr, s.rpcbGetAddr)
+		rs.Handle(Prog, vers, RPCBProcDump, s.rpcbDump)
+	}
+	rs.Handle(Prog, Version4, RPCBProcGetAddrList, s.rpcbGetAddrList)
+}
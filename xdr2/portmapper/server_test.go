@@ -0,0 +1,266 @@
+/*
+ * Copyright (c) 2014 Dave Collins <dave@davec.name>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package portmapper_test
+
+import (
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/davecgh/go-xdr/xdr2/oncrpc"
+	. "github.com/davecgh/go-xdr/xdr2/portmapper"
+)
+
+// Program registered with the portmapper by the tests.
+const (
+	testProg = 0x20000001
+	testVers = 1
+	procAdd  = 1 // Replies with the sum of two unsigned integers
+)
+
+// serve serves the passed server over TCP and UDP on the loopback interface
+// and returns their addresses along with a function which shuts them down.
+func serve(t *testing.T, s *oncrpc.Server) (*net.TCPAddr, *net.UDPAddr, func()) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen unexpected error: %v", err)
+	}
+	go s.Serve(ln)
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		ln.Close()
+		t.Fatalf("ListenPacket unexpected error: %v", err)
+	}
+	go s.ServePacket(pc)
+
+	return ln.Addr().(*net.TCPAddr), pc.LocalAddr().(*net.UDPAddr), func() {
+		ln.Close()
+		pc.Close()
+	}
+}
+
+// pmapList mirrors the XDR definition of the list of mappings returned by the
+// version 2 DUMP procedure.
+type pmapList struct {
+	Map  Mapping
+	Next *pmapList `xdr:"optional"`
+}
+
+// TestServer ensures the portmapper and rpcbind procedures of a Server behave
+// as expected when called with a Client over TCP and UDP.
+func TestServer(t *testing.T) {
+	var pm Server
+	var rs oncrpc.Server
+	pm.Register(&rs)
+	pmTCP, pmUDP, done := serve(t, &rs)
+	defer done()
+
+	// A service registered with the portmapper.
+	var svc oncrpc.Server
+	svc.Handle(testProg, testVers, procAdd, func(r *oncrpc.Request) (interface{}, error) {
+		var args [2]uint32
+		if err := r.Args(&args); err != nil {
+			return nil, err
+		}
+		return args[0] + args[1], nil
+	})
+	svcTCP, svcUDP, svcDone := serve(t, &svc)
+	defer svcDone()
+	tcpPort, udpPort := uint32(svcTCP.Port), uint32(svcUDP.Port)
+
+	for _, addr := range []net.Addr{pmTCP, pmUDP} {
+		c, err := Dial(addr.Network(), addr.String())
+		if err != nil {
+			t.Fatalf("Dial unexpected error: %v", err)
+		}
+		defer c.Close()
+
+		// Register the service for both protocols.  Registering it
+		// again fails.
+		mappings := []Mapping{
+			{testProg, testVers, IPProtoTCP, tcpPort},
+			{testProg, testVers, IPProtoUDP, udpPort},
+		}
+		for i, m := range mappings {
+			ok, err := c.Set(m)
+			if err != nil || !ok {
+				t.Fatalf("Set #%d got %v, %v want true", i, ok,
+					err)
+			}
+			ok, err = c.Set(m)
+			if err != nil || ok {
+				t.Fatalf("Set #%d got %v, %v want false", i, ok,
+					err)
+			}
+		}
+
+		// Mappings with an unknown protocol or a port which does not
+		// fit in 16 bits are rejected.
+		invalid := []Mapping{
+			{testProg, 2, 0, tcpPort},
+			{testProg, 2, IPProtoTCP, 0x10000 + tcpPort},
+		}
+		for i, m := range invalid {
+			ok, err := c.Set(m)
+			if err != nil || ok {
+				t.Errorf("Set invalid #%d got %v, %v want false",
+					i, ok, err)
+			}
+		}
+
+		// Look up the service with each protocol version.
+		for i, m := range mappings {
+			port, err := c.GetPort(m.Prog, m.Vers, m.Prot)
+			if err != nil || port != m.Port {
+				t.Errorf("GetPort #%d got %d, %v want %d", i,
+					port, err, m.Port)
+			}
+		}
+		if port, _ := c.GetPort(testProg, 2, IPProtoTCP); port != 0 {
+			t.Errorf("GetPort got %d for unregistered version", port)
+		}
+		dump, err := c.Dump()
+		if err != nil || !reflect.DeepEqual(dump, mappings) {
+			t.Errorf("Dump got %v, %v want %v", dump, err, mappings)
+		}
+		for _, vers := range []uint32{Version3, Version4} {
+			c.RPCBVers = vers
+			uaddr, err := c.GetAddr(testProg, testVers, "udp")
+			want := "0.0.0.0." + portOctets(udpPort)
+			if err != nil || uaddr != want {
+				t.Errorf("GetAddr v%d got %q, %v want %q", vers,
+					uaddr, err, want)
+			}
+
+			// An empty netid selects the transport of the call.
+			uaddr, err = c.GetAddr(testProg, testVers, "")
+			want = "0.0.0.0." + portOctets(tcpPort)
+			if addr.Network() == "udp" {
+				want = "0.0.0.0." + portOctets(udpPort)
+			}
+			if err != nil || uaddr != want {
+				t.Errorf("GetAddr v%d got %q, %v want %q", vers,
+					uaddr, err, want)
+			}
+		}
+		entries, err := c.GetAddrList(testProg, testVers)
+		wantEntries := []RPCBEntry{
+			{"0.0.0.0." + portOctets(tcpPort), "tcp", NCTPICotsOrd,
+				"inet", "tcp"},
+			{"0.0.0.0." + portOctets(udpPort), "udp", NCTPIClts,
+				"inet", "udp"},
+		}
+		if err != nil || !reflect.DeepEqual(entries, wantEntries) {
+			t.Errorf("GetAddrList got %v, %v want %v", entries, err,
+				wantEntries)
+		}
+
+		// Call the service through the portmapper.
+		c.RPC().Timeout = 2 * time.Second
+		var sum uint32
+		port, err := c.CallIt(testProg, testVers, procAdd,
+			[2]uint32{2, 3}, &sum)
+		if err != nil || port != udpPort || sum != 5 {
+			t.Errorf("CallIt got %d, %d, %v want %d, 5", port, sum,
+				err, udpPort)
+		}
+
+		// Calls through the portmapper which fail are not replied to.
+		c.RPC().Timeout = 100 * time.Millisecond
+		_, err = c.CallIt(testProg, testVers, 9, nil, nil)
+		if err != oncrpc.ErrTimeout {
+			t.Errorf("CallIt got error %v want %v", err,
+				oncrpc.ErrTimeout)
+		}
+
+		// The registrations are removed for all protocols.
+		ok, err := c.Unset(Mapping{Prog: testProg, Vers: testVers})
+		if err != nil || !ok {
+			t.Errorf("Unset got %v, %v want true", ok, err)
+		}
+		ok, err = c.Unset(Mapping{Prog: testProg, Vers: testVers})
+		if err != nil || ok {
+			t.Errorf("Unset got %v, %v want false", ok, err)
+		}
+		if dump, _ := c.Dump(); len(dump) != 0 {
+			t.Errorf("Dump got %v after Unset", dump)
+		}
+	}
+}
+
+// portOctets returns the last two components of the universal address of the
+// passed port.
+func portOctets(port uint32) string {
+	uaddr, _ := UniversalAddr(&net.TCPAddr{Port: int(port)})
+	return uaddr[len("0.0.0.0."):]
+}
+
+// TestServerRPCB ensures registrations made with the rpcbind protocol are
+// visible with the portmapper protocol and that the lists of registrations are
+// encoded as XDR linked lists.
+func TestServerRPCB(t *testing.T) {
+	var pm Server
+	var rs oncrpc.Server
+	pm.Register(&rs)
+	pmTCP, _, done := serve(t, &rs)
+	defer done()
+
+	c, err := oncrpc.Dial("tcp", pmTCP.String())
+	if err != nil {
+		t.Fatalf("Dial unexpected error: %v", err)
+	}
+	defer c.Close()
+
+	entries := []RPCB{
+		{testProg, testVers, "tcp", "127.0.0.1.8.1", "owner"},
+		{testProg, testVers, "local", "/run/test.sock", "owner"},
+	}
+	for i, e := range entries {
+		var ok bool
+		err := c.Call(Prog, Version3, RPCBProcSet, &e, &ok)
+		if err != nil || !ok {
+			t.Fatalf("SET #%d got %v, %v want true", i, ok, err)
+		}
+	}
+	if got := pm.Dump(); !reflect.DeepEqual(got, entries) {
+		t.Errorf("Dump got %v want %v", got, entries)
+	}
+
+	// Only the TCP registration is a version 2 mapping.
+	var list struct {
+		Head *pmapList `xdr:"optional"`
+	}
+	if err := c.Call(Prog, Version2, ProcDump, nil, &list); err != nil {
+		t.Fatalf("DUMP unexpected error: %v", err)
+	}
+	want := &pmapList{Map: Mapping{testProg, testVers, IPProtoTCP, 2049}}
+	if !reflect.DeepEqual(list.Head, want) {
+		t.Errorf("DUMP got %+v want %+v", list.Head, want)
+	}
+
+	// Unregister only the local transport.
+	var ok bool
+	e := RPCB{Prog: testProg, Vers: testVers, NetID: "local"}
+	if err := c.Call(Prog, Version4, RPCBProcUnset, &e, &ok); err != nil {
+		t.Fatalf("UNSET unexpected error: %v", err)
+	}
+	if got := pm.Dump(); !ok || !reflect.DeepEqual(got, entries[:1]) {
+		t.Errorf("Dump got %v want %v", got, entries[:1])
+	}
+}
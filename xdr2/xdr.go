@@ -0,0 +1,143 @@
+/*
+ * Copyright (c) 2014 Dave Collins <dave@davec.name>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package xdr
+
+import (
+	"io"
+	"math/big"
+	"reflect"
+
+	"github.com/davecgh/go-xdr/xdr3"
+)
+
+// Types of the xdr3 package.
+type (
+	Decoder        = xdr.Decoder
+	DecoderOptions = xdr.DecoderOptions
+	Encoder        = xdr.Encoder
+	ErrorCode      = xdr.ErrorCode
+	MarshalError   = xdr.MarshalError
+	Marshaler      = xdr.Marshaler
+	Quadruple      = xdr.Quadruple
+	RecordReader   = xdr.RecordReader
+	RecordWriter   = xdr.RecordWriter
+	UnmarshalError = xdr.UnmarshalError
+	Unmarshaler    = xdr.Unmarshaler
+)
+
+// Error codes of the xdr3 package.
+const (
+	ErrBadArguments    = xdr.ErrBadArguments
+	ErrUnsupportedType = xdr.ErrUnsupportedType
+	ErrBadEnumValue    = xdr.ErrBadEnumValue
+	ErrNotSettable     = xdr.ErrNotSettable
+	ErrOverflow        = xdr.ErrOverflow
+	ErrNilInterface    = xdr.ErrNilInterface
+	ErrIO              = xdr.ErrIO
+	ErrParseTime       = xdr.ErrParseTime
+	ErrBadDiscriminant = xdr.ErrBadDiscriminant
+	ErrMaxTotalBytes   = xdr.ErrMaxTotalBytes
+	ErrMaxElements     = xdr.ErrMaxElements
+	ErrMaxDepth        = xdr.ErrMaxDepth
+	ErrMaxAllocBytes   = xdr.ErrMaxAllocBytes
+	ErrNonzeroPadding  = xdr.ErrNonzeroPadding
+	ErrTrailingBytes   = xdr.ErrTrailingBytes
+	ErrNonCanonicalNaN = xdr.ErrNonCanonicalNaN
+	ErrDuplicateKey    = xdr.ErrDuplicateKey
+	ErrCycle           = xdr.ErrCycle
+)
+
+// Record marking fragment sizes of the xdr3 package.
+const (
+	MaxFragmentSize     = xdr.MaxFragmentSize
+	DefaultFragmentSize = xdr.DefaultFragmentSize
+)
+
+// Marshal is xdr3.Marshal.
+func Marshal(w io.Writer, v interface{}) (int, error) {
+	return xdr.Marshal(w, v)
+}
+
+// Unmarshal is xdr3.Unmarshal.
+func Unmarshal(r io.Reader, v interface{}) (int, error) {
+	return xdr.Unmarshal(r, v)
+}
+
+// UnmarshalLimited is xdr3.UnmarshalLimited.
+func UnmarshalLimited(r io.Reader, v interface{}, maxSize uint) (int, error) {
+	return xdr.UnmarshalLimited(r, v, maxSize)
+}
+
+// UnmarshalWithOptions is xdr3.UnmarshalWithOptions.
+func UnmarshalWithOptions(r io.Reader, v interface{}, opts DecoderOptions) (int, error) {
+	return xdr.UnmarshalWithOptions(r, v, opts)
+}
+
+// NewEncoder is xdr3.NewEncoder.
+func NewEncoder(w io.Writer) *Encoder {
+	return xdr.NewEncoder(w)
+}
+
+// NewDecoder is xdr3.NewDecoder.
+func NewDecoder(r io.Reader) *Decoder {
+	return xdr.NewDecoder(r)
+}
+
+// NewDecoderLimited is xdr3.NewDecoderLimited.
+func NewDecoderLimited(r io.Reader, maxSize uint) *Decoder {
+	return xdr.NewDecoderLimited(r, maxSize)
+}
+
+// NewDecoderWithOptions is xdr3.NewDecoderWithOptions.
+func NewDecoderWithOptions(r io.Reader, opts DecoderOptions) *Decoder {
+	return xdr.NewDecoderWithOptions(r, opts)
+}
+
+// IsIO is xdr3.IsIO.
+func IsIO(err error) bool {
+	return xdr.IsIO(err)
+}
+
+// QuadrupleFromFloat64 is xdr3.QuadrupleFromFloat64.
+func QuadrupleFromFloat64(f float64) Quadruple {
+	return xdr.QuadrupleFromFloat64(f)
+}
+
+// QuadrupleFromBig is xdr3.QuadrupleFromBig.
+func QuadrupleFromBig(x *big.Float, mode big.RoundingMode) Quadruple {
+	return xdr.QuadrupleFromBig(x, mode)
+}
+
+// RegisterUnion is xdr3.RegisterUnion.
+func RegisterUnion(ifaceType reflect.Type, arms map[int32]reflect.Type) {
+	xdr.RegisterUnion(ifaceType, arms)
+}
+
+// NewRecordWriter is xdr3.NewRecordWriter.
+func NewRecordWriter(w io.Writer) *RecordWriter {
+	return xdr.NewRecordWriter(w)
+}
+
+// NewRecordWriterSize is xdr3.NewRecordWriterSize.
+func NewRecordWriterSize(w io.Writer, size int) *RecordWriter {
+	return xdr.NewRecordWriterSize(w, size)
+}
+
+// NewRecordReader is xdr3.NewRecordReader.
+func NewRecordReader(r io.Reader, maxSize uint) *RecordReader {
+	return xdr.NewRecordReader(r, maxSize)
+}
@@ -0,0 +1,252 @@
+/*
+ * Copyright (c) 2014 Dave Collins <dave@davec.name>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package xdr_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/davecgh/go-xdr/xdr2"
+	xdr3 "github.com/davecgh/go-xdr/xdr3"
+)
+
+// TestCompat ensures the package round trips values through the xdr3
+// implementation and returns its error types unchanged.
+func TestCompat(t *testing.T) {
+	type record struct {
+		ID   uint32
+		Name string
+		Data []byte
+	}
+	want := record{1, "xdr", []byte{0x01, 0x02}}
+
+	var buf bytes.Buffer
+	if _, err := xdr.Marshal(&buf, &want); err != nil {
+		t.Fatalf("Marshal unexpected error: %v", err)
+	}
+	v3, err := xdr3.AppendMarshal(nil, &want)
+	if err != nil {
+		t.Fatalf("AppendMarshal unexpected error: %v", err)
+	}
+	if !bytes.Equal(buf.Bytes(), v3) {
+		t.Fatalf("Marshal got: %x want: %x", buf.Bytes(), v3)
+	}
+
+	var got record
+	if _, err := xdr.Unmarshal(&buf, &got); err != nil {
+		t.Fatalf("Unmarshal unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Unmarshal got: %v want: %v", got, want)
+	}
+
+	// Errors must be the xdr3 types so callers of either package can
+	// inspect them.
+	_, err = xdr.Unmarshal(bytes.NewReader(nil), &got)
+	uerr, ok := err.(*xdr3.UnmarshalError)
+	if !ok {
+		t.Fatalf("Unmarshal error got: %T want: *xdr3.UnmarshalError", err)
+	}
+	if uerr.ErrorCode != xdr.ErrIO || !xdr.IsIO(err) {
+		t.Fatalf("Unmarshal error code got: %v want: %v", uerr.ErrorCode,
+			xdr.ErrIO)
+	}
+	if !errors.Is(err, xdr.ErrIO) || !errors.Is(err, io.EOF) {
+		t.Fatalf("Unmarshal error got: %v want: %v", err, xdr.ErrIO)
+	}
+}
+
+// TestErrorCodes ensures the error codes of the package are those of the xdr3
+// package.
+func TestErrorCodes(t *testing.T) {
+	tests := []struct {
+		in   xdr.ErrorCode
+		want xdr3.ErrorCode
+	}{
+		{xdr.ErrBadArguments, xdr3.ErrBadArguments},
+		{xdr.ErrUnsupportedType, xdr3.ErrUnsupportedType},
+		{xdr.ErrBadEnumValue, xdr3.ErrBadEnumValue},
+		{xdr.ErrNotSettable, xdr3.ErrNotSettable},
+		{xdr.ErrOverflow, xdr3.ErrOverflow},
+		{xdr.ErrNilInterface, xdr3.ErrNilInterface},
+		{xdr.ErrIO, xdr3.ErrIO},
+		{xdr.ErrParseTime, xdr3.ErrParseTime},
+		{xdr.ErrBadDiscriminant, xdr3.ErrBadDiscriminant},
+		{xdr.ErrMaxTotalBytes, xdr3.ErrMaxTotalBytes},
+		{xdr.ErrMaxElements, xdr3.ErrMaxElements},
+		{xdr.ErrMaxDepth, xdr3.ErrMaxDepth},
+		{xdr.ErrMaxAllocBytes, xdr3.ErrMaxAllocBytes},
+		{xdr.ErrNonzeroPadding, xdr3.ErrNonzeroPadding},
+		{xdr.ErrTrailingBytes, xdr3.ErrTrailingBytes},
+		{xdr.ErrNonCanonicalNaN, xdr3.ErrNonCanonicalNaN},
+		{xdr.ErrDuplicateKey, xdr3.ErrDuplicateKey},
+		{xdr.ErrCycle, xdr3.ErrCycle},
+	}
+
+	for i, test := range tests {
+		if test.in != test.want || test.in.String() != test.want.String() {
+			t.Errorf("ErrorCode #%d got: %v want: %v", i, test.in,
+				test.want)
+		}
+	}
+}
+
+// compatShape is used to test RegisterUnion.
+type compatShape interface {
+	corners() int
+}
+
+// compatSquare is a compatShape used to test RegisterUnion.
+type compatSquare struct {
+	Side uint32
+}
+
+func (compatSquare) corners() int { return 4 }
+
+// TestFunctions ensures the functions of the package behave the same as those
+// of the xdr3 package.
+func TestFunctions(t *testing.T) {
+	// The encoding of uint32(1) and of the string "a" with nonzero
+	// padding.
+	one := []byte{0x00, 0x00, 0x00, 0x01}
+	badPad := []byte{0x00, 0x00, 0x00, 0x01, 0x61, 0x00, 0x00, 0x01}
+	long := []byte{0x00, 0x00, 0x00, 0x08}
+
+	xdr.RegisterUnion(reflect.TypeOf((*compatShape)(nil)).Elem(),
+		map[int32]reflect.Type{1: reflect.TypeOf(compatSquare{})})
+	type shapeUnion struct {
+		Kind  int32 `xdr:"union"`
+		Shape compatShape
+	}
+
+	tests := []struct {
+		name    string
+		fn      func() (interface{}, error)
+		want    interface{}
+		wantErr error
+	}{
+		{"Marshal", func() (interface{}, error) {
+			var buf bytes.Buffer
+			_, err := xdr.Marshal(&buf, uint32(1))
+			return buf.Bytes(), err
+		}, one, nil},
+		{"NewEncoder", func() (interface{}, error) {
+			var buf bytes.Buffer
+			_, err := xdr.NewEncoder(&buf).Encode(uint32(1))
+			return buf.Bytes(), err
+		}, one, nil},
+		{"Unmarshal", func() (interface{}, error) {
+			var v uint32
+			_, err := xdr.Unmarshal(bytes.NewReader(one), &v)
+			return v, err
+		}, uint32(1), nil},
+		{"UnmarshalLimited", func() (interface{}, error) {
+			var v []byte
+			_, err := xdr.UnmarshalLimited(bytes.NewReader(long),
+				&v, 4)
+			return v, err
+		}, []byte(nil), xdr.ErrOverflow},
+		{"UnmarshalWithOptions", func() (interface{}, error) {
+			var v uint32
+			opts := xdr.DecoderOptions{MaxTotalBytes: 2}
+			_, err := xdr.UnmarshalWithOptions(bytes.NewReader(one),
+				&v, opts)
+			return v, err
+		}, uint32(0), xdr.ErrMaxTotalBytes},
+		{"NewDecoder", func() (interface{}, error) {
+			var v uint32
+			_, err := xdr.NewDecoder(bytes.NewReader(one)).Decode(&v)
+			return v, err
+		}, uint32(1), nil},
+		{"NewDecoderLimited", func() (interface{}, error) {
+			var v string
+			d := xdr.NewDecoderLimited(bytes.NewReader(long), 4)
+			_, err := d.Decode(&v)
+			return v, err
+		}, "", xdr.ErrOverflow},
+		{"NewDecoderWithOptions", func() (interface{}, error) {
+			var v string
+			opts := xdr.DecoderOptions{Strict: true}
+			d := xdr.NewDecoderWithOptions(bytes.NewReader(badPad),
+				opts)
+			_, err := d.Decode(&v)
+			return v, err
+		}, "", xdr.ErrNonzeroPadding},
+		{"IsIO", func() (interface{}, error) {
+			var v uint32
+			_, err := xdr.Unmarshal(bytes.NewReader(nil), &v)
+			return xdr.IsIO(err), nil
+		}, true, nil},
+		{"QuadrupleFromFloat64", func() (interface{}, error) {
+			q := xdr.QuadrupleFromFloat64(1.5)
+			return q.Float64(big.ToNearestEven), nil
+		}, 1.5, nil},
+		{"QuadrupleFromBig", func() (interface{}, error) {
+			q := xdr.QuadrupleFromBig(big.NewFloat(-2),
+				big.ToNearestEven)
+			return q.Float64(big.ToNearestEven), nil
+		}, -2.0, nil},
+		{"RegisterUnion", func() (interface{}, error) {
+			var v shapeUnion
+			data := []byte{0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00,
+				0x03}
+			_, err := xdr3.Unmarshal(bytes.NewReader(data), &v)
+			return v, err
+		}, shapeUnion{1, compatSquare{3}}, nil},
+		{"NewRecordWriter", func() (interface{}, error) {
+			var buf bytes.Buffer
+			w := xdr.NewRecordWriter(&buf)
+			w.Write([]byte{0x01})
+			err := w.Close()
+			return buf.Bytes(), err
+		}, []byte{0x80, 0x00, 0x00, 0x01, 0x01}, nil},
+		{"NewRecordWriterSize", func() (interface{}, error) {
+			var buf bytes.Buffer
+			w := xdr.NewRecordWriterSize(&buf, 1)
+			w.Write([]byte{0x01, 0x02})
+			err := w.Close()
+			return buf.Bytes(), err
+		}, []byte{0x00, 0x00, 0x00, 0x01, 0x01, 0x80, 0x00, 0x00,
+			0x01, 0x02}, nil},
+		{"NewRecordReader", func() (interface{}, error) {
+			data := []byte{0x80, 0x00, 0x00, 0x02, 0x01, 0x02}
+			r := xdr.NewRecordReader(bytes.NewReader(data), 1)
+			return r.ReadRecord()
+		}, []byte(nil), xdr.ErrOverflow},
+	}
+
+	for _, test := range tests {
+		got, err := test.fn()
+		if test.wantErr != nil {
+			if !errors.Is(err, test.wantErr) {
+				t.Errorf("%s: error got: %v want: %v",
+					test.name, err, test.wantErr)
+			}
+		} else if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got: %v want: %v", test.name, got,
+				test.want)
+		}
+	}
+}
@@ -0,0 +1,350 @@
+/*
+ * Copyright (c) 2014 Dave Collins <dave@davec.name>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package xdrlang
+
+import "fmt"
+
+// Pos describes a position within the source of a specification.  Both the
+// line and column are 1-based.
+type Pos struct {
+	Line   int
+	Column int
+}
+
+// String returns the position in the form line:column.
+func (p Pos) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+// Node is implemented by all nodes of the syntax tree.
+type Node interface {
+	// Position returns the position the node was parsed from.
+	Position() Pos
+}
+
+// Specification is the root of the syntax tree and houses each definition in
+// the order it appears in the source.
+//
+// Reference:
+// 	RFC 4506 Section 6.3 - specification: definition *
+type Specification struct {
+	Definitions []Definition
+}
+
+// Definition is implemented by the top-level definitions of a specification.
+// It is one of *ConstDef, *TypeDef, or *ProgramDef.
+type Definition interface {
+	Node
+	definition()
+}
+
+// Type is implemented by the type specifiers of a declaration.  It is one of
+// *BasicType, *NamedType, *EnumType, *StructType, or *UnionType.
+type Type interface {
+	Node
+	typ()
+}
+
+// Value is either an integer constant or the name of a constant.
+//
+// Reference:
+// 	RFC 4506 Section 6.3 - value: constant | identifier
+type Value struct {
+	Pos  Pos
+	Name string // Name of the referenced constant, empty for literals
+	Int  int64  // Literal value when Name is empty
+}
+
+// IsConst returns whether or not the value is a literal constant as opposed to
+// a reference to a named constant.
+func (v *Value) IsConst() bool {
+	return v.Name == ""
+}
+
+// String returns the name of the referenced constant or the literal value.
+func (v *Value) String() string {
+	if v.Name != "" {
+		return v.Name
+	}
+	return fmt.Sprintf("%d", v.Int)
+}
+
+// Position returns the position the value was parsed from.
+func (v *Value) Position() Pos { return v.Pos }
+
+// ConstDef is a constant definition.
+//
+// Reference:
+// 	RFC 4506 Section 6.3 - constant-def
+type ConstDef struct {
+	Pos   Pos
+	Name  string
+	Value Value
+}
+
+// Position returns the position the definition was parsed from.
+func (d *ConstDef) Position() Pos { return d.Pos }
+func (d *ConstDef) definition()   {}
+
+// TypeDef is a type definition.  A typedef is represented by the declaration
+// it defines while the enum, struct, and union forms which name the type
+// directly are represented by a scalar declaration of the type with the
+// defined name.  For example, "struct point {...};" is represented the same as
+// "typedef struct {...} point;".
+//
+// Reference:
+// 	RFC 4506 Section 6.3 - type-def
+type TypeDef struct {
+	Pos  Pos
+	Decl *Decl
+}
+
+// Name returns the name of the defined type.
+func (d *TypeDef) Name() string { return d.Decl.Name }
+
+// Position returns the position the definition was parsed from.
+func (d *TypeDef) Position() Pos { return d.Pos }
+func (d *TypeDef) definition()   {}
+
+// DeclKind identifies the form of a declaration.
+type DeclKind int
+
+const (
+	// DeclScalar is a single value of the declared type.
+	DeclScalar DeclKind = iota
+
+	// DeclFixedArray is a fixed-length array or fixed-length opaque data.
+	// Its length is the Size of the declaration.
+	DeclFixedArray
+
+	// DeclVarArray is a variable-length array, variable-length opaque data,
+	// or a string.  Its maximum length is the Size of the declaration which
+	// is nil when there is no maximum.
+	DeclVarArray
+
+	// DeclOptional is optional-data.
+	DeclOptional
+
+	// DeclVoid is void.  Declarations of this kind do not have a name or
+	// type.
+	DeclVoid
+)
+
+// Map of DeclKind values back to their constant names for pretty printing.
+var declKindStrings = map[DeclKind]string{
+	DeclScalar:     "DeclScalar",
+	DeclFixedArray: "DeclFixedArray",
+	DeclVarArray:   "DeclVarArray",
+	DeclOptional:   "DeclOptional",
+	DeclVoid:       "DeclVoid",
+}
+
+// String returns the DeclKind as a human-readable name.
+func (k DeclKind) String() string {
+	if s := declKindStrings[k]; s != "" {
+		return s
+	}
+	return fmt.Sprintf("Unknown DeclKind (%d)", k)
+}
+
+// Decl is a declaration of a named value of a type such as a struct field,
+// union arm, or typedef.
+//
+// Reference:
+// 	RFC 4506 Section 6.3 - declaration
+type Decl struct {
+	Pos  Pos
+	Kind DeclKind
+	Name string
+	Type Type
+	Size *Value
+}
+
+// Position returns the position the declaration was parsed from.
+func (d *Decl) Position() Pos { return d.Pos }
+
+// BasicKind identifies a built-in type.
+type BasicKind int
+
+// These constants identify each of the built-in types.
+const (
+	Int BasicKind = iota
+	UnsignedInt
+	Hyper
+	UnsignedHyper
+	Float
+	Double
+	Quadruple
+	Bool
+	Opaque
+	String
+)
+
+// Map of BasicKind values back to their XDR language spelling.
+var basicKindStrings = map[BasicKind]string{
+	Int:           "int",
+	UnsignedInt:   "unsigned int",
+	Hyper:         "hyper",
+	UnsignedHyper: "unsigned hyper",
+	Float:         "float",
+	Double:        "double",
+	Quadruple:     "quadruple",
+	Bool:          "bool",
+	Opaque:        "opaque",
+	String:        "string",
+}
+
+// String returns the BasicKind as it is spelled in the XDR language.
+func (k BasicKind) String() string {
+	if s := basicKindStrings[k]; s != "" {
+		return s
+	}
+	return fmt.Sprintf("Unknown BasicKind (%d)", k)
+}
+
+// BasicType is one of the built-in types.  Opaque and String only appear in
+// array declarations.
+type BasicType struct {
+	Pos  Pos
+	Kind BasicKind
+}
+
+// Position returns the position the type was parsed from.
+func (t *BasicType) Position() Pos { return t.Pos }
+func (t *BasicType) typ()          {}
+
+// NamedType is a reference to a type defined elsewhere by name.
+type NamedType struct {
+	Pos  Pos
+	Name string
+}
+
+// Position returns the position the type was parsed from.
+func (t *NamedType) Position() Pos { return t.Pos }
+func (t *NamedType) typ()          {}
+
+// EnumMember is a named value of an enumeration.
+type EnumMember struct {
+	Pos   Pos
+	Name  string
+	Value Value
+}
+
+// Position returns the position the member was parsed from.
+func (m *EnumMember) Position() Pos { return m.Pos }
+
+// EnumType is an enumeration.
+//
+// Reference:
+// 	RFC 4506 Section 6.3 - enum-type-spec
+type EnumType struct {
+	Pos     Pos
+	Members []*EnumMember
+}
+
+// Position returns the position the type was parsed from.
+func (t *EnumType) Position() Pos { return t.Pos }
+func (t *EnumType) typ()          {}
+
+// StructType is a structure.
+//
+// Reference:
+// 	RFC 4506 Section 6.3 - struct-type-spec
+type StructType struct {
+	Pos    Pos
+	Fields []*Decl
+}
+
+// Position returns the position the type was parsed from.
+func (t *StructType) Position() Pos { return t.Pos }
+func (t *StructType) typ()          {}
+
+// UnionCase is an arm of a discriminated union along with the discriminant
+// values which select it.
+//
+// Reference:
+// 	RFC 4506 Section 6.3 - case-spec
+type UnionCase struct {
+	Pos    Pos
+	Values []Value
+	Decl   *Decl
+}
+
+// Position returns the position the case was parsed from.
+func (c *UnionCase) Position() Pos { return c.Pos }
+
+// UnionType is a discriminated union.  Default is nil when the union does not
+// have a default arm.
+//
+// Reference:
+// 	RFC 4506 Section 6.3 - union-type-spec
+type UnionType struct {
+	Pos          Pos
+	Discriminant *Decl
+	Cases        []*UnionCase
+	Default      *Decl
+}
+
+// Position returns the position the type was parsed from.
+func (t *UnionType) Position() Pos { return t.Pos }
+func (t *UnionType) typ()          {}
+
+// ProgramDef is an ONC RPC program definition.
+//
+// Reference:
+// 	RFC 5531 Section 12.2 - program-def
+type ProgramDef struct {
+	Pos      Pos
+	Name     string
+	Number   Value
+	Versions []*VersionDef
+}
+
+// Position returns the position the definition was parsed from.
+func (d *ProgramDef) Position() Pos { return d.Pos }
+func (d *ProgramDef) definition()   {}
+
+// VersionDef is a version of an ONC RPC program.
+//
+// Reference:
+// 	RFC 5531 Section 12.2 - version-def
+type VersionDef struct {
+	Pos        Pos
+	Name       string
+	Number     Value
+	Procedures []*ProcDef
+}
+
+// Position returns the position the definition was parsed from.
+func (d *VersionDef) Position() Pos { return d.Pos }
+
+// ProcDef is a procedure of a version of an ONC RPC program.  Result is nil
+// for procedures which return void and Args is empty for procedures which take
+// void.
+//
+// Reference:
+// 	RFC 5531 Section 12.2 - procedure-def
+type ProcDef struct {
+	Pos    Pos
+	Name   string
+	Number Value
+	Result Type
+	Args   []Type
+}
+
+// Position returns the position the definition was parsed from.
+func (d *ProcDef) Position() Pos { return d.Pos }
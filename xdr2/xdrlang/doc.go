@@ -0,0 +1,57 @@
+/*
+ * Copyright (c) 2014 Dave Collins <dave@davec.name>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+/*
+Package xdrlang implements a parser for the XDR data description language as
+specified in RFC 4506 Section 6 along with the program, version, and procedure
+definitions of the ONC RPC language as specified in RFC 5531 Section 12.
+
+The Parse function turns the contents of an XDR specification file (typically
+.x extension) into a Specification which holds a typed abstract syntax tree of
+each of the definitions in the file.  Every node of the tree records the line
+and column it was parsed from.
+
+The following constructs are supported:
+
+	const NAME = 10;
+	typedef unsigned int uint32;
+	typedef opaque fixed[16];
+	typedef opaque variable<>;
+	typedef string name<255>;
+	typedef int ids<MAXIDS>;
+	typedef entry *entryptr;
+	enum color { RED = 0, GREEN = 1 };
+	struct point { int x; int y; };
+	union result switch (int status) {
+	case 0:
+		opaque data<>;
+	default:
+		void;
+	};
+	program PROG {
+		version VERS {
+			void PROC_NULL(void) = 0;
+		} = 1;
+	} = 0x20000001;
+
+Both C style block comments and // line comments are ignored, as are lines which start
+with % or # since they are passthrough and preprocessor directives typically
+found in files intended for rpcgen.
+
+Syntax errors are returned as an *Error which carries the line and column the
+error was detected at.
+*/
+package xdrlang
@@ -0,0 +1,199 @@
+/*
+ * Copyright (c) 2014 Dave Collins <dave@davec.name>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package xdrlang
+
+import "fmt"
+
+// tokenKind identifies the kind of a lexical token.
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokNumber
+	tokPunct
+)
+
+// Map of tokenKind values to descriptions for error messages.
+var tokenKindStrings = map[tokenKind]string{
+	tokEOF:    "end of file",
+	tokIdent:  "identifier",
+	tokNumber: "number",
+	tokPunct:  "punctuation",
+}
+
+// String returns a human-readable description of the token kind.
+func (k tokenKind) String() string {
+	if s := tokenKindStrings[k]; s != "" {
+		return s
+	}
+	return fmt.Sprintf("Unknown tokenKind (%d)", k)
+}
+
+// token is a lexical token along with the position it starts at.
+type token struct {
+	kind tokenKind
+	text string
+	pos  Pos
+}
+
+// String returns a description of the token for use in error messages.
+func (t token) String() string {
+	if t.kind == tokEOF {
+		return t.kind.String()
+	}
+	return fmt.Sprintf("'%s'", t.text)
+}
+
+// lexer splits the source of a specification into tokens.
+type lexer struct {
+	src  []byte
+	off  int
+	line int
+	col  int
+}
+
+// newLexer returns a lexer for the passed source.
+func newLexer(src []byte) *lexer {
+	return &lexer{src: src, line: 1, col: 1}
+}
+
+// peekByte returns the byte at the passed offset from the current position or
+// 0 when it is past the end of the source.
+func (l *lexer) peekByte(n int) byte {
+	if l.off+n >= len(l.src) {
+		return 0
+	}
+	return l.src[l.off+n]
+}
+
+// advance moves past the next n bytes while tracking the line and column.
+func (l *lexer) advance(n int) {
+	for i := 0; i < n && l.off < len(l.src); i++ {
+		if l.src[l.off] == '\n' {
+			l.line++
+			l.col = 1
+		} else {
+			l.col++
+		}
+		l.off++
+	}
+}
+
+// skipLine moves past the remainder of the current line.
+func (l *lexer) skipLine() {
+	for l.off < len(l.src) && l.src[l.off] != '\n' {
+		l.advance(1)
+	}
+}
+
+// skipSpace moves past whitespace, comments, and passthrough and preprocessor
+// lines.
+func (l *lexer) skipSpace() error {
+	for l.off < len(l.src) {
+		c := l.src[l.off]
+		switch {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n' ||
+			c == '\f' || c == '\v':
+
+			l.advance(1)
+
+		case (c == '%' || c == '#') && l.col == 1:
+			l.skipLine()
+
+		case c == '/' && l.peekByte(1) == '/':
+			l.skipLine()
+
+		case c == '/' && l.peekByte(1) == '*':
+			start := Pos{l.line, l.col}
+			l.advance(2)
+			for {
+				if l.off >= len(l.src) {
+					return &Error{Pos: start,
+						Msg: "unterminated comment"}
+				}
+				if l.src[l.off] == '*' && l.peekByte(1) == '/' {
+					l.advance(2)
+					break
+				}
+				l.advance(1)
+			}
+
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+// isLetter returns whether or not the passed byte can start an identifier.
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_'
+}
+
+// isDigit returns whether or not the passed byte is a decimal digit.
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// isHexDigit returns whether or not the passed byte is a hexadecimal digit.
+func isHexDigit(c byte) bool {
+	return isDigit(c) || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
+
+// next returns the next token from the source.
+func (l *lexer) next() (token, error) {
+	if err := l.skipSpace(); err != nil {
+		return token{}, err
+	}
+
+	pos := Pos{l.line, l.col}
+	if l.off >= len(l.src) {
+		return token{kind: tokEOF, pos: pos}, nil
+	}
+
+	start := l.off
+	c := l.src[l.off]
+	switch {
+	case isLetter(c):
+		for l.off < len(l.src) && (isLetter(l.src[l.off]) ||
+			isDigit(l.src[l.off])) {
+
+			l.advance(1)
+		}
+		return token{tokIdent, string(l.src[start:l.off]), pos}, nil
+
+	case isDigit(c) || c == '-' && isDigit(l.peekByte(1)):
+		l.advance(1)
+		if c == '0' && (l.peekByte(0) == 'x' || l.peekByte(0) == 'X') {
+			l.advance(1)
+		}
+		for l.off < len(l.src) && isHexDigit(l.src[l.off]) {
+			l.advance(1)
+		}
+		return token{tokNumber, string(l.src[start:l.off]), pos}, nil
+	}
+
+	switch c {
+	case '{', '}', '[', ']', '<', '>', '(', ')', ';', ',', ':', '=', '*':
+		l.advance(1)
+		return token{tokPunct, string(c), pos}, nil
+	}
+
+	return token{}, &Error{Pos: pos,
+		Msg: fmt.Sprintf("unexpected character %q", c)}
+}
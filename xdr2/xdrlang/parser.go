@@ -0,0 +1,691 @@
+/*
+ * Copyright (c) 2014 Dave Collins <dave@davec.name>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package xdrlang
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// keywords houses the reserved words of the XDR and ONC RPC languages which
+// can't be used as identifiers.
+//
+// Reference:
+// 	RFC 4506 Section 6.4 - Syntax Notes
+// 	RFC 5531 Section 12.2 - Syntax Notes
+var keywords = map[string]bool{
+	"bool":      true,
+	"case":      true,
+	"const":     true,
+	"default":   true,
+	"double":    true,
+	"quadruple": true,
+	"enum":      true,
+	"float":     true,
+	"hyper":     true,
+	"int":       true,
+	"opaque":    true,
+	"string":    true,
+	"struct":    true,
+	"switch":    true,
+	"typedef":   true,
+	"union":     true,
+	"unsigned":  true,
+	"void":      true,
+	"program":   true,
+	"version":   true,
+}
+
+// basicTypes maps the keywords of built-in type specifiers to their kinds.
+var basicTypes = map[string]BasicKind{
+	"int":       Int,
+	"hyper":     Hyper,
+	"float":     Float,
+	"double":    Double,
+	"quadruple": Quadruple,
+	"bool":      Bool,
+}
+
+// parser is a recursive descent parser which produces the syntax tree of a
+// specification from the tokens of a lexer.
+type parser struct {
+	lex *lexer
+	tok token // Current token
+}
+
+// Parse parses the passed XDR language source and returns the resulting syntax
+// tree.
+//
+// An *Error which identifies the line and column of the issue is returned when
+// the source is not a valid specification.
+func Parse(src []byte) (*Specification, error) {
+	p := parser{lex: newLexer(src)}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+
+	spec := &Specification{}
+	for p.tok.kind != tokEOF {
+		def, err := p.parseDefinition()
+		if err != nil {
+			return nil, err
+		}
+		spec.Definitions = append(spec.Definitions, def)
+	}
+	return spec, nil
+}
+
+// next advances to the next token.
+func (p *parser) next() error {
+	tok, err := p.lex.next()
+	if err != nil {
+		return err
+	}
+	p.tok = tok
+	return nil
+}
+
+// errorf returns an *Error at the position of the current token.
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return &Error{Pos: p.tok.pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+// is returns whether or not the current token is the passed punctuation or
+// keyword.
+func (p *parser) is(text string) bool {
+	return (p.tok.kind == tokPunct || p.tok.kind == tokIdent) &&
+		p.tok.text == text
+}
+
+// expect consumes the current token when it is the passed punctuation or
+// keyword and returns an error otherwise.
+func (p *parser) expect(text string) error {
+	if !p.is(text) {
+		return p.errorf("expected '%s', found %s", text, p.tok)
+	}
+	return p.next()
+}
+
+// parseIdent consumes the current token when it is an identifier which is not
+// a keyword and returns its text.
+func (p *parser) parseIdent() (string, error) {
+	if p.tok.kind != tokIdent || keywords[p.tok.text] {
+		return "", p.errorf("expected identifier, found %s", p.tok)
+	}
+	name := p.tok.text
+	return name, p.next()
+}
+
+// parseValue parses an integer constant or the name of a constant.
+//
+// Reference:
+// 	RFC 4506 Section 6.3 - value: constant | identifier
+func (p *parser) parseValue() (Value, error) {
+	v := Value{Pos: p.tok.pos}
+	switch {
+	case p.tok.kind == tokNumber:
+		i, err := strconv.ParseInt(p.tok.text, 0, 64)
+		if err != nil {
+			return v, p.errorf("invalid constant %s", p.tok)
+		}
+		v.Int = i
+
+	case p.tok.kind == tokIdent && !keywords[p.tok.text]:
+		v.Name = p.tok.text
+
+	default:
+		return v, p.errorf("expected constant or identifier, found %s",
+			p.tok)
+	}
+	return v, p.next()
+}
+
+// parseDefinition parses a constant, type, or program definition.
+//
+// Reference:
+// 	RFC 4506 Section 6.3 - definition: type-def | constant-def
+// 	RFC 5531 Section 12.2 - definition: type-def | constant-def | program-def
+func (p *parser) parseDefinition() (Definition, error) {
+	pos := p.tok.pos
+	switch {
+	case p.is("const"):
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		name, err := p.parseIdent()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect("="); err != nil {
+			return nil, err
+		}
+		val, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(";"); err != nil {
+			return nil, err
+		}
+		return &ConstDef{Pos: pos, Name: name, Value: val}, nil
+
+	case p.is("typedef"):
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		declPos := p.tok.pos
+		decl, err := p.parseDecl()
+		if err != nil {
+			return nil, err
+		}
+		if decl.Kind == DeclVoid {
+			return nil, &Error{Pos: declPos, Msg: "typedef of void"}
+		}
+		if err := p.expect(";"); err != nil {
+			return nil, err
+		}
+		return &TypeDef{Pos: pos, Decl: decl}, nil
+
+	case p.is("enum"), p.is("struct"), p.is("union"):
+		keyword := p.tok.text
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		namePos := p.tok.pos
+		name, err := p.parseIdent()
+		if err != nil {
+			return nil, err
+		}
+		var typ Type
+		switch keyword {
+		case "enum":
+			typ, err = p.parseEnumBody(pos)
+		case "struct":
+			typ, err = p.parseStructBody(pos)
+		case "union":
+			typ, err = p.parseUnionBody(pos)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(";"); err != nil {
+			return nil, err
+		}
+		decl := &Decl{Pos: namePos, Kind: DeclScalar, Name: name,
+			Type: typ}
+		return &TypeDef{Pos: pos, Decl: decl}, nil
+
+	case p.is("program"):
+		return p.parseProgram()
+	}
+
+	return nil, p.errorf("expected definition, found %s", p.tok)
+}
+
+// parseTypeSpec parses a type specifier.
+//
+// Reference:
+// 	RFC 4506 Section 6.3 - type-specifier
+func (p *parser) parseTypeSpec() (Type, error) {
+	pos := p.tok.pos
+	if p.tok.kind != tokIdent {
+		return nil, p.errorf("expected type, found %s", p.tok)
+	}
+
+	if p.is("unsigned") {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		// A bare unsigned is an unsigned int.
+		kind := UnsignedInt
+		switch {
+		case p.is("int"):
+		case p.is("hyper"):
+			kind = UnsignedHyper
+		default:
+			return &BasicType{Pos: pos, Kind: kind}, nil
+		}
+		return &BasicType{Pos: pos, Kind: kind}, p.next()
+	}
+
+	if kind, ok := basicTypes[p.tok.text]; ok {
+		return &BasicType{Pos: pos, Kind: kind}, p.next()
+	}
+
+	switch {
+	case p.is("enum"), p.is("struct"), p.is("union"):
+		keyword := p.tok.text
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+
+		// Allow the C style reference to a named type such as
+		// "struct foo *next".
+		if p.tok.kind == tokIdent && !keywords[p.tok.text] {
+			name := p.tok.text
+			return &NamedType{Pos: pos, Name: name}, p.next()
+		}
+
+		switch keyword {
+		case "enum":
+			return p.parseEnumBody(pos)
+		case "struct":
+			return p.parseStructBody(pos)
+		}
+		return p.parseUnionBody(pos)
+
+	case !keywords[p.tok.text]:
+		name := p.tok.text
+		return &NamedType{Pos: pos, Name: name}, p.next()
+	}
+
+	return nil, p.errorf("expected type, found %s", p.tok)
+}
+
+// parseSize parses the size of a fixed-length array in brackets or the
+// optional maximum size of a variable-length array in angle brackets.  The
+// current token must be the opening bracket.
+func (p *parser) parseSize(decl *Decl) error {
+	if p.is("[") {
+		if err := p.next(); err != nil {
+			return err
+		}
+		size, err := p.parseValue()
+		if err != nil {
+			return err
+		}
+		decl.Kind = DeclFixedArray
+		decl.Size = &size
+		return p.expect("]")
+	}
+
+	if err := p.expect("<"); err != nil {
+		return err
+	}
+	decl.Kind = DeclVarArray
+	if !p.is(">") {
+		size, err := p.parseValue()
+		if err != nil {
+			return err
+		}
+		decl.Size = &size
+	}
+	return p.expect(">")
+}
+
+// parseDecl parses a declaration.
+//
+// Reference:
+// 	RFC 4506 Section 6.3 - declaration
+func (p *parser) parseDecl() (*Decl, error) {
+	decl := &Decl{Pos: p.tok.pos}
+	switch {
+	case p.is("void"):
+		decl.Kind = DeclVoid
+		return decl, p.next()
+
+	case p.is("opaque"), p.is("string"):
+		kind := Opaque
+		if p.is("string") {
+			kind = String
+		}
+		decl.Type = &BasicType{Pos: p.tok.pos, Kind: kind}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		name, err := p.parseIdent()
+		if err != nil {
+			return nil, err
+		}
+		decl.Name = name
+
+		// Strings must be variable-length.
+		if kind == String && !p.is("<") {
+			return nil, p.errorf("expected '<', found %s", p.tok)
+		}
+		if !p.is("[") && !p.is("<") {
+			return nil, p.errorf("expected '[' or '<', found %s",
+				p.tok)
+		}
+		if err := p.parseSize(decl); err != nil {
+			return nil, err
+		}
+		return decl, nil
+	}
+
+	typ, err := p.parseTypeSpec()
+	if err != nil {
+		return nil, err
+	}
+	decl.Type = typ
+
+	if p.is("*") {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		decl.Kind = DeclOptional
+	}
+	name, err := p.parseIdent()
+	if err != nil {
+		return nil, err
+	}
+	decl.Name = name
+
+	if decl.Kind == DeclScalar && (p.is("[") || p.is("<")) {
+		if err := p.parseSize(decl); err != nil {
+			return nil, err
+		}
+	}
+	return decl, nil
+}
+
+// parseEnumBody parses the body of an enumeration.
+//
+// Reference:
+// 	RFC 4506 Section 6.3 - enum-body
+func (p *parser) parseEnumBody(pos Pos) (*EnumType, error) {
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+
+	typ := &EnumType{Pos: pos}
+	for {
+		member := &EnumMember{Pos: p.tok.pos}
+		name, err := p.parseIdent()
+		if err != nil {
+			return nil, err
+		}
+		member.Name = name
+		if err := p.expect("="); err != nil {
+			return nil, err
+		}
+		val, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		member.Value = val
+		typ.Members = append(typ.Members, member)
+
+		if !p.is(",") {
+			break
+		}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+	}
+
+	return typ, p.expect("}")
+}
+
+// parseStructBody parses the body of a structure.
+//
+// Reference:
+// 	RFC 4506 Section 6.3 - struct-body
+func (p *parser) parseStructBody(pos Pos) (*StructType, error) {
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+
+	typ := &StructType{Pos: pos}
+	for {
+		decl, err := p.parseDecl()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(";"); err != nil {
+			return nil, err
+		}
+		typ.Fields = append(typ.Fields, decl)
+
+		if p.is("}") {
+			break
+		}
+	}
+
+	return typ, p.next()
+}
+
+// parseUnionBody parses the body of a discriminated union.
+//
+// Reference:
+// 	RFC 4506 Section 6.3 - union-body
+func (p *parser) parseUnionBody(pos Pos) (*UnionType, error) {
+	if err := p.expect("switch"); err != nil {
+		return nil, err
+	}
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	declPos := p.tok.pos
+	disc, err := p.parseDecl()
+	if err != nil {
+		return nil, err
+	}
+	if disc.Kind != DeclScalar {
+		return nil, &Error{Pos: declPos,
+			Msg: "union discriminant must be a scalar declaration"}
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+
+	typ := &UnionType{Pos: pos, Discriminant: disc}
+	for p.is("case") {
+		c := &UnionCase{Pos: p.tok.pos}
+		for p.is("case") {
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+			val, err := p.parseValue()
+			if err != nil {
+				return nil, err
+			}
+			c.Values = append(c.Values, val)
+			if err := p.expect(":"); err != nil {
+				return nil, err
+			}
+		}
+
+		decl, err := p.parseDecl()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(";"); err != nil {
+			return nil, err
+		}
+		c.Decl = decl
+		typ.Cases = append(typ.Cases, c)
+	}
+	if len(typ.Cases) == 0 {
+		return nil, p.errorf("expected 'case', found %s", p.tok)
+	}
+
+	if p.is("default") {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+		decl, err := p.parseDecl()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(";"); err != nil {
+			return nil, err
+		}
+		typ.Default = decl
+	}
+
+	return typ, p.expect("}")
+}
+
+// parseNumber parses the "= value ;" which assigns a number to a program,
+// version, or procedure.
+func (p *parser) parseNumber() (Value, error) {
+	if err := p.expect("="); err != nil {
+		return Value{}, err
+	}
+	val, err := p.parseValue()
+	if err != nil {
+		return Value{}, err
+	}
+	return val, p.expect(";")
+}
+
+// parseProgram parses an ONC RPC program definition.
+//
+// Reference:
+// 	RFC 5531 Section 12.2 - program-def
+func (p *parser) parseProgram() (*ProgramDef, error) {
+	prog := &ProgramDef{Pos: p.tok.pos}
+	if err := p.expect("program"); err != nil {
+		return nil, err
+	}
+	name, err := p.parseIdent()
+	if err != nil {
+		return nil, err
+	}
+	prog.Name = name
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+
+	for {
+		vers, err := p.parseVersion()
+		if err != nil {
+			return nil, err
+		}
+		prog.Versions = append(prog.Versions, vers)
+		if p.is("}") {
+			break
+		}
+	}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+
+	prog.Number, err = p.parseNumber()
+	if err != nil {
+		return nil, err
+	}
+	return prog, nil
+}
+
+// parseVersion parses a version of an ONC RPC program.
+//
+// Reference:
+// 	RFC 5531 Section 12.2 - version-def
+func (p *parser) parseVersion() (*VersionDef, error) {
+	vers := &VersionDef{Pos: p.tok.pos}
+	if err := p.expect("version"); err != nil {
+		return nil, err
+	}
+	name, err := p.parseIdent()
+	if err != nil {
+		return nil, err
+	}
+	vers.Name = name
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+
+	for {
+		proc, err := p.parseProcedure()
+		if err != nil {
+			return nil, err
+		}
+		vers.Procedures = append(vers.Procedures, proc)
+		if p.is("}") {
+			break
+		}
+	}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+
+	vers.Number, err = p.parseNumber()
+	if err != nil {
+		return nil, err
+	}
+	return vers, nil
+}
+
+// parseProcType parses the result or argument type of a procedure where void
+// is represented by a nil type.
+func (p *parser) parseProcType() (Type, error) {
+	if p.is("void") {
+		return nil, p.next()
+	}
+	return p.parseTypeSpec()
+}
+
+// parseProcedure parses a procedure of a version of an ONC RPC program.
+//
+// Reference:
+// 	RFC 5531 Section 12.2 - procedure-def
+func (p *parser) parseProcedure() (*ProcDef, error) {
+	proc := &ProcDef{Pos: p.tok.pos}
+	result, err := p.parseProcType()
+	if err != nil {
+		return nil, err
+	}
+	proc.Result = result
+
+	name, err := p.parseIdent()
+	if err != nil {
+		return nil, err
+	}
+	proc.Name = name
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+
+	argPos := p.tok.pos
+	arg, err := p.parseProcType()
+	if err != nil {
+		return nil, err
+	}
+	if arg != nil {
+		proc.Args = append(proc.Args, arg)
+	}
+	for p.is(",") {
+		if arg == nil {
+			return nil, &Error{Pos: argPos,
+				Msg: "void must be the only argument"}
+		}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		arg, err := p.parseTypeSpec()
+		if err != nil {
+			return nil, err
+		}
+		proc.Args = append(proc.Args, arg)
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+
+	proc.Number, err = p.parseNumber()
+	if err != nil {
+		return nil, err
+	}
+	return proc, nil
+}
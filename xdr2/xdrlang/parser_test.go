@@ -0,0 +1,300 @@
+/*
+ * Copyright (c) 2014 Dave Collins <dave@davec.name>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package xdrlang_test
+
+import (
+	"reflect"
+	"testing"
+
+	. "github.com/davecgh/go-xdr/xdr2/xdrlang"
+)
+
+// pos is a convenience function for creating positions.
+func pos(line, col int) Pos {
+	return Pos{Line: line, Column: col}
+}
+
+// TestParse ensures parsing individual definitions produces the expected
+// syntax tree.
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Definition
+	}{
+		// Constants.
+		{"const MAXNAME = 255;", &ConstDef{pos(1, 1), "MAXNAME",
+			Value{Pos: pos(1, 17), Int: 255}}},
+		{"const PROG = 0x20000001;", &ConstDef{pos(1, 1), "PROG",
+			Value{Pos: pos(1, 14), Int: 0x20000001}}},
+		{"const NEG = -010;", &ConstDef{pos(1, 1), "NEG",
+			Value{Pos: pos(1, 13), Int: -8}}},
+		{"const ALIAS = MAXNAME;", &ConstDef{pos(1, 1), "ALIAS",
+			Value{Pos: pos(1, 15), Name: "MAXNAME"}}},
+
+		// Typedefs of the various declaration forms.
+		{"typedef unsigned hyper u64;", &TypeDef{pos(1, 1), &Decl{
+			Pos: pos(1, 9), Kind: DeclScalar, Name: "u64",
+			Type: &BasicType{pos(1, 9), UnsignedHyper}}}},
+		{"typedef unsigned u32;", &TypeDef{pos(1, 1), &Decl{
+			Pos: pos(1, 9), Kind: DeclScalar, Name: "u32",
+			Type: &BasicType{pos(1, 9), UnsignedInt}}}},
+		{"typedef opaque hash[16];", &TypeDef{pos(1, 1), &Decl{
+			Pos: pos(1, 9), Kind: DeclFixedArray, Name: "hash",
+			Type: &BasicType{pos(1, 9), Opaque},
+			Size: &Value{Pos: pos(1, 21), Int: 16}}}},
+		{"typedef opaque data<>;", &TypeDef{pos(1, 1), &Decl{
+			Pos: pos(1, 9), Kind: DeclVarArray, Name: "data",
+			Type: &BasicType{pos(1, 9), Opaque}}}},
+		{"typedef string name<MAXNAME>;", &TypeDef{pos(1, 1), &Decl{
+			Pos: pos(1, 9), Kind: DeclVarArray, Name: "name",
+			Type: &BasicType{pos(1, 9), String},
+			Size: &Value{Pos: pos(1, 21), Name: "MAXNAME"}}}},
+		{"typedef int ids<8>;", &TypeDef{pos(1, 1), &Decl{
+			Pos: pos(1, 9), Kind: DeclVarArray, Name: "ids",
+			Type: &BasicType{pos(1, 9), Int},
+			Size: &Value{Pos: pos(1, 17), Int: 8}}}},
+		{"typedef double pt[3];", &TypeDef{pos(1, 1), &Decl{
+			Pos: pos(1, 9), Kind: DeclFixedArray, Name: "pt",
+			Type: &BasicType{pos(1, 9), Double},
+			Size: &Value{Pos: pos(1, 19), Int: 3}}}},
+		{"typedef entry *list;", &TypeDef{pos(1, 1), &Decl{
+			Pos: pos(1, 9), Kind: DeclOptional, Name: "list",
+			Type: &NamedType{pos(1, 9), "entry"}}}},
+		{"typedef struct entry *list;", &TypeDef{pos(1, 1), &Decl{
+			Pos: pos(1, 9), Kind: DeclOptional, Name: "list",
+			Type: &NamedType{pos(1, 9), "entry"}}}},
+
+		// Named enums, structs, and unions.
+		{"enum color { RED = 0, GREEN = 1 };", &TypeDef{pos(1, 1), &Decl{
+			Pos: pos(1, 6), Kind: DeclScalar, Name: "color",
+			Type: &EnumType{pos(1, 1), []*EnumMember{
+				{pos(1, 14), "RED", Value{Pos: pos(1, 20)}},
+				{pos(1, 23), "GREEN",
+					Value{Pos: pos(1, 31), Int: 1}},
+			}}}}},
+		{"struct point { int x; float y; };", &TypeDef{pos(1, 1), &Decl{
+			Pos: pos(1, 8), Kind: DeclScalar, Name: "point",
+			Type: &StructType{pos(1, 1), []*Decl{
+				{Pos: pos(1, 16), Kind: DeclScalar, Name: "x",
+					Type: &BasicType{pos(1, 16), Int}},
+				{Pos: pos(1, 23), Kind: DeclScalar, Name: "y",
+					Type: &BasicType{pos(1, 23), Float}},
+			}}}}},
+		{"union u switch (bool b) { case TRUE: quadruple q; };",
+			&TypeDef{pos(1, 1), &Decl{
+				Pos: pos(1, 7), Kind: DeclScalar, Name: "u",
+				Type: &UnionType{
+					Pos: pos(1, 1),
+					Discriminant: &Decl{Pos: pos(1, 17),
+						Kind: DeclScalar, Name: "b",
+						Type: &BasicType{pos(1, 17), Bool}},
+					Cases: []*UnionCase{{pos(1, 27),
+						[]Value{{Pos: pos(1, 32), Name: "TRUE"}},
+						&Decl{Pos: pos(1, 38), Kind: DeclScalar,
+							Name: "q", Type: &BasicType{
+								pos(1, 38), Quadruple}}}},
+				}}}},
+
+		// Programs.
+		{"program P { version V { void NUL(void) = 0; } = 1; } = 9;",
+			&ProgramDef{pos(1, 1), "P", Value{Pos: pos(1, 56), Int: 9},
+				[]*VersionDef{{pos(1, 13), "V",
+					Value{Pos: pos(1, 49), Int: 1},
+					[]*ProcDef{{Pos: pos(1, 25), Name: "NUL",
+						Number: Value{Pos: pos(1, 42)}}}}}}},
+	}
+
+	for i, test := range tests {
+		spec, err := Parse([]byte(test.in))
+		if err != nil {
+			t.Errorf("Parse #%d (%s) unexpected error: %v", i, test.in,
+				err)
+			continue
+		}
+		if len(spec.Definitions) != 1 {
+			t.Errorf("Parse #%d (%s) got %d definitions want 1", i,
+				test.in, len(spec.Definitions))
+			continue
+		}
+		if !reflect.DeepEqual(spec.Definitions[0], test.want) {
+			t.Errorf("Parse #%d (%s)\n got: %#v\nwant: %#v", i, test.in,
+				spec.Definitions[0], test.want)
+			continue
+		}
+	}
+}
+
+// TestParseSpecification ensures parsing a full specification which exercises
+// comments, passthrough lines, and nested types produces the expected syntax
+// tree.
+func TestParseSpecification(t *testing.T) {
+	src := `/*
+ * A directory listing protocol.
+ */
+%#include <rpc/types.h>
+#define unused
+
+const MAXNAMELEN = 255;      // Maximum file name length
+typedef string filename<MAXNAMELEN>;
+
+enum status {
+	OK = 0,
+	NOENT = 2
+};
+
+struct entry {
+	unsigned hyper fileid;
+	filename name;
+	entry *nextentry;
+};
+
+union readdirres switch (status stat) {
+case OK:
+	entry *entries;
+case NOENT:
+case 5:
+	void;
+default:
+	opaque cookie[8];
+};
+
+program DIRPROG {
+	version DIRVERS {
+		void DIRPROC_NULL(void) = 0;
+		readdirres READDIR(filename, unsigned int) = 1;
+	} = 1;
+} = 0x20000076;
+`
+	spec, err := Parse([]byte(src))
+	if err != nil {
+		t.Fatalf("Parse unexpected error: %v", err)
+	}
+	if len(spec.Definitions) != 6 {
+		t.Fatalf("Parse got %d definitions want 6",
+			len(spec.Definitions))
+	}
+
+	wantNames := []string{"MAXNAMELEN", "filename", "status", "entry",
+		"readdirres", "DIRPROG"}
+	wantPos := []Pos{pos(7, 1), pos(8, 1), pos(10, 1), pos(15, 1),
+		pos(21, 1), pos(31, 1)}
+	for i, def := range spec.Definitions {
+		var name string
+		switch d := def.(type) {
+		case *ConstDef:
+			name = d.Name
+		case *TypeDef:
+			name = d.Name()
+		case *ProgramDef:
+			name = d.Name
+		}
+		if name != wantNames[i] {
+			t.Errorf("Definition #%d name got: %s want: %s", i, name,
+				wantNames[i])
+		}
+		if def.Position() != wantPos[i] {
+			t.Errorf("Definition #%d position got: %v want: %v", i,
+				def.Position(), wantPos[i])
+		}
+	}
+
+	entry := spec.Definitions[3].(*TypeDef).Decl.Type.(*StructType)
+	next := entry.Fields[2]
+	if next.Kind != DeclOptional || next.Name != "nextentry" ||
+		next.Type.(*NamedType).Name != "entry" {
+
+		t.Errorf("struct entry unexpected nextentry field: %#v", next)
+	}
+
+	u := spec.Definitions[4].(*TypeDef).Decl.Type.(*UnionType)
+	if u.Discriminant.Type.(*NamedType).Name != "status" {
+		t.Errorf("union unexpected discriminant: %#v", u.Discriminant)
+	}
+	if len(u.Cases) != 2 || len(u.Cases[1].Values) != 2 ||
+		u.Cases[1].Values[1].Int != 5 ||
+		u.Cases[1].Decl.Kind != DeclVoid {
+
+		t.Errorf("union unexpected cases: %#v", u.Cases)
+	}
+	if u.Default == nil || u.Default.Kind != DeclFixedArray ||
+		u.Default.Size.Int != 8 {
+
+		t.Errorf("union unexpected default: %#v", u.Default)
+	}
+
+	prog := spec.Definitions[5].(*ProgramDef)
+	if prog.Number.Int != 0x20000076 || len(prog.Versions) != 1 {
+		t.Fatalf("program unexpected: %#v", prog)
+	}
+	procs := prog.Versions[0].Procedures
+	if len(procs) != 2 {
+		t.Fatalf("program got %d procedures want 2", len(procs))
+	}
+	if procs[0].Result != nil || len(procs[0].Args) != 0 {
+		t.Errorf("DIRPROC_NULL unexpected: %#v", procs[0])
+	}
+	if procs[1].Result.(*NamedType).Name != "readdirres" ||
+		len(procs[1].Args) != 2 ||
+		procs[1].Args[1].(*BasicType).Kind != UnsignedInt ||
+		procs[1].Number.Int != 1 || procs[1].Pos != pos(34, 3) {
+
+		t.Errorf("READDIR unexpected: %#v", procs[1])
+	}
+}
+
+// TestParseErrors ensures invalid specifications are rejected with an *Error
+// which identifies the position of the issue.
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"const X = 1", "xdrlang:1:12: expected ';', found end of file"},
+		{"const int = 1;", "xdrlang:1:7: expected identifier, found 'int'"},
+		{"const X = 99999999999999999999;",
+			"xdrlang:1:11: invalid constant '99999999999999999999'"},
+		{"typedef void v;", "xdrlang:1:9: typedef of void"},
+		{"typedef string s[4];", "xdrlang:1:17: expected '<', found '['"},
+		{"typedef opaque o;", "xdrlang:1:17: expected '[' or '<', found ';'"},
+		{"foo bar;", "xdrlang:1:1: expected definition, found 'foo'"},
+		{"struct s { };", "xdrlang:1:12: expected type, found '}'"},
+		{"enum e { A };", "xdrlang:1:12: expected '=', found '}'"},
+		{"union u switch (void) { case 0: void; };",
+			"xdrlang:1:17: union discriminant must be a scalar declaration"},
+		{"union u switch (int d) { default: void; };",
+			"xdrlang:1:26: expected 'case', found 'default'"},
+		{"program P { version V { void F(void, int) = 1; } = 1; } = 1;",
+			"xdrlang:1:32: void must be the only argument"},
+		{"const X = 1;\n\ttypedef int x$;",
+			"xdrlang:2:15: unexpected character '$'"},
+		{"/* unterminated\n", "xdrlang:1:1: unterminated comment"},
+	}
+
+	for i, test := range tests {
+		_, err := Parse([]byte(test.in))
+		if _, ok := err.(*Error); !ok {
+			t.Errorf("Parse #%d (%s) got error %v (%T) want *Error", i,
+				test.in, err, err)
+			continue
+		}
+		if err.Error() != test.want {
+			t.Errorf("Parse #%d (%s)\n got: %s\nwant: %s", i, test.in,
+				err, test.want)
+			continue
+		}
+	}
+}
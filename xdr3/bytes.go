@@ -0,0 +1,148 @@
+/*
+ * Copyright (c) 2014 Dave Collins <dave@davec.name>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package xdr
+
+import "io"
+
+// sliceWriter is an io.Writer which appends the data written to it to a byte
+// slice.  It never returns an error.
+type sliceWriter struct {
+	buf []byte
+}
+
+// Write appends the passed data to the byte slice of the writer.  It is part
+// of the io.Writer interface implementation.
+func (sw *sliceWriter) Write(p []byte) (int, error) {
+	sw.buf = append(sw.buf, p...)
+	return len(p), nil
+}
+
+// sliceReader is an io.Reader which reads from a byte slice.  It allows a
+// Decoder created with NewBytesDecoder to return opaque data which aliases the
+// slice instead of copying it into new storage.
+type sliceReader struct {
+	buf []byte
+
+	// copy forces opaque data to be copied instead of aliasing buf.
+	copy bool
+}
+
+// Read reads the next len(p) bytes of the slice into p.  It is part of the
+// io.Reader interface implementation.
+func (sr *sliceReader) Read(p []byte) (int, error) {
+	if len(sr.buf) == 0 && len(p) > 0 {
+		return 0, io.EOF
+	}
+	n := copy(p, sr.buf)
+	sr.buf = sr.buf[n:]
+	return n, nil
+}
+
+// Len returns the number of unread bytes of the slice.
+func (sr *sliceReader) Len() int {
+	return len(sr.buf)
+}
+
+// next returns the next n bytes of the slice without copying them.  The caller
+// must ensure there are at least n bytes remaining.
+func (sr *sliceReader) next(n int) []byte {
+	b := sr.buf[:n:n]
+	sr.buf = sr.buf[n:]
+	return b
+}
+
+// AppendMarshal appends the XDR encoding of v to dst and returns the extended
+// byte slice.  It is identical to Marshal other than encoding into memory
+// instead of to a writer, so see Marshal for details of how v is encoded.
+// Passing a nil dst allocates a new byte slice.
+//
+// The passed dst is returned unchanged along with a MarshalError if any issues
+// are encountered while encoding v.
+func AppendMarshal(dst []byte, v interface{}) ([]byte, error) {
+	sw := sliceWriter{buf: dst}
+	enc := Encoder{w: &sw}
+	if _, err := enc.Encode(v); err != nil {
+		return dst, err
+	}
+	return sw.buf, nil
+}
+
+// UnmarshalBytes parses the XDR encoded data at the start of the passed byte
+// slice into the value pointed to by v and returns the bytes which remain
+// after it.  It is identical to Unmarshal other than decoding from memory
+// instead of from a reader, so see Unmarshal for details of how v is decoded.
+// Opaque data is copied, so v does not retain a reference to data.  Use a
+// Decoder created with NewBytesDecoder to avoid the copies.
+//
+// An UnmarshalError is returned if any issues are encountered while decoding
+// v.  The returned bytes are those which remain after the bytes that were read
+// before the error occurred.
+func UnmarshalBytes(data []byte, v interface{}) (rest []byte, err error) {
+	sr := sliceReader{buf: data, copy: true}
+	d := Decoder{r: &sr, sr: &sr}
+	_, err = d.Decode(v)
+	return sr.buf, err
+}
+
+// NewBytesDecoder returns a Decoder which reads the XDR encoded data from the
+// passed byte slice instead of from a reader.  The byte slices returned by
+// DecodeFixedOpaque and DecodeOpaque, as well as those decoded into []byte
+// values, alias data instead of being copied into new storage.  This avoids an
+// allocation and copy for each of them, but it means data must not be modified
+// while they are in use.  Strings are built directly from data with a single
+// allocation.
+//
+// Use NewBytesDecoderWithOptions with the CopyBytes option set to copy the byte
+// slices instead.
+func NewBytesDecoder(data []byte) *Decoder {
+	sr := &sliceReader{buf: data}
+	return &Decoder{r: sr, sr: sr}
+}
+
+// NewBytesDecoderWithOptions is identical to NewBytesDecoder but it enforces
+// the resource limits of the passed options and copies byte slices when the
+// CopyBytes option is set.  See DecoderOptions for details.
+func NewBytesDecoderWithOptions(data []byte, opts DecoderOptions) *Decoder {
+	sr := &sliceReader{buf: data, copy: opts.CopyBytes}
+	return &Decoder{r: sr, sr: sr, limits: &decodeLimits{opts: opts}}
+}
+
+// NewBytesEncoder returns an Encoder which appends the XDR encoded data to an
+// internal byte slice that is available via the Data method instead of writing
+// it to a writer.  It never returns an error with an error code of ErrIO.
+func NewBytesEncoder() *Encoder {
+	sw := new(sliceWriter)
+	return &Encoder{w: sw, sw: sw}
+}
+
+// Data returns the XDR encoded data held by an Encoder created with
+// NewBytesEncoder.  It returns nil for Encoders which write to an io.Writer.
+func (enc *Encoder) Data() []byte {
+	if enc.sw == nil {
+		return nil
+	}
+	return enc.sw.buf
+}
+
+// Reset discards the XDR encoded data held by an Encoder created with
+// NewBytesEncoder so that it may be reused.  It has no effect on Encoders
+// which write to an io.Writer.
+func (enc *Encoder) Reset() {
+	if enc.sw != nil {
+		enc.sw.buf = enc.sw.buf[:0]
+	}
+}
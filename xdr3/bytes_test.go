@@ -0,0 +1,247 @@
+/*
+ * Copyright (c) 2014 Dave Collins <dave@davec.name>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package xdr_test
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"testing"
+
+	. "github.com/davecgh/go-xdr/xdr3"
+)
+
+// TestAppendMarshal ensures AppendMarshal appends the expected encoding to the
+// passed byte slice and leaves it unchanged on errors.
+func TestAppendMarshal(t *testing.T) {
+	prefix := []byte{0xaa, 0xbb}
+	tests := []struct {
+		dst  []byte      // slice to append to
+		in   interface{} // value to encode
+		want []byte      // expected result
+		err  error       // expected error
+	}{
+		{nil, uint32(1), []byte{0x00, 0x00, 0x00, 0x01}, nil},
+		{prefix, "xdr", []byte{0xaa, 0xbb, 0x00, 0x00, 0x00, 0x03,
+			0x78, 0x64, 0x72, 0x00}, nil},
+		{prefix, []interface{}{uint32(1), make(chan int)}, prefix,
+			&MarshalError{ErrorCode: ErrUnsupportedType}},
+		{nil, nil, nil, &MarshalError{ErrorCode: ErrNilInterface}},
+	}
+
+	for i, test := range tests {
+		got, err := AppendMarshal(test.dst, test.in)
+		testName := fmt.Sprintf("AppendMarshal #%d", i)
+		if !testExpectedMRet(t, testName, len(got), len(test.want),
+			err, test.err) {
+			continue
+		}
+		if !bytes.Equal(got, test.want) {
+			t.Errorf("%s: unexpected result - got: %x want: %x",
+				testName, got, test.want)
+			continue
+		}
+	}
+}
+
+// TestUnmarshalBytes ensures UnmarshalBytes decodes the expected value and
+// returns the bytes which remain after it.
+func TestUnmarshalBytes(t *testing.T) {
+	tests := []struct {
+		in       []byte      // input bytes
+		wantVal  interface{} // expected value
+		wantRest []byte      // expected remaining bytes
+		err      error       // expected error
+	}{
+		{[]byte{0x00, 0x00, 0x00, 0x01}, uint32(1), []byte{}, nil},
+		{[]byte{0x00, 0x00, 0x00, 0x01, 0x78, 0x00, 0x00, 0x00, 0x01},
+			"x", []byte{0x01}, nil},
+		{[]byte{0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00, 0x01},
+			[]uint32{}, []byte{}, &UnmarshalError{ErrorCode: ErrIO}},
+		{[]byte{0x00, 0x00, 0x00, 0x02}, true, []byte{},
+			&UnmarshalError{ErrorCode: ErrBadEnumValue}},
+	}
+
+	for i, test := range tests {
+		pv := reflect.New(reflect.TypeOf(test.wantVal))
+		rest, err := UnmarshalBytes(test.in, pv.Interface())
+		testName := fmt.Sprintf("UnmarshalBytes #%d", i)
+		wantN := len(test.in) - len(test.wantRest)
+		if !testExpectedURet(t, testName, len(test.in)-len(rest), wantN,
+			err, test.err) {
+			continue
+		}
+		if test.err != nil {
+			continue
+		}
+		if !reflect.DeepEqual(pv.Elem().Interface(), test.wantVal) {
+			t.Errorf("%s: unexpected result - got: %v want: %v",
+				testName, pv.Elem().Interface(), test.wantVal)
+			continue
+		}
+	}
+}
+
+// TestBytesEncoder ensures an Encoder created with NewBytesEncoder holds the
+// encoded data and can be reset.
+func TestBytesEncoder(t *testing.T) {
+	enc := NewBytesEncoder()
+	if _, err := enc.EncodeUint(1); err != nil {
+		t.Fatalf("EncodeUint unexpected error: %v", err)
+	}
+	if _, err := enc.Encode("x"); err != nil {
+		t.Fatalf("Encode unexpected error: %v", err)
+	}
+	want := []byte{0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x01, 0x78,
+		0x00, 0x00, 0x00}
+	if !bytes.Equal(enc.Data(), want) {
+		t.Errorf("Data got: %x want: %x", enc.Data(), want)
+	}
+
+	enc.Reset()
+	if len(enc.Data()) != 0 {
+		t.Errorf("Data after Reset got: %x want: empty", enc.Data())
+	}
+	if _, err := enc.EncodeBool(true); err != nil {
+		t.Fatalf("EncodeBool unexpected error: %v", err)
+	}
+	want = []byte{0x00, 0x00, 0x00, 0x01}
+	if !bytes.Equal(enc.Data(), want) {
+		t.Errorf("Data after Reset got: %x want: %x", enc.Data(), want)
+	}
+
+	// Encoders which write to a writer have no data.
+	var buf bytes.Buffer
+	enc = NewEncoder(&buf)
+	enc.EncodeUint(1)
+	enc.Reset()
+	if enc.Data() != nil || buf.Len() != 4 {
+		t.Errorf("Data of writer Encoder got: %x want: nil", enc.Data())
+	}
+}
+
+// TestBytesDecoder ensures a Decoder created with NewBytesDecoder decodes the
+// expected values with opaque data aliasing the input unless the CopyBytes
+// option is set.
+func TestBytesDecoder(t *testing.T) {
+	type blob struct {
+		Data  []byte
+		Name  string
+		Fixed [3]byte
+	}
+	encoded := []byte{
+		0x00, 0x00, 0x00, 0x02, 0x01, 0x02, 0x00, 0x00, // Data
+		0x00, 0x00, 0x00, 0x01, 0x78, 0x00, 0x00, 0x00, // Name
+		0x0a, 0x0b, 0x0c, 0x00, // Fixed
+		0x00, 0x00, 0x00, 0x03, 0x04, 0x05, 0x06, 0x00, // opaque
+	}
+	want := blob{[]byte{0x01, 0x02}, "x", [3]byte{0x0a, 0x0b, 0x0c}}
+
+	tests := []struct {
+		copy bool // copy opaque data
+	}{
+		{false},
+		{true},
+	}
+
+	for i, test := range tests {
+		data := append([]byte(nil), encoded...)
+		d := NewBytesDecoder(data)
+		if test.copy {
+			opts := DecoderOptions{CopyBytes: true}
+			d = NewBytesDecoderWithOptions(data, opts)
+		}
+
+		var got blob
+		n, err := d.Decode(&got)
+		if err != nil || n != 20 {
+			t.Errorf("Decode #%d got: %d, %v want: 20, nil", i, n,
+				err)
+			continue
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Decode #%d got: %v want: %v", i, got, want)
+			continue
+		}
+		opaque, n, err := d.DecodeOpaque()
+		if err != nil || n != 8 {
+			t.Errorf("DecodeOpaque #%d got: %d, %v want: 8, nil", i,
+				n, err)
+			continue
+		}
+		if !bytes.Equal(opaque, []byte{0x04, 0x05, 0x06}) {
+			t.Errorf("DecodeOpaque #%d got: %x want: 040506", i,
+				opaque)
+			continue
+		}
+
+		// Appending to the decoded data must never overwrite the
+		// input.
+		_ = append(got.Data, 0xff)
+		if data[6] != 0x00 {
+			t.Errorf("append #%d overwrote the input", i)
+			continue
+		}
+
+		// Modify the input to determine which values alias it.
+		// Strings and arrays never do.
+		for j := range data {
+			data[j] = 0xee
+		}
+		aliased := got.Data[0] == 0xee && opaque[0] == 0xee
+		if aliased == test.copy {
+			t.Errorf("Decode #%d aliased: %v want: %v", i, aliased,
+				!test.copy)
+			continue
+		}
+		if got.Name != want.Name || got.Fixed != want.Fixed {
+			t.Errorf("Decode #%d got: %v want: %v", i, got, want)
+			continue
+		}
+	}
+
+	// Insufficient data must result in an error reporting what was read.
+	d := NewBytesDecoder([]byte{0x00, 0x00, 0x00, 0x08, 0x01, 0x02})
+	_, n, err := d.DecodeOpaque()
+	if !testExpectedURet(t, "DecodeOpaque short", n, 6, err,
+		&UnmarshalError{ErrorCode: ErrIO}) {
+		return
+	}
+}
+
+// TestBytesDecoderAllocs ensures a Decoder created with NewBytesDecoder does
+// not allocate for opaque data and allocates once for strings.
+func TestBytesDecoderAllocs(t *testing.T) {
+	encoded := bytes.Repeat([]byte{0x00, 0x00, 0x00, 0x05, 0x01, 0x02,
+		0x03, 0x04, 0x05, 0x00, 0x00, 0x00}, 200)
+
+	d := NewBytesDecoder(encoded)
+	allocs := testing.AllocsPerRun(100, func() {
+		d.DecodeOpaque()
+	})
+	if allocs != 0 {
+		t.Errorf("DecodeOpaque allocs got: %v want: 0", allocs)
+	}
+
+	d = NewBytesDecoder(encoded)
+	allocs = testing.AllocsPerRun(100, func() {
+		d.DecodeString()
+	})
+	if allocs != 1 {
+		t.Errorf("DecodeString allocs got: %v want: 1", allocs)
+	}
+}
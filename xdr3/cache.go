@@ -0,0 +1,83 @@
+/*
+ * Copyright (c) 2014 Dave Collins <dave@davec.name>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package xdr
+
+import (
+	"reflect"
+	"sync"
+	"time"
+)
+
+// typeInfo is the plan for encoding and decoding values of a Go type.  It
+// houses everything about the type which only depends on the type itself so
+// the reflection and struct tag parsing needed to determine it is only done
+// once per type rather than once per value.
+type typeInfo struct {
+	// Whether or not the type or a pointer to it implements the Marshaler
+	// and Unmarshaler interfaces.
+	marshaler      bool
+	ptrMarshaler   bool
+	unmarshaler    bool
+	ptrUnmarshaler bool
+
+	// isTime is whether or not the type is time.Time.
+	isTime bool
+
+	// fields and fieldsErr are the results of structFields for struct
+	// types.
+	fields    []structField
+	fieldsErr error
+}
+
+// timeType is the reflection type of time.Time.
+var timeType = reflect.TypeOf(time.Time{})
+
+// typeInfos houses the compiled typeInfo for each type that has been encoded
+// or decoded keyed by the type.  It is only ever added to, so a sync.Map is
+// used to avoid lock contention between concurrent encoders and decoders.
+var typeInfos sync.Map
+
+// newTypeInfo compiles the typeInfo for the passed type.
+func newTypeInfo(t reflect.Type) *typeInfo {
+	ti := &typeInfo{
+		marshaler:   t.Implements(marshalerType),
+		unmarshaler: t.Implements(unmarshalerType),
+		isTime:      t == timeType,
+	}
+	if t.Kind() != reflect.Ptr && t.Kind() != reflect.Interface {
+		pt := reflect.PtrTo(t)
+		ti.ptrMarshaler = pt.Implements(marshalerType)
+		ti.ptrUnmarshaler = pt.Implements(unmarshalerType)
+	}
+	if t.Kind() == reflect.Struct {
+		ti.fields, ti.fieldsErr = structFields(t)
+	}
+	return ti
+}
+
+// cachedTypeInfo returns the typeInfo for the passed type compiling and
+// caching it on first use.  It is safe for concurrent access.
+func cachedTypeInfo(t reflect.Type) *typeInfo {
+	if ti, ok := typeInfos.Load(t); ok {
+		return ti.(*typeInfo)
+	}
+
+	// Compiling the same type concurrently is harmless since the results
+	// are identical, so only the first one stored is kept.
+	ti, _ := typeInfos.LoadOrStore(t, newTypeInfo(t))
+	return ti.(*typeInfo)
+}
@@ -0,0 +1,87 @@
+/*
+ * Copyright (c) 2014 Dave Collins <dave@davec.name>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package xdr_test
+
+import (
+	"bytes"
+	"reflect"
+	"sync"
+	"testing"
+
+	. "github.com/davecgh/go-xdr/xdr3"
+)
+
+// cacheTest is a type which is only used by TestConcurrentCodec so its codec
+// plan is compiled while the goroutines race to use it.
+type cacheTest struct {
+	Kind  int32 `xdr:"union"`
+	Name  string
+	Int   int32      `xdr:"unioncase=1"`
+	Str   string     `xdr:"unioncase=2"`
+	Data  []byte     `xdropaque:"false"`
+	Next  *cacheTest `xdr:"optional"`
+	Stamp hyperInt
+}
+
+// TestConcurrentCodec ensures values can be marshalled and unmarshalled from
+// multiple goroutines concurrently while the codec plans for their types are
+// being compiled and used.
+func TestConcurrentCodec(t *testing.T) {
+	in := cacheTest{
+		Kind:  2,
+		Name:  "a",
+		Str:   "b",
+		Data:  []byte{1},
+		Next:  &cacheTest{Kind: 1, Int: 3},
+		Stamp: 4,
+	}
+
+	const numGoroutines = 16
+	errs := make(chan error, numGoroutines)
+	results := make(chan cacheTest, numGoroutines)
+	var wg sync.WaitGroup
+	for i := 0; i < numGoroutines; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var buf bytes.Buffer
+			if _, err := Marshal(&buf, &in); err != nil {
+				errs <- err
+				return
+			}
+			var out cacheTest
+			if _, err := Unmarshal(&buf, &out); err != nil {
+				errs <- err
+				return
+			}
+			results <- out
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	close(results)
+
+	for err := range errs {
+		t.Errorf("unexpected error: %v", err)
+	}
+	for out := range results {
+		if !reflect.DeepEqual(out, in) {
+			t.Errorf("unexpected result\n got: %+v\nwant: %+v", out,
+				in)
+		}
+	}
+}
@@ -25,10 +25,24 @@ import (
 )
 
 var (
-	errMaxSlice = "data exceeds max slice limit"
-	errIODecode = "%s while decoding %d bytes"
+	errMaxSlice        = "data exceeds max slice limit"
+	errIODecode        = "%s while decoding %d bytes"
+	errNonzeroPadding  = "padding contains nonzero bytes"
+	errNonCanonicalNaN = "NaN is not the canonical quiet NaN"
 )
 
+const (
+	// canonicalNaN32 and canonicalNaN64 are the bits of the only NaNs an
+	// Encoder writes and a strict Decoder accepts.  They are the quiet
+	// NaNs with no payload and the sign bit clear.
+	canonicalNaN32 = 0x7fc00000
+	canonicalNaN64 = 0x7ff8000000000000
+)
+
+// canonicalNaN128 is the quadruple-precision counterpart of canonicalNaN32 and
+// canonicalNaN64.  It is a variable since Quadruple is an array.
+var canonicalNaN128 = Quadruple{0x7f, 0xff, 0x80}
+
 /*
 Unmarshal parses XDR-encoded data into the value pointed to by v reading from
 reader r and returning the total number of bytes read.  An addressable pointer
@@ -49,6 +63,7 @@ by v and performs a mapping of underlying XDR types to Go types as follows:
 	bool <- XDR Boolean
 	float32 <- XDR Floating-Point
 	float64 <- XDR Double-Precision Floating-Point
+	Quadruple <- XDR Quadruple-Precision Floating-Point
 	string <- XDR String
 	byte <- XDR Integer
 	[]byte <- XDR Variable-Length Opaque Data
@@ -56,15 +71,38 @@ by v and performs a mapping of underlying XDR types to Go types as follows:
 	[]<type> <- XDR Variable-Length Array
 	[#]<type> <- XDR Fixed-Length Array
 	struct <- XDR Structure
+	struct with union tags <- XDR Discriminated Union
+	*<type> with optional tag <- XDR Optional-Data
 	map <- XDR Variable-Length Array of two-element XDR Structures
 	time.Time <- XDR String encoded with RFC3339 nanosecond precision
+	Unmarshaler <- XDR read by its DecodeXDR method
 
 Notes and Limitations:
 
+	* Values which implement the Unmarshaler interface, or whose pointer
+	  does when they are addressable, are decoded by calling their
+	  DecodeXDR method instead of using reflection.  This applies at every
+	  level of nesting, so values with custom encodings may be embedded in
+	  otherwise reflected types
 	* Automatic unmarshalling of variable and fixed-length arrays of uint8s
 	  requires a special struct tag `xdropaque:"false"` since byte slices
 	  and byte arrays are assumed to be opaque data and byte is a Go alias
 	  for uint8 thus indistinguishable under reflection
+	* Discriminated unions are described with the `xdr:"union"`,
+	  `xdr:"unioncase=<value>[,<value>...]"`, and `xdr:"default"` struct
+	  tags as detailed in the package documentation
+	* Pointer fields tagged with `xdr:"optional"` are XDR optional-data
+	  and are set to nil when the data is not present
+	* String, slice, and map fields tagged with `xdr:"max=<n>"` can't have
+	  more than n elements and slice fields tagged with `xdr:"len=<n>"` are
+	  decoded from fixed-length arrays of n elements
+	* Integer fields tagged with `xdr:"hyper"` are decoded from XDR hyper
+	  integers, or unsigned hyper integers for unsigned types, and values
+	  which are too large to fit into the field result in ErrOverflow
+	* Struct fields tagged with `xdr:"-"` are skipped and fields tagged with
+	  `xdr:"order=<n>"` are decoded in ascending order of n rather than the
+	  order they are declared.  The fields of embedded structs are promoted
+	  into the embedding struct
 	* Cyclic data structures are not supported and will result in infinite
 	  loops
 
@@ -86,10 +124,22 @@ func UnmarshalLimited(r io.Reader, v interface{}, maxSize uint) (int, error) {
 	return d.Decode(v)
 }
 
+// UnmarshalWithOptions is identical to Unmarshal but it enforces the resource
+// limits of the passed options.  It should be used in place of Unmarshal for
+// untrusted input.  See DecoderOptions for details.
+func UnmarshalWithOptions(r io.Reader, v interface{}, opts DecoderOptions) (int, error) {
+	d := Decoder{r: r, limits: &decodeLimits{opts: opts}}
+	n, err := d.Decode(v)
+	if err == nil && opts.Strict {
+		err = d.checkTrailing("UnmarshalWithOptions")
+	}
+	return n, err
+}
+
 // A Decoder wraps an io.Reader that is expected to provide an XDR-encoded byte
 // stream and provides several exposed methods to manually decode various XDR
-// primitives without relying on reflection.  The NewDecoder function can be
-// used to get a new Decoder directly.
+// primitives without relying on reflection.  The NewDecoder and
+// NewBytesDecoder functions can be used to get a new Decoder directly.
 //
 // Typically, Unmarshal should be used instead of manual decoding.  A Decoder
 // is exposed so it is possible to perform manual decoding should it be
@@ -102,6 +152,23 @@ type Decoder struct {
 	// is unlimited and provides backwards compatability.  Setting it to a
 	// non-zero value caps reads.
 	maxReadSize uint
+
+	// limits houses the resource limits of the Decoder along with the
+	// resources consumed against them.  It is nil when the Decoder was
+	// not created with options so the common case doesn't pay for them.
+	limits *decodeLimits
+
+	// sr is the underlying reader when the Decoder reads from a byte slice.
+	// It is nil except for Decoders created with NewBytesDecoder.
+	sr *sliceReader
+
+	// off is the number of bytes read by the Decoder.  It locates the
+	// values which fail to decode.
+	off int64
+
+	// scratch is the storage primitives are read into when the Decoder
+	// reads from an io.Reader so they don't require an allocation.
+	scratch [16]byte
 }
 
 // DecodeInt treats the next 4 bytes as an XDR encoded integer and returns the
@@ -113,11 +180,8 @@ type Decoder struct {
 // 	RFC Section 4.1 - Integer
 // 	32-bit big-endian signed integer in range [-2147483648, 2147483647]
 func (d *Decoder) DecodeInt() (int32, int, error) {
-	var buf [4]byte
-	n, err := io.ReadFull(d.r, buf[:])
+	buf, n, err := d.readFull("DecodeInt", 4)
 	if err != nil {
-		msg := fmt.Sprintf(errIODecode, err.Error(), 4)
-		err := unmarshalError("DecodeInt", ErrIO, msg, buf[:n], err)
 		return 0, n, err
 	}
 
@@ -135,11 +199,8 @@ func (d *Decoder) DecodeInt() (int32, int, error) {
 // 	RFC Section 4.2 - Unsigned Integer
 // 	32-bit big-endian unsigned integer in range [0, 4294967295]
 func (d *Decoder) DecodeUint() (uint32, int, error) {
-	var buf [4]byte
-	n, err := io.ReadFull(d.r, buf[:])
+	buf, n, err := d.readFull("DecodeUint", 4)
 	if err != nil {
-		msg := fmt.Sprintf(errIODecode, err.Error(), 4)
-		err := unmarshalError("DecodeUint", ErrIO, msg, buf[:n], err)
 		return 0, n, err
 	}
 
@@ -208,11 +269,8 @@ func (d *Decoder) DecodeBool() (bool, int, error) {
 // 	RFC Section 4.5 - Hyper Integer
 // 	64-bit big-endian signed integer in range [-9223372036854775808, 9223372036854775807]
 func (d *Decoder) DecodeHyper() (int64, int, error) {
-	var buf [8]byte
-	n, err := io.ReadFull(d.r, buf[:])
+	buf, n, err := d.readFull("DecodeHyper", 8)
 	if err != nil {
-		msg := fmt.Sprintf(errIODecode, err.Error(), 8)
-		err := unmarshalError("DecodeHyper", ErrIO, msg, buf[:n], err)
 		return 0, n, err
 	}
 
@@ -233,11 +291,8 @@ func (d *Decoder) DecodeHyper() (int64, int, error) {
 // 	RFC Section 4.5 - Unsigned Hyper Integer
 // 	64-bit big-endian unsigned integer in range [0, 18446744073709551615]
 func (d *Decoder) DecodeUhyper() (uint64, int, error) {
-	var buf [8]byte
-	n, err := io.ReadFull(d.r, buf[:])
+	buf, n, err := d.readFull("DecodeUhyper", 8)
 	if err != nil {
-		msg := fmt.Sprintf(errIODecode, err.Error(), 8)
-		err := unmarshalError("DecodeUhyper", ErrIO, msg, buf[:n], err)
 		return 0, n, err
 	}
 
@@ -257,17 +312,20 @@ func (d *Decoder) DecodeUhyper() (uint64, int, error) {
 // 	RFC Section 4.6 - Floating Point
 // 	32-bit single-precision IEEE 754 floating point
 func (d *Decoder) DecodeFloat() (float32, int, error) {
-	var buf [4]byte
-	n, err := io.ReadFull(d.r, buf[:])
+	buf, n, err := d.readFull("DecodeFloat", 4)
 	if err != nil {
-		msg := fmt.Sprintf(errIODecode, err.Error(), 4)
-		err := unmarshalError("DecodeFloat", ErrIO, msg, buf[:n], err)
 		return 0, n, err
 	}
 
 	val := uint32(buf[3]) | uint32(buf[2])<<8 |
 		uint32(buf[1])<<16 | uint32(buf[0])<<24
-	return math.Float32frombits(val), n, nil
+	f := math.Float32frombits(val)
+	if f != f && val != canonicalNaN32 && d.strict() {
+		err := unmarshalError("DecodeFloat", ErrNonCanonicalNaN,
+			errNonCanonicalNaN, val, nil)
+		return 0, n, err
+	}
+	return f, n, nil
 }
 
 // DecodeDouble treats the next 8 bytes as an XDR encoded double-precision
@@ -280,11 +338,8 @@ func (d *Decoder) DecodeFloat() (float32, int, error) {
 // 	RFC Section 4.7 -  Double-Precision Floating Point
 // 	64-bit double-precision IEEE 754 floating point
 func (d *Decoder) DecodeDouble() (float64, int, error) {
-	var buf [8]byte
-	n, err := io.ReadFull(d.r, buf[:])
+	buf, n, err := d.readFull("DecodeDouble", 8)
 	if err != nil {
-		msg := fmt.Sprintf(errIODecode, err.Error(), 8)
-		err := unmarshalError("DecodeDouble", ErrIO, msg, buf[:n], err)
 		return 0, n, err
 	}
 
@@ -292,16 +347,44 @@ func (d *Decoder) DecodeDouble() (float64, int, error) {
 		uint64(buf[5])<<16 | uint64(buf[4])<<24 |
 		uint64(buf[3])<<32 | uint64(buf[2])<<40 |
 		uint64(buf[1])<<48 | uint64(buf[0])<<56
-	return math.Float64frombits(val), n, nil
+	f := math.Float64frombits(val)
+	if f != f && val != canonicalNaN64 && d.strict() {
+		err := unmarshalError("DecodeDouble", ErrNonCanonicalNaN,
+			errNonCanonicalNaN, val, nil)
+		return 0, n, err
+	}
+	return f, n, nil
 }
 
-// RFC Section 4.8 -  Quadruple-Precision Floating Point
-// 128-bit quadruple-precision floating point
-// Not Implemented
+// DecodeQuadruple treats the next 16 bytes as an XDR encoded
+// quadruple-precision floating point and returns the result as a Quadruple
+// along with the number of bytes actually read.
+//
+// An UnmarshalError is returned if there are insufficient bytes remaining.
+//
+// Reference:
+// 	RFC Section 4.8 -  Quadruple-Precision Floating Point
+// 	128-bit quadruple-precision IEEE 754 floating point
+func (d *Decoder) DecodeQuadruple() (Quadruple, int, error) {
+	var q Quadruple
+	buf, n, err := d.readFull("DecodeQuadruple", len(q))
+	if err != nil {
+		return q, n, err
+	}
+
+	copy(q[:], buf)
+	if q.IsNaN() && q != canonicalNaN128 && d.strict() {
+		err := unmarshalError("DecodeQuadruple", ErrNonCanonicalNaN,
+			errNonCanonicalNaN, q, nil)
+		return Quadruple{}, n, err
+	}
+	return q, n, nil
+}
 
 // DecodeFixedOpaque treats the next 'size' bytes as XDR encoded opaque data and
 // returns the result as a byte slice along with the number of bytes actually
-// read.
+// read.  For a Decoder created with NewBytesDecoder, the result aliases
+// the byte slice being decoded unless the CopyBytes option is set.
 //
 // An UnmarshalError is returned if there are insufficient bytes remaining to
 // satisfy the passed size, including the necessary padding to make it a
@@ -311,6 +394,15 @@ func (d *Decoder) DecodeDouble() (float64, int, error) {
 // 	RFC Section 4.9 - Fixed-Length Opaque Data
 // 	Fixed-length uninterpreted data zero-padded to a multiple of four
 func (d *Decoder) DecodeFixedOpaque(size int32) ([]byte, int, error) {
+	return d.decodeFixedOpaque(size, d.sr != nil && !d.sr.copy)
+}
+
+// decodeFixedOpaque is the implementation of DecodeFixedOpaque.  The alias flag
+// controls whether or not the returned bytes may alias the byte slice of a
+// Decoder created with NewBytesDecoder.  It is set by callers which copy the
+// bytes elsewhere regardless, such as into a string or an array, so they are
+// not needlessly copied twice.
+func (d *Decoder) decodeFixedOpaque(size int32, alias bool) ([]byte, int, error) {
 	// Nothing to do if size is 0.
 	if size == 0 {
 		return nil, 0, nil
@@ -324,20 +416,39 @@ func (d *Decoder) DecodeFixedOpaque(size int32) ([]byte, int, error) {
 		return nil, 0, err
 	}
 
-	buf := make([]byte, paddedSize)
-	n, err := io.ReadFull(d.r, buf)
+	// Ensure the data is within the limits of the Decoder before
+	// allocating storage for it.
+	err := d.checkTotal("DecodeFixedOpaque", uint(paddedSize))
+	if err != nil {
+		return nil, 0, err
+	}
+	err = d.allocate("DecodeFixedOpaque", uint(paddedSize), 1)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	buf, n, err := d.readGrowing("DecodeFixedOpaque", int(paddedSize),
+		alias)
 	if err != nil {
-		msg := fmt.Sprintf(errIODecode, err.Error(), paddedSize)
-		err := unmarshalError("DecodeFixedOpaque", ErrIO, msg, buf[:n],
-			err)
 		return nil, n, err
 	}
-	return buf[0:size], n, nil
+	if d.strict() {
+		for _, b := range buf[size:] {
+			if b != 0 {
+				err := unmarshalError("DecodeFixedOpaque",
+					ErrNonzeroPadding, errNonzeroPadding,
+					buf[size:], nil)
+				return nil, n, err
+			}
+		}
+	}
+	return buf[0:size:size], n, nil
 }
 
 // DecodeOpaque treats the next bytes as variable length XDR encoded opaque
 // data and returns the result as a byte slice along with the number of bytes
-// actually read.
+// actually read.  For a Decoder created with NewBytesDecoder, the result
+// aliases the byte slice being decoded unless the CopyBytes option is set.
 //
 // An UnmarshalError is returned if there are insufficient bytes remaining or
 // the opaque data is larger than the max length of a Go slice.
@@ -391,7 +502,7 @@ func (d *Decoder) DecodeString() (string, int, error) {
 		return "", n, err
 	}
 
-	opaque, n2, err := d.DecodeFixedOpaque(int32(dataLen))
+	opaque, n2, err := d.decodeFixedOpaque(int32(dataLen), true)
 	n += n2
 	if err != nil {
 		return "", n, err
@@ -413,9 +524,9 @@ func (d *Decoder) DecodeString() (string, int, error) {
 // 	Individually XDR encoded array elements
 func (d *Decoder) decodeFixedArray(v reflect.Value, ignoreOpaque bool) (int, error) {
 	// Treat [#]byte (byte is alias for uint8) as opaque data unless
-	// ignored.
-	if !ignoreOpaque && v.Type().Elem().Kind() == reflect.Uint8 {
-		data, n, err := d.DecodeFixedOpaque(int32(v.Len()))
+	// ignored or the bytes have their own encoding.
+	if !ignoreOpaque && isOpaqueElem(v.Type().Elem()) {
+		data, n, err := d.decodeFixedOpaque(int32(v.Len()), true)
 		if err != nil {
 			return n, err
 		}
@@ -426,10 +537,11 @@ func (d *Decoder) decodeFixedArray(v reflect.Value, ignoreOpaque bool) (int, err
 	// Decode each array element.
 	var n int
 	for i := 0; i < v.Len(); i++ {
+		start := d.off
 		n2, err := d.decode(v.Index(i))
 		n += n2
 		if err != nil {
-			return n, err
+			return n, withPath(err, indexElem(i), start)
 		}
 	}
 	return n, nil
@@ -462,20 +574,29 @@ func (d *Decoder) decodeArray(v reflect.Value, ignoreOpaque bool) (int, error) {
 		return n, err
 	}
 
-	// Allocate storage for the slice elements (the underlying array) if
-	// existing slice does not have enough capacity.
-	sliceLen := int(dataLen)
-	if v.Cap() < sliceLen {
-		v.Set(reflect.MakeSlice(v.Type(), sliceLen, sliceLen))
-	}
-	if v.Len() < sliceLen {
-		v.SetLen(sliceLen)
-	}
+	n2, err := d.decodeArrayElements(v, int(dataLen), ignoreOpaque)
+	n += n2
+	return n, err
+}
 
-	// Treat []byte (byte is alias for uint8) as opaque data unless ignored.
-	if !ignoreOpaque && v.Type().Elem().Kind() == reflect.Uint8 {
-		data, n2, err := d.DecodeFixedOpaque(int32(sliceLen))
-		n += n2
+// decodeArrayElements treats the next bytes as the passed number of XDR
+// encoded elements of the same type as the slice represented by the reflection
+// value and decodes them into the slice, resizing it as needed.  The
+// ignoreOpaque flag controls whether or not uint8 (byte) elements should be
+// decoded individually or as a sequence of opaque data.  It returns the number
+// of bytes actually read.
+//
+// An UnmarshalError is returned if any issues are encountered while decoding
+// the array elements.
+func (d *Decoder) decodeArrayElements(v reflect.Value, sliceLen int, ignoreOpaque bool) (int, error) {
+	// Treat []byte (byte is alias for uint8) as opaque data unless ignored
+	// or the bytes have their own encoding.  The opaque data is decoded
+	// into newly allocated storage, or aliases the data of a Decoder
+	// created with NewBytesDecoder, so there is no need to allocate
+	// storage for the slice elements.
+	elemType := v.Type().Elem()
+	if !ignoreOpaque && isOpaqueElem(elemType) {
+		data, n, err := d.DecodeFixedOpaque(int32(sliceLen))
 		if err != nil {
 			return n, err
 		}
@@ -483,14 +604,90 @@ func (d *Decoder) decodeArray(v reflect.Value, ignoreOpaque bool) (int, error) {
 		return n, nil
 	}
 
-	// Decode each slice element.
+	// Allocate storage for the slice elements (the underlying array) if
+	// existing slice does not have enough capacity.  Since the length is
+	// untrusted, the storage allocated up front is limited by preallocLen
+	// and grown as the elements are decoded.
+	if err := d.addElements("decodeArray", uint(sliceLen)); err != nil {
+		return 0, err
+	}
+	if v.Cap() < sliceLen {
+		err := d.allocate("decodeArray", uint(sliceLen),
+			uint(elemType.Size()))
+		if err != nil {
+			return 0, err
+		}
+		c := d.preallocLen(sliceLen, elemType.Size())
+		v.Set(reflect.MakeSlice(v.Type(), c, c))
+	}
+	if v.Len() < sliceLen {
+		l := v.Cap()
+		if l > sliceLen {
+			l = sliceLen
+		}
+		v.SetLen(l)
+	}
+
+	// Decode each slice element doubling the storage, up to the decoded
+	// length, whenever it is exhausted.
+	var n int
 	for i := 0; i < sliceLen; i++ {
+		if i == v.Len() {
+			c := 2 * i
+			if c > sliceLen {
+				c = sliceLen
+			}
+			grown := reflect.MakeSlice(v.Type(), c, c)
+			reflect.Copy(grown, v)
+			v.Set(grown)
+		}
+		start := d.off
 		n2, err := d.decode(v.Index(i))
 		n += n2
 		if err != nil {
+			return n, withPath(err, indexElem(i), start)
+		}
+	}
+	return n, nil
+}
+
+// decodeHyper treats the next 8 bytes as an XDR encoded hyper integer, or an
+// unsigned hyper integer when the integer represented by the passed reflection
+// value is unsigned, and decodes it into the integer.  It is used for fields
+// with the hyper option.  It returns the number of bytes actually read.
+//
+// An UnmarshalError is returned if there are insufficient bytes remaining or
+// the decoded value is too large to fit into the integer.
+func (d *Decoder) decodeHyper(v reflect.Value) (int, error) {
+	switch v.Kind() {
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int,
+		reflect.Int64:
+		i, n, err := d.DecodeHyper()
+		if err != nil {
+			return n, err
+		}
+		if v.OverflowInt(i) {
+			msg := fmt.Sprintf("signed integer too large to fit '%s'",
+				v.Kind().String())
+			err = unmarshalError("decodeHyper", ErrOverflow, msg, i,
+				nil)
 			return n, err
 		}
+		v.SetInt(i)
+		return n, nil
 	}
+
+	ui, n, err := d.DecodeUhyper()
+	if err != nil {
+		return n, err
+	}
+	if v.OverflowUint(ui) {
+		msg := fmt.Sprintf("unsigned integer too large to fit '%s'",
+			v.Kind().String())
+		err = unmarshalError("decodeHyper", ErrOverflow, msg, ui, nil)
+		return n, err
+	}
+	v.SetUint(ui)
 	return n, nil
 }
 
@@ -499,47 +696,123 @@ func (d *Decoder) decodeArray(v reflect.Value, ignoreOpaque bool) (int, error) {
 // passed reflection value.  Pointers are automatically indirected and
 // allocated as necessary.  It returns the  the number of bytes actually read.
 //
+// Fields tagged as discriminated union arms are only decoded when they are the
+// arm selected by the decoded value of their discriminant.  The arms which are
+// not selected are set to their zero value.
+//
 // An UnmarshalError is returned if any issues are encountered while decoding
 // the elements.
 //
 // Reference:
 // 	RFC Section 4.14 - Structure
 // 	XDR encoded elements in the order of their declaration in the struct
+// 	unless the order option gives a different order
 func (d *Decoder) decodeStruct(v reflect.Value) (int, error) {
+	ti := cachedTypeInfo(v.Type())
+	fields := ti.fields
+	if ti.fieldsErr != nil {
+		msg := fmt.Sprintf("invalid struct tag for '%v': %v",
+			v.Type().String(), ti.fieldsErr)
+		err := unmarshalError("decodeStruct", ErrBadArguments, msg,
+			nil, nil)
+		return 0, err
+	}
+
 	var n int
-	vt := v.Type()
-	for i := 0; i < v.NumField(); i++ {
-		// Skip unexported fields.
-		vtf := vt.Field(i)
-		if vtf.PkgPath != "" {
+	var value int64
+	arm := -1
+	for i := range fields {
+		// Zero union arms which are not selected by their
+		// discriminant.
+		f := &fields[i]
+		vf := v.FieldByIndex(f.index)
+		if f.isArm() && i != arm {
+			if vf.CanSet() {
+				vf.Set(reflect.Zero(f.typ))
+			}
 			continue
 		}
+		start := d.off
+
+		// Optional data is preceded by a boolean that indicates
+		// whether or not the pointer has a value.
+		if f.optional {
+			present, n2, err := d.DecodeBool()
+			n += n2
+			if err != nil {
+				return n, withPath(err, f.name, start)
+			}
+			if !present {
+				if !vf.CanSet() {
+					msg := fmt.Sprintf("can't decode to "+
+						"unsettable '%v'",
+						vf.Type().String())
+					err := unmarshalError("decodeStruct",
+						ErrNotSettable, msg, nil, nil)
+					return n, withPath(err, f.name, start)
+				}
+				vf.Set(reflect.Zero(f.typ))
+				continue
+			}
+		}
 
 		// Indirect through pointers allocating them as needed and
 		// ensure the field is settable.
-		vf := v.Field(i)
 		vf, err := d.indirect(vf)
 		if err != nil {
-			return n, err
+			return n, withPath(err, f.name, start)
 		}
 		if !vf.CanSet() {
 			msg := fmt.Sprintf("can't decode to unsettable '%v'",
 				vf.Type().String())
 			err := unmarshalError("decodeStruct", ErrNotSettable,
 				msg, nil, nil)
-			return n, err
+			return n, withPath(err, f.name, start)
+		}
+
+		// Values which implement Unmarshaler are decoded by it rather
+		// than the fast paths for hyper integers, slices, and arrays
+		// below.
+		_, custom := unmarshaler(vf)
+
+		// Integers tagged as hyper integers are decoded as such
+		// regardless of their size.
+		if f.hyper && !custom {
+			n2, err := d.decodeHyper(vf)
+			n += n2
+			if err != nil {
+				return n, withPath(err, f.name, start)
+			}
+			continue
+		}
+
+		// Enforce the size bounds of the field before allocating.
+		// Fixed-length arrays need no special handling since their
+		// length is checked when the struct tag is parsed, and values
+		// which implement Unmarshaler are checked once decoded.
+		bounded := (f.maxLen >= 0 || f.fixedLen >= 0) &&
+			vf.Kind() != reflect.Array
+		if bounded {
+			if !custom {
+				n2, err := d.decodeBounded(vf, f)
+				n += n2
+				if err != nil {
+					return n, withPath(err, f.name, start)
+				}
+				continue
+			}
 		}
 
 		// Handle non-opaque data to []uint8 and [#]uint8 based on
-		// struct tag.
-		tag := vtf.Tag.Get("xdropaque")
-		if tag == "false" {
+		// struct tag.  Values which implement Unmarshaler are decoded
+		// by it instead.
+		if f.noOpaque && !custom {
 			switch vf.Kind() {
 			case reflect.Slice:
 				n2, err := d.decodeArray(vf, true)
 				n += n2
 				if err != nil {
-					return n, err
+					return n, withPath(err, f.name, start)
 				}
 				continue
 
@@ -547,31 +820,119 @@ func (d *Decoder) decodeStruct(v reflect.Value) (int, error) {
 				n2, err := d.decodeFixedArray(vf, true)
 				n += n2
 				if err != nil {
-					return n, err
+					return n, withPath(err, f.name, start)
 				}
 				continue
 			}
 		}
 
-		// Decode each struct field.
-		n2, err := d.decode(vf)
+		// Decode each struct field.  Interface union arms are
+		// allocated according to the types registered for the union.
+		var n2 int
+		if f.isArm() && vf.Kind() == reflect.Interface {
+			n2, err = d.decodeUnionInterface(vf, value)
+		} else {
+			n2, err = d.decode(vf)
+		}
 		n += n2
 		if err != nil {
-			return n, err
+			return n, withPath(err, f.name, start)
+		}
+		if bounded {
+			if msg := f.checkLen(vf.Len()); msg != "" {
+				err := unmarshalError("decodeStruct",
+					ErrOverflow, msg, vf.Len(), nil)
+				return n, withPath(err, f.name, start)
+			}
+		}
+
+		// Determine which arm the discriminant of a union selects.
+		if f.union {
+			value = discriminant(vf)
+			arm = selectArm(fields, i, value)
+			if arm < 0 {
+				msg := fmt.Sprintf("no union arm for "+
+					"discriminant '%s'", f.name)
+				err := unmarshalError("decodeStruct",
+					ErrBadDiscriminant, msg, value, nil)
+				return n, withPath(err, f.name, start)
+			}
 		}
 	}
 
 	return n, nil
 }
 
+// decodeBounded decodes the passed struct field represented by the reflection
+// value, which is a string, slice, or map, while enforcing the size bound from
+// its max or len option.  Slices with a fixed length are decoded as
+// fixed-length arrays of that length.  It returns the number of bytes actually
+// read.
+//
+// An UnmarshalError with an error code of ErrOverflow that names the field is
+// returned if the length of variable-length data exceeds the maximum.  The
+// length is checked before any storage for the data is allocated.
+func (d *Decoder) decodeBounded(v reflect.Value, f *structField) (int, error) {
+	if f.fixedLen >= 0 {
+		return d.decodeArrayElements(v, f.fixedLen, f.noOpaque)
+	}
+
+	dataLen, n, err := d.DecodeUint()
+	if err != nil {
+		return n, err
+	}
+	if uint(dataLen) > uint(f.maxLen) {
+		msg := fmt.Sprintf("field '%s' exceeds the maximum length of "+
+			"%d", f.name, f.maxLen)
+		err := unmarshalError("decodeStruct", ErrOverflow, msg, dataLen,
+			nil)
+		return n, err
+	}
+	if d.maxReadSize != 0 && uint(dataLen) > d.maxReadSize &&
+		v.Kind() != reflect.Map {
+
+		err := unmarshalError("decodeStruct", ErrOverflow, errMaxSlice,
+			dataLen, nil)
+		return n, err
+	}
+
+	var n2 int
+	switch v.Kind() {
+	case reflect.String:
+		var data []byte
+		data, n2, err = d.decodeFixedOpaque(int32(dataLen), true)
+		if err == nil {
+			v.SetString(string(data))
+		}
+
+	case reflect.Slice:
+		n2, err = d.decodeArrayElements(v, int(dataLen), f.noOpaque)
+
+	case reflect.Map:
+		n2, err = d.decodeMapEntries(v, dataLen)
+	}
+	n += n2
+	return n, err
+}
+
 // RFC Section 4.15 - Discriminated Union
+// Discriminated unions are handled by decodeStruct via struct tags.  The
+// discriminant is a field with an `xdr:"union"` tag and the arms are the fields
+// that follow it tagged with `xdr:"unioncase=<value>[,<value>...]"` or
+// `xdr:"default"`.
+
 // RFC Section 4.16 - Void
 // RFC Section 4.17 - Constant
 // RFC Section 4.18 - Typedef
+// RFC Sections 4.16 though 4.18 only apply to the data specification language
+// which is not implemented by this package.  A void union arm can be expressed
+// with a field of type struct{}.
+
 // RFC Section 4.19 - Optional data
-// RFC Sections 4.15 though 4.19 only apply to the data specification language
-// which is not implemented by this package.  In the case of discriminated
-// unions, struct tags are used to perform a similar function.
+// Optional data is handled by decodeStruct for pointer fields with an
+// `xdr:"optional"` tag.  The pointer is set to nil when the boolean preceding
+// the data indicates there is no value and is otherwise allocated as needed
+// and decoded into.
 
 // decodeMap treats the next bytes as an XDR encoded variable array of 2-element
 // structures whose fields are of the same type as the map keys and elements
@@ -587,28 +948,74 @@ func (d *Decoder) decodeMap(v reflect.Value) (int, error) {
 		return n, err
 	}
 
-	// Allocate storage for the underlying map if needed.
+	n2, err := d.decodeMapEntries(v, dataLen)
+	n += n2
+	return n, err
+}
+
+// decodeMapEntries treats the next bytes as the passed number of XDR encoded
+// 2-element structures whose fields are of the same type as the map keys and
+// elements represented by the passed reflection value and decodes them into
+// the map, allocating it if needed.  It returns the number of bytes actually
+// read.
+//
+// An UnmarshalError is returned if any issues are encountered while decoding
+// the elements.
+func (d *Decoder) decodeMapEntries(v reflect.Value, dataLen uint32) (int, error) {
+	// Ensure the entries are within the limits of the Decoder before
+	// allocating storage for the underlying map if needed.
 	vt := v.Type()
+	keyType := vt.Key()
+	elemType := vt.Elem()
+	if err := d.addElements("decodeMap", uint(dataLen)); err != nil {
+		return 0, err
+	}
+	err := d.allocate("decodeMap", uint(dataLen),
+		uint(keyType.Size()+elemType.Size()))
+	if err != nil {
+		return 0, err
+	}
 	if v.IsNil() {
-		v.Set(reflect.MakeMap(vt))
+		// Maps need storage even for zero-sized keys and elements, so
+		// account for at least a byte per entry.
+		size := keyType.Size() + elemType.Size() + 1
+		hint := d.preallocLen(int(dataLen), size)
+		v.Set(reflect.MakeMapWithSize(vt, hint))
 	}
 
-	// Decode each key and value according to their type.
-	keyType := vt.Key()
-	elemType := vt.Elem()
+	// Decode each key and value according to their type.  Strict decoders
+	// track the keys decoded so far to reject duplicates since they can't
+	// be distinguished from keys that were already in the map.
+	var seen map[interface{}]struct{}
+	if d.strict() {
+		seen = make(map[interface{}]struct{})
+	}
+	var n int
 	for i := uint32(0); i < dataLen; i++ {
+		start := d.off
 		key := reflect.New(keyType).Elem()
 		n2, err := d.decode(key)
 		n += n2
 		if err != nil {
-			return n, err
+			return n, withPath(err, indexElem(int(i)), start)
+		}
+		if seen != nil {
+			k := key.Interface()
+			if _, ok := seen[k]; ok {
+				msg := "map contains a duplicate key"
+				err := unmarshalError("decodeMap",
+					ErrDuplicateKey, msg, k, nil)
+				return n, withPath(err, keyElem(key), start)
+			}
+			seen[k] = struct{}{}
 		}
 
+		valStart := d.off
 		val := reflect.New(elemType).Elem()
 		n2, err = d.decode(val)
 		n += n2
 		if err != nil {
-			return n, err
+			return n, withPath(err, keyElem(key), valStart)
 		}
 		v.SetMapIndex(key, val)
 	}
@@ -648,29 +1055,147 @@ func (d *Decoder) decodeInterface(v reflect.Value) (int, error) {
 	return d.decode(ve)
 }
 
+// decodeUnionInterface decodes into the interface represented by the passed
+// reflection value as the arm of a discriminated union with the passed
+// discriminant value.  When the interface type has been registered with
+// RegisterUnion, a new value of the type registered for the discriminant is
+// allocated, decoded into, and stored in the interface.  Otherwise, the
+// interface is decoded into the same as any other interface.  It returns the
+// number of bytes actually read.
+//
+// An UnmarshalError is returned if the discriminant does not have a registered
+// type or any issues are encountered while decoding the interface.
+func (d *Decoder) decodeUnionInterface(v reflect.Value, disc int64) (int, error) {
+	t, registered, ok := unionArmType(v.Type(), disc)
+	if !registered {
+		return d.decodeInterface(v)
+	}
+	if !ok {
+		msg := fmt.Sprintf("no type registered for '%v' discriminant",
+			v.Type().String())
+		err := unmarshalError("decodeUnionInterface", ErrBadDiscriminant,
+			msg, disc, nil)
+		return 0, err
+	}
+
+	// Void arms do not have a value.
+	if t == nil {
+		v.Set(reflect.Zero(v.Type()))
+		return 0, nil
+	}
+
+	// Decode into a newly allocated value of the registered type.  Pointer
+	// types are stored as the pointer to the allocated value.
+	var n int
+	var err error
+	if t.Kind() == reflect.Ptr {
+		pv := reflect.New(t.Elem())
+		n, err = d.decode(pv)
+		v.Set(pv)
+	} else {
+		pv := reflect.New(t)
+		n, err = d.decode(pv)
+		v.Set(pv.Elem())
+	}
+	return n, err
+}
+
+// Unmarshaler is the interface implemented by types that can unmarshal an XDR
+// representation of themselves.
+type Unmarshaler interface {
+	// DecodeXDR reads the XDR encoded representation of the value from the
+	// passed Decoder and returns the number of bytes read.
+	DecodeXDR(d *Decoder) (int, error)
+}
+
+// unmarshalerType is the reflection type of the Unmarshaler interface.
+var unmarshalerType = reflect.TypeOf((*Unmarshaler)(nil)).Elem()
+
+// unmarshaler returns the Unmarshaler implemented by the passed reflection
+// value or by a pointer to it when it is addressable.  Nil pointers and
+// interfaces are never considered Unmarshalers since there is nothing to
+// decode into.
+func unmarshaler(v reflect.Value) (Unmarshaler, bool) {
+	switch v.Kind() {
+	case reflect.Interface:
+		return nil, false
+	case reflect.Ptr:
+		if v.IsNil() {
+			return nil, false
+		}
+	}
+	if !v.CanInterface() {
+		return nil, false
+	}
+
+	ti := cachedTypeInfo(v.Type())
+	if ti.ptrUnmarshaler && v.CanAddr() {
+		return v.Addr().Interface().(Unmarshaler), true
+	}
+	if ti.unmarshaler {
+		return v.Interface().(Unmarshaler), true
+	}
+	return nil, false
+}
+
 // decode is the main workhorse for unmarshalling via reflection.  It uses
 // the passed reflection value to choose the XDR primitives to decode from
 // the encapsulated reader.  It is a recursive function,
 // so cyclic data structures are not supported and will result in an infinite
 // loop.  It returns the  the number of bytes actually read.
 func (d *Decoder) decode(v reflect.Value) (int, error) {
+	// Track the nesting depth only when it is limited so the common case
+	// doesn't pay for it.
+	if d.limits == nil || d.limits.opts.MaxDepth == 0 {
+		return d.decodeValue(v)
+	}
+	if err := d.enter("decode"); err != nil {
+		return 0, err
+	}
+	n, err := d.decodeValue(v)
+	d.leave()
+	return n, err
+}
+
+// decodeValue decodes the passed reflection value for decode once the nesting
+// depth has been accounted for.
+func (d *Decoder) decodeValue(v reflect.Value) (int, error) {
 	if !v.IsValid() {
 		msg := fmt.Sprintf("type '%s' is not valid", v.Kind().String())
 		err := unmarshalError("decode", ErrUnsupportedType, msg, nil, nil)
 		return 0, err
 	}
 
-	// Indirect through pointers allocating them as needed.
+	// Indirect through pointers allocating them as needed while using the
+	// Unmarshaler interface of the first value in the chain which
+	// implements it.
+	for {
+		if v.Kind() == reflect.Ptr && v.IsNil() {
+			if !v.CanSet() {
+				break
+			}
+			err := d.allocate("decode", 1, uint(v.Type().Elem().Size()))
+			if err != nil {
+				return 0, err
+			}
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		if u, ok := unmarshaler(v); ok {
+			return u.DecodeXDR(d)
+		}
+		if v.Kind() != reflect.Ptr {
+			break
+		}
+		v = v.Elem()
+	}
 	ve, err := d.indirect(v)
 	if err != nil {
 		return 0, err
 	}
 
 	// Handle time.Time values by decoding them as an RFC3339 formatted
-	// string with nanosecond precision.  Check the type string rather
-	// than doing a full blown conversion to interface and type assertion
-	// since checking a string is much quicker.
-	if ve.Type().String() == "time.Time" {
+	// string with nanosecond precision.
+	if cachedTypeInfo(ve.Type()).isTime {
 		// Read the value as a string and parse it.
 		timeString, n, err := d.DecodeString()
 		if err != nil {
@@ -824,7 +1349,12 @@ func (d *Decoder) indirect(v reflect.Value) (reflect.Value, error) {
 			return rv, err
 		}
 		if isNil {
-			rv.Set(reflect.New(rv.Type().Elem()))
+			elemType := rv.Type().Elem()
+			err := d.allocate("indirect", 1, uint(elemType.Size()))
+			if err != nil {
+				return rv, err
+			}
+			rv.Set(reflect.New(elemType))
 		}
 		rv = rv.Elem()
 	}
@@ -856,7 +1386,12 @@ func (d *Decoder) Decode(v interface{}) (int, error) {
 		return 0, err
 	}
 
-	return d.decode(vv)
+	start := d.off
+	n, err := d.decode(vv)
+	if err != nil {
+		return n, withPath(err, typeElem(indirectType(vv.Type())), start)
+	}
+	return n, nil
 }
 
 // NewDecoder returns a Decoder that can be used to manually decode XDR data
@@ -871,3 +1406,10 @@ func NewDecoder(r io.Reader) *Decoder {
 func NewDecoderLimited(r io.Reader, maxSize uint) *Decoder {
 	return &Decoder{r: r, maxReadSize: maxSize}
 }
+
+// NewDecoderWithOptions is identical to NewDecoder but it enforces the
+// resource limits of the passed options across all values decoded by the
+// Decoder.  See DecoderOptions for details.
+func NewDecoderWithOptions(r io.Reader, opts DecoderOptions) *Decoder {
+	return &Decoder{r: r, limits: &decodeLimits{opts: opts}}
+}
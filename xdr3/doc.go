@@ -0,0 +1,481 @@
+/*
+ * Copyright (c) 2012-2014 Dave Collins <dave@davec.name>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+/*
+Package xdr implements the data representation portion of the External Data
+Representation (XDR) standard protocol as specified in RFC 4506 (obsoletes
+RFC 1832 and RFC 1014).
+
+The XDR RFC defines both a data specification language and a data
+representation standard.  This package implements methods to encode and decode
+XDR data per the data representation standard.  Parsing of the data
+specification language is provided by the xdr2/xdrlang package which produces a
+syntax tree from an XDR data specification file (typically .x extension), and
+the xdr2/cmd/xdrgen command uses it to generate Go types along with
+reflection-free methods to encode and decode them.  In practice, working from a
+specification file is largely unnecessary due to the reflection capabilities of
+Go as described below.  The ONC RPC protocol which is typically carried in XDR
+is implemented by the xdr2/oncrpc package and the portmapper used to locate RPC
+services by the xdr2/portmapper package.
+
+This package is version 3 of the XDR package.  It unifies the byte slice based
+API of version 1 with the io.Reader and io.Writer based API of version 2 on top
+of a single implementation.  The xdr and xdr2 packages remain available for
+existing clients as compatibility shims over this package.
+
+Compatibility note: embedded structs are encoded the same as by versions 1 and
+2, that is as nested structs, and those of unexported types are still skipped.
+Promoting the fields of embedded structs requires the inline option described
+in the Struct Fields section below.
+
+This package provides two approaches for encoding and decoding XDR data:
+
+	1) Marshal/Unmarshal functions which automatically map between XDR and Go types
+	2) Individual Encoder/Decoder objects to manually work with XDR primitives
+
+For the Marshal/Unmarshal functions, Go reflection capabilities are used to
+choose the type of the underlying XDR data based upon the Go type to encode or
+the target Go type to decode into.  A description of how each type is mapped is
+provided below, however one important type worth reviewing is Go structs.  In
+the case of structs, each exported field (first letter capitalized) is reflected
+and mapped in order.  As a result, this means a Go struct with exported fields
+of the appropriate types listed in the expected order can be used to
+automatically encode / decode the XDR data thereby eliminating the need to write
+a lot of boilerplate code to encode/decode and error check each piece of XDR
+data as is typically required with C based XDR libraries.
+
+Go Type to XDR Type Mappings
+
+The following chart shows an overview of how Go types are mapped to XDR types
+for automatic marshalling and unmarshalling.  The documentation for the Marshal
+and Unmarshal functions has specific details of how the mapping proceeds.
+
+	Go Type <-> XDR Type
+	--------------------
+	int8, int16, int32, int <-> XDR Integer
+	uint8, uint16, uint32, uint <-> XDR Unsigned Integer
+	int64 <-> XDR Hyper Integer
+	uint64 <-> XDR Unsigned Hyper Integer
+	bool <-> XDR Boolean
+	float32 <-> XDR Floating-Point
+	float64 <-> XDR Double-Precision Floating-Point
+	Quadruple <-> XDR Quadruple-Precision Floating-Point
+	string <-> XDR String
+	byte <-> XDR Integer
+	[]byte <-> XDR Variable-Length Opaque Data
+	[#]byte <-> XDR Fixed-Length Opaque Data
+	[]<type> <-> XDR Variable-Length Array
+	[#]<type> <-> XDR Fixed-Length Array
+	struct <-> XDR Structure
+	struct with union tags <-> XDR Discriminated Union
+	*<type> with optional tag <-> XDR Optional-Data
+	map <-> XDR Variable-Length Array of two-element XDR Structures
+	time.Time <-> XDR String encoded with RFC3339 nanosecond precision
+	Marshaler/Unmarshaler <-> XDR encoded by the EncodeXDR/DecodeXDR methods
+
+Notes and Limitations:
+
+	* Automatic marshalling and unmarshalling of variable and fixed-length
+	  arrays of uint8s require a special struct tag `xdropaque:"false"`
+	  since byte slices and byte arrays are assumed to be opaque data and
+	  byte is a Go alias for uint8 thus indistinguishable under reflection.
+	  Arrays and slices of named uint8 types which implement the Marshaler
+	  or Unmarshaler interfaces are not opaque data and each element is
+	  encoded by its EncodeXDR and DecodeXDR methods
+	* Values which implement the Marshaler or Unmarshaler interfaces are
+	  encoded by their EncodeXDR and DecodeXDR methods even when their field
+	  has a len option or the `xdropaque:"false"` tag
+	* Channel, complex, and function types cannot be encoded
+	* Interfaces without a concrete value cannot be encoded and can only be
+	  decoded into when they are union arms registered with RegisterUnion
+	* Cyclic data structures cannot be encoded and result in a MarshalError
+	  with an error code of ErrCycle
+	* Strings are marshalled and unmarshalled with UTF-8 character encoding
+	  which differs from the XDR specification of ASCII, however UTF-8 is
+	  backwards compatible with ASCII so this should rarely cause issues
+
+Discriminated Unions
+
+Discriminated unions are described with struct tags.  The discriminant is a
+field of an integer, unsigned integer, or bool type tagged with `xdr:"union"`
+and the arms are the fields which follow it tagged with either
+`xdr:"unioncase=<value>[,<value>...]"` or `xdr:"default"`.  Only the arm
+selected by the value of the discriminant is encoded or decoded.  The arms that
+are not selected are ignored when encoding and set to their zero values when
+decoding.  A void arm can be expressed with a field of type struct{}.
+
+For example, the following XDR union:
+
+	union Result switch (int status) {
+	case 0:
+		opaque data<>;
+	case 1:
+	case 2:
+		string message<>;
+	default:
+		void;
+	};
+
+can be described by:
+
+	type Result struct {
+		Status  int32    `xdr:"union"`
+		Data    []byte   `xdr:"unioncase=0"`
+		Message string   `xdr:"unioncase=1,2"`
+		Void    struct{} `xdr:"default"`
+	}
+
+Arms may also be interface fields.  An interface field which follows the
+discriminant without any union arm options is the default arm.  Calling
+RegisterUnion with the interface type and a map of discriminant values to
+concrete types allows the arm to be decoded into a nil interface since the
+concrete type to allocate is selected by the discriminant.  For example:
+
+	type Message interface{}
+	type Call struct{ Proc uint32 }
+	type Reply struct{ Status int32 }
+	type Envelope struct {
+		Type int32 `xdr:"union"`
+		Body Message
+	}
+
+	xdr.RegisterUnion(reflect.TypeOf((*Message)(nil)).Elem(),
+		map[int32]reflect.Type{
+			0: reflect.TypeOf(Call{}),
+			1: reflect.TypeOf((*Reply)(nil)),
+		})
+
+A MarshalError or UnmarshalError with an error code of ErrBadDiscriminant is
+returned when the discriminant does not select any arm and there is no default
+arm, or when it selects an interface arm whose registered type does not match
+the concrete value being encoded.
+
+Optional Data
+
+Optional data is described by tagging a pointer field with `xdr:"optional"`.
+It is encoded as a boolean which indicates whether or not the pointer is
+non-nil followed by the value pointed to when it is.  When decoding, the pointer
+is set to nil or allocated to match.  This allows recursive types such as
+linked lists to be modelled directly.  For example, the following XDR
+definition:
+
+	struct entry {
+		unsigned hyper fileid;
+		string name<>;
+		entry *nextentry;
+	};
+
+can be described by:
+
+	type Entry struct {
+		FileID    uint64
+		Name      string
+		NextEntry *Entry `xdr:"optional"`
+	}
+
+Size Bounds
+
+The maximum size declared for variable-length data in XDR, such as
+string name<255> or int ids<16>, is described by tagging a string, slice, or
+map field with `xdr:"max=<n>"`.  A slice field tagged with `xdr:"len=<n>"` is
+encoded as a fixed-length array of n elements, such as int ids[16], in place of
+a variable-length array.  The tag may also be used on array fields as long as
+it matches the length of the array.  For example:
+
+	type Group struct {
+		Name string   `xdr:"max=255"`
+		IDs  []uint32 `xdr:"max=16"`
+		Key  []byte   `xdr:"len=32"`
+	}
+
+Both Marshal and Unmarshal enforce the bounds and return a MarshalError or
+UnmarshalError with an error code of ErrOverflow which names the field when
+they are violated.  When decoding, the length of variable-length data is
+checked before any storage for it is allocated.
+
+Struct Fields
+
+The exported fields of a struct are encoded in the order they are declared,
+while unexported fields and fields tagged with `xdr:"-"` are skipped, so they
+may hold in-memory state which is not part of the wire format.  When the
+declaration order differs from the wire order, every field can be tagged with
+`xdr:"order=<n>"` to encode the fields in ascending order of n instead.  For
+example, the following is encoded as the XDR struct { unsigned int id;
+string name<>; }:
+
+	type Entry struct {
+		Name  string `xdr:"order=1"`
+		ID    uint32 `xdr:"order=0"`
+		Dirty bool   `xdr:"-"`
+	}
+
+Embedded structs are encoded as nested structs like any other field, and those
+of unexported types are skipped like any other unexported field.  Since a
+nested struct is encoded as its fields in order, this is the same encoding as
+promoting them.  Tagging an embedded struct with `xdr:"inline"` promotes its
+exported fields, even when its type is unexported, into the embedding struct
+in place of it.  A promoted union discriminant may therefore select arms
+declared in the embedding struct, and the order option, which is the only
+other option allowed with inline, places the promoted fields as a group.  For
+example:
+
+	type Header struct {
+		Kind uint32 `xdr:"union"`
+	}
+
+	type Message struct {
+		Header `xdr:"inline"`
+		Name   string `xdr:"unioncase=1"`
+		ID     uint32 `xdr:"unioncase=2"`
+	}
+
+Unlike Go, a promoted field may not have the same name as another field of the
+embedding struct, including fields promoted from other embedded structs, since
+Go would either hide one of them, which would silently drop it from the wire
+format, or treat them as ambiguous.  Such structs result in an error with an
+error code of ErrBadArguments instead.  The inline option is not allowed on
+embedded pointers, time.Time, or embedded types which implement the Marshaler
+or Unmarshaler interfaces.  Note that the methods of an embedded type are
+promoted by Go too, so a struct which embeds a Marshaler is itself a
+Marshaler.
+
+Hyper Integers
+
+The int and uint types are encoded as XDR integers and unsigned integers, so
+encoding a value of one of them which doesn't fit into 32 bits results in a
+MarshalError with an error code of ErrOverflow rather than truncating it.
+Integer fields tagged with `xdr:"hyper"` are encoded as XDR hyper integers, or
+unsigned hyper integers for unsigned types, regardless of their size instead.
+For example, the XDR hyper size is described by:
+
+	type File struct {
+		Size int `xdr:"hyper"`
+	}
+
+The tag has no effect on fields whose types implement the Marshaler and
+Unmarshaler interfaces since they are always encoded and decoded by them.
+
+Quadruple-Precision Floating Point
+
+Go has no 128-bit floating point type, so XDR quadruple-precision floating
+point values are represented by the Quadruple type, which holds the 16 bytes of
+the IEEE 754 binary128 value.  Since every float64 value can be represented
+exactly, QuadrupleFromFloat64 converts without loss, while the Float64 method
+rounds using the passed big.RoundingMode.  For the full precision, the BigFloat
+method and QuadrupleFromBig convert to and from a *big.Float.  For example:
+
+	q := xdr.QuadrupleFromFloat64(1.5)
+	f := q.Float64(big.ToNearestEven)
+
+Custom Encodings
+
+Types which need a wire format that can't be described by reflection can
+implement the Marshaler and Unmarshaler interfaces.  Their EncodeXDR and
+DecodeXDR methods are called in place of the reflection-based encoding wherever
+values of the type appear, including as fields of structs and elements of
+arrays, slices, and maps that are otherwise encoded via reflection.  Methods
+with pointer receivers are used when the value is addressable, such as when a
+pointer to the containing struct is passed to Marshal.  For example, the
+following type is encoded as an XDR unsigned hyper integer holding the number
+of seconds since the Unix epoch:
+
+	type Timestamp struct{ time.Time }
+
+	func (t Timestamp) EncodeXDR(enc *xdr.Encoder) (int, error) {
+		return enc.EncodeUhyper(uint64(t.Unix()))
+	}
+
+	func (t *Timestamp) DecodeXDR(d *xdr.Decoder) (int, error) {
+		secs, n, err := d.DecodeUhyper()
+		if err != nil {
+			return n, err
+		}
+		t.Time = time.Unix(int64(secs), 0)
+		return n, nil
+	}
+
+Encoding
+
+To encode XDR data, use the Marshal function.
+	func Marshal(w io.Writer, v interface{}) (int, error)
+
+For example, given the following code snippet:
+
+	type ImageHeader struct {
+		Signature	[3]byte
+		Version		uint32
+		IsGrayscale	bool
+		NumSections	uint32
+	}
+	h := ImageHeader{[3]byte{0xAB, 0xCD, 0xEF}, 2, true, 10}
+
+	var w bytes.Buffer
+	bytesWritten, err := xdr.Marshal(&w, &h)
+	// Error check elided
+
+The result, encodedData, will then contain the following XDR encoded byte
+sequence:
+
+	0xAB, 0xCD, 0xEF, 0x00,
+	0x00, 0x00, 0x00, 0x02,
+	0x00, 0x00, 0x00, 0x01,
+	0x00, 0x00, 0x00, 0x0A
+
+
+Data may also be encoded directly into a byte slice with the AppendMarshal
+function, which appends the encoding to the passed slice:
+
+	data, err := xdr.AppendMarshal(nil, &h)
+	// Error check elided
+
+In addition, while the automatic marshalling discussed above will work for the
+vast majority of cases, an Encoder object is provided that can be used to
+manually encode XDR primitives for complex scenarios where automatic
+reflection-based encoding won't work.  The included examples provide a sample of
+manual usage via an Encoder.  An Encoder created with NewBytesEncoder holds the
+encoded data in memory where it is available via its Data method.
+
+
+Decoding
+
+To decode XDR data, use the Unmarshal function.
+	func Unmarshal(r io.Reader, v interface{}) (int, error)
+
+For example, given the following code snippet:
+
+	type ImageHeader struct {
+		Signature	[3]byte
+		Version		uint32
+		IsGrayscale	bool
+		NumSections	uint32
+	}
+
+	// Using output from the Encoding section above.
+	encodedData := []byte{
+		0xAB, 0xCD, 0xEF, 0x00,
+		0x00, 0x00, 0x00, 0x02,
+		0x00, 0x00, 0x00, 0x01,
+		0x00, 0x00, 0x00, 0x0A,
+	}
+
+	var h ImageHeader
+	bytesRead, err := xdr.Unmarshal(bytes.NewReader(encodedData), &h)
+	// Error check elided
+
+The struct instance, h, will then contain the following values:
+
+	h.Signature = [3]byte{0xAB, 0xCD, 0xEF}
+	h.Version = 2
+	h.IsGrayscale = true
+	h.NumSections = 10
+
+Data may also be decoded directly from a byte slice with the UnmarshalBytes
+function, which returns the bytes remaining after the decoded value:
+
+	rest, err := xdr.UnmarshalBytes(encodedData, &h)
+	// Error check elided
+
+In addition, while the automatic unmarshalling discussed above will work for the
+vast majority of cases, a Decoder object is provided that can be used to
+manually decode XDR primitives for complex scenarios where automatic
+reflection-based decoding won't work.  The included examples provide a sample of
+manual usage via a Decoder.
+
+A Decoder created with NewBytesDecoder decodes from a byte slice without copying
+it.  Opaque data decoded by it, whether by DecodeOpaque or into []byte values,
+aliases the byte slice instead of being copied into new storage, so the byte
+slice must not be modified while the decoded values are in use.  This makes
+decoding large amounts of opaque data from memory much cheaper.  Set the
+CopyBytes option of NewBytesDecoderWithOptions to copy the data instead.
+
+Resource Limits
+
+The lengths of variable-length data are read from the input, so decoding
+untrusted input with Unmarshal may consume an unbounded amount of memory and
+time.  The UnmarshalWithOptions function and NewDecoderWithOptions constructor
+accept a DecoderOptions which limits the total bytes read, the total elements of
+arrays and maps, the nesting depth, and the bytes allocated while decoding:
+
+	opts := xdr.DecoderOptions{
+		MaxTotalBytes: 1 << 20,
+		MaxElements:   1 << 16,
+		MaxDepth:      32,
+		MaxAllocBytes: 4 << 20,
+	}
+	_, err := xdr.UnmarshalWithOptions(r, &msg, opts)
+	// Error check elided
+
+An UnmarshalError with an error code of ErrMaxTotalBytes, ErrMaxElements,
+ErrMaxDepth, or ErrMaxAllocBytes is returned when the corresponding limit is
+exceeded.  The limits are checked before any storage is allocated.
+
+Independent of any limits, the storage for variable-length data is only
+allocated up front when it is modest in size or the reader reports that enough
+bytes remain via a Len method, as bytes.Reader and bytes.Buffer do.  Otherwise
+it is grown as the data is decoded, so a short message that claims a huge length
+fails once the input runs out rather than allocating storage for that length.
+
+Strict Decoding
+
+By default the Decoder accepts some encodings that are not canonical, such as
+nonzero padding bytes.  Protocols that hash or sign encoded data depend on every
+value having exactly one encoding, so setting the Strict field of DecoderOptions
+rejects nonzero padding, bytes remaining after the value passed to
+UnmarshalWithOptions, NaNs other than the quiet NaN with no payload, and maps
+with duplicate keys.  They are reported with the error codes ErrNonzeroPadding,
+ErrTrailingBytes, ErrNonCanonicalNaN, and ErrDuplicateKey respectively.  The
+Encoder only produces canonical encodings, including writing every NaN as the
+quiet NaN with no payload, so its output is always accepted.
+
+Record Marking
+
+Stream transports such as TCP frame each XDR message as a record using the
+record marking standard of RFC 5531.  A RecordWriter frames the data written to
+it and a RecordReader deframes one record at a time, so they can be used
+directly as the writer and reader of Marshal and Unmarshal:
+
+	rw := xdr.NewRecordWriter(conn)
+	_, err := xdr.Marshal(rw, &request)
+	// Error check elided
+	err = rw.Flush()
+	// Error check elided
+
+	rr := xdr.NewRecordReader(conn, maxRecordSize)
+	err = rr.Next()
+	// Error check elided
+	_, err = xdr.Unmarshal(rr, &reply)
+	// Error check elided
+
+Errors
+
+All errors are either of type UnmarshalError or MarshalError.  Both provide
+human-readable output as well as an ErrorCode field which can be inspected by
+sophisticated callers if necessary.
+
+Errors returned by Marshal, Unmarshal, and the Encode and Decode methods also
+locate the value which failed within the passed value.  The Path field holds a
+path to it such as Reply.Entries[3].Name, and the Offset field holds the byte
+offset in the stream at which it began.
+
+The errors also work with errors.Is and errors.As.  The error codes are errors
+themselves, so errors.Is(err, xdr.ErrOverflow) tests the error code, while the
+underlying error of an ErrIO is available via Unwrap, so errors.Is(err,
+io.ErrUnexpectedEOF) reports whether the data ended in the middle of a value.
+
+See the documentation of UnmarshalError, MarshalError, and ErrorCode for further
+details.
+*/
+package xdr
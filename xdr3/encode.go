@@ -0,0 +1,1249 @@
+/*
+ * Copyright (c) 2012-2014 Dave Collins <dave@davec.name>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package xdr
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"sort"
+	"time"
+)
+
+var errIOEncode = "%s while encoding %d bytes"
+
+/*
+Marshal writes the XDR encoding of v to writer w and returns the number of bytes
+written.  It traverses v recursively and automatically indirects pointers
+through arbitrary depth to encode the actual value pointed to.
+
+Marshal uses reflection to determine the type of the concrete value contained by
+v and performs a mapping of Go types to the underlying XDR types as follows:
+
+	Go Type -> XDR Type
+	--------------------
+	int8, int16, int32, int -> XDR Integer
+	uint8, uint16, uint32, uint -> XDR Unsigned Integer
+	int64 -> XDR Hyper Integer
+	uint64 -> XDR Unsigned Hyper Integer
+	bool -> XDR Boolean
+	float32 -> XDR Floating-Point
+	float64 -> XDR Double-Precision Floating-Point
+	Quadruple -> XDR Quadruple-Precision Floating-Point
+	string -> XDR String
+	byte -> XDR Integer
+	[]byte -> XDR Variable-Length Opaque Data
+	[#]byte -> XDR Fixed-Length Opaque Data
+	[]<type> -> XDR Variable-Length Array
+	[#]<type> -> XDR Fixed-Length Array
+	struct -> XDR Structure
+	struct with union tags -> XDR Discriminated Union
+	*<type> with optional tag -> XDR Optional-Data
+	map -> XDR Variable-Length Array of two-element XDR Structures
+	time.Time -> XDR String encoded with RFC3339 nanosecond precision
+	Marshaler -> XDR written by its EncodeXDR method
+
+Notes and Limitations:
+
+	* Values which implement the Marshaler interface, or whose pointer does
+	  when they are addressable, are encoded by calling their EncodeXDR
+	  method instead of using reflection.  This applies at every level of
+	  nesting, so values with custom encodings may be embedded in otherwise
+	  reflected types
+	* Automatic marshalling of variable and fixed-length arrays of uint8s
+	  requires a special struct tag `xdropaque:"false"` since byte slices and
+	  byte arrays are assumed to be opaque data and byte is a Go alias for uint8
+	  thus indistinguishable under reflection
+	* Discriminated unions are described with the `xdr:"union"`,
+	  `xdr:"unioncase=<value>[,<value>...]"`, and `xdr:"default"` struct
+	  tags as detailed in the package documentation
+	* Pointer fields tagged with `xdr:"optional"` are XDR optional-data,
+	  otherwise nil pointers can't be encoded
+	* String, slice, and map fields tagged with `xdr:"max=<n>"` can't have
+	  more than n elements and slice fields tagged with `xdr:"len=<n>"` are
+	  encoded as fixed-length arrays which must have exactly n elements
+	* Int and uint values are 64 bits on some platforms, so encoding one
+	  which does not fit into an XDR integer or unsigned integer results in
+	  ErrOverflow rather than truncating it.  Integer fields tagged with
+	  `xdr:"hyper"` are encoded as XDR hyper integers, or unsigned hyper
+	  integers for unsigned types, instead
+	* Struct fields tagged with `xdr:"-"` are skipped and fields tagged with
+	  `xdr:"order=<n>"` are encoded in ascending order of n rather than the
+	  order they are declared.  The fields of embedded structs are promoted
+	  into the embedding struct
+	* Map entries are encoded in a deterministic order so encoding the same
+	  map always produces the same bytes.  Integer, unsigned integer, string,
+	  bool, and floating point keys are ordered by value while keys of other
+	  types, such as fixed-length arrays, are ordered bytewise by their
+	  encoding
+	* Channel, complex, and function types cannot be encoded
+	* Interfaces without a concrete value cannot be encoded
+	* Cyclic data structures, such as a struct with a pointer field which
+	  points back to it, cannot be encoded and result in a MarshalError with
+	  an error code of ErrCycle
+	* Strings are marshalled with UTF-8 character encoding which differs from
+	  the XDR specification of ASCII, however UTF-8 is backwards compatible with
+	  ASCII so this should rarely cause issues
+
+If any issues are encountered during the marshalling process, a MarshalError is
+returned with a human readable description as well as an ErrorCode value for
+further inspection from sophisticated callers.  Some potential issues are
+unsupported Go types, attempting to encode more opaque data than can be
+represented by a single opaque XDR entry, and exceeding max slice limitations.
+*/
+func Marshal(w io.Writer, v interface{}) (int, error) {
+	enc := Encoder{w: w}
+	return enc.Encode(v)
+}
+
+// An Encoder wraps an io.Writer that will receive the XDR encoded byte stream.
+// See NewEncoder and NewBytesEncoder.
+type Encoder struct {
+	w io.Writer
+
+	// sw is the writer of Encoders created by NewBytesEncoder which holds
+	// the encoded data.  It is nil for all other Encoders.
+	sw *sliceWriter
+
+	// off is the number of bytes written by the Encoder.  It locates the
+	// values which fail to encode.
+	off int64
+
+	// depth is the current nesting depth of encode and seen holds the
+	// values being encoded once it exceeds startDetectingCyclesAfter.
+	// Together they detect cyclic data structures.  cycle is the value
+	// which contains itself once one is detected so the path of the error
+	// can be limited to the cycle.
+	depth int
+	seen  map[cycleKey]struct{}
+	cycle cycleKey
+}
+
+// EncodeInt writes the XDR encoded representation of the passed 32-bit signed
+// integer to the encapsulated writer and returns the number of bytes written.
+//
+// A MarshalError with an error code of ErrIO is returned if writing the data
+// fails.
+//
+// Reference:
+// 	RFC Section 4.1 - Integer
+// 	32-bit big-endian signed integer in range [-2147483648, 2147483647]
+func (enc *Encoder) EncodeInt(v int32) (int, error) {
+	var b [4]byte
+	b[0] = byte(v >> 24)
+	b[1] = byte(v >> 16)
+	b[2] = byte(v >> 8)
+	b[3] = byte(v)
+
+	n, err := enc.w.Write(b[:])
+	enc.off += int64(n)
+	if err != nil {
+		msg := fmt.Sprintf(errIOEncode, err.Error(), 4)
+		err := marshalError("EncodeInt", ErrIO, msg, b[:n], err)
+		return n, err
+	}
+
+	return n, nil
+}
+
+// EncodeUint writes the XDR encoded representation of the passed 32-bit
+// unsigned integer to the encapsulated writer and returns the number of bytes
+// written.
+//
+// A MarshalError with an error code of ErrIO is returned if writing the data
+// fails.
+//
+// Reference:
+// 	RFC Section 4.2 - Unsigned Integer
+// 	32-bit big-endian unsigned integer in range [0, 4294967295]
+func (enc *Encoder) EncodeUint(v uint32) (int, error) {
+	var b [4]byte
+	b[0] = byte(v >> 24)
+	b[1] = byte(v >> 16)
+	b[2] = byte(v >> 8)
+	b[3] = byte(v)
+
+	n, err := enc.w.Write(b[:])
+	enc.off += int64(n)
+	if err != nil {
+		msg := fmt.Sprintf(errIOEncode, err.Error(), 4)
+		err := marshalError("EncodeUint", ErrIO, msg, b[:n], err)
+		return n, err
+	}
+
+	return n, nil
+}
+
+// EncodeEnum treats the passed 32-bit signed integer as an enumeration value
+// and, if it is in the list of passed valid enumeration values, writes the XDR
+// encoded representation of it to the encapsulated writer.  It returns the
+// number of bytes written.
+//
+// A MarshalError is returned if the enumeration value is not one of the
+// provided valid values or if writing the data fails.
+//
+// Reference:
+// 	RFC Section 4.3 - Enumeration
+// 	Represented as an XDR encoded signed integer
+func (enc *Encoder) EncodeEnum(v int32, validEnums map[int32]bool) (int, error) {
+	if !validEnums[v] {
+		err := marshalError("EncodeEnum", ErrBadEnumValue,
+			"invalid enum", v, nil)
+		return 0, err
+	}
+	return enc.EncodeInt(v)
+}
+
+// EncodeBool writes the XDR encoded representation of the passed boolean to the
+// encapsulated writer and returns the number of bytes written.
+//
+// A MarshalError with an error code of ErrIO is returned if writing the data
+// fails.
+//
+// Reference:
+// 	RFC Section 4.4 - Boolean
+// 	Represented as an XDR encoded enumeration where 0 is false and 1 is true
+func (enc *Encoder) EncodeBool(v bool) (int, error) {
+	i := int32(0)
+	if v == true {
+		i = 1
+	}
+	return enc.EncodeInt(i)
+}
+
+// EncodeHyper writes the XDR encoded representation of the passed 64-bit
+// signed integer to the encapsulated writer and returns the number of bytes
+// written.
+//
+// A MarshalError with an error code of ErrIO is returned if writing the data
+// fails.
+//
+// Reference:
+// 	RFC Section 4.5 - Hyper Integer
+// 	64-bit big-endian signed integer in range [-9223372036854775808, 9223372036854775807]
+func (enc *Encoder) EncodeHyper(v int64) (int, error) {
+	var b [8]byte
+	b[0] = byte(v >> 56)
+	b[1] = byte(v >> 48)
+	b[2] = byte(v >> 40)
+	b[3] = byte(v >> 32)
+	b[4] = byte(v >> 24)
+	b[5] = byte(v >> 16)
+	b[6] = byte(v >> 8)
+	b[7] = byte(v)
+
+	n, err := enc.w.Write(b[:])
+	enc.off += int64(n)
+	if err != nil {
+		msg := fmt.Sprintf(errIOEncode, err.Error(), 8)
+		err := marshalError("EncodeHyper", ErrIO, msg, b[:n], err)
+		return n, err
+	}
+
+	return n, nil
+}
+
+// EncodeUhyper writes the XDR encoded representation of the passed 64-bit
+// unsigned integer to the encapsulated writer and returns the number of bytes
+// written.
+//
+// A MarshalError with an error code of ErrIO is returned if writing the data
+// fails.
+//
+// Reference:
+// 	RFC Section 4.5 - Unsigned Hyper Integer
+// 	64-bit big-endian unsigned integer in range [0, 18446744073709551615]
+func (enc *Encoder) EncodeUhyper(v uint64) (int, error) {
+	var b [8]byte
+	b[0] = byte(v >> 56)
+	b[1] = byte(v >> 48)
+	b[2] = byte(v >> 40)
+	b[3] = byte(v >> 32)
+	b[4] = byte(v >> 24)
+	b[5] = byte(v >> 16)
+	b[6] = byte(v >> 8)
+	b[7] = byte(v)
+
+	n, err := enc.w.Write(b[:])
+	enc.off += int64(n)
+	if err != nil {
+		msg := fmt.Sprintf(errIOEncode, err.Error(), 8)
+		err := marshalError("EncodeUhyper", ErrIO, msg, b[:n], err)
+		return n, err
+	}
+
+	return n, nil
+}
+
+// EncodeFloat writes the XDR encoded representation of the passed 32-bit
+// (single-precision) floating point to the encapsulated writer and returns the
+// number of bytes written.  NaNs are written as the canonical quiet NaN with no
+// payload and the sign bit clear, so that every value has a single encoding
+// which a strict Decoder accepts.
+//
+// A MarshalError with an error code of ErrIO is returned if writing the data
+// fails.
+//
+// Reference:
+// 	RFC Section 4.6 - Floating Point
+// 	32-bit single-precision IEEE 754 floating point
+func (enc *Encoder) EncodeFloat(v float32) (int, error) {
+	ui := math.Float32bits(v)
+	if v != v {
+		ui = canonicalNaN32
+	}
+	return enc.EncodeUint(ui)
+}
+
+// EncodeDouble writes the XDR encoded representation of the passed 64-bit
+// (double-precision) floating point to the encapsulated writer and returns the
+// number of bytes written.  NaNs are written as the canonical quiet NaN the
+// same as EncodeFloat.
+//
+// A MarshalError with an error code of ErrIO is returned if writing the data
+// fails.
+//
+// Reference:
+// 	RFC Section 4.7 -  Double-Precision Floating Point
+// 	64-bit double-precision IEEE 754 floating point
+func (enc *Encoder) EncodeDouble(v float64) (int, error) {
+	ui := math.Float64bits(v)
+	if v != v {
+		ui = canonicalNaN64
+	}
+	return enc.EncodeUhyper(ui)
+}
+
+// EncodeQuadruple writes the XDR encoded representation of the passed 128-bit
+// (quadruple-precision) floating point to the encapsulated writer and returns
+// the number of bytes written.  NaNs are written as the canonical quiet NaN the
+// same as EncodeFloat.
+//
+// A MarshalError with an error code of ErrIO is returned if writing the data
+// fails.
+//
+// Reference:
+// 	RFC Section 4.8 -  Quadruple-Precision Floating Point
+// 	128-bit quadruple-precision IEEE 754 floating point
+func (enc *Encoder) EncodeQuadruple(v Quadruple) (int, error) {
+	if v.IsNaN() {
+		v = canonicalNaN128
+	}
+	n, err := enc.w.Write(v[:])
+	enc.off += int64(n)
+	if err != nil {
+		msg := fmt.Sprintf(errIOEncode, err.Error(), 16)
+		err := marshalError("EncodeQuadruple", ErrIO, msg, v[:n], err)
+		return n, err
+	}
+
+	return n, nil
+}
+
+// EncodeFixedOpaque treats the passed byte slice as opaque data of a fixed
+// size and writes the XDR encoded representation of it  to the encapsulated
+// writer.  It returns the number of bytes written.
+//
+// A MarshalError with an error code of ErrIO is returned if writing the data
+// fails.
+//
+// Reference:
+// 	RFC Section 4.9 - Fixed-Length Opaque Data
+// 	Fixed-length uninterpreted data zero-padded to a multiple of four
+func (enc *Encoder) EncodeFixedOpaque(v []byte) (int, error) {
+	l := len(v)
+	pad := (4 - (l % 4)) % 4
+
+	// Write the actual bytes.
+	n, err := enc.w.Write(v)
+	enc.off += int64(n)
+	if err != nil {
+		msg := fmt.Sprintf(errIOEncode, err.Error(), len(v))
+		err := marshalError("EncodeFixedOpaque", ErrIO, msg, v[:n], err)
+		return n, err
+	}
+
+	// Write any padding if needed.
+	if pad > 0 {
+		b := make([]byte, pad)
+		n2, err := enc.w.Write(b)
+		enc.off += int64(n2)
+		n += n2
+		if err != nil {
+			written := make([]byte, l+n2)
+			copy(written, v)
+			copy(written[l:], b[:n2])
+			msg := fmt.Sprintf(errIOEncode, err.Error(), l+pad)
+			err := marshalError("EncodeFixedOpaque", ErrIO, msg,
+				written, err)
+			return n, err
+		}
+	}
+
+	return n, nil
+}
+
+// EncodeOpaque treats the passed byte slice as opaque data of a variable
+// size and writes the XDR encoded representation of it to the encapsulated
+// writer.  It returns the number of bytes written.
+//
+// A MarshalError with an error code of ErrIO is returned if writing the data
+// fails.
+//
+// Reference:
+// 	RFC Section 4.10 - Variable-Length Opaque Data
+// 	Unsigned integer length followed by fixed opaque data of that length
+func (enc *Encoder) EncodeOpaque(v []byte) (int, error) {
+	// Length of opaque data.
+	n, err := enc.EncodeUint(uint32(len(v)))
+	if err != nil {
+		return n, err
+	}
+
+	n2, err := enc.EncodeFixedOpaque(v)
+	n += n2
+	return n, err
+}
+
+// EncodeString writes the XDR encoded representation of the passed string
+// to the encapsulated writer and returns the number of bytes written.
+// Character encoding is assumed to be UTF-8 and therefore ASCII compatible.  If
+// the underlying character encoding is not compatible with this assumption, the
+// data can instead be written as variable-length opaque data (EncodeOpaque) and
+// manually converted as needed.
+//
+// A MarshalError with an error code of ErrIO is returned if writing the data
+// fails.
+//
+// Reference:
+// 	RFC Section 4.11 - String
+// 	Unsigned integer length followed by bytes zero-padded to a multiple of four
+func (enc *Encoder) EncodeString(v string) (int, error) {
+	// Length of string.
+	n, err := enc.EncodeUint(uint32(len(v)))
+	if err != nil {
+		return n, err
+	}
+
+	n2, err := enc.EncodeFixedOpaque([]byte(v))
+	n += n2
+	return n, err
+}
+
+// encodeFixedArray writes the XDR encoded representation of each element
+// in the passed array represented by the reflection value to the encapsulated
+// writer and returns the number of bytes written.  The ignoreOpaque flag
+// controls whether or not uint8 (byte) elements should be encoded individually
+// or as a fixed sequence of opaque data.
+//
+// A MarshalError is returned if any issues are encountered while encoding
+// the array elements.
+//
+// Reference:
+// 	RFC Section 4.12 - Fixed-Length Array
+// 	Individually XDR encoded array elements
+func (enc *Encoder) encodeFixedArray(v reflect.Value, ignoreOpaque bool) (int, error) {
+	// Treat [#]byte (byte is alias for uint8) as opaque data unless ignored
+	// or the bytes have their own encoding.
+	if !ignoreOpaque && isOpaqueElem(v.Type().Elem()) {
+		// Create a slice of the underlying array for better efficiency
+		// when possible.  Can't create a slice of an unaddressable
+		// value.
+		if v.CanAddr() {
+			return enc.EncodeFixedOpaque(v.Slice(0, v.Len()).Bytes())
+		}
+
+		// When the underlying array isn't addressable fall back to
+		// copying the array into a new slice.  This is rather ugly, but
+		// the inability to create a constant slice from an
+		// unaddressable array is a limitation of Go.
+		slice := make([]byte, v.Len(), v.Len())
+		reflect.Copy(reflect.ValueOf(slice), v)
+		return enc.EncodeFixedOpaque(slice)
+	}
+
+	// Encode each array element.
+	var n int
+	for i := 0; i < v.Len(); i++ {
+		start := enc.off
+		n2, err := enc.encode(v.Index(i))
+		n += n2
+		if err != nil {
+			return n, withPath(err, indexElem(i), start)
+		}
+	}
+
+	return n, nil
+}
+
+// isOpaqueElem returns whether or not arrays and slices with elements of the
+// passed type are opaque data.  That is the case for bytes (uint8) unless their
+// type implements Marshaler or Unmarshaler, in which case each element is
+// encoded and decoded individually by it.
+func isOpaqueElem(t reflect.Type) bool {
+	if t.Kind() != reflect.Uint8 {
+		return false
+	}
+	return t.PkgPath() == "" || !hasCustomEncoding(t)
+}
+
+// encodeArray writes an XDR encoded integer representing the number of
+// elements in the passed slice represented by the reflection value followed by
+// the XDR encoded representation of each element in slice to the encapsulated
+// writer and returns the number of bytes written.  The ignoreOpaque flag
+// controls whether or not uint8 (byte) elements should be encoded individually
+// or as a variable sequence of opaque data.
+//
+// A MarshalError is returned if any issues are encountered while encoding
+// the array elements.
+//
+// Reference:
+// 	RFC Section 4.13 - Variable-Length Array
+// 	Unsigned integer length followed by individually XDR encoded array elements
+func (enc *Encoder) encodeArray(v reflect.Value, ignoreOpaque bool) (int, error) {
+	numItems := uint32(v.Len())
+	n, err := enc.EncodeUint(numItems)
+	if err != nil {
+		return n, err
+	}
+
+	n2, err := enc.encodeFixedArray(v, ignoreOpaque)
+	n += n2
+	return n, err
+}
+
+// encodeHyper writes the XDR encoded representation of the integer represented
+// by the passed reflection value as a hyper integer, or an unsigned hyper
+// integer when it is unsigned, to the encapsulated writer and returns the
+// number of bytes written.  It is used for fields with the hyper option.
+//
+// A MarshalError with an error code of ErrIO is returned if writing the data
+// fails.
+func (enc *Encoder) encodeHyper(v reflect.Value) (int, error) {
+	switch v.Kind() {
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int,
+		reflect.Int64:
+		return enc.EncodeHyper(v.Int())
+	}
+	return enc.EncodeUhyper(v.Uint())
+}
+
+// encodeStruct writes an XDR encoded representation of each value in the
+// exported fields of the struct represented by the passed reflection value to
+// the encapsulated writer and returns the number of bytes written.  Pointers
+// are automatically indirected through arbitrary depth to encode the actual
+// value pointed to.
+//
+// Fields tagged as discriminated union arms are only encoded when they are the
+// arm selected by the value of their discriminant.
+//
+// A MarshalError is returned if any issues are encountered while encoding
+// the elements.
+//
+// Reference:
+// 	RFC Section 4.14 - Structure
+// 	XDR encoded elements in the order of their declaration in the struct
+// 	unless the order option gives a different order
+func (enc *Encoder) encodeStruct(v reflect.Value) (int, error) {
+	ti := cachedTypeInfo(v.Type())
+	fields := ti.fields
+	if ti.fieldsErr != nil {
+		msg := fmt.Sprintf("invalid struct tag for '%v': %v",
+			v.Type().String(), ti.fieldsErr)
+		err := marshalError("encodeStruct", ErrBadArguments, msg, nil,
+			nil)
+		return 0, err
+	}
+
+	var n int
+	var value int64
+	arm := -1
+	for i := range fields {
+		// Skip union arms which are not selected by their
+		// discriminant and indirect through pointers.
+		f := &fields[i]
+		if f.isArm() && i != arm {
+			continue
+		}
+		vf := v.FieldByIndex(f.index)
+		start := enc.off
+
+		// Optional data is preceded by a boolean that indicates
+		// whether or not the pointer has a value.
+		if f.optional {
+			n2, err := enc.EncodeBool(!vf.IsNil())
+			n += n2
+			if err != nil {
+				return n, withPath(err, f.name, start)
+			}
+			if vf.IsNil() {
+				continue
+			}
+		}
+		vf = enc.indirect(vf)
+
+		// Values which implement Marshaler are encoded by it rather
+		// than the fast paths for hyper integers, slices, and arrays
+		// below.
+		custom := false
+		if vf.IsValid() {
+			_, custom = marshaler(vf)
+		}
+
+		// Integers tagged as hyper integers are encoded as such
+		// regardless of their size.
+		if f.hyper && vf.IsValid() && !custom {
+			n2, err := enc.encodeHyper(vf)
+			n += n2
+			if err != nil {
+				return n, withPath(err, f.name, start)
+			}
+			continue
+		}
+
+		// Enforce the size bounds of the field.  Slices with a fixed
+		// length are encoded as fixed-length arrays.
+		if vf.IsValid() && (f.maxLen >= 0 || f.fixedLen >= 0) {
+			if msg := f.checkLen(vf.Len()); msg != "" {
+				err := marshalError("encodeStruct", ErrOverflow,
+					msg, vf.Len(), nil)
+				return n, withPath(err, f.name, start)
+			}
+			if f.fixedLen >= 0 && vf.Kind() == reflect.Slice &&
+				!custom {
+
+				n2, err := enc.encodeFixedArray(vf, f.noOpaque)
+				n += n2
+				if err != nil {
+					return n, withPath(err, f.name, start)
+				}
+				continue
+			}
+		}
+
+		// Handle non-opaque data to []uint8 and [#]uint8 based on struct tag.
+		if f.noOpaque && !custom {
+			switch vf.Kind() {
+			case reflect.Slice:
+				n2, err := enc.encodeArray(vf, true)
+				n += n2
+				if err != nil {
+					return n, withPath(err, f.name, start)
+				}
+				continue
+
+			case reflect.Array:
+				n2, err := enc.encodeFixedArray(vf, true)
+				n += n2
+				if err != nil {
+					return n, withPath(err, f.name, start)
+				}
+				continue
+			}
+		}
+
+		// Encode each struct field.  Interface union arms are checked
+		// against the types registered for the union.
+		var n2 int
+		var err error
+		if f.isArm() && vf.Kind() == reflect.Interface {
+			n2, err = enc.encodeUnionInterface(vf, value)
+		} else {
+			n2, err = enc.encode(vf)
+		}
+		n += n2
+		if err != nil {
+			return n, withPath(err, f.name, start)
+		}
+
+		// Determine which arm the discriminant of a union selects.
+		if f.union {
+			value = discriminant(vf)
+			arm = selectArm(fields, i, value)
+			if arm < 0 {
+				msg := fmt.Sprintf("no union arm for "+
+					"discriminant '%s'", f.name)
+				err := marshalError("encodeStruct",
+					ErrBadDiscriminant, msg, value, nil)
+				return n, withPath(err, f.name, start)
+			}
+		}
+	}
+
+	return n, nil
+}
+
+// RFC Section 4.15 - Discriminated Union
+// Discriminated unions are handled by encodeStruct via struct tags.  The
+// discriminant is a field with an `xdr:"union"` tag and the arms are the fields
+// that follow it tagged with `xdr:"unioncase=<value>[,<value>...]"` or
+// `xdr:"default"`.
+
+// RFC Section 4.16 - Void
+// RFC Section 4.17 - Constant
+// RFC Section 4.18 - Typedef
+// RFC Sections 4.16 though 4.18 only apply to the data specification language
+// which is not implemented by this package.  A void union arm can be expressed
+// with a field of type struct{}.
+
+// RFC Section 4.19 - Optional data
+// Optional data is handled by encodeStruct for pointer fields with an
+// `xdr:"optional"` tag.  It is encoded as a boolean that indicates whether or
+// not the pointer is non-nil followed by the value pointed to when it is.
+
+// encodeMap treats the map represented by the passed reflection value as a
+// variable-length array of 2-element structures whose fields are of the same
+// type as the map keys and elements and writes its XDR encoded representation
+// to the encapsulated writer.  The entries are written in the order of their
+// keys as determined by sortedMapEntries, so encoding the same map always
+// produces the same bytes.  It returns the number of bytes written.
+//
+// A MarshalError is returned if any issues are encountered while encoding
+// the elements.
+func (enc *Encoder) encodeMap(v reflect.Value) (int, error) {
+	entries, err := enc.sortedMapEntries(v)
+	if err != nil {
+		return 0, err
+	}
+
+	// Number of elements.
+	n, err := enc.EncodeUint(uint32(len(entries)))
+	if err != nil {
+		return n, err
+	}
+
+	// Encode each key and value according to their type.
+	for _, entry := range entries {
+		start := enc.off
+		n2, err := enc.encode(entry.key)
+		n += n2
+		if err != nil {
+			return n, withPath(err, keyElem(entry.key), start)
+		}
+
+		start = enc.off
+		n2, err = enc.encode(entry.val)
+		n += n2
+		if err != nil {
+			return n, withPath(err, keyElem(entry.key), start)
+		}
+	}
+
+	return n, nil
+}
+
+// mapEntry is a key and value of a map along with the XDR encoding of the key
+// when it is needed to order the entries.
+type mapEntry struct {
+	key, val reflect.Value
+	encoded  []byte
+}
+
+// sortedMapEntries returns the entries of the map represented by the passed
+// reflection value in a deterministic order.  Keys of integer, unsigned
+// integer, string, bool, and floating point kinds are ordered by their values,
+// with false before true and NaNs before all other floating point values.
+// Keys of any other kind, such as fixed-length arrays, are ordered bytewise by
+// their XDR encoding.  Entries whose keys are ordered the same are ordered
+// bytewise by the XDR encoding of their values.
+//
+// A MarshalError is returned if a key of another kind or the value of such an
+// entry can't be encoded.
+func (enc *Encoder) sortedMapEntries(v reflect.Value) ([]mapEntry, error) {
+	// The entries are gathered by iterating the map rather than looking up
+	// its keys since NaN keys can't be looked up.
+	entries := make([]mapEntry, 0, v.Len())
+	for iter := v.MapRange(); iter.Next(); {
+		entries = append(entries, mapEntry{key: iter.Key(),
+			val: iter.Value()})
+	}
+
+	var less func(a, b reflect.Value) bool
+	switch v.Type().Key().Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Int64:
+
+		less = func(a, b reflect.Value) bool {
+			return a.Int() < b.Int()
+		}
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+		reflect.Uint64, reflect.Uintptr:
+
+		less = func(a, b reflect.Value) bool {
+			return a.Uint() < b.Uint()
+		}
+
+	case reflect.String:
+		less = func(a, b reflect.Value) bool {
+			return a.String() < b.String()
+		}
+
+	case reflect.Bool:
+		less = func(a, b reflect.Value) bool {
+			return !a.Bool() && b.Bool()
+		}
+
+	case reflect.Float32, reflect.Float64:
+		// NaNs are never equal, so a map may have any number of them.
+		// They are all encoded as the canonical NaN, so they are
+		// ordered the same.
+		less = func(a, b reflect.Value) bool {
+			fa, fb := a.Float(), b.Float()
+			return math.IsNaN(fa) && !math.IsNaN(fb) || fa < fb
+		}
+
+	default:
+		for i := range entries {
+			encoded, err := enc.encodeToBytes(entries[i].key)
+			if err != nil {
+				return nil, err
+			}
+			entries[i].encoded = encoded
+		}
+		sort.SliceStable(entries, func(i, j int) bool {
+			return bytes.Compare(entries[i].encoded,
+				entries[j].encoded) < 0
+		})
+	}
+
+	if less != nil {
+		sort.SliceStable(entries, func(i, j int) bool {
+			return less(entries[i].key, entries[j].key)
+		})
+	}
+
+	// Distinct keys which are ordered the same, such as NaNs with the same
+	// bits or keys with the same encoding, are ordered by the encoding of
+	// their values so the order never depends on the iteration order of
+	// the map.
+	tied := func(a, b *mapEntry) bool {
+		if less == nil {
+			return bytes.Equal(a.encoded, b.encoded)
+		}
+		return !less(a.key, b.key) && !less(b.key, a.key)
+	}
+	for i := 0; i < len(entries); {
+		j := i + 1
+		for j < len(entries) && tied(&entries[i], &entries[j]) {
+			j++
+		}
+		if j-i > 1 {
+			if err := enc.sortTiedEntries(entries[i:j]); err != nil {
+				return nil, err
+			}
+		}
+		i = j
+	}
+	return entries, nil
+}
+
+// sortTiedEntries orders the passed map entries, whose keys are ordered the
+// same, bytewise by the XDR encoding of their values.
+//
+// A MarshalError is returned if a value can't be encoded.
+func (enc *Encoder) sortTiedEntries(entries []mapEntry) error {
+	encoded := make([][]byte, len(entries))
+	for i := range entries {
+		var err error
+		encoded[i], err = enc.encodeToBytes(entries[i].val)
+		if err != nil {
+			return err
+		}
+	}
+	sort.Stable(tiedEntries{entries, encoded})
+	return nil
+}
+
+// tiedEntries sorts map entries by the passed encodings of their values.
+type tiedEntries struct {
+	entries []mapEntry
+	encoded [][]byte
+}
+
+func (t tiedEntries) Len() int { return len(t.entries) }
+
+func (t tiedEntries) Less(i, j int) bool {
+	return bytes.Compare(t.encoded[i], t.encoded[j]) < 0
+}
+
+func (t tiedEntries) Swap(i, j int) {
+	t.entries[i], t.entries[j] = t.entries[j], t.entries[i]
+	t.encoded[i], t.encoded[j] = t.encoded[j], t.encoded[i]
+}
+
+// encodeToBytes returns the XDR encoding of the value represented by the
+// passed reflection value.  It is encoded by an Encoder which continues the
+// nesting depth and cycle detection of the Encoder, so cycles through the
+// value are still detected.
+func (enc *Encoder) encodeToBytes(v reflect.Value) ([]byte, error) {
+	var buf bytes.Buffer
+	inner := Encoder{w: &buf, depth: enc.depth, seen: enc.seen}
+	_, err := inner.encode(v)
+	if err != nil {
+		enc.cycle = inner.cycle
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// encodeInterface examines the interface represented by the passed reflection
+// value to detect whether it is an interface that can be encoded if it is,
+// extracts the underlying value to pass back into the encode function for
+// encoding according to its type.
+//
+// A MarshalError is returned if any issues are encountered while encoding
+// the interface.
+func (enc *Encoder) encodeInterface(v reflect.Value) (int, error) {
+	if v.IsNil() || !v.CanInterface() {
+		msg := fmt.Sprintf("can't encode nil interface")
+		err := marshalError("encodeInterface", ErrNilInterface, msg,
+			nil, nil)
+		return 0, err
+	}
+
+	// Extract underlying value from the interface and indirect through pointers.
+	ve := reflect.ValueOf(v.Interface())
+	ve = enc.indirect(ve)
+	return enc.encode(ve)
+}
+
+// encodeUnionInterface encodes the interface represented by the passed
+// reflection value as the arm of a discriminated union with the passed
+// discriminant value.  When the interface type has been registered with
+// RegisterUnion, the concrete type of the interface value must be the type
+// registered for the discriminant.  Otherwise, the interface is encoded the
+// same as any other interface.
+//
+// A MarshalError is returned if the discriminant does not have a registered
+// type, the concrete type does not match the registered type, or any issues
+// are encountered while encoding the interface.
+func (enc *Encoder) encodeUnionInterface(v reflect.Value, disc int64) (int, error) {
+	t, registered, ok := unionArmType(v.Type(), disc)
+	if !registered {
+		return enc.encodeInterface(v)
+	}
+	if !ok {
+		msg := fmt.Sprintf("no type registered for '%v' discriminant",
+			v.Type().String())
+		err := marshalError("encodeUnionInterface", ErrBadDiscriminant,
+			msg, disc, nil)
+		return 0, err
+	}
+
+	// Void arms must not have a value.
+	if t == nil {
+		if !v.IsNil() {
+			msg := fmt.Sprintf("'%v' value for void union arm",
+				v.Elem().Type().String())
+			err := marshalError("encodeUnionInterface",
+				ErrBadDiscriminant, msg, disc, nil)
+			return 0, err
+		}
+		return 0, nil
+	}
+
+	if !v.IsNil() && v.Elem().Type() != t {
+		msg := fmt.Sprintf("'%v' value for union arm of type '%v'",
+			v.Elem().Type().String(), t.String())
+		err := marshalError("encodeUnionInterface", ErrBadDiscriminant,
+			msg, disc, nil)
+		return 0, err
+	}
+	return enc.encodeInterface(v)
+}
+
+// Marshaler is the interface implemented by types that can marshal themselves
+// into valid XDR.
+type Marshaler interface {
+	// EncodeXDR writes the XDR encoded representation of the value to the
+	// passed Encoder and returns the number of bytes written.
+	EncodeXDR(enc *Encoder) (int, error)
+}
+
+// marshalerType is the reflection type of the Marshaler interface.
+var marshalerType = reflect.TypeOf((*Marshaler)(nil)).Elem()
+
+// marshaler returns the Marshaler implemented by the passed reflection value
+// or by a pointer to it when it is addressable.  Nil pointers and interfaces
+// are never considered Marshalers so encoding reports them as usual.
+func marshaler(v reflect.Value) (Marshaler, bool) {
+	switch v.Kind() {
+	case reflect.Interface:
+		return nil, false
+	case reflect.Ptr:
+		if v.IsNil() {
+			return nil, false
+		}
+	}
+	if !v.CanInterface() {
+		return nil, false
+	}
+
+	ti := cachedTypeInfo(v.Type())
+	if ti.marshaler {
+		return v.Interface().(Marshaler), true
+	}
+	if ti.ptrMarshaler && v.CanAddr() {
+		return v.Addr().Interface().(Marshaler), true
+	}
+	return nil, false
+}
+
+// startDetectingCyclesAfter is the nesting depth beyond which the Encoder
+// tracks the values being encoded to detect cycles.  Tracking them is
+// relatively expensive and only values nested this deeply can be cyclic without
+// being detected, so, as encoding/json does, tracking is delayed until then.
+// A cycle is still detected the first time it repeats after this depth.
+const startDetectingCyclesAfter = 1000
+
+// cycleKey identifies a value being encoded by its address and type.  The type
+// is necessary since a struct and its first field share the same address.
+type cycleKey struct {
+	addr uintptr
+	typ  reflect.Type
+}
+
+// cycleKeyOf returns the cycleKey of the value the passed reflection value
+// refers to through any pointers and whether or not it has one.  Only values
+// which contain other values can be part of a cycle, and every cycle passes
+// through an addressable value, such as the target of a pointer or an element
+// of a slice, or a map, so those are the values which have keys.
+func cycleKeyOf(v reflect.Value) (cycleKey, bool) {
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Array, reflect.Struct, reflect.Interface:
+		if v.CanAddr() {
+			return cycleKey{v.UnsafeAddr(), v.Type()}, true
+		}
+
+	case reflect.Map:
+		if !v.IsNil() {
+			return cycleKey{v.Pointer(), v.Type()}, true
+		}
+	}
+	return cycleKey{}, false
+}
+
+// encode is the main workhorse for marshalling via reflection.  It uses
+// the passed reflection value to choose the XDR primitives to encode into
+// the encapsulated writer and returns the number of bytes written.  It is a
+// recursive function, so it keeps track of the values being encoded once the
+// nesting is deep enough to return a MarshalError with an error code of
+// ErrCycle, rather than recursing forever, when a value contains itself.
+func (enc *Encoder) encode(v reflect.Value) (int, error) {
+	if enc.depth++; enc.depth <= startDetectingCyclesAfter {
+		n, err := enc.encodeValue(v)
+		enc.depth--
+		return n, err
+	}
+
+	key, ok := cycleKeyOf(v)
+	if ok {
+		if _, seen := enc.seen[key]; seen {
+			enc.depth--
+			enc.cycle = key
+			msg := fmt.Sprintf("encountered a cycle via '%v'", key.typ)
+			err := marshalError("encode", ErrCycle, msg, nil, nil)
+			return 0, err
+		}
+		if enc.seen == nil {
+			enc.seen = make(map[cycleKey]struct{})
+		}
+		enc.seen[key] = struct{}{}
+	}
+	n, err := enc.encodeValue(v)
+	if ok {
+		delete(enc.seen, key)
+
+		// The path of the error leads from the value which contains
+		// itself back to it, so stop adding the path elements which
+		// lead to the value since they are nested too deeply to be
+		// useful.
+		if e, isCycle := err.(*MarshalError); isCycle &&
+			e.ErrorCode == ErrCycle && key == enc.cycle {
+
+			c := *e
+			c.pathDone = true
+			err = &c
+		}
+	}
+	enc.depth--
+	return n, err
+}
+
+// encodeValue encodes the passed reflection value for encode once it has been
+// checked for cycles.
+func (enc *Encoder) encodeValue(v reflect.Value) (int, error) {
+	if !v.IsValid() {
+		msg := fmt.Sprintf("type '%s' is not valid", v.Kind().String())
+		err := marshalError("encode", ErrUnsupportedType, msg, nil, nil)
+		return 0, err
+	}
+
+	// Use the Marshaler interface of the first value in the chain of
+	// pointers which implements it, otherwise indirect through the pointers
+	// to get at the concrete value.
+	for {
+		if m, ok := marshaler(v); ok {
+			return m.EncodeXDR(enc)
+		}
+		if v.Kind() != reflect.Ptr || v.IsNil() {
+			break
+		}
+		v = v.Elem()
+	}
+	ve := enc.indirect(v)
+
+	// Handle time.Time values by encoding them as an RFC3339 formatted
+	// string with nanosecond precision.
+	if cachedTypeInfo(ve.Type()).isTime && ve.CanInterface() {
+		viface := ve.Interface()
+		if tv, ok := viface.(time.Time); ok {
+			return enc.EncodeString(tv.Format(time.RFC3339Nano))
+		}
+	}
+
+	// Handle native Go types.
+	switch ve.Kind() {
+	case reflect.Int8, reflect.Int16, reflect.Int32:
+		return enc.EncodeInt(int32(ve.Int()))
+
+	case reflect.Int:
+		// Ints are 64 bits on some platforms, so ensure the value fits
+		// into an XDR integer.
+		i := ve.Int()
+		if i < math.MinInt32 || i > math.MaxInt32 {
+			msg := fmt.Sprintf("signed integer %d too large to fit "+
+				"an XDR integer", i)
+			err := marshalError("encode", ErrOverflow, msg, i, nil)
+			return 0, err
+		}
+		return enc.EncodeInt(int32(i))
+
+	case reflect.Int64:
+		return enc.EncodeHyper(ve.Int())
+
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return enc.EncodeUint(uint32(ve.Uint()))
+
+	case reflect.Uint:
+		// Uints are 64 bits on some platforms, so ensure the value fits
+		// into an XDR unsigned integer.
+		ui := ve.Uint()
+		if ui > math.MaxUint32 {
+			msg := fmt.Sprintf("unsigned integer %d too large to "+
+				"fit an XDR unsigned integer", ui)
+			err := marshalError("encode", ErrOverflow, msg, ui, nil)
+			return 0, err
+		}
+		return enc.EncodeUint(uint32(ui))
+
+	case reflect.Uint64:
+		return enc.EncodeUhyper(ve.Uint())
+
+	case reflect.Bool:
+		return enc.EncodeBool(ve.Bool())
+
+	case reflect.Float32:
+		return enc.EncodeFloat(float32(ve.Float()))
+
+	case reflect.Float64:
+		return enc.EncodeDouble(ve.Float())
+
+	case reflect.String:
+		return enc.EncodeString(ve.String())
+
+	case reflect.Array:
+		return enc.encodeFixedArray(ve, false)
+
+	case reflect.Slice:
+		return enc.encodeArray(ve, false)
+
+	case reflect.Struct:
+		return enc.encodeStruct(ve)
+
+	case reflect.Map:
+		return enc.encodeMap(ve)
+
+	case reflect.Interface:
+		return enc.encodeInterface(ve)
+	}
+
+	// The only unhandled types left are unsupported.  At the time of this
+	// writing the only remaining unsupported types that exist are
+	// reflect.Uintptr and reflect.UnsafePointer.
+	msg := fmt.Sprintf("unsupported Go type '%s'", ve.Kind().String())
+	err := marshalError("encode", ErrUnsupportedType, msg, nil, nil)
+	return 0, err
+}
+
+// indirect dereferences pointers until it reaches a non-pointer.  This allows
+// transparent encoding through arbitrary levels of indirection.
+func (enc *Encoder) indirect(v reflect.Value) reflect.Value {
+	rv := v
+	for rv.Kind() == reflect.Ptr {
+		rv = rv.Elem()
+	}
+	return rv
+}
+
+// Encode operates identically to the Marshal function with the exception of
+// using the writer associated with the Encoder for the destination of the
+// XDR-encoded data instead of a user-supplied writer.  See the Marshal
+// documentation for specifics.
+func (enc *Encoder) Encode(v interface{}) (int, error) {
+	if v == nil {
+		msg := "can't marshal nil interface"
+		err := marshalError("Marshal", ErrNilInterface, msg, nil, nil)
+		return 0, err
+	}
+
+	vv := reflect.ValueOf(v)
+	vve := vv
+	for vve.Kind() == reflect.Ptr {
+		if vve.IsNil() {
+			msg := fmt.Sprintf("can't marshal nil pointer '%v'",
+				vv.Type().String())
+			err := marshalError("Marshal", ErrBadArguments, msg,
+				nil, nil)
+			return 0, err
+		}
+		vve = vve.Elem()
+	}
+
+	start := enc.off
+	n, err := enc.encode(vve)
+	if err != nil {
+		return n, withPath(err, typeElem(vve.Type()), start)
+	}
+	return n, nil
+}
+
+// NewEncoder returns an object that can be used to manually choose fields to
+// XDR encode to the passed writer w.  Typically, Marshal should be used instead
+// of manually creating an Encoder. An Encoder, along with several of its
+// methods to encode XDR primitives, is exposed so it is possible to perform
+// manual encoding of data without relying on reflection should it be necessary
+// in complex scenarios where automatic reflection-based encoding won't work.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w}
+}
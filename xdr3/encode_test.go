@@ -0,0 +1,1148 @@
+/*
+ * Copyright (c) 2012-2014 Dave Collins <dave@davec.name>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package xdr_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/davecgh/go-xdr/xdr3"
+)
+
+// testExpectedMRet is a convenience method to test an expected number of bytes
+// written and error for a marshal.
+func testExpectedMRet(t *testing.T, name string, n, wantN int, err, wantErr error) bool {
+	// First ensure the number of bytes written is the expected value.  The
+	// bytes read should be accurate even when an error occurs.
+	if n != wantN {
+		t.Errorf("%s: unexpected num bytes written - got: %v want: %v\n",
+			name, n, wantN)
+		return false
+	}
+
+	// Next check for the expected error.
+	if reflect.TypeOf(err) != reflect.TypeOf(wantErr) {
+		t.Errorf("%s: failed to detect error - got: %v <%[2]T> want: %T",
+			name, err, wantErr)
+		return false
+	}
+	if rerr, ok := err.(*MarshalError); ok {
+		if werr, ok := wantErr.(*MarshalError); ok {
+			if rerr.ErrorCode != werr.ErrorCode {
+				t.Errorf("%s: failed to detect error code - "+
+					"got: %v want: %v", name,
+					rerr.ErrorCode, werr.ErrorCode)
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// TestMarshal ensures the Marshal function works properly with all types.
+func TestMarshal(t *testing.T) {
+	// Variables for various unsupported Marshal types.
+	var nilInterface interface{}
+	var testChan chan int
+	var testFunc func()
+	var testComplex64 complex64
+	var testComplex128 complex128
+
+	// testInterface is used to test Marshal with values nested in an
+	// interface.
+	testInterface := interface{}(17)
+
+	// structMarshalTestIn is input data for the big struct test of all
+	// supported types.
+	structMarshalTestIn := allTypesTest{
+		127,                                // A
+		255,                                // B
+		32767,                              // C
+		65535,                              // D
+		2147483647,                         // E
+		4294967295,                         // F
+		9223372036854775807,                // G
+		18446744073709551615,               // H
+		true,                               // I
+		3.14,                               // J
+		3.141592653589793,                  // K
+		"xdr",                              // L
+		[]byte{1, 2, 3, 4},                 // M
+		[3]byte{1, 2, 3},                   // N
+		[]int16{512, 1024, 2048},           // O
+		[2]subTest{{"one", 1}, {"two", 2}}, // P
+		&subTest{"bar", 3},                 // Q
+		map[string]uint32{"map1": 1},       // R
+		time.Unix(1396581888, 0).UTC(),     // S
+	}
+
+	// structMarshalTestWant is the expected output after marshalling
+	// structMarshalTestIn.
+	structMarshalTestWant := []byte{
+		0x00, 0x00, 0x00, 0x7F, // A
+		0x00, 0x00, 0x00, 0xFF, // B
+		0x00, 0x00, 0x7F, 0xFF, // C
+		0x00, 0x00, 0xFF, 0xFF, // D
+		0x7F, 0xFF, 0xFF, 0xFF, // E
+		0xFF, 0xFF, 0xFF, 0xFF, // F
+		0x7F, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, // G
+		0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, // H
+		0x00, 0x00, 0x00, 0x01, // I
+		0x40, 0x48, 0xF5, 0xC3, // J
+		0x40, 0x09, 0x21, 0xfb, 0x54, 0x44, 0x2d, 0x18, // K
+		0x00, 0x00, 0x00, 0x03, 0x78, 0x64, 0x72, 0x00, // L
+		0x00, 0x00, 0x00, 0x04, 0x01, 0x02, 0x03, 0x04, // M
+		0x01, 0x02, 0x03, 0x00, // N
+		0x00, 0x00, 0x00, 0x03, 0x00, 0x00, 0x02, 0x00,
+		0x00, 0x00, 0x04, 0x00, 0x00, 0x00, 0x08, 0x00, // O
+		0x00, 0x00, 0x00, 0x03, 0x6F, 0x6E, 0x65, 0x00, // P[0].A
+		0x00, 0x00, 0x00, 0x01, // P[0].B
+		0x00, 0x00, 0x00, 0x03, 0x74, 0x77, 0x6F, 0x00, // P[1].A
+		0x00, 0x00, 0x00, 0x02, // P[1].B
+		0x00, 0x00, 0x00, 0x03, 0x62, 0x61, 0x72, 0x00, // Q.A
+		0x00, 0x00, 0x00, 0x03, // Q.B
+		0x00, 0x00, 0x00, 0x01, // R length
+		0x00, 0x00, 0x00, 0x04, 0x6D, 0x61, 0x70, 0x31, // R key map1
+		0x00, 0x00, 0x00, 0x01, // R value map1
+		0x00, 0x00, 0x00, 0x14, 0x32, 0x30, 0x31, 0x34,
+		0x2d, 0x30, 0x34, 0x2d, 0x30, 0x34, 0x54, 0x30,
+		0x33, 0x3a, 0x32, 0x34, 0x3a, 0x34, 0x38, 0x5a, // S
+	}
+
+	tests := []struct {
+		in        interface{} // input value
+		wantBytes []byte      // expected bytes
+		wantN     int         // expected/max number of bytes written
+		err       error       // expected error
+	}{
+		// interface
+		{testInterface, []byte{0x00, 0x00, 0x00, 0x11}, 4, nil},
+		{&testInterface, []byte{0x00, 0x00, 0x00, 0x11}, 4, nil},
+
+		// int8 - XDR Integer
+		{int8(0), []byte{0x00, 0x00, 0x00, 0x00}, 4, nil},
+		{int8(64), []byte{0x00, 0x00, 0x00, 0x40}, 4, nil},
+		{int8(127), []byte{0x00, 0x00, 0x00, 0x7F}, 4, nil},
+		{int8(-1), []byte{0xFF, 0xFF, 0xFF, 0xFF}, 4, nil},
+		{int8(-128), []byte{0xFF, 0xFF, 0xFF, 0x80}, 4, nil},
+		// Expected Failure -- Short write
+		{int8(127), []byte{0x00, 0x00, 0x00}, 3, &MarshalError{ErrorCode: ErrIO}},
+
+		// uint8 - XDR Unsigned Integer
+		{uint8(0), []byte{0x00, 0x00, 0x00, 0x00}, 4, nil},
+		{uint8(64), []byte{0x00, 0x00, 0x00, 0x40}, 4, nil},
+		{uint8(255), []byte{0x00, 0x00, 0x00, 0xFF}, 4, nil},
+		// Expected Failure -- Short write
+		{uint8(255), []byte{0x00, 0x00}, 2, &MarshalError{ErrorCode: ErrIO}},
+
+		// int16 - XDR Integer
+		{int16(0), []byte{0x00, 0x00, 0x00, 0x00}, 4, nil},
+		{int16(1024), []byte{0x00, 0x00, 0x04, 0x00}, 4, nil},
+		{int16(32767), []byte{0x00, 0x00, 0x7F, 0xFF}, 4, nil},
+		{int16(-1), []byte{0xFF, 0xFF, 0xFF, 0xFF}, 4, nil},
+		{int16(-32768), []byte{0xFF, 0xFF, 0x80, 0x00}, 4, nil},
+		// Expected Failure -- Short write
+		{int16(-32768), []byte{0xFF}, 1, &MarshalError{ErrorCode: ErrIO}},
+
+		// uint16 - XDR Unsigned Integer
+		{uint16(0), []byte{0x00, 0x00, 0x00, 0x00}, 4, nil},
+		{uint16(1024), []byte{0x00, 0x00, 0x04, 0x00}, 4, nil},
+		{uint16(65535), []byte{0x00, 0x00, 0xFF, 0xFF}, 4, nil},
+		// Expected Failure -- Short write
+		{uint16(65535), []byte{0x00, 0x00}, 2, &MarshalError{ErrorCode: ErrIO}},
+
+		// int32 - XDR Integer
+		{int32(0), []byte{0x00, 0x00, 0x00, 0x00}, 4, nil},
+		{int32(262144), []byte{0x00, 0x04, 0x00, 0x00}, 4, nil},
+		{int32(2147483647), []byte{0x7F, 0xFF, 0xFF, 0xFF}, 4, nil},
+		{int32(-1), []byte{0xFF, 0xFF, 0xFF, 0xFF}, 4, nil},
+		{int32(-2147483648), []byte{0x80, 0x00, 0x00, 0x00}, 4, nil},
+		// Expected Failure -- Short write
+		{int32(2147483647), []byte{0x7F, 0xFF, 0xFF}, 3, &MarshalError{ErrorCode: ErrIO}},
+
+		// uint32 - XDR Unsigned Integer
+		{uint32(0), []byte{0x00, 0x00, 0x00, 0x00}, 4, nil},
+		{uint32(262144), []byte{0x00, 0x04, 0x00, 0x00}, 4, nil},
+		{uint32(4294967295), []byte{0xFF, 0xFF, 0xFF, 0xFF}, 4, nil},
+		// Expected Failure -- Short write
+		{uint32(262144), []byte{0x00, 0x04, 0x00}, 3, &MarshalError{ErrorCode: ErrIO}},
+
+		// int64 - XDR Hyper Integer
+		{int64(0), []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}, 8, nil},
+		{int64(1 << 34), []byte{0x00, 0x00, 0x00, 0x04, 0x00, 0x00, 0x00, 0x00}, 8, nil},
+		{int64(1 << 42), []byte{0x00, 0x00, 0x04, 0x00, 0x00, 0x00, 0x00, 0x00}, 8, nil},
+		{int64(9223372036854775807), []byte{0x7F, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}, 8, nil},
+		{int64(-1), []byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}, 8, nil},
+		{int64(-9223372036854775808), []byte{0x80, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}, 8, nil},
+		// Expected Failure -- Short write
+		{int64(-9223372036854775808), []byte{0x80, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}, 7, &MarshalError{ErrorCode: ErrIO}},
+
+		// uint64 - XDR Unsigned Hyper Integer
+		{uint64(0), []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}, 8, nil},
+		{uint64(1 << 34), []byte{0x00, 0x00, 0x00, 0x04, 0x00, 0x00, 0x00, 0x00}, 8, nil},
+		{uint64(1 << 42), []byte{0x00, 0x00, 0x04, 0x00, 0x00, 0x00, 0x00, 0x00}, 8, nil},
+		{uint64(18446744073709551615), []byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}, 8, nil},
+		{uint64(9223372036854775808), []byte{0x80, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}, 8, nil},
+		// Expected Failure -- Short write
+		{uint64(9223372036854775808), []byte{0x80}, 1, &MarshalError{ErrorCode: ErrIO}},
+
+		// bool - XDR Integer
+		{false, []byte{0x00, 0x00, 0x00, 0x00}, 4, nil},
+		{true, []byte{0x00, 0x00, 0x00, 0x01}, 4, nil},
+		// Expected Failure -- Short write
+		{true, []byte{0x00, 0x00, 0x00}, 3, &MarshalError{ErrorCode: ErrIO}},
+
+		// float32 - XDR Floating-Point
+		{float32(0), []byte{0x00, 0x00, 0x00, 0x00}, 4, nil},
+		{float32(3.14), []byte{0x40, 0x48, 0xF5, 0xC3}, 4, nil},
+		{float32(1234567.0), []byte{0x49, 0x96, 0xB4, 0x38}, 4, nil},
+		{float32(math.Inf(-1)), []byte{0xFF, 0x80, 0x00, 0x00}, 4, nil},
+		{float32(math.Inf(0)), []byte{0x7F, 0x80, 0x00, 0x00}, 4, nil},
+		// Expected Failure -- Short write
+		{float32(3.14), []byte{0x40, 0x48, 0xF5}, 3, &MarshalError{ErrorCode: ErrIO}},
+
+		// float64 - XDR Double-precision Floating-Point
+		{float64(0), []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}, 8, nil},
+		{float64(3.141592653589793), []byte{0x40, 0x09, 0x21, 0xfb, 0x54, 0x44, 0x2d, 0x18}, 8, nil},
+		{float64(math.Inf(-1)), []byte{0xFF, 0xF0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}, 8, nil},
+		{float64(math.Inf(0)), []byte{0x7F, 0xF0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}, 8, nil},
+		// Expected Failure -- Short write
+		{float64(3.141592653589793), []byte{0x40, 0x09, 0x21, 0xfb, 0x54, 0x44, 0x2d}, 7, &MarshalError{ErrorCode: ErrIO}},
+
+		// string - XDR String
+		{"", []byte{0x00, 0x00, 0x00, 0x00}, 4, nil},
+		{"xdr", []byte{0x00, 0x00, 0x00, 0x03, 0x78, 0x64, 0x72, 0x00}, 8, nil},
+		{"τ=2π", []byte{0x00, 0x00, 0x00, 0x06, 0xCF, 0x84, 0x3D, 0x32, 0xCF, 0x80, 0x00, 0x00}, 12, nil},
+		// Expected Failures -- Short write in length and payload
+		{"xdr", []byte{0x00, 0x00, 0x00}, 3, &MarshalError{ErrorCode: ErrIO}},
+		{"xdr", []byte{0x00, 0x00, 0x00, 0x03, 0x78}, 5, &MarshalError{ErrorCode: ErrIO}},
+
+		// []byte - XDR Variable Opaque
+		{[]byte{0x01}, []byte{0x00, 0x00, 0x00, 0x01, 0x01, 0x00, 0x00, 0x00}, 8, nil},
+		{[]byte{0x01, 0x02, 0x03}, []byte{0x00, 0x00, 0x00, 0x03, 0x01, 0x02, 0x03, 0x00}, 8, nil},
+		// Expected Failures -- Short write in length and payload
+		{[]byte{0x01}, []byte{0x00, 0x00, 0x00}, 3, &MarshalError{ErrorCode: ErrIO}},
+		{[]byte{0x01}, []byte{0x00, 0x00, 0x00, 0x01, 0x01}, 5, &MarshalError{ErrorCode: ErrIO}},
+
+		// [#]byte - XDR Fixed Opaque
+		{[1]byte{0x01}, []byte{0x01, 0x00, 0x00, 0x00}, 4, nil}, // No & here to test unaddressable arrays
+		{&[2]byte{0x01, 0x02}, []byte{0x01, 0x02, 0x00, 0x00}, 4, nil},
+		{&[3]byte{0x01, 0x02, 0x03}, []byte{0x01, 0x02, 0x03, 0x00}, 4, nil},
+		{&[4]byte{0x01, 0x02, 0x03, 0x04}, []byte{0x01, 0x02, 0x03, 0x04}, 4, nil},
+		{&[5]byte{0x01, 0x02, 0x03, 0x04, 0x05}, []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x00, 0x00, 0x00}, 8, nil},
+		// Expected Failure -- Short write
+		{[1]byte{0x01}, []byte{0x01, 0x00, 0x00}, 3, &MarshalError{ErrorCode: ErrIO}},
+
+		// []<type> - XDR Variable-Length Array
+		{&[]int16{512, 1024, 2048},
+			[]byte{0x00, 0x00, 0x00, 0x03, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00, 0x04, 0x00, 0x00, 0x00, 0x08, 0x00},
+			16, nil},
+		{[]bool{true, false}, []byte{0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00}, 12, nil},
+		// Expected Failures -- Short write in number of elements and
+		// payload
+		{[]bool{true, false}, []byte{0x00, 0x00, 0x00}, 3, &MarshalError{ErrorCode: ErrIO}},
+		{[]bool{true, false}, []byte{0x00, 0x00, 0x00, 0x02, 0x00}, 5, &MarshalError{ErrorCode: ErrIO}},
+
+		// [#]<type> - XDR Fixed-Length Array
+		{&[2]uint32{512, 1024}, []byte{0x00, 0x00, 0x02, 0x00, 0x00, 0x00, 0x04, 0x00}, 8, nil},
+		// Expected Failures -- Short write in number of elements and
+		// payload
+		{[2]uint32{512, 1024}, []byte{0x00, 0x00, 0x02}, 3, &MarshalError{ErrorCode: ErrIO}},
+		{[2]uint32{512, 1024}, []byte{0x00, 0x00, 0x02, 0x00, 0x00}, 5, &MarshalError{ErrorCode: ErrIO}},
+
+		// map[string]uint32
+		{map[string]uint32{"map1": 1},
+			[]byte{0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x04, 0x6D, 0x61, 0x70, 0x31, 0x00, 0x00, 0x00, 0x01},
+			16, nil},
+		// Expected Failures -- Short write in number of elements, key,
+		// and payload
+		{map[string]uint32{"map1": 1}, []byte{0x00, 0x00, 0x00}, 3, &MarshalError{ErrorCode: ErrIO}},
+		{map[string]uint32{"map1": 1}, []byte{0x00, 0x00, 0x00, 0x01, 0x00}, 5, &MarshalError{ErrorCode: ErrIO}},
+		{map[string]uint32{"map1": 1}, []byte{0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x04}, 8, &MarshalError{ErrorCode: ErrIO}},
+		{map[string]uint32{"map1": 1},
+			[]byte{0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x04, 0x6D, 0x61, 0x70, 0x31},
+			12, &MarshalError{ErrorCode: ErrIO}},
+
+		// time.Time - XDR String per RFC3339
+		{time.Unix(1396581888, 0).UTC(),
+			[]byte{
+				0x00, 0x00, 0x00, 0x14, 0x32, 0x30, 0x31, 0x34,
+				0x2d, 0x30, 0x34, 0x2d, 0x30, 0x34, 0x54, 0x30,
+				0x33, 0x3a, 0x32, 0x34, 0x3a, 0x34, 0x38, 0x5a,
+			}, 24, nil},
+		// Expected Failure -- Short write
+		{time.Unix(1396581888, 0).UTC(), []byte{0x00, 0x00, 0x00, 0x14, 0x32, 0x30, 0x31, 0x34}, 8, &MarshalError{ErrorCode: ErrIO}},
+
+		// struct - XDR Structure -- test struct contains all supported types
+		{&structMarshalTestIn, structMarshalTestWant, len(structMarshalTestWant), nil},
+		{opaqueStruct{[]uint8{1}, [1]uint8{2}},
+			[]byte{
+				0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x01,
+				0x00, 0x00, 0x00, 0x02,
+			}, 12, nil},
+		// Expected Failures -- Short write in variable length,
+		// variable payload, and fixed payload.
+		{structMarshalTestIn, structMarshalTestWant[:3], 3, &MarshalError{ErrorCode: ErrIO}},
+		{opaqueStruct{[]uint8{1}, [1]uint8{2}}, []byte{0x00, 0x00, 0x00}, 3, &MarshalError{ErrorCode: ErrIO}},
+		{opaqueStruct{[]uint8{1}, [1]uint8{2}}, []byte{0x00, 0x00, 0x00, 0x01}, 4, &MarshalError{ErrorCode: ErrIO}},
+		{opaqueStruct{[]uint8{1}, [1]uint8{2}},
+			[]byte{0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x01},
+			8, &MarshalError{ErrorCode: ErrIO}},
+
+		// struct - XDR Discriminated Union
+		{unionTest{Status: 0, Data: []byte{0x01, 0x02}, Message: "ignored"},
+			[]byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0x01, 0x02, 0x00, 0x00},
+			12, nil},
+		{&unionTest{Status: 2, Message: "x"},
+			[]byte{0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00, 0x01, 0x78, 0x00, 0x00, 0x00},
+			12, nil},
+		{unionTest{Status: 9, Data: []byte{0x01}}, []byte{0x00, 0x00, 0x00, 0x09}, 4, nil},
+		{boolUnionTest{true, 5}, []byte{0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x05}, 8, nil},
+		// Expected Failures -- Short write in arm, no arm for the
+		// discriminant, and arm without a discriminant.
+		{unionTest{Status: 1, Message: "x"}, []byte{0x00, 0x00, 0x00, 0x01, 0x00, 0x00}, 6, &MarshalError{ErrorCode: ErrIO}},
+		{boolUnionTest{false, 5}, []byte{0x00, 0x00, 0x00, 0x00}, 4, &MarshalError{ErrorCode: ErrBadDiscriminant}},
+		{badUnionTest{1}, []byte{}, 0, &MarshalError{ErrorCode: ErrBadArguments}},
+		// Interface typed union arms.
+		{shapeUnionTest{0, nil}, []byte{0x00, 0x00, 0x00, 0x00}, 4, nil},
+		{shapeUnionTest{1, square{5}}, []byte{0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x05}, 8, nil},
+		{shapeUnionTest{2, &triangle{3, 4, 5}},
+			[]byte{0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00, 0x03, 0x00, 0x00, 0x00, 0x04, 0x00, 0x00, 0x00, 0x05},
+			16, nil},
+		// Expected Failures -- no type registered for the discriminant,
+		// value for a void arm, and value of the wrong type.
+		{shapeUnionTest{3, square{5}}, []byte{0x00, 0x00, 0x00, 0x03}, 4, &MarshalError{ErrorCode: ErrBadDiscriminant}},
+		{shapeUnionTest{0, square{5}}, []byte{0x00, 0x00, 0x00, 0x00}, 4, &MarshalError{ErrorCode: ErrBadDiscriminant}},
+		{shapeUnionTest{1, &triangle{3, 4, 5}}, []byte{0x00, 0x00, 0x00, 0x01}, 4, &MarshalError{ErrorCode: ErrBadDiscriminant}},
+
+		// struct - XDR Optional-Data
+		{listTest{1, nil}, []byte{0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00}, 8, nil},
+		{&listTest{1, &listTest{2, nil}},
+			[]byte{
+				0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x01,
+				0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00, 0x00,
+			}, 16, nil},
+		// Expected Failures -- Short write in boolean and value, and
+		// optional data that isn't a pointer.
+		{listTest{1, nil}, []byte{0x00, 0x00, 0x00, 0x01, 0x00, 0x00}, 6, &MarshalError{ErrorCode: ErrIO}},
+		{listTest{1, &listTest{2, nil}},
+			[]byte{0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x01, 0x00},
+			9, &MarshalError{ErrorCode: ErrIO}},
+		{badOptionalTest{1}, []byte{}, 0, &MarshalError{ErrorCode: ErrBadArguments}},
+
+		// struct - size bounds
+		{boundedValue, boundedEncoded, 44, nil},
+		// Expected Failures -- string exceeding its maximum length, slice
+		// without its fixed length, and a size bound on a type without
+		// a length.
+		{boundedTest{Name: "abcd"}, []byte{}, 0, &MarshalError{ErrorCode: ErrOverflow}},
+		{boundedTest{Name: "abc", Data: []byte{1, 2}, IDs: []uint32{1}}, boundedEncoded[:16], 16, &MarshalError{ErrorCode: ErrOverflow}},
+		{badBoundTest{1}, []byte{}, 0, &MarshalError{ErrorCode: ErrBadArguments}},
+
+		// Expected errors
+		{nilInterface, []byte{}, 0, &MarshalError{ErrorCode: ErrNilInterface}},
+		{&nilInterface, []byte{}, 0, &MarshalError{ErrorCode: ErrNilInterface}},
+		{(*interface{})(nil), []byte{}, 0, &MarshalError{ErrorCode: ErrBadArguments}},
+		{testChan, []byte{}, 0, &MarshalError{ErrorCode: ErrUnsupportedType}},
+		{&testChan, []byte{}, 0, &MarshalError{ErrorCode: ErrUnsupportedType}},
+		{testFunc, []byte{}, 0, &MarshalError{ErrorCode: ErrUnsupportedType}},
+		{&testFunc, []byte{}, 0, &MarshalError{ErrorCode: ErrUnsupportedType}},
+		{testComplex64, []byte{}, 0, &MarshalError{ErrorCode: ErrUnsupportedType}},
+		{&testComplex64, []byte{}, 0, &MarshalError{ErrorCode: ErrUnsupportedType}},
+		{testComplex128, []byte{}, 0, &MarshalError{ErrorCode: ErrUnsupportedType}},
+		{&testComplex128, []byte{}, 0, &MarshalError{ErrorCode: ErrUnsupportedType}},
+	}
+
+	for i, test := range tests {
+		data := newFixedWriter(test.wantN)
+		n, err := Marshal(data, test.in)
+
+		// First ensure the number of bytes written is the expected
+		// value and the error is the expected one.
+		testName := fmt.Sprintf("Marshal #%d", i)
+		testExpectedMRet(t, testName, n, test.wantN, err, test.err)
+
+		rv := data.Bytes()
+		if len(rv) != len(test.wantBytes) {
+			t.Errorf("%s: unexpected len - got: %v want: %v\n",
+				testName, len(rv), len(test.wantBytes))
+			continue
+		}
+		if !reflect.DeepEqual(rv, test.wantBytes) {
+			t.Errorf("%s: unexpected result - got: %v want: %v\n",
+				testName, rv, test.wantBytes)
+			continue
+		}
+	}
+}
+
+// encodeFunc is used to identify which public function of the Encoder object
+// a test applies to.
+type encodeFunc int
+
+const (
+	fEncodeBool encodeFunc = iota
+	fEncodeDouble
+	fEncodeEnum
+	fEncodeFixedOpaque
+	fEncodeFloat
+	fEncodeHyper
+	fEncodeInt
+	fEncodeOpaque
+	fEncodeString
+	fEncodeUhyper
+	fEncodeUint
+)
+
+// Map of encodeFunc values to names for pretty printing.
+var encodeFuncStrings = map[encodeFunc]string{
+	fEncodeBool:        "EncodeBool",
+	fEncodeDouble:      "EncodeDouble",
+	fEncodeEnum:        "EncodeEnum",
+	fEncodeFixedOpaque: "EncodeFixedOpaque",
+	fEncodeFloat:       "EncodeFloat",
+	fEncodeHyper:       "EncodeHyper",
+	fEncodeInt:         "EncodeInt",
+	fEncodeOpaque:      "EncodeOpaque",
+	fEncodeString:      "EncodeString",
+	fEncodeUhyper:      "EncodeUhyper",
+	fEncodeUint:        "EncodeUint",
+}
+
+// String implements the fmt.Stringer interface and returns the encode function
+// as a human-readable string.
+func (f encodeFunc) String() string {
+	if s := encodeFuncStrings[f]; s != "" {
+		return s
+	}
+	return fmt.Sprintf("Unknown encodeFunc (%d)", f)
+}
+
+// TestEncoder ensures an Encoder works as intended.
+func TestEncoder(t *testing.T) {
+	tests := []struct {
+		f         encodeFunc  // function to use to encode
+		in        interface{} // input value
+		wantBytes []byte      // expected bytes
+		wantN     int         // expected number of bytes written
+		err       error       // expected error
+	}{
+		// Bool
+		{fEncodeBool, false, []byte{0x00, 0x00, 0x00, 0x00}, 4, nil},
+		{fEncodeBool, true, []byte{0x00, 0x00, 0x00, 0x01}, 4, nil},
+		// Expected Failure -- Short write
+		{fEncodeBool, true, []byte{0x00, 0x00, 0x00}, 3, &MarshalError{ErrorCode: ErrIO}},
+
+		// Double
+		{fEncodeDouble, float64(0), []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}, 8, nil},
+		{fEncodeDouble, float64(3.141592653589793), []byte{0x40, 0x09, 0x21, 0xfb, 0x54, 0x44, 0x2d, 0x18}, 8, nil},
+		{fEncodeDouble, float64(math.Inf(-1)), []byte{0xFF, 0xF0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}, 8, nil},
+		{fEncodeDouble, float64(math.Inf(0)), []byte{0x7F, 0xF0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}, 8, nil},
+		// Expected Failure -- Short write
+		{fEncodeDouble, float64(3.141592653589793), []byte{0x40, 0x09, 0x21, 0xfb, 0x54, 0x44, 0x2d}, 7, &MarshalError{ErrorCode: ErrIO}},
+
+		// Enum
+		{fEncodeEnum, int32(0), []byte{0x00, 0x00, 0x00, 0x00}, 4, nil},
+		{fEncodeEnum, int32(1), []byte{0x00, 0x00, 0x00, 0x01}, 4, nil},
+		// Expected Failures -- Invalid enum values
+		{fEncodeEnum, int32(2), []byte{}, 0, &MarshalError{ErrorCode: ErrBadEnumValue}},
+		{fEncodeEnum, int32(1234), []byte{}, 0, &MarshalError{ErrorCode: ErrBadEnumValue}},
+
+		// FixedOpaque
+		{fEncodeFixedOpaque, []byte{0x01}, []byte{0x01, 0x00, 0x00, 0x00}, 4, nil},
+		{fEncodeFixedOpaque, []byte{0x01, 0x02}, []byte{0x01, 0x02, 0x00, 0x00}, 4, nil},
+		{fEncodeFixedOpaque, []byte{0x01, 0x02, 0x03}, []byte{0x01, 0x02, 0x03, 0x00}, 4, nil},
+		{fEncodeFixedOpaque, []byte{0x01, 0x02, 0x03, 0x04}, []byte{0x01, 0x02, 0x03, 0x04}, 4, nil},
+		{fEncodeFixedOpaque, []byte{0x01, 0x02, 0x03, 0x04, 0x05}, []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x00, 0x00, 0x00}, 8, nil},
+		// Expected Failure -- Short write
+		{fEncodeFixedOpaque, []byte{0x01}, []byte{0x01, 0x00, 0x00}, 3, &MarshalError{ErrorCode: ErrIO}},
+
+		// Float
+		{fEncodeFloat, float32(0), []byte{0x00, 0x00, 0x00, 0x00}, 4, nil},
+		{fEncodeFloat, float32(3.14), []byte{0x40, 0x48, 0xF5, 0xC3}, 4, nil},
+		{fEncodeFloat, float32(1234567.0), []byte{0x49, 0x96, 0xB4, 0x38}, 4, nil},
+		{fEncodeFloat, float32(math.Inf(-1)), []byte{0xFF, 0x80, 0x00, 0x00}, 4, nil},
+		{fEncodeFloat, float32(math.Inf(0)), []byte{0x7F, 0x80, 0x00, 0x00}, 4, nil},
+		// Expected Failure -- Short write
+		{fEncodeFloat, float32(3.14), []byte{0x40, 0x48, 0xF5}, 3, &MarshalError{ErrorCode: ErrIO}},
+
+		// Hyper
+		{fEncodeHyper, int64(0), []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}, 8, nil},
+		{fEncodeHyper, int64(1 << 34), []byte{0x00, 0x00, 0x00, 0x04, 0x00, 0x00, 0x00, 0x00}, 8, nil},
+		{fEncodeHyper, int64(1 << 42), []byte{0x00, 0x00, 0x04, 0x00, 0x00, 0x00, 0x00, 0x00}, 8, nil},
+		{fEncodeHyper, int64(9223372036854775807), []byte{0x7F, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}, 8, nil},
+		{fEncodeHyper, int64(-1), []byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}, 8, nil},
+		{fEncodeHyper, int64(-9223372036854775808), []byte{0x80, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}, 8, nil},
+		// Expected Failure -- Short write
+		{fEncodeHyper, int64(-9223372036854775808), []byte{0x80, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}, 7, &MarshalError{ErrorCode: ErrIO}},
+
+		// Int
+		{fEncodeInt, int32(0), []byte{0x00, 0x00, 0x00, 0x00}, 4, nil},
+		{fEncodeInt, int32(262144), []byte{0x00, 0x04, 0x00, 0x00}, 4, nil},
+		{fEncodeInt, int32(2147483647), []byte{0x7F, 0xFF, 0xFF, 0xFF}, 4, nil},
+		{fEncodeInt, int32(-1), []byte{0xFF, 0xFF, 0xFF, 0xFF}, 4, nil},
+		{fEncodeInt, int32(-2147483648), []byte{0x80, 0x00, 0x00, 0x00}, 4, nil},
+		// Expected Failure -- Short write
+		{fEncodeInt, int32(2147483647), []byte{0x7F, 0xFF, 0xFF}, 3, &MarshalError{ErrorCode: ErrIO}},
+
+		// Opaque
+		{fEncodeOpaque, []byte{0x01}, []byte{0x00, 0x00, 0x00, 0x01, 0x01, 0x00, 0x00, 0x00}, 8, nil},
+		{fEncodeOpaque, []byte{0x01, 0x02, 0x03}, []byte{0x00, 0x00, 0x00, 0x03, 0x01, 0x02, 0x03, 0x00}, 8, nil},
+		// Expected Failures -- Short write in length and payload
+		{fEncodeOpaque, []byte{0x01}, []byte{0x00, 0x00, 0x00}, 3, &MarshalError{ErrorCode: ErrIO}},
+		{fEncodeOpaque, []byte{0x01}, []byte{0x00, 0x00, 0x00, 0x01, 0x01}, 5, &MarshalError{ErrorCode: ErrIO}},
+
+		// String
+		{fEncodeString, "", []byte{0x00, 0x00, 0x00, 0x00}, 4, nil},
+		{fEncodeString, "xdr", []byte{0x00, 0x00, 0x00, 0x03, 0x78, 0x64, 0x72, 0x00}, 8, nil},
+		{fEncodeString, "τ=2π", []byte{0x00, 0x00, 0x00, 0x06, 0xCF, 0x84, 0x3D, 0x32, 0xCF, 0x80, 0x00, 0x00}, 12, nil},
+		// Expected Failures -- Short write in length and payload
+		{fEncodeString, "xdr", []byte{0x00, 0x00, 0x00}, 3, &MarshalError{ErrorCode: ErrIO}},
+		{fEncodeString, "xdr", []byte{0x00, 0x00, 0x00, 0x03, 0x78}, 5, &MarshalError{ErrorCode: ErrIO}},
+
+		// Uhyper
+		{fEncodeUhyper, uint64(0), []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}, 8, nil},
+		{fEncodeUhyper, uint64(1 << 34), []byte{0x00, 0x00, 0x00, 0x04, 0x00, 0x00, 0x00, 0x00}, 8, nil},
+		{fEncodeUhyper, uint64(1 << 42), []byte{0x00, 0x00, 0x04, 0x00, 0x00, 0x00, 0x00, 0x00}, 8, nil},
+		{fEncodeUhyper, uint64(18446744073709551615), []byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}, 8, nil},
+		{fEncodeUhyper, uint64(9223372036854775808), []byte{0x80, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}, 8, nil},
+		// Expected Failure -- Short write
+		{fEncodeUhyper, uint64(9223372036854775808), []byte{0x80}, 1, &MarshalError{ErrorCode: ErrIO}},
+
+		// Uint
+		{fEncodeUint, uint32(0), []byte{0x00, 0x00, 0x00, 0x00}, 4, nil},
+		{fEncodeUint, uint32(262144), []byte{0x00, 0x04, 0x00, 0x00}, 4, nil},
+		{fEncodeUint, uint32(4294967295), []byte{0xFF, 0xFF, 0xFF, 0xFF}, 4, nil},
+		// Expected Failure -- Short write
+		{fEncodeUint, uint32(262144), []byte{0x00, 0x04, 0x00}, 3, &MarshalError{ErrorCode: ErrIO}},
+	}
+
+	validEnums := make(map[int32]bool)
+	validEnums[0] = true
+	validEnums[1] = true
+
+	var err error
+	var n int
+
+	for i, test := range tests {
+		err = nil
+		data := newFixedWriter(test.wantN)
+		enc := NewEncoder(data)
+		switch test.f {
+		case fEncodeBool:
+			in := test.in.(bool)
+			n, err = enc.EncodeBool(in)
+		case fEncodeDouble:
+			in := test.in.(float64)
+			n, err = enc.EncodeDouble(in)
+		case fEncodeEnum:
+			in := test.in.(int32)
+			n, err = enc.EncodeEnum(in, validEnums)
+		case fEncodeFixedOpaque:
+			in := test.in.([]byte)
+			n, err = enc.EncodeFixedOpaque(in)
+		case fEncodeFloat:
+			in := test.in.(float32)
+			n, err = enc.EncodeFloat(in)
+		case fEncodeHyper:
+			in := test.in.(int64)
+			n, err = enc.EncodeHyper(in)
+		case fEncodeInt:
+			in := test.in.(int32)
+			n, err = enc.EncodeInt(in)
+		case fEncodeOpaque:
+			in := test.in.([]byte)
+			n, err = enc.EncodeOpaque(in)
+		case fEncodeString:
+			in := test.in.(string)
+			n, err = enc.EncodeString(in)
+		case fEncodeUhyper:
+			in := test.in.(uint64)
+			n, err = enc.EncodeUhyper(in)
+		case fEncodeUint:
+			in := test.in.(uint32)
+			n, err = enc.EncodeUint(in)
+		default:
+			t.Errorf("%v #%d unrecognized function", test.f, i)
+			continue
+		}
+
+		// First ensure the number of bytes written is the expected
+		// value and the error is the expected one.
+		testName := fmt.Sprintf("%v #%d", test.f, i)
+		testExpectedMRet(t, testName, n, test.wantN, err, test.err)
+
+		// Finally, ensure the written bytes are what is expected.
+		rv := data.Bytes()
+		if len(rv) != len(test.wantBytes) {
+			t.Errorf("%s: unexpected len - got: %v want: %v\n",
+				testName, len(rv), len(test.wantBytes))
+			continue
+		}
+		if !reflect.DeepEqual(rv, test.wantBytes) {
+			t.Errorf("%s: unexpected result - got: %v want: %v\n",
+				testName, rv, test.wantBytes)
+			continue
+		}
+	}
+}
+
+// TestMarshalMapOrder ensures maps are encoded with their entries ordered by
+// key so the same map always produces the same bytes.
+func TestMarshalMapOrder(t *testing.T) {
+	tests := []struct {
+		in        interface{} // input value
+		wantBytes []byte      // expected bytes
+	}{
+		{map[int32]bool{2: true, -1: false, 0: true}, []byte{
+			0x00, 0x00, 0x00, 0x03,
+			0xff, 0xff, 0xff, 0xff, 0x00, 0x00, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01,
+			0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00, 0x01,
+		}},
+		{map[uint16]bool{0xffff: true, 1: false, 3: true}, []byte{
+			0x00, 0x00, 0x00, 0x03,
+			0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x03, 0x00, 0x00, 0x00, 0x01,
+			0x00, 0x00, 0xff, 0xff, 0x00, 0x00, 0x00, 0x01,
+		}},
+		{map[string]bool{"b": true, "ab": false, "a": true}, []byte{
+			0x00, 0x00, 0x00, 0x03,
+			0x00, 0x00, 0x00, 0x01, 0x61, 0x00, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x01,
+			0x00, 0x00, 0x00, 0x02, 0x61, 0x62, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x01, 0x62, 0x00, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x01,
+		}},
+		{map[bool]int32{true: 1, false: 2}, []byte{
+			0x00, 0x00, 0x00, 0x02,
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02,
+			0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x01,
+		}},
+		{map[float32]bool{1.5: true, -2: false, float32(math.NaN()): true},
+			[]byte{
+				0x00, 0x00, 0x00, 0x03,
+				0x7f, 0xc0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01,
+				0xc0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+				0x3f, 0xc0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01,
+			}},
+		// NaN keys are never equal, so entries with NaN keys which
+		// have the same bits or encoding are ordered by their values.
+		{map[float32]int32{float32(math.NaN()): 3,
+			float32(math.NaN()): 1, float32(math.NaN()): 2},
+			[]byte{
+				0x00, 0x00, 0x00, 0x03,
+				0x7f, 0xc0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01,
+				0x7f, 0xc0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02,
+				0x7f, 0xc0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x03,
+			}},
+		{map[[1]float32]int32{{float32(math.NaN())}: 2,
+			{float32(math.NaN())}: -1, {1}: 0},
+			[]byte{
+				0x00, 0x00, 0x00, 0x03,
+				0x3f, 0x80, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+				0x7f, 0xc0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02,
+				0x7f, 0xc0, 0x00, 0x00, 0xff, 0xff, 0xff, 0xff,
+			}},
+		{map[[2]byte]bool{{1, 0}: true, {0, 2}: false}, []byte{
+			0x00, 0x00, 0x00, 0x02,
+			0x00, 0x02, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01,
+		}},
+		{map[[1]int32]bool{{-1}: true, {1}: false}, []byte{
+			0x00, 0x00, 0x00, 0x02,
+			0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00,
+			0xff, 0xff, 0xff, 0xff, 0x00, 0x00, 0x00, 0x01,
+		}},
+	}
+
+	for i, test := range tests {
+		// Encode each map several times since the iteration order of
+		// maps is randomized.
+		for j := 0; j < 10; j++ {
+			var buf bytes.Buffer
+			if _, err := Marshal(&buf, test.in); err != nil {
+				t.Errorf("Marshal #%d unexpected error: %v", i,
+					err)
+				break
+			}
+			if !bytes.Equal(buf.Bytes(), test.wantBytes) {
+				t.Errorf("Marshal #%d unexpected result - got: "+
+					"%x want: %x", i, buf.Bytes(),
+					test.wantBytes)
+				break
+			}
+		}
+	}
+}
+
+// TestMarshalCorners ensures the Marshal function properly handles various
+// cases not already covered by the other tests.
+func TestMarshalCorners(t *testing.T) {
+	// Ensure encode of an invalid reflect value returns the expected
+	// error.
+	testName := "Encode invalid reflect value"
+	expectedN := 0
+	expectedErr := error(&MarshalError{ErrorCode: ErrUnsupportedType})
+	expectedVal := []byte{}
+	data := newFixedWriter(expectedN)
+	n, err := TstEncode(data)(reflect.Value{})
+	testExpectedMRet(t, testName, n, expectedN, err, expectedErr)
+	if !reflect.DeepEqual(data.Bytes(), expectedVal) {
+		t.Errorf("%s: unexpected result - got: %x want: %x\n",
+			testName, data.Bytes(), expectedVal)
+	}
+
+	// Ensure marshal of a struct with both exported and unexported fields
+	// skips the unexported fields but still marshals to the exported
+	// fields.
+	type unexportedStruct struct {
+		unexported int
+		Exported   int
+	}
+	testName = "Marshal struct with exported and unexported fields"
+	tstruct := unexportedStruct{0, 1}
+	expectedN = 4
+	expectedErr = nil
+	expectedVal = []byte{0x00, 0x00, 0x00, 0x01}
+	data = newFixedWriter(expectedN)
+	n, err = Marshal(data, tstruct)
+	testExpectedMRet(t, testName, n, expectedN, err, expectedErr)
+	if !reflect.DeepEqual(data.Bytes(), expectedVal) {
+		t.Errorf("%s: unexpected result - got: %x want: %x\n",
+			testName, data.Bytes(), expectedVal)
+	}
+
+	// Ensure errors for size bound violations name the field.
+	testName = "Marshal struct exceeding a size bound"
+	_, err = Marshal(newFixedWriter(0), boundedTest{Name: "abcd"})
+	if err == nil || !strings.Contains(err.Error(), "'Name'") {
+		t.Errorf("%s: error does not name the field - got: %v",
+			testName, err)
+	}
+	in := []byte{0x00, 0x00, 0x00, 0x04}
+	_, err = Unmarshal(bytes.NewReader(in), &boundedTest{})
+	if err == nil || !strings.Contains(err.Error(), "'Name'") {
+		t.Errorf("%s: error does not name the field - got: %v",
+			testName, err)
+	}
+}
+
+// TestMarshalErrorPath ensures errors returned by Marshal locate the value
+// which failed to encode.
+func TestMarshalErrorPath(t *testing.T) {
+	type holder struct {
+		ID    uint32
+		Items []interface{}
+		Attrs map[string]interface{}
+	}
+
+	tests := []struct {
+		in         interface{} // value to encode
+		wantPath   string      // expected error path
+		wantOffset int64       // expected error offset
+	}{
+		{holder{Items: []interface{}{uint32(1), make(chan int)}},
+			"holder.Items[1]", 12},
+		{&holder{Attrs: map[string]interface{}{"a": uint32(1),
+			"b": make(chan int)}}, "holder.Attrs[b]", 32},
+		{[]interface{}{true, func() {}}, "[1]", 8},
+		{complex64(1), "", 0},
+	}
+
+	for i, test := range tests {
+		_, err := Marshal(&bytes.Buffer{}, test.in)
+		merr, ok := err.(*MarshalError)
+		if !ok {
+			t.Errorf("Marshal #%d unexpected error - got: %v", i, err)
+			continue
+		}
+		if merr.Path != test.wantPath || merr.Offset != test.wantOffset {
+			t.Errorf("Marshal #%d path - got: %q at %d want: %q "+
+				"at %d", i, merr.Path, merr.Offset,
+				test.wantPath, test.wantOffset)
+			continue
+		}
+	}
+}
+
+// TestMarshalHyper ensures int and uint values which don't fit into XDR
+// integers are rejected and that integer fields with the hyper option are
+// encoded and decoded as XDR hyper integers.
+func TestMarshalHyper(t *testing.T) {
+	type hyperTest struct {
+		A int    `xdr:"hyper"`
+		B uint   `xdr:"hyper"`
+		C *int8  `xdr:"hyper"`
+		D uint16 `xdr:"hyper"`
+	}
+	type badHyper struct {
+		S string `xdr:"hyper"`
+	}
+	type hyperUnion struct {
+		Kind int32 `xdr:"union,hyper"`
+	}
+	c := int8(-2)
+
+	tests := []struct {
+		in   interface{} // value to encode
+		want []byte      // expected encoding
+		err  error       // expected error
+	}{
+		{hyperTest{-1, 1 << 33, &c, 7}, []byte{
+			0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+			0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00, 0x00,
+			0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xfe,
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x07,
+		}, nil},
+		{int(math.MaxInt32), []byte{0x7f, 0xff, 0xff, 0xff}, nil},
+		{int(math.MinInt32), []byte{0x80, 0x00, 0x00, 0x00}, nil},
+		{uint(math.MaxUint32), []byte{0xff, 0xff, 0xff, 0xff}, nil},
+		{badHyper{}, []byte{}, &MarshalError{ErrorCode: ErrBadArguments}},
+		{hyperUnion{}, []byte{}, &MarshalError{ErrorCode: ErrBadArguments}},
+	}
+
+	// Values outside of 32 bits can only be tested on platforms where int
+	// is 64 bits.
+	if math.MaxInt > math.MaxInt32 {
+		big := int64(math.MaxInt32) + 1
+		small := int64(math.MinInt32) - 1
+		tests = append(tests, []struct {
+			in   interface{}
+			want []byte
+			err  error
+		}{
+			{int(big), []byte{}, &MarshalError{ErrorCode: ErrOverflow}},
+			{int(small), []byte{}, &MarshalError{ErrorCode: ErrOverflow}},
+			{uint(big) << 1, []byte{},
+				&MarshalError{ErrorCode: ErrOverflow}},
+			{[]int{1, int(big)}, []byte{0x00, 0x00, 0x00, 0x02,
+				0x00, 0x00, 0x00, 0x01},
+				&MarshalError{ErrorCode: ErrOverflow}},
+		}...)
+	}
+
+	for i, test := range tests {
+		var buf bytes.Buffer
+		n, err := Marshal(&buf, test.in)
+		testName := fmt.Sprintf("Marshal #%d", i)
+		if !testExpectedMRet(t, testName, n, len(test.want), err,
+			test.err) {
+			continue
+		}
+		if !bytes.Equal(buf.Bytes(), test.want) {
+			t.Errorf("%s: unexpected result - got: %x want: %x",
+				testName, buf.Bytes(), test.want)
+			continue
+		}
+		if test.err != nil {
+			continue
+		}
+
+		// Ensure the value round trips.
+		pv := reflect.New(reflect.TypeOf(test.in))
+		_, err = Unmarshal(&buf, pv.Interface())
+		if err != nil {
+			t.Errorf("%s: unexpected unmarshal error: %v", testName,
+				err)
+			continue
+		}
+		if !reflect.DeepEqual(pv.Elem().Interface(), test.in) {
+			t.Errorf("%s: unexpected round trip - got: %v want: %v",
+				testName, pv.Elem().Interface(), test.in)
+			continue
+		}
+	}
+
+	// Ensure hyper integers which don't fit into the field are rejected.
+	encoded := []byte{
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00,
+	}
+	var h hyperTest
+	_, err := Unmarshal(bytes.NewReader(encoded), &h)
+	uerr, ok := err.(*UnmarshalError)
+	if !ok || uerr.ErrorCode != ErrOverflow || uerr.Path != "hyperTest.D" {
+		t.Errorf("Unmarshal overflow got: %v want: %v at hyperTest.D",
+			err, ErrOverflow)
+	}
+}
+
+// TestMarshalCycle ensures cyclic data structures are rejected with an error
+// code of ErrCycle rather than recursing forever while values which are merely
+// nested deeply, or shared without being cyclic, are encoded.
+func TestMarshalCycle(t *testing.T) {
+	type node struct {
+		Val  uint32
+		Next *node `xdr:"optional"`
+	}
+	type pair struct {
+		A, B *node
+	}
+
+	// A list which points back to its head.
+	ring := &node{Val: 1, Next: &node{Val: 2}}
+	ring.Next.Next = ring
+
+	// Slices, maps, and interfaces which contain themselves.
+	slice := []interface{}{uint32(1), nil}
+	slice[1] = slice
+	m := map[string]interface{}{"a": uint32(1)}
+	m["self"] = m
+	var iface interface{}
+	iface = &iface
+
+	// A map whose values contain it and are encoded to order the entries
+	// since its NaN keys are ordered the same.
+	var self interface{}
+	nanMap := map[float64]interface{}{math.NaN(): &self, math.NaN(): &self}
+	self = nanMap
+
+	// A list which is nested far deeper than cycle detection begins and a
+	// pair which refers to it twice without being cyclic.
+	var list *node
+	for i := 0; i < 3000; i++ {
+		list = &node{Val: uint32(i), Next: list}
+	}
+	shared := &pair{A: list, B: list}
+
+	// The paths of cycle errors lead from the value which contains itself
+	// back to it.
+	tests := []struct {
+		in   interface{} // value to encode
+		want int         // expected number of bytes
+		err  error       // expected error
+		path string      // expected path of the error
+	}{
+		{ring, 0, &MarshalError{ErrorCode: ErrCycle}, "Next.Next"},
+		{slice, 0, &MarshalError{ErrorCode: ErrCycle}, "[1]"},
+		{m, 0, &MarshalError{ErrorCode: ErrCycle}, "[self]"},
+		{iface, 0, &MarshalError{ErrorCode: ErrCycle}, ""},
+		{nanMap, 0, &MarshalError{ErrorCode: ErrCycle}, ""},
+		{list, 3000 * 8, nil, ""},
+		{shared, 2 * 3000 * 8, nil, ""},
+	}
+
+	// Use the same Encoder for every test to ensure it is left usable by
+	// failures.
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	for i, test := range tests {
+		buf.Reset()
+		n, err := enc.Encode(test.in)
+		if test.err != nil {
+			if !errors.Is(err, ErrCycle) {
+				t.Errorf("Encode #%d unexpected error - got: %v "+
+					"want: %v", i, err, ErrCycle)
+				continue
+			}
+			if path := err.(*MarshalError).Path; path != test.path {
+				t.Errorf("Encode #%d unexpected path - got: %q "+
+					"want: %q", i, path, test.path)
+			}
+			continue
+		}
+		testName := fmt.Sprintf("Encode #%d", i)
+		testExpectedMRet(t, testName, n, test.want, err, test.err)
+	}
+}
+
+// fieldTagInner is an unexported type embedded by the field tag tests to
+// ensure unexported embedded structs are skipped unless they have the inline
+// option, in which case their exported fields are promoted.
+type fieldTagInner struct {
+	X uint32
+	Y uint32
+}
+
+// TestMarshalFieldTags ensures fields tagged with `xdr:"-"` are skipped, fields
+// with the order option are placed in that order, embedded structs are encoded
+// as nested structs, and the fields of embedded structs with the inline option
+// are promoted when encoding and decoding.
+func TestMarshalFieldTags(t *testing.T) {
+	type skipped struct {
+		A     uint32
+		Cache map[string]bool `xdr:"-"`
+		B     uint32
+	}
+	type ordered struct {
+		A uint32 `xdr:"order=2"`
+		B uint32 `xdr:"order=0"`
+		C uint32 `xdr:"order=1"`
+	}
+	type Named struct {
+		Name string
+	}
+	type Other struct {
+		Name string
+	}
+	type nested struct {
+		Named
+		Name string
+	}
+	type twoNested struct {
+		Named
+		Other
+	}
+	type unexportedEmbed struct {
+		fieldTagInner
+		Z uint32
+	}
+	type groupOrdered struct {
+		Z             uint32 `xdr:"order=1"`
+		fieldTagInner `xdr:"inline,order=0"`
+	}
+	type header struct {
+		Kind uint32 `xdr:"union"`
+	}
+	type message struct {
+		header `xdr:"inline"`
+		Name   string `xdr:"unioncase=1"`
+		ID     uint32 `xdr:"unioncase=2"`
+	}
+	type stamped struct {
+		time.Time
+	}
+
+	tests := []struct {
+		in   interface{} // value to encode
+		want []byte      // expected encoding
+	}{
+		{&skipped{A: 1, B: 2}, []byte{
+			0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x02,
+		}},
+		{&ordered{A: 1, B: 2, C: 3}, []byte{
+			0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00, 0x03,
+			0x00, 0x00, 0x00, 0x01,
+		}},
+		// Embedded structs are nested structs, so fields of the same
+		// name are all encoded.
+		{&nested{Named{"a"}, "b"}, []byte{
+			0x00, 0x00, 0x00, 0x01, 'a', 0x00, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x01, 'b', 0x00, 0x00, 0x00,
+		}},
+		{&twoNested{Named{"a"}, Other{"b"}}, []byte{
+			0x00, 0x00, 0x00, 0x01, 'a', 0x00, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x01, 'b', 0x00, 0x00, 0x00,
+		}},
+		// Unexported embedded structs are skipped.
+		{&unexportedEmbed{fieldTagInner{X: 1, Y: 2}, 3}, []byte{
+			0x00, 0x00, 0x00, 0x03,
+		}},
+		{&groupOrdered{Z: 3, fieldTagInner: fieldTagInner{1, 2}}, []byte{
+			0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x02,
+			0x00, 0x00, 0x00, 0x03,
+		}},
+		// The union arms follow the promoted discriminant.
+		{&message{header: header{2}, ID: 7}, []byte{
+			0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00, 0x07,
+		}},
+		// Embedded time.Time is a single field.
+		{&stamped{time.Unix(0, 0).UTC()}, []byte{
+			0x00, 0x00, 0x00, 0x14, '1', '9', '7', '0',
+			'-', '0', '1', '-', '0', '1', 'T', '0',
+			'0', ':', '0', '0', ':', '0', '0', 'Z',
+		}},
+	}
+
+	for i, test := range tests {
+		var buf bytes.Buffer
+		n, err := Marshal(&buf, test.in)
+		testName := fmt.Sprintf("Marshal #%d", i)
+		if !testExpectedMRet(t, testName, n, len(test.want), err, nil) {
+			continue
+		}
+		if !bytes.Equal(buf.Bytes(), test.want) {
+			t.Errorf("%s: unexpected result - got: %x want: %x",
+				testName, buf.Bytes(), test.want)
+			continue
+		}
+
+		// Decoding must produce the original value.  Skipped fields
+		// are left as they are, so they are preset.
+		out := reflect.New(reflect.TypeOf(test.in).Elem())
+		switch v := out.Interface().(type) {
+		case *skipped:
+			v.Cache = test.in.(*skipped).Cache
+		case *unexportedEmbed:
+			v.fieldTagInner = test.in.(*unexportedEmbed).fieldTagInner
+		}
+		n, err = Unmarshal(bytes.NewReader(test.want), out.Interface())
+		testName = fmt.Sprintf("Unmarshal #%d", i)
+		if !testExpectedURet(t, testName, n, len(test.want), err, nil) {
+			continue
+		}
+		if !reflect.DeepEqual(out.Interface(), test.in) {
+			t.Errorf("%s: unexpected result - got: %v want: %v",
+				testName, out.Interface(), test.in)
+			continue
+		}
+	}
+
+	// Invalid combinations of options must be rejected.
+	type missingOrder struct {
+		A uint32 `xdr:"order=0"`
+		B uint32
+	}
+	type duplicateOrder struct {
+		A uint32 `xdr:"order=1"`
+		B uint32 `xdr:"order=1"`
+	}
+	type inlineOption struct {
+		fieldTagInner `xdr:"inline,optional"`
+	}
+	type inlineField struct {
+		A Named `xdr:"inline"`
+	}
+	type inlinePointer struct {
+		*Named `xdr:"inline"`
+	}
+	type hidden struct {
+		fieldTagInner `xdr:"inline"`
+		X             uint32
+	}
+	type other struct {
+		X uint32
+	}
+	type ambiguous struct {
+		fieldTagInner `xdr:"inline"`
+		other         `xdr:"inline"`
+	}
+
+	errTests := []interface{}{
+		missingOrder{}, duplicateOrder{}, inlineOption{}, inlineField{},
+		inlinePointer{}, hidden{}, ambiguous{},
+	}
+	for i, in := range errTests {
+		var buf bytes.Buffer
+		_, err := Marshal(&buf, in)
+		if !errors.Is(err, ErrBadArguments) {
+			t.Errorf("Marshal invalid #%d unexpected error - got: %v "+
+				"want: %v", i, err, ErrBadArguments)
+		}
+	}
+}
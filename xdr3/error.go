@@ -0,0 +1,367 @@
+/*
+ * Copyright (c) 2012-2014 Dave Collins <dave@davec.name>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package xdr
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+// ErrorCode identifies a kind of error.
+type ErrorCode int
+
+const (
+	// ErrBadArguments indicates arguments passed to the function are not
+	// what was expected.
+	ErrBadArguments ErrorCode = iota
+
+	// ErrUnsupportedType indicates the Go type is not a supported type for
+	// marshalling and unmarshalling XDR data.
+	ErrUnsupportedType
+
+	// ErrBadEnumValue indicates an enumeration value is not in the list of
+	// valid values.
+	ErrBadEnumValue
+
+	// ErrNotSettable indicates an interface value cannot be written to.
+	// This usually means the interface value was not passed with the &
+	// operator, but it can also happen if automatic pointer allocation
+	// fails.
+	ErrNotSettable
+
+	// ErrOverflow indicates that the data in question is too large to fit
+	// into the corresponding Go or XDR data type.  For example, an integer
+	// decoded from XDR that is too large to fit into a target type of int8,
+	// or opaque data that exceeds the max length of a Go slice.
+	ErrOverflow
+
+	// ErrNilInterface indicates an interface with no concrete type
+	// information was encountered.  Type information is necessary to
+	// perform mapping between XDR and Go types.
+	ErrNilInterface
+
+	// ErrIO indicates an error was encountered while reading or writing to
+	// an io.Reader or io.Writer, respectively.  The actual underlying error
+	// will be available via the Err field of the MarshalError or
+	// UnmarshalError struct.
+	ErrIO
+
+	// ErrParseTime indicates an error was encountered while parsing an
+	// RFC3339 formatted time value.  The actual underlying error will be
+	// available via the Err field of the UnmarshalError struct.
+	ErrParseTime
+
+	// ErrBadDiscriminant indicates the discriminant of a discriminated
+	// union does not select any of the union arms and the union does not
+	// have a default arm, or it selects an interface arm whose registered
+	// type does not match the concrete value.
+	ErrBadDiscriminant
+
+	// ErrMaxTotalBytes indicates decoding the data requires reading more
+	// bytes in total than the MaxTotalBytes limit of the Decoder allows.
+	ErrMaxTotalBytes
+
+	// ErrMaxElements indicates the data contains more array elements and
+	// map entries in total than the MaxElements limit of the Decoder
+	// allows.
+	ErrMaxElements
+
+	// ErrMaxDepth indicates the data is nested more deeply than the
+	// MaxDepth limit of the Decoder allows.
+	ErrMaxDepth
+
+	// ErrMaxAllocBytes indicates decoding the data requires allocating
+	// more bytes in total than the MaxAllocBytes limit of the Decoder
+	// allows.
+	ErrMaxAllocBytes
+
+	// ErrNonzeroPadding indicates the padding which follows opaque data or
+	// a string contains nonzero bytes.  It is only reported by strict
+	// decoders.
+	ErrNonzeroPadding
+
+	// ErrTrailingBytes indicates there are bytes remaining after the value
+	// passed to Unmarshal was decoded.  It is only reported by strict
+	// decoders.
+	ErrTrailingBytes
+
+	// ErrNonCanonicalNaN indicates a floating point NaN is not encoded
+	// as the canonical quiet NaN.  It is only reported by strict decoders.
+	ErrNonCanonicalNaN
+
+	// ErrDuplicateKey indicates a map contains the same key more than
+	// once.  It is only reported by strict decoders.
+	ErrDuplicateKey
+
+	// ErrCycle indicates a value being encoded contains itself, such as a
+	// struct with a pointer field which points back to it.
+	ErrCycle
+)
+
+// Map of ErrorCode values back to their constant names for pretty printing.
+var errorCodeStrings = map[ErrorCode]string{
+	ErrBadArguments:    "ErrBadArguments",
+	ErrUnsupportedType: "ErrUnsupportedType",
+	ErrBadEnumValue:    "ErrBadEnumValue",
+	ErrNotSettable:     "ErrNotSettable",
+	ErrOverflow:        "ErrOverflow",
+	ErrNilInterface:    "ErrNilInterface",
+	ErrIO:              "ErrIO",
+	ErrParseTime:       "ErrParseTime",
+	ErrBadDiscriminant: "ErrBadDiscriminant",
+	ErrMaxTotalBytes:   "ErrMaxTotalBytes",
+	ErrMaxElements:     "ErrMaxElements",
+	ErrMaxDepth:        "ErrMaxDepth",
+	ErrMaxAllocBytes:   "ErrMaxAllocBytes",
+	ErrNonzeroPadding:  "ErrNonzeroPadding",
+	ErrTrailingBytes:   "ErrTrailingBytes",
+	ErrNonCanonicalNaN: "ErrNonCanonicalNaN",
+	ErrDuplicateKey:    "ErrDuplicateKey",
+	ErrCycle:           "ErrCycle",
+}
+
+// String returns the ErrorCode as a human-readable name.
+func (e ErrorCode) String() string {
+	if s := errorCodeStrings[e]; s != "" {
+		return s
+	}
+	return fmt.Sprintf("Unknown ErrorCode (%d)", e)
+}
+
+// Error returns the ErrorCode as a human-readable name.  It satisfies the
+// error interface so an ErrorCode can be used as the target of errors.Is to
+// test whether an error is an UnmarshalError or MarshalError with that error
+// code:
+//
+//	if errors.Is(err, xdr.ErrOverflow) {
+//		// Handle the overflow.
+//	}
+func (e ErrorCode) Error() string {
+	return e.String()
+}
+
+// UnmarshalError describes a problem encountered while unmarshaling data.
+// Some potential issues are unsupported Go types, attempting to decode a value
+// which is too large to fit into a specified Go type, and exceeding max slice
+// limitations.
+//
+// For errors returned by Decode and the Unmarshal functions, Path locates the
+// value which failed to decode within the value passed to them, such as
+// Reply.Entries[3].Name, and Offset is the number of bytes the Decoder had
+// read when that value began.  Path starts with the name of the type of the
+// passed value, if it has one, and identifies map entries by their key, or by
+// their index when the key itself failed to decode.
+type UnmarshalError struct {
+	ErrorCode   ErrorCode   // Describes the kind of error
+	Func        string      // Function name
+	Value       interface{} // Value actually parsed where appropriate
+	Description string      // Human readable description of the issue
+	Err         error       // The underlying error for IO errors
+	Path        string      // Path to the failing value such as A.B[3].C
+	Offset      int64       // Byte offset at which the failing value began
+}
+
+// Error satisfies the error interface and prints human-readable errors.
+func (e *UnmarshalError) Error() string {
+	switch e.ErrorCode {
+	case ErrBadEnumValue, ErrOverflow, ErrIO, ErrParseTime,
+		ErrBadDiscriminant, ErrNonzeroPadding, ErrNonCanonicalNaN,
+		ErrDuplicateKey:
+		return fmt.Sprintf("xdr:%s: %s%s - read: '%v'", e.Func,
+			pathPrefix(e.Path), e.Description, e.Value)
+	}
+	return fmt.Sprintf("xdr:%s: %s%s", e.Func, pathPrefix(e.Path),
+		e.Description)
+}
+
+// Unwrap returns the underlying error, if any, so errors.Is and errors.As can
+// examine it.  For example, errors.Is(err, io.ErrUnexpectedEOF) reports
+// whether the data ended in the middle of a value.
+func (e *UnmarshalError) Unwrap() error {
+	return e.Err
+}
+
+// Is returns whether the passed target is an ErrorCode equal to the error code
+// of the error.  It allows errors.Is to test the error code.
+func (e *UnmarshalError) Is(target error) bool {
+	code, ok := target.(ErrorCode)
+	return ok && e.ErrorCode == code
+}
+
+// unmarshalError creates an error given a set of arguments and will copy byte
+// slices into the Value field since they might otherwise be changed from from
+// the original value.
+func unmarshalError(f string, c ErrorCode, desc string, v interface{}, err error) *UnmarshalError {
+	e := &UnmarshalError{ErrorCode: c, Func: f, Description: desc, Err: err}
+	switch t := v.(type) {
+	case []byte:
+		slice := make([]byte, len(t))
+		copy(slice, t)
+		e.Value = slice
+	default:
+		e.Value = v
+	}
+
+	return e
+}
+
+// IsIO returns a boolean indicating whether the error is known to report that
+// the underlying reader or writer encountered an ErrIO.  It is equivalent to
+// errors.Is(err, ErrIO), so errors which wrap such an error are recognized too.
+func IsIO(err error) bool {
+	return errors.Is(err, ErrIO)
+}
+
+// MarshalError describes a problem encountered while marshaling data.
+// Some potential issues are unsupported Go types, attempting to encode more
+// opaque data than can be represented by a single opaque XDR entry, and
+// exceeding max slice limitations.
+//
+// For errors returned by Encode and Marshal, Path locates the value which
+// failed to encode within the value passed to them in the same way as for an
+// UnmarshalError, and Offset is the number of bytes the Encoder had written
+// when that value began.  For errors with an error code of ErrCycle, Path
+// instead leads from the value which contains itself back to it, since the
+// path to that value is at least as deeply nested as cycle detection begins.
+type MarshalError struct {
+	ErrorCode   ErrorCode   // Describes the kind of error
+	Func        string      // Function name
+	Value       interface{} // Value actually parsed where appropriate
+	Description string      // Human readable description of the issue
+	Err         error       // The underlying error for IO errors
+	Path        string      // Path to the failing value such as A.B[3].C
+	Offset      int64       // Byte offset at which the failing value began
+
+	// pathDone stops withPath from adding elements to the path.
+	pathDone bool
+}
+
+// Error satisfies the error interface and prints human-readable errors.
+func (e *MarshalError) Error() string {
+	switch e.ErrorCode {
+	case ErrIO:
+		return fmt.Sprintf("xdr:%s: %s%s - wrote: '%v'", e.Func,
+			pathPrefix(e.Path), e.Description, e.Value)
+	case ErrBadEnumValue, ErrBadDiscriminant:
+		return fmt.Sprintf("xdr:%s: %s%s - value: '%v'", e.Func,
+			pathPrefix(e.Path), e.Description, e.Value)
+	}
+	return fmt.Sprintf("xdr:%s: %s%s", e.Func, pathPrefix(e.Path),
+		e.Description)
+}
+
+// Unwrap returns the underlying error, if any, so errors.Is and errors.As can
+// examine it.  For example, errors.Is(err, io.ErrUnexpectedEOF) reports
+// whether the data ended in the middle of a value.
+func (e *MarshalError) Unwrap() error {
+	return e.Err
+}
+
+// Is returns whether the passed target is an ErrorCode equal to the error code
+// of the error.  It allows errors.Is to test the error code.
+func (e *MarshalError) Is(target error) bool {
+	code, ok := target.(ErrorCode)
+	return ok && e.ErrorCode == code
+}
+
+// marshalError creates an error given a set of arguments and will copy byte
+// slices into the Value field since they might otherwise be changed from from
+// the original value.
+func marshalError(f string, c ErrorCode, desc string, v interface{}, err error) *MarshalError {
+	e := &MarshalError{ErrorCode: c, Func: f, Description: desc, Err: err}
+	switch t := v.(type) {
+	case []byte:
+		slice := make([]byte, len(t))
+		copy(slice, t)
+		e.Value = slice
+	default:
+		e.Value = v
+	}
+
+	return e
+}
+
+// pathPrefix returns the passed path formatted to precede the description of
+// an error, or an empty string when there is no path.
+func pathPrefix(path string) string {
+	if path == "" {
+		return ""
+	}
+	return path + ": "
+}
+
+// typeElem returns the path element for a value of the passed type at the root
+// of a path.  Only named types which are not predeclared have one.
+func typeElem(t reflect.Type) string {
+	if t.PkgPath() == "" {
+		return ""
+	}
+	return t.Name()
+}
+
+// indexElem returns the path element for the element at the passed index of an
+// array or slice.
+func indexElem(i int) string {
+	return "[" + strconv.Itoa(i) + "]"
+}
+
+// keyElem returns the path element for the map entry with the passed key.
+func keyElem(key reflect.Value) string {
+	return fmt.Sprintf("[%v]", key)
+}
+
+// withPath prepends the passed element, which is either a field or type name
+// or an index or key in brackets, to the path of the passed UnmarshalError or
+// MarshalError.  The offset of the error is set to the passed offset at which
+// the value began when the error does not yet have a path since that means it
+// is the innermost value which failed.  Other errors are returned unchanged.
+//
+// The error is copied rather than modified in place since it may have been
+// returned by a Marshaler or Unmarshaler which reuses it.
+func withPath(err error, elem string, off int64) error {
+	switch e := err.(type) {
+	case *UnmarshalError:
+		c := *e
+		if c.Path == "" {
+			c.Offset = off
+		}
+		c.Path = joinPath(elem, c.Path)
+		return &c
+
+	case *MarshalError:
+		if e.pathDone {
+			return err
+		}
+		c := *e
+		if c.Path == "" {
+			c.Offset = off
+		}
+		c.Path = joinPath(elem, c.Path)
+		return &c
+	}
+	return err
+}
+
+// joinPath returns the passed path with the passed element prepended to it.
+func joinPath(elem, path string) string {
+	if elem == "" || path == "" || path[0] == '[' {
+		return elem + path
+	}
+	return elem + "." + path
+}
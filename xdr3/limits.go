@@ -0,0 +1,328 @@
+/*
+ * Copyright (c) 2014 Dave Collins <dave@davec.name>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package xdr
+
+import (
+	"fmt"
+	"io"
+)
+
+// DecoderOptions limits the resources a Decoder may consume and controls how
+// strictly it validates the data so that untrusted input can be decoded
+// safely.  A zero value for any limit means it is unlimited.
+//
+// The byte, element, and allocation limits accumulate across all values
+// decoded by a Decoder, so a Decoder which is reused for multiple values
+// should be created with limits that cover all of them.
+type DecoderOptions struct {
+	// MaxTotalBytes is the maximum number of bytes read from the
+	// underlying reader.  Exceeding it results in an UnmarshalError with
+	// an error code of ErrMaxTotalBytes.
+	MaxTotalBytes uint
+
+	// MaxElements is the maximum number of elements of variable-length
+	// arrays and entries of maps summed across all of them.  Exceeding it
+	// results in an UnmarshalError with an error code of ErrMaxElements.
+	MaxElements uint
+
+	// MaxDepth is the maximum nesting depth of the values decoded where
+	// the value passed to Decode is at depth 1 and the fields, elements,
+	// and map entries of a value are one level deeper than it.  Exceeding
+	// it results in an UnmarshalError with an error code of ErrMaxDepth.
+	MaxDepth uint
+
+	// MaxAllocBytes is the maximum number of bytes allocated for opaque
+	// data, strings, slices, maps, and pointers.  Sizes are measured
+	// using the in-memory size of the Go types involved.  Exceeding it
+	// results in an UnmarshalError with an error code of
+	// ErrMaxAllocBytes.
+	MaxAllocBytes uint
+
+	// Strict rejects data which is not in the canonical form produced by
+	// an Encoder, so that only one encoding of any value is accepted.
+	// This is necessary when the encoded bytes are hashed or signed.  The
+	// following result in an UnmarshalError with the listed error code:
+	//
+	// 	* Nonzero padding bytes - ErrNonzeroPadding
+	// 	* Bytes remaining after the value passed to Unmarshal -
+	// 	  ErrTrailingBytes
+	// 	* NaNs other than the quiet NaN with no payload and the sign
+	// 	  bit clear - ErrNonCanonicalNaN
+	// 	* Maps with the same key more than once - ErrDuplicateKey
+	//
+	// An Encoder writes every NaN as the canonical NaN, so its output is
+	// always accepted.  Trailing bytes are only checked by
+	// UnmarshalWithOptions since a Decoder may be used to decode
+	// multiple values from the same reader.
+	Strict bool
+
+	// CopyBytes copies opaque data decoded by a Decoder created with
+	// NewBytesDecoderWithOptions into new storage instead of aliasing the
+	// byte slice being decoded.  It has no effect on Decoders which read
+	// from an io.Reader since their data is always copied.
+	CopyBytes bool
+}
+
+// maxPrealloc is the maximum number of bytes of storage allocated up front for
+// variable-length data based on its decoded length alone.  Since the length is
+// untrusted, storage beyond it is only allocated once the underlying reader
+// shows the data is actually there, either by reporting enough remaining bytes
+// or by providing it while decoding.  This prevents a short message which
+// claims a huge length from causing a huge allocation.
+const maxPrealloc = 64 * 1024
+
+// decodeLimits houses the resource limits of a Decoder along with the
+// resources consumed against them.  depth is the current nesting depth while
+// the others accumulate across all values decoded.
+type decodeLimits struct {
+	opts     DecoderOptions
+	total    uint
+	elements uint
+	alloc    uint
+	depth    uint
+}
+
+// checkTotal returns an UnmarshalError with an error code of ErrMaxTotalBytes
+// if reading the passed number of bytes would exceed the MaxTotalBytes limit
+// of the Decoder.
+func (d *Decoder) checkTotal(f string, size uint) error {
+	l := d.limits
+	if l == nil || l.opts.MaxTotalBytes == 0 {
+		return nil
+	}
+	max := l.opts.MaxTotalBytes
+	if size > max || l.total > max-size {
+		msg := fmt.Sprintf("reading %d bytes exceeds the limit of %d "+
+			"total bytes", size, max)
+		return unmarshalError(f, ErrMaxTotalBytes, msg, l.total, nil)
+	}
+	return nil
+}
+
+// readFull reads exactly the passed number of bytes, which must be at most 16,
+// from the underlying reader while enforcing the MaxTotalBytes limit of the
+// Decoder.  It returns them along with the number of bytes actually read.  The
+// returned bytes are only valid until the next read.
+//
+// An UnmarshalError with an error code of ErrIO is returned if there are
+// insufficient bytes remaining.
+func (d *Decoder) readFull(f string, size int) ([]byte, int, error) {
+	if err := d.checkTotal(f, uint(size)); err != nil {
+		return nil, 0, err
+	}
+
+	if d.sr != nil && size <= d.sr.Len() {
+		if d.limits != nil {
+			d.limits.total += uint(size)
+		}
+		d.off += int64(size)
+		return d.sr.next(size), size, nil
+	}
+	buf := d.scratch[:size]
+	n, err := io.ReadFull(d.r, buf)
+	d.off += int64(n)
+	if d.limits != nil {
+		d.limits.total += uint(n)
+	}
+	if err != nil {
+		msg := fmt.Sprintf(errIODecode, err.Error(), size)
+		read := append([]byte(nil), buf[:n]...)
+		return nil, n, unmarshalError(f, ErrIO, msg, read, err)
+	}
+	return buf, n, nil
+}
+
+// readGrowing reads exactly the passed number of bytes from the underlying
+// reader while enforcing the MaxTotalBytes limit of the Decoder and returns
+// them along with the number of bytes actually read.  Unlike readFull, the
+// storage for the bytes is allocated by preallocLen and grown as they are read,
+// so it is suitable for data with an untrusted length.  The alias flag controls
+// whether or not the returned bytes may alias the byte slice of a Decoder
+// created with NewBytesDecoder.
+//
+// An UnmarshalError with an error code of ErrIO is returned if there are
+// insufficient bytes remaining.
+func (d *Decoder) readGrowing(f string, size int, alias bool) ([]byte, int, error) {
+	if err := d.checkTotal(f, uint(size)); err != nil {
+		return nil, 0, err
+	}
+
+	// The bytes are known to be present when reading from a byte slice,
+	// so either alias them or copy them into storage of the exact size.
+	if d.sr != nil && size <= d.sr.Len() {
+		buf := d.sr.next(size)
+		if !alias {
+			buf = append([]byte(nil), buf...)
+		}
+		if d.limits != nil {
+			d.limits.total += uint(size)
+		}
+		d.off += int64(size)
+		return buf, size, nil
+	}
+
+	buf := make([]byte, d.preallocLen(size, 1))
+	var n int
+	var err error
+	for {
+		var n2 int
+		n2, err = io.ReadFull(d.r, buf[n:])
+		n += n2
+		if err != nil || n == size {
+			break
+		}
+
+		// Double the storage, up to the requested size, now that
+		// the bytes read so far have shown the data is there.
+		grow := len(buf)
+		if grow > size-n {
+			grow = size - n
+		}
+		buf = append(buf, make([]byte, grow)...)
+	}
+	d.off += int64(n)
+	if d.limits != nil {
+		d.limits.total += uint(n)
+	}
+	if err != nil {
+		if err == io.EOF && n > 0 {
+			err = io.ErrUnexpectedEOF
+		}
+		msg := fmt.Sprintf(errIODecode, err.Error(), size)
+		return nil, n, unmarshalError(f, ErrIO, msg, buf[:n], err)
+	}
+	return buf, n, nil
+}
+
+// addElements accounts for the passed number of array elements or map entries
+// against the MaxElements limit of the Decoder.  It returns an UnmarshalError
+// with an error code of ErrMaxElements when the limit is exceeded.
+func (d *Decoder) addElements(f string, count uint) error {
+	l := d.limits
+	if l == nil || l.opts.MaxElements == 0 {
+		return nil
+	}
+	max := l.opts.MaxElements
+	if count > max || l.elements > max-count {
+		msg := fmt.Sprintf("%d elements exceeds the limit of %d total "+
+			"elements", count, max)
+		return unmarshalError(f, ErrMaxElements, msg, l.elements, nil)
+	}
+	l.elements += count
+	return nil
+}
+
+// allocate accounts for an allocation of the passed number of items of the
+// passed size in bytes against the MaxAllocBytes limit of the Decoder.  It
+// returns an UnmarshalError with an error code of ErrMaxAllocBytes when the
+// limit is exceeded.
+func (d *Decoder) allocate(f string, count, size uint) error {
+	l := d.limits
+	if l == nil || l.opts.MaxAllocBytes == 0 {
+		return nil
+	}
+	max := l.opts.MaxAllocBytes
+	bytes := ^uint(0)
+	if size == 0 || count <= bytes/size {
+		bytes = count * size
+	}
+	if bytes > max || l.alloc > max-bytes {
+		msg := fmt.Sprintf("allocating %d bytes exceeds the limit of "+
+			"%d bytes", bytes, max)
+		return unmarshalError(f, ErrMaxAllocBytes, msg, l.alloc, nil)
+	}
+	l.alloc += bytes
+	return nil
+}
+
+// enter accounts for decoding a value one level deeper than the current
+// nesting depth against the MaxDepth limit of the Decoder.  It returns an
+// UnmarshalError with an error code of ErrMaxDepth when the limit is exceeded.
+// Each successful call must be paired with a call to leave and it must only be
+// called when the Decoder has limits.
+func (d *Decoder) enter(f string) error {
+	l := d.limits
+	if l.depth >= l.opts.MaxDepth {
+		msg := fmt.Sprintf("nesting exceeds the limit of %d levels",
+			l.opts.MaxDepth)
+		return unmarshalError(f, ErrMaxDepth, msg, l.depth+1, nil)
+	}
+	l.depth++
+	return nil
+}
+
+// leave returns to the previous nesting depth.
+func (d *Decoder) leave() {
+	d.limits.depth--
+}
+
+// remaining returns the number of unread bytes of the underlying reader when it
+// reports them via a Len method, such as a bytes.Reader, bytes.Buffer, or
+// strings.Reader, or -1 otherwise.
+func (d *Decoder) remaining() int {
+	if lr, ok := d.r.(interface {
+		Len() int
+	}); ok {
+		return lr.Len()
+	}
+	return -1
+}
+
+// preallocLen returns the number of items of the passed size in bytes to
+// allocate storage for up front when decoding variable-length data with the
+// passed decoded length.  It is the decoded length unless the storage for it
+// would exceed both maxPrealloc and the number of bytes remaining in the
+// underlying reader, in which case the storage must be grown as the items are
+// decoded.  It is never 0 for a non-zero length.
+func (d *Decoder) preallocLen(count int, size uintptr) int {
+	if size == 0 || uintptr(count) <= maxPrealloc/size {
+		return count
+	}
+	max := maxPrealloc
+	if rem := d.remaining(); rem > max {
+		max = rem
+		if uintptr(count) <= uintptr(max)/size {
+			return count
+		}
+	}
+	if n := int(uintptr(max) / size); n > 0 {
+		return n
+	}
+	return 1
+}
+
+// strict returns whether or not the Decoder rejects data which is not in
+// canonical form.
+func (d *Decoder) strict() bool {
+	return d.limits != nil && d.limits.opts.Strict
+}
+
+// checkTrailing returns an UnmarshalError with an error code of
+// ErrTrailingBytes if the underlying reader has any bytes remaining.  The
+// reader is read from when it does not report how many bytes remain.
+func (d *Decoder) checkTrailing(f string) error {
+	rem := d.remaining()
+	if rem < 0 {
+		var buf [1]byte
+		rem, _ = io.ReadFull(d.r, buf[:])
+	}
+	if rem > 0 {
+		msg := "unexpected bytes after the encoded value"
+		return unmarshalError(f, ErrTrailingBytes, msg, rem, nil)
+	}
+	return nil
+}
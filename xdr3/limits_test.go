@@ -0,0 +1,355 @@
+/*
+ * Copyright (c) 2014 Dave Collins <dave@davec.name>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package xdr_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"runtime"
+	"testing"
+
+	. "github.com/davecgh/go-xdr/xdr3"
+)
+
+// twoSlices is used to test the element limit across multiple slices.
+type twoSlices struct {
+	A []uint32
+	B []uint32
+}
+
+// TestUnmarshalWithOptions ensures the resource limits of DecoderOptions are
+// enforced with the expected error codes.
+func TestUnmarshalWithOptions(t *testing.T) {
+	nested := []byte{
+		0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x01,
+		0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00, 0x01,
+		0x00, 0x00, 0x00, 0x03, 0x00, 0x00, 0x00, 0x00,
+	}
+
+	tests := []struct {
+		in      []byte         // input bytes
+		wantVal interface{}    // expected value
+		opts    DecoderOptions // limits
+		wantN   int            // expected number of bytes read
+		err     error          // expected error
+	}{
+		// No limits.
+		{nested, listTest{1, &listTest{2, &listTest{3, nil}}},
+			DecoderOptions{}, 24, nil},
+
+		// Limits which are not exceeded.
+		{nested, listTest{1, &listTest{2, &listTest{3, nil}}},
+			DecoderOptions{24, 0, 4, 32, false, false}, 24, nil},
+		{[]byte{0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x02},
+			[]uint32{1, 2}, DecoderOptions{12, 2, 2, 8, false, false}, 12, nil},
+
+		// MaxTotalBytes.
+		{[]byte{0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00, 0x03},
+			[3]uint32{}, DecoderOptions{MaxTotalBytes: 8}, 8, &UnmarshalError{ErrorCode: ErrMaxTotalBytes}},
+		{[]byte{0x00, 0x00, 0x00, 0x64}, []byte{}, DecoderOptions{MaxTotalBytes: 8},
+			4, &UnmarshalError{ErrorCode: ErrMaxTotalBytes}},
+
+		// MaxElements.
+		{[]byte{0x00, 0x01, 0x00, 0x00}, []uint32{}, DecoderOptions{MaxElements: 3},
+			4, &UnmarshalError{ErrorCode: ErrMaxElements}},
+		{[]byte{
+			0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x02,
+			0x00, 0x00, 0x00, 0x02,
+		}, twoSlices{}, DecoderOptions{MaxElements: 3}, 16, &UnmarshalError{ErrorCode: ErrMaxElements}},
+		{[]byte{0x00, 0x00, 0x00, 0x04}, map[uint32]uint32{}, DecoderOptions{MaxElements: 3},
+			4, &UnmarshalError{ErrorCode: ErrMaxElements}},
+
+		// MaxDepth.
+		{nested, listTest{}, DecoderOptions{MaxDepth: 3}, 16, &UnmarshalError{ErrorCode: ErrMaxDepth}},
+
+		// MaxAllocBytes.
+		{[]byte{0x00, 0x00, 0x00, 0x04}, []uint64{}, DecoderOptions{MaxAllocBytes: 16},
+			4, &UnmarshalError{ErrorCode: ErrMaxAllocBytes}},
+		{[]byte{0x00, 0x00, 0x00, 0x14}, []byte{}, DecoderOptions{MaxAllocBytes: 16},
+			4, &UnmarshalError{ErrorCode: ErrMaxAllocBytes}},
+		{[]byte{0x00, 0x00, 0x00, 0x01}, map[uint64]uint64{}, DecoderOptions{MaxAllocBytes: 8},
+			4, &UnmarshalError{ErrorCode: ErrMaxAllocBytes}},
+	}
+
+	for i, test := range tests {
+		// Create a new pointer to the appropriate type.
+		wantValType := reflect.TypeOf(test.wantVal)
+		pv := reflect.New(wantValType)
+
+		n, err := UnmarshalWithOptions(bytes.NewReader(test.in),
+			pv.Interface(), test.opts)
+
+		// First ensure the number of bytes read is the expected value
+		// and the error is the expected one.
+		testName := fmt.Sprintf("UnmarshalWithOptions #%d", i)
+		if !testExpectedURet(t, testName, n, test.wantN, err, test.err) {
+			continue
+		}
+		if test.err != nil {
+			continue
+		}
+
+		// Finally, ensure the read value is the expected one.
+		if !reflect.DeepEqual(pv.Elem().Interface(), test.wantVal) {
+			t.Errorf("%s: unexpected result - got: %v want: %v\n",
+				testName, pv.Elem().Interface(), test.wantVal)
+			continue
+		}
+	}
+
+	// Ensure limits accumulate across the values decoded by a Decoder.
+	in := []byte{0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x02,
+		0x00, 0x00, 0x00, 0x03}
+	d := NewDecoderWithOptions(bytes.NewReader(in),
+		DecoderOptions{MaxTotalBytes: 8})
+	for i := 0; i < 3; i++ {
+		var v uint32
+		n, err := d.Decode(&v)
+
+		wantN, wantErr := 4, error(nil)
+		if i == 2 {
+			wantN = 0
+			wantErr = &UnmarshalError{ErrorCode: ErrMaxTotalBytes}
+		}
+		testName := fmt.Sprintf("Decode #%d", i)
+		testExpectedURet(t, testName, n, wantN, err, wantErr)
+	}
+}
+
+// unsizedReader hides the Len method of the wrapped reader so the decoder is
+// unable to determine how many bytes remain.
+type unsizedReader struct {
+	io.Reader
+}
+
+// TestUnmarshalPrealloc ensures decoding variable-length data whose length
+// claims far more data than is available does not allocate storage for the
+// claimed length while large data which is available still decodes properly.
+func TestUnmarshalPrealloc(t *testing.T) {
+	// A length just under the maximum followed by only 8 bytes of data.
+	bomb := []byte{
+		0x7f, 0xff, 0xff, 0xf0, 0x00, 0x00, 0x00, 0x01,
+		0x00, 0x00, 0x00, 0x02,
+	}
+	bombs := []interface{}{
+		[]uint32{}, []uint64{}, []byte{}, "", map[uint32]uint32{},
+		[]string{},
+	}
+
+	for i, v := range bombs {
+		for _, sized := range []bool{false, true} {
+			var r io.Reader = bytes.NewReader(bomb)
+			if !sized {
+				r = unsizedReader{r}
+			}
+
+			var before, after runtime.MemStats
+			runtime.ReadMemStats(&before)
+			pv := reflect.New(reflect.TypeOf(v))
+			n, err := Unmarshal(r, pv.Interface())
+			runtime.ReadMemStats(&after)
+
+			testName := fmt.Sprintf("Unmarshal #%d (sized %v)", i,
+				sized)
+			wantErr := &UnmarshalError{ErrorCode: ErrIO}
+			testExpectedURet(t, testName, n, len(bomb), err, wantErr)
+			allocated := after.TotalAlloc - before.TotalAlloc
+			if allocated > 1<<20 {
+				t.Errorf("%s: allocated %d bytes", testName,
+					allocated)
+			}
+		}
+	}
+
+	// Ensure data which is larger than the storage allocated up front
+	// decodes properly.
+	uints := make([]uint32, 1<<16)
+	opaque := make([]byte, 1<<17+1)
+	entries := make(map[uint32]uint32)
+	for i := range uints {
+		uints[i] = uint32(i)
+		opaque[i] = byte(i)
+		if i < 1<<13 {
+			entries[uint32(i)] = uint32(i) * 2
+		}
+	}
+	values := []interface{}{uints, opaque, string(opaque), entries}
+
+	for i, v := range values {
+		var buf bytes.Buffer
+		wantN, err := Marshal(&buf, v)
+		if err != nil {
+			t.Errorf("Marshal #%d unexpected error: %v", i, err)
+			continue
+		}
+		for _, sized := range []bool{false, true} {
+			var r io.Reader = bytes.NewReader(buf.Bytes())
+			if !sized {
+				r = unsizedReader{r}
+			}
+
+			pv := reflect.New(reflect.TypeOf(v))
+			n, err := Unmarshal(r, pv.Interface())
+			testName := fmt.Sprintf("Unmarshal #%d (sized %v)", i,
+				sized)
+			if !testExpectedURet(t, testName, n, wantN, err, nil) {
+				continue
+			}
+			if !reflect.DeepEqual(pv.Elem().Interface(), v) {
+				t.Errorf("%s: unexpected result", testName)
+				continue
+			}
+		}
+	}
+}
+
+// TestUnmarshalStrict ensures strict decoders reject data which is not in
+// canonical form with the expected error codes while decoders which are not
+// strict accept it.
+func TestUnmarshalStrict(t *testing.T) {
+	tests := []struct {
+		in      []byte      // input bytes
+		wantVal interface{} // expected value
+		wantN   int         // expected number of bytes read
+		err     error       // expected error when strict
+	}{
+		// Canonical data.
+		{[]byte{0x00, 0x00, 0x00, 0x01, 0xab, 0x00, 0x00, 0x00},
+			[]byte{0xab}, 8, nil},
+		{[]byte{0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00, 0x01,
+			0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00, 0x02,
+			0x00, 0x00, 0x00, 0x03}, map[uint32]uint32{1: 2, 2: 3}, 20,
+			nil},
+		{[]byte{0x3f, 0xc0, 0x00, 0x00}, float32(1.5), 4, nil},
+
+		// Nonzero padding.
+		{[]byte{0x00, 0x00, 0x00, 0x01, 0xab, 0x00, 0x00, 0x01},
+			[]byte{0xab}, 8, &UnmarshalError{ErrorCode: ErrNonzeroPadding}},
+		{[]byte{0x00, 0x00, 0x00, 0x02, 0x61, 0x62, 0x80, 0x00},
+			"ab", 8, &UnmarshalError{ErrorCode: ErrNonzeroPadding}},
+		{[]byte{0x01, 0x02, 0x03, 0x04}, [3]byte{1, 2, 3}, 4,
+			&UnmarshalError{ErrorCode: ErrNonzeroPadding}},
+
+		// Trailing bytes.
+		{[]byte{0x00, 0x00, 0x00, 0x01, 0x00}, uint32(1), 4,
+			&UnmarshalError{ErrorCode: ErrTrailingBytes}},
+
+		// Non-canonical NaNs.
+		{[]byte{0x7f, 0xc0, 0x00, 0x01}, float32(0), 4,
+			&UnmarshalError{ErrorCode: ErrNonCanonicalNaN}},
+		{[]byte{0xff, 0xc0, 0x00, 0x00}, float32(0), 4,
+			&UnmarshalError{ErrorCode: ErrNonCanonicalNaN}},
+		{[]byte{0xff, 0xf8, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+			float64(0), 8, &UnmarshalError{ErrorCode: ErrNonCanonicalNaN}},
+		{[]byte{0x7f, 0xf8, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01},
+			float64(0), 8, &UnmarshalError{ErrorCode: ErrNonCanonicalNaN}},
+
+		// Duplicate map keys.
+		{[]byte{0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00, 0x01,
+			0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00, 0x01,
+			0x00, 0x00, 0x00, 0x03}, map[uint32]uint32{1: 3}, 16,
+			&UnmarshalError{ErrorCode: ErrDuplicateKey}},
+	}
+
+	strict := DecoderOptions{Strict: true}
+	for i, test := range tests {
+		// Ensure the data is accepted when not strict.
+		wantValType := reflect.TypeOf(test.wantVal)
+		pv := reflect.New(wantValType)
+		_, err := UnmarshalWithOptions(bytes.NewReader(test.in),
+			pv.Interface(), DecoderOptions{})
+		if err != nil {
+			t.Errorf("UnmarshalWithOptions #%d unexpected error: %v",
+				i, err)
+			continue
+		}
+
+		// Ensure the data is rejected with the expected error when
+		// strict regardless of whether or not the reader reports how
+		// many bytes remain.
+		for _, sized := range []bool{false, true} {
+			var r io.Reader = bytes.NewReader(test.in)
+			if !sized {
+				r = unsizedReader{r}
+			}
+
+			pv := reflect.New(wantValType)
+			n, err := UnmarshalWithOptions(r, pv.Interface(), strict)
+			testName := fmt.Sprintf("UnmarshalWithOptions #%d "+
+				"(sized %v)", i, sized)
+			if !testExpectedURet(t, testName, n, test.wantN, err,
+				test.err) {
+				continue
+			}
+			if test.err != nil {
+				continue
+			}
+			if !reflect.DeepEqual(pv.Elem().Interface(), test.wantVal) {
+				t.Errorf("%s: unexpected result - got: %v want: %v\n",
+					testName, pv.Elem().Interface(), test.wantVal)
+				continue
+			}
+		}
+	}
+
+	// Ensure the canonical NaNs are accepted.
+	canonical := []byte{0x7f, 0xc0, 0x00, 0x00,
+		0x7f, 0xf8, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}
+	var nans struct {
+		F float32
+		D float64
+	}
+	_, err := UnmarshalWithOptions(bytes.NewReader(canonical), &nans, strict)
+	if err != nil {
+		t.Errorf("UnmarshalWithOptions (canonical NaN) unexpected "+
+			"error: %v", err)
+	} else if !math.IsNaN(float64(nans.F)) || !math.IsNaN(nans.D) {
+		t.Errorf("UnmarshalWithOptions (canonical NaN) got %v, %v "+
+			"want NaN", nans.F, nans.D)
+	}
+
+	// Ensure the NaNs written by an Encoder, including those with payloads
+	// and the sign bit set, are the canonical NaNs and are accepted.
+	type allNaNs struct {
+		F float32
+		D float64
+		Q Quadruple
+		M map[float64]bool
+	}
+	negNaN := math.Float64frombits(0xfff0000000000001)
+	in := allNaNs{float32(math.NaN()), negNaN, QuadrupleFromFloat64(negNaN),
+		map[float64]bool{math.NaN(): true, negNaN: false}}
+	encoded, err := AppendMarshal(nil, &in)
+	if err != nil {
+		t.Fatalf("AppendMarshal (NaN) unexpected error: %v", err)
+	}
+	if !bytes.Equal(encoded[:12], canonical) {
+		t.Errorf("AppendMarshal (NaN) got: %x want: %x", encoded[:12],
+			canonical)
+	}
+	var out allNaNs
+	_, err = UnmarshalWithOptions(bytes.NewReader(encoded), &out, strict)
+	if err != nil {
+		t.Errorf("UnmarshalWithOptions (encoded NaN) unexpected "+
+			"error: %v", err)
+	} else if !out.Q.IsNaN() || len(out.M) != 2 {
+		t.Errorf("UnmarshalWithOptions (encoded NaN) got %v", out)
+	}
+}
@@ -0,0 +1,336 @@
+/*
+ * Copyright (c) 2014 Dave Collins <dave@davec.name>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package xdr_test
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+
+	. "github.com/davecgh/go-xdr/xdr3"
+)
+
+// hyperInt is an int32 which implements the Marshaler and Unmarshaler
+// interfaces to encode itself as an XDR hyper integer rather than an XDR
+// integer.  EncodeXDR has a value receiver so it is usable on non-addressable
+// values.
+type hyperInt int32
+
+func (h hyperInt) EncodeXDR(enc *Encoder) (int, error) {
+	return enc.EncodeHyper(int64(h))
+}
+
+func (h *hyperInt) DecodeXDR(d *Decoder) (int, error) {
+	v, n, err := d.DecodeHyper()
+	if err != nil {
+		return n, err
+	}
+	*h = hyperInt(v)
+	return n, nil
+}
+
+// ptrCounter is a struct which implements the Marshaler interface with a
+// pointer receiver to encode only its count field as an XDR unsigned integer.
+type ptrCounter struct {
+	Count uint32
+	Extra uint32
+}
+
+func (c *ptrCounter) EncodeXDR(enc *Encoder) (int, error) {
+	return enc.EncodeUint(c.Count)
+}
+
+func (c *ptrCounter) DecodeXDR(d *Decoder) (int, error) {
+	v, n, err := d.DecodeUint()
+	if err != nil {
+		return n, err
+	}
+	c.Count = v
+	return n, nil
+}
+
+// failMarshaler always fails to encode and decode.
+type failMarshaler struct{}
+
+var errFailMarshaler = errors.New("failMarshaler")
+
+func (failMarshaler) EncodeXDR(enc *Encoder) (int, error) {
+	return 0, errFailMarshaler
+}
+
+func (*failMarshaler) DecodeXDR(d *Decoder) (int, error) {
+	return 0, errFailMarshaler
+}
+
+// customTest houses values which implement the Marshaler and Unmarshaler
+// interfaces at various levels of nesting.
+type customTest struct {
+	A hyperInt
+	B *hyperInt
+	C []hyperInt
+	D [1]ptrCounter
+	E map[string]hyperInt
+	F ptrCounter
+}
+
+// customTestBytes is the expected encoding of customTestValue.
+var customTestBytes = []byte{
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01, // A
+	0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xfe, // B
+	0x00, 0x00, 0x00, 0x01, // C length
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x03, // C[0]
+	0x00, 0x00, 0x00, 0x04, // D[0].Count
+	0x00, 0x00, 0x00, 0x01, // E length
+	0x00, 0x00, 0x00, 0x01, 'k', 0x00, 0x00, 0x00, // E key
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x05, // E value
+	0x00, 0x00, 0x00, 0x06, // F.Count
+}
+
+// customTestValue returns the value which encodes to customTestBytes.
+func customTestValue() *customTest {
+	b := hyperInt(-2)
+	return &customTest{
+		A: 1,
+		B: &b,
+		C: []hyperInt{3},
+		D: [1]ptrCounter{{Count: 4}},
+		E: map[string]hyperInt{"k": 5},
+		F: ptrCounter{Count: 6},
+	}
+}
+
+// TestMarshaler ensures types which implement the Marshaler interface are
+// encoded via their EncodeXDR method at all levels of nesting.
+func TestMarshaler(t *testing.T) {
+	h := hyperInt(7)
+	tests := []struct {
+		in        interface{}
+		wantBytes []byte
+	}{
+		// Value receiver at the top level, through a pointer, and
+		// through multiple pointers.
+		{h, []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x07}},
+		{&h, []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x07}},
+		{func() **hyperInt { p := &h; return &p }(),
+			[]byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x07}},
+
+		// Pointer receiver on an addressable value.
+		{&ptrCounter{Count: 8, Extra: 9}, []byte{0x00, 0x00, 0x00, 0x08}},
+
+		// Pointer receiver on a non-addressable value falls back to
+		// reflection.
+		{ptrCounter{Count: 8, Extra: 9},
+			[]byte{0x00, 0x00, 0x00, 0x08, 0x00, 0x00, 0x00, 0x09}},
+
+		// Nested in a struct, pointer, slice, array, and map.
+		{customTestValue(), customTestBytes},
+
+		// Inside an interface.
+		{struct{ I interface{} }{hyperInt(1)},
+			[]byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01}},
+	}
+
+	for i, test := range tests {
+		var buf bytes.Buffer
+		n, err := Marshal(&buf, test.in)
+		if err != nil {
+			t.Errorf("Marshal #%d (%T) unexpected error: %v", i, test.in,
+				err)
+			continue
+		}
+		if n != len(test.wantBytes) {
+			t.Errorf("Marshal #%d (%T) got %d bytes want %d", i,
+				test.in, n, len(test.wantBytes))
+			continue
+		}
+		if !bytes.Equal(buf.Bytes(), test.wantBytes) {
+			t.Errorf("Marshal #%d (%T)\n got: %x\nwant: %x", i, test.in,
+				buf.Bytes(), test.wantBytes)
+			continue
+		}
+	}
+
+	// Ensure errors from EncodeXDR are passed through unmodified.
+	var buf bytes.Buffer
+	_, err := Marshal(&buf, struct{ F failMarshaler }{})
+	if err != errFailMarshaler {
+		t.Errorf("Marshal got error %v want %v", err, errFailMarshaler)
+	}
+}
+
+// TestUnmarshaler ensures types which implement the Unmarshaler interface are
+// decoded via their DecodeXDR method at all levels of nesting.
+func TestUnmarshaler(t *testing.T) {
+	tests := []struct {
+		in      []byte
+		wantVal interface{}
+	}{
+		{[]byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x07},
+			hyperInt(7)},
+		{[]byte{0x00, 0x00, 0x00, 0x08}, ptrCounter{Count: 8}},
+		{customTestBytes, *customTestValue()},
+	}
+
+	for i, test := range tests {
+		v := reflect.New(reflect.TypeOf(test.wantVal))
+		n, err := Unmarshal(bytes.NewReader(test.in), v.Interface())
+		if err != nil {
+			t.Errorf("Unmarshal #%d (%T) unexpected error: %v", i,
+				test.wantVal, err)
+			continue
+		}
+		if n != len(test.in) {
+			t.Errorf("Unmarshal #%d (%T) got %d bytes want %d", i,
+				test.wantVal, n, len(test.in))
+			continue
+		}
+		if !reflect.DeepEqual(v.Elem().Interface(), test.wantVal) {
+			t.Errorf("Unmarshal #%d\n got: %+v\nwant: %+v", i,
+				v.Elem().Interface(), test.wantVal)
+			continue
+		}
+	}
+
+	// Ensure nil pointers to Unmarshalers are allocated before decoding
+	// into them.
+	var p *ptrCounter
+	_, err := Unmarshal(bytes.NewReader([]byte{0x00, 0x00, 0x00, 0x0a}), &p)
+	if err != nil {
+		t.Errorf("Unmarshal unexpected error: %v", err)
+	} else if p == nil || p.Count != 10 {
+		t.Errorf("Unmarshal got %+v want Count 10", p)
+	}
+
+	// Ensure errors from DecodeXDR are passed through unmodified.
+	var f struct{ F failMarshaler }
+	_, err = Unmarshal(bytes.NewReader(nil), &f)
+	if err != errFailMarshaler {
+		t.Errorf("Unmarshal got error %v want %v", err, errFailMarshaler)
+	}
+}
+
+// wordByte is a byte which implements the Marshaler and Unmarshaler interfaces
+// to encode itself as an XDR unsigned integer so arrays and slices of it are
+// not opaque data.
+type wordByte uint8
+
+func (b wordByte) EncodeXDR(enc *Encoder) (int, error) {
+	return enc.EncodeUint(uint32(b))
+}
+
+func (b *wordByte) DecodeXDR(d *Decoder) (int, error) {
+	v, n, err := d.DecodeUint()
+	if err != nil {
+		return n, err
+	}
+	*b = wordByte(v)
+	return n, nil
+}
+
+// hyperBytes is a byte slice which implements the Marshaler and Unmarshaler
+// interfaces to encode itself as a variable-length array of XDR hyper integers.
+type hyperBytes []byte
+
+func (h hyperBytes) EncodeXDR(enc *Encoder) (int, error) {
+	n, err := enc.EncodeUint(uint32(len(h)))
+	for _, b := range h {
+		if err != nil {
+			return n, err
+		}
+		var n2 int
+		n2, err = enc.EncodeHyper(int64(b))
+		n += n2
+	}
+	return n, err
+}
+
+func (h *hyperBytes) DecodeXDR(d *Decoder) (int, error) {
+	count, n, err := d.DecodeUint()
+	if err != nil {
+		return n, err
+	}
+	*h = make(hyperBytes, count)
+	for i := range *h {
+		v, n2, err := d.DecodeHyper()
+		n += n2
+		if err != nil {
+			return n, err
+		}
+		(*h)[i] = byte(v)
+	}
+	return n, nil
+}
+
+// TestMarshalerFieldOptions ensures values which implement the Marshaler and
+// Unmarshaler interfaces, and arrays and slices of them, are encoded and
+// decoded by them when struct tags select special handling of arrays, slices,
+// and integers.
+func TestMarshalerFieldOptions(t *testing.T) {
+	type optionsTest struct {
+		A hyperBytes `xdr:"len=2"`
+		B []wordByte `xdr:"len=2"`
+		C [2]wordByte
+		D []wordByte
+		E hyperBytes `xdropaque:"false"`
+		F wordByte   `xdr:"hyper"`
+		G *wordByte  `xdr:"hyper,optional"`
+	}
+	g := wordByte(10)
+	in := optionsTest{
+		A: hyperBytes{1, 2},
+		B: []wordByte{3, 4},
+		C: [2]wordByte{5, 6},
+		D: []wordByte{7},
+		E: hyperBytes{8},
+		F: 9,
+		G: &g,
+	}
+	want := []byte{
+		0x00, 0x00, 0x00, 0x02, // A length
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01, // A[0]
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, // A[1]
+		0x00, 0x00, 0x00, 0x03, 0x00, 0x00, 0x00, 0x04, // B
+		0x00, 0x00, 0x00, 0x05, 0x00, 0x00, 0x00, 0x06, // C
+		0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x07, // D
+		0x00, 0x00, 0x00, 0x01, // E length
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x08, // E[0]
+		0x00, 0x00, 0x00, 0x09, // F
+		0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x0a, // G
+	}
+
+	var buf bytes.Buffer
+	n, err := Marshal(&buf, &in)
+	if err != nil {
+		t.Fatalf("Marshal unexpected error: %v", err)
+	}
+	if n != len(want) || !bytes.Equal(buf.Bytes(), want) {
+		t.Fatalf("Marshal\n got: %x\nwant: %x", buf.Bytes(), want)
+	}
+
+	var out optionsTest
+	n, err = Unmarshal(bytes.NewReader(want), &out)
+	if err != nil {
+		t.Fatalf("Unmarshal unexpected error: %v", err)
+	}
+	if n != len(want) {
+		t.Fatalf("Unmarshal got %d bytes want %d", n, len(want))
+	}
+	if !reflect.DeepEqual(out, in) {
+		t.Fatalf("Unmarshal\n got: %+v\nwant: %+v", out, in)
+	}
+}
@@ -0,0 +1,285 @@
+/*
+ * Copyright (c) 2014 Dave Collins <dave@davec.name>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package xdr
+
+import (
+	"math"
+	"math/big"
+)
+
+// Parameters of the IEEE 754 binary128 format used by quadruple-precision
+// floating point values.
+const (
+	quadMantBits = 112   // Explicit mantissa bits
+	quadExpMax   = 16383 // Largest unbiased exponent and the exponent bias
+	quadExpMin   = -16382
+	quadExpMask  = 0x7fff
+)
+
+// Parameters of the IEEE 754 binary64 format used by float64 values.
+const (
+	doubleMantBits = 52
+	doubleExpMax   = 1023
+	doubleExpMin   = -1022
+)
+
+// Quadruple is an IEEE 754 binary128 quadruple-precision floating point value.
+// It holds the 16 bytes of the value in big-endian order, which is also its
+// XDR encoding, so values are encoded and decoded without any conversion.  Go
+// has no native quadruple-precision type, so conversions to and from float64
+// and *big.Float are provided.
+//
+// Quadruple implements the Marshaler and Unmarshaler interfaces, so values of
+// it are encoded and decoded as XDR quadruple-precision floating point values
+// by Marshal and Unmarshal.
+type Quadruple [16]byte
+
+// QuadrupleFromFloat64 returns the passed float64 as a Quadruple.  The
+// conversion is exact since every float64 value, including infinities and NaN
+// payloads, can be represented by a Quadruple.
+func QuadrupleFromFloat64(f float64) Quadruple {
+	bits := math.Float64bits(f)
+	neg := bits>>63 != 0
+	switch {
+	case math.IsNaN(f):
+		// Keep the payload, including the quiet bit, in the most
+		// significant bits of the fraction.
+		frac := new(big.Int).SetUint64(bits & (1<<doubleMantBits - 1))
+		frac.Lsh(frac, quadMantBits-doubleMantBits)
+		return newQuadruple(neg, quadExpMask, frac)
+
+	case math.IsInf(f, 0):
+		return newQuadruple(neg, quadExpMask, new(big.Int))
+
+	case f == 0:
+		return newQuadruple(neg, 0, new(big.Int))
+	}
+
+	return QuadrupleFromBig(new(big.Float).SetFloat64(f), big.ToNearestEven)
+}
+
+// QuadrupleFromBig returns the passed *big.Float rounded to a Quadruple using
+// the passed rounding mode.  Values too large in magnitude for a Quadruple
+// become an infinity or the largest finite Quadruple as the rounding mode
+// dictates, and values too small become subnormal values or zero.
+func QuadrupleFromBig(x *big.Float, mode big.RoundingMode) Quadruple {
+	neg := x.Signbit()
+	switch {
+	case x.IsInf():
+		return newQuadruple(neg, quadExpMask, new(big.Int))
+
+	case x.Sign() == 0:
+		return newQuadruple(neg, 0, new(big.Int))
+	}
+
+	exp, frac := roundBinary(x, quadMantBits, quadExpMin, quadExpMax, mode)
+	return newQuadruple(neg, exp, frac)
+}
+
+// newQuadruple returns the Quadruple with the passed sign, biased exponent, and
+// fraction.
+func newQuadruple(neg bool, exp int, frac *big.Int) Quadruple {
+	bits := new(big.Int).SetInt64(int64(exp))
+	bits.Lsh(bits, quadMantBits)
+	bits.Or(bits, frac)
+	var q Quadruple
+	bits.FillBytes(q[:])
+	if neg {
+		q[0] |= 0x80
+	}
+	return q
+}
+
+// parts returns the sign, biased exponent, and fraction of the Quadruple.
+func (q Quadruple) parts() (neg bool, exp int, frac *big.Int) {
+	neg = q[0]&0x80 != 0
+	exp = int(q[0]&0x7f)<<8 | int(q[1])
+	frac = new(big.Int).SetBytes(q[2:])
+	return neg, exp, frac
+}
+
+// IsNaN returns whether or not the Quadruple is a NaN.
+func (q Quadruple) IsNaN() bool {
+	_, exp, frac := q.parts()
+	return exp == quadExpMask && frac.Sign() != 0
+}
+
+// BigFloat returns the Quadruple as a *big.Float with a precision of 113 bits,
+// which represents it exactly.  Since a *big.Float can't represent a NaN, it
+// returns nil when the Quadruple is a NaN.
+func (q Quadruple) BigFloat() *big.Float {
+	neg, exp, frac := q.parts()
+	x := new(big.Float).SetPrec(quadMantBits + 1)
+	switch {
+	case exp == quadExpMask && frac.Sign() != 0:
+		return nil
+
+	case exp == quadExpMask:
+		x.SetInf(neg)
+		return x
+
+	case exp == 0:
+		// Zero and subnormal values have the same exponent as the
+		// smallest normal values but no implicit leading bit.
+		exp = 1
+
+	default:
+		frac.SetBit(frac, quadMantBits, 1)
+	}
+
+	x.SetInt(frac)
+	x.SetMantExp(x, exp-quadExpMax-quadMantBits)
+	if neg {
+		x.Neg(x)
+	}
+	return x
+}
+
+// Float64 returns the Quadruple rounded to a float64 using the passed rounding
+// mode.  Values too large in magnitude for a float64 become an infinity or the
+// largest finite float64 as the rounding mode dictates, and values too small
+// become subnormal values or zero.  A NaN keeps its sign and the most
+// significant bits of its payload.
+func (q Quadruple) Float64(mode big.RoundingMode) float64 {
+	neg, exp, frac := q.parts()
+	var sign uint64
+	if neg {
+		sign = 1 << 63
+	}
+	switch {
+	case exp == quadExpMask && frac.Sign() != 0:
+		// The quiet bit is set when the bits of the payload which fit
+		// are all zero since the result would otherwise be an
+		// infinity.
+		frac.Rsh(frac, quadMantBits-doubleMantBits)
+		payload := frac.Uint64()
+		if payload == 0 {
+			payload = 1 << (doubleMantBits - 1)
+		}
+		return math.Float64frombits(sign | 0x7ff<<doubleMantBits |
+			payload)
+
+	case exp == quadExpMask:
+		return math.Float64frombits(sign | 0x7ff<<doubleMantBits)
+
+	case exp == 0 && frac.Sign() == 0:
+		return math.Float64frombits(sign)
+	}
+
+	exp, frac = roundBinary(q.BigFloat(), doubleMantBits, doubleExpMin,
+		doubleExpMax, mode)
+	return math.Float64frombits(sign | uint64(exp)<<doubleMantBits |
+		frac.Uint64())
+}
+
+// roundBinary rounds the passed finite nonzero value to the IEEE 754 binary
+// format with the passed number of explicit mantissa bits and range of
+// unbiased exponents using the passed rounding mode.  It returns the biased
+// exponent and the fraction of the result which, along with the sign of the
+// value, make up its encoding.
+//
+// The value is rounded to an integer multiple of the spacing of the values of
+// the format at its magnitude, which is what makes values below the smallest
+// normal value round to subnormal values.  Values whose magnitude is too large
+// result in an infinity, or the largest finite value when the rounding mode
+// rounds toward zero, as IEEE 754 specifies.
+func roundBinary(x *big.Float, mantBits uint, expMin, expMax int, mode big.RoundingMode) (int, *big.Int) {
+	neg := x.Signbit()
+	abs := new(big.Float).Abs(x)
+
+	// Scale the value so the spacing of the values at its magnitude is 1.
+	// The exponent returned by MantExp is for a mantissa in [0.5, 1.0).
+	exp := abs.MantExp(nil) - 1
+	if exp < expMin {
+		exp = expMin
+	}
+	scale := exp - int(mantBits)
+	scaled := new(big.Float).SetMantExp(abs, -scale)
+
+	// Split the scaled value into its integer and fractional parts and
+	// round the integer part according to the rounding mode.
+	m, _ := scaled.Int(nil)
+	rem := new(big.Float).SetPrec(scaled.Prec()).SetInt(m)
+	rem.Sub(scaled, rem)
+	inexact := rem.Sign() != 0
+	half := rem.Cmp(big.NewFloat(0.5))
+
+	var up bool
+	switch mode {
+	case big.ToNearestEven:
+		up = half > 0 || half == 0 && m.Bit(0) == 1
+	case big.ToNearestAway:
+		up = half >= 0
+	case big.AwayFromZero:
+		up = inexact
+	case big.ToNegativeInf:
+		up = inexact && neg
+	case big.ToPositiveInf:
+		up = inexact && !neg
+	}
+	if up {
+		m.Add(m, big.NewInt(1))
+	}
+
+	// Rounding up may carry into the next power of two.
+	if m.BitLen() > int(mantBits)+1 {
+		m.Rsh(m, 1)
+		exp++
+	}
+
+	// Values too large in magnitude become an infinity unless the rounding
+	// mode rounds them toward zero, in which case they become the largest
+	// finite value.
+	bias := expMax
+	if exp > expMax {
+		towardZero := mode == big.ToZero ||
+			mode == big.ToNegativeInf && !neg ||
+			mode == big.ToPositiveInf && neg
+		if !towardZero {
+			return 2*bias + 1, new(big.Int)
+		}
+		max := new(big.Int).Lsh(big.NewInt(1), mantBits)
+		return 2 * bias, max.Sub(max, big.NewInt(1))
+	}
+
+	// Subnormal values, including those which rounded to zero, have a
+	// biased exponent of zero and no implicit leading bit.
+	if m.BitLen() <= int(mantBits) {
+		return 0, m
+	}
+	m.SetBit(m, int(mantBits), 0)
+	return exp + bias, m
+}
+
+// EncodeXDR writes the XDR encoded representation of the Quadruple to the
+// passed Encoder and returns the number of bytes written.  It is part of the
+// Marshaler interface implementation.
+func (q Quadruple) EncodeXDR(enc *Encoder) (int, error) {
+	return enc.EncodeQuadruple(q)
+}
+
+// DecodeXDR reads the XDR encoded representation of a Quadruple from the
+// passed Decoder into the Quadruple and returns the number of bytes read.  It
+// is part of the Unmarshaler interface implementation.
+func (q *Quadruple) DecodeXDR(d *Decoder) (int, error) {
+	v, n, err := d.DecodeQuadruple()
+	if err != nil {
+		return n, err
+	}
+	*q = v
+	return n, nil
+}
@@ -0,0 +1,252 @@
+/*
+ * Copyright (c) 2014 Dave Collins <dave@davec.name>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package xdr_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"math/big"
+	"testing"
+
+	. "github.com/davecgh/go-xdr/xdr3"
+)
+
+// quad returns the Quadruple described by the passed hex string.  It panics
+// when the string is not valid hex so it must only be used with hard-coded
+// values.
+func quad(s string) Quadruple {
+	var q Quadruple
+	copy(q[:], hexBytes(s))
+	return q
+}
+
+// TestQuadrupleFloat64 ensures converting float64 values to and from Quadruple
+// values produces the expected encodings and round trips exactly.
+func TestQuadrupleFloat64(t *testing.T) {
+	tests := []struct {
+		in   float64   // float64 to convert
+		want Quadruple // expected quadruple
+	}{
+		{1, quad("3fff0000000000000000000000000000")},
+		{-2, quad("c0000000000000000000000000000000")},
+		{0.1, quad("3ffb999999999999a000000000000000")},
+		{math.MaxFloat64, quad("43fefffffffffffff000000000000000")},
+		{math.SmallestNonzeroFloat64,
+			quad("3bcd0000000000000000000000000000")},
+		{0, quad("00000000000000000000000000000000")},
+		{math.Copysign(0, -1), quad("80000000000000000000000000000000")},
+		{math.Inf(1), quad("7fff0000000000000000000000000000")},
+		{math.Inf(-1), quad("ffff0000000000000000000000000000")},
+		{math.Float64frombits(0x7ff8000000000000),
+			quad("7fff8000000000000000000000000000")},
+		{math.Float64frombits(0xfff4000000000001),
+			quad("ffff4000000000001000000000000000")},
+	}
+
+	for i, test := range tests {
+		result := QuadrupleFromFloat64(test.in)
+		if result != test.want {
+			t.Errorf("QuadrupleFromFloat64 #%d got: %x want: %x", i,
+				result, test.want)
+			continue
+		}
+
+		// Converting back must produce the same bits for every mode
+		// since the conversion is exact.
+		for _, mode := range []big.RoundingMode{big.ToNearestEven,
+			big.ToZero, big.AwayFromZero} {
+
+			f := result.Float64(mode)
+			if math.Float64bits(f) != math.Float64bits(test.in) {
+				t.Errorf("Float64 #%d (%v) got: %x want: %x", i,
+					mode, math.Float64bits(f),
+					math.Float64bits(test.in))
+			}
+		}
+		if result.IsNaN() != math.IsNaN(test.in) {
+			t.Errorf("IsNaN #%d got: %v want: %v", i, result.IsNaN(),
+				math.IsNaN(test.in))
+		}
+	}
+}
+
+// TestQuadrupleRounding ensures converting Quadruple values which are not
+// exactly representable as a float64, and *big.Float values which are not
+// exactly representable as a Quadruple, rounds as the rounding mode dictates.
+func TestQuadrupleRounding(t *testing.T) {
+	// One plus the smallest Quadruple increment and one plus half of the
+	// smallest float64 increment.
+	onePlusUlp := quad("3fff0000000000000000000000000001")
+	onePlusHalf := quad("3fff0000000000000800000000000000")
+	// Largest finite Quadruple, which is far beyond float64 range.
+	maxQuad := quad("7ffeffffffffffffffffffffffffffff")
+	// Smallest positive subnormal Quadruple.
+	minQuad := quad("00000000000000000000000000000001")
+
+	floatTests := []struct {
+		in   Quadruple        // quadruple to convert
+		mode big.RoundingMode // rounding mode
+		want float64          // expected float64
+	}{
+		{onePlusUlp, big.ToNearestEven, 1},
+		{onePlusUlp, big.ToZero, 1},
+		{onePlusUlp, big.AwayFromZero, math.Nextafter(1, 2)},
+		{onePlusUlp, big.ToPositiveInf, math.Nextafter(1, 2)},
+		{onePlusUlp, big.ToNegativeInf, 1},
+		{onePlusHalf, big.ToNearestEven, 1},
+		{onePlusHalf, big.ToNearestAway, math.Nextafter(1, 2)},
+		{maxQuad, big.ToNearestEven, math.Inf(1)},
+		{maxQuad, big.ToZero, math.MaxFloat64},
+		{minQuad, big.ToNearestEven, 0},
+		{minQuad, big.AwayFromZero, math.SmallestNonzeroFloat64},
+	}
+
+	for i, test := range floatTests {
+		result := test.in.Float64(test.mode)
+		if result != test.want {
+			t.Errorf("Float64 #%d got: %v want: %v", i, result,
+				test.want)
+			continue
+		}
+	}
+
+	// A NaN whose payload only has bits which don't fit in a float64 must
+	// still be a NaN.
+	nan := quad("7fff0000000000000000000000000001")
+	if result := nan.Float64(big.ToNearestEven); !math.IsNaN(result) {
+		t.Errorf("Float64 of NaN got: %v want: NaN", result)
+	}
+
+	// 2^-16494 is the smallest subnormal Quadruple and 2^16384 is the
+	// smallest power of two which overflows a Quadruple.
+	tiny := new(big.Float).SetMantExp(big.NewFloat(1), -16495)
+	huge := new(big.Float).SetMantExp(big.NewFloat(1), 16384)
+	third := new(big.Float).SetPrec(200).Quo(big.NewFloat(1),
+		big.NewFloat(3))
+
+	bigTests := []struct {
+		in   *big.Float       // value to convert
+		mode big.RoundingMode // rounding mode
+		want Quadruple        // expected quadruple
+	}{
+		{third, big.ToNearestEven, quad("3ffd5555555555555555555555555555")},
+		{third, big.AwayFromZero, quad("3ffd5555555555555555555555555556")},
+		{tiny, big.ToNearestEven, quad("00000000000000000000000000000000")},
+		{tiny, big.ToPositiveInf, minQuad},
+		{huge, big.ToNearestEven, quad("7fff0000000000000000000000000000")},
+		{huge, big.ToZero, maxQuad},
+		{new(big.Float).Neg(huge), big.ToPositiveInf,
+			quad("fffeffffffffffffffffffffffffffff")},
+	}
+
+	for i, test := range bigTests {
+		result := QuadrupleFromBig(test.in, test.mode)
+		if result != test.want {
+			t.Errorf("QuadrupleFromBig #%d got: %x want: %x", i,
+				result, test.want)
+			continue
+		}
+
+		// Values which are exactly representable must round trip.
+		if test.want.IsNaN() || test.in.Cmp(test.want.BigFloat()) != 0 {
+			continue
+		}
+		if result := QuadrupleFromBig(test.want.BigFloat(),
+			test.mode); result != test.want {
+
+			t.Errorf("BigFloat #%d got: %x want: %x", i, result,
+				test.want)
+		}
+	}
+}
+
+// TestQuadrupleXDR ensures Quadruple values are encoded and decoded as XDR
+// quadruple-precision floating point values.
+func TestQuadrupleXDR(t *testing.T) {
+	type quadTest struct {
+		Q Quadruple
+		P *Quadruple
+	}
+	one := QuadrupleFromFloat64(1)
+	encoded := []byte{
+		0x3f, 0xff, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0xc0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	}
+	in := quadTest{one, new(Quadruple)}
+	*in.P = QuadrupleFromFloat64(-2)
+
+	var buf bytes.Buffer
+	n, err := Marshal(&buf, in)
+	if !testExpectedMRet(t, "Marshal", n, len(encoded), err, nil) {
+		return
+	}
+	if !bytes.Equal(buf.Bytes(), encoded) {
+		t.Errorf("Marshal: unexpected result - got: %x want: %x",
+			buf.Bytes(), encoded)
+	}
+
+	var out quadTest
+	n, err = Unmarshal(bytes.NewReader(encoded), &out)
+	if !testExpectedURet(t, "Unmarshal", n, len(encoded), err, nil) {
+		return
+	}
+	if out.Q != in.Q || out.P == nil || *out.P != *in.P {
+		t.Errorf("Unmarshal: unexpected result - got: %v want: %v",
+			out, in)
+	}
+
+	// Truncated data and, for strict decoders, NaNs other than the
+	// canonical NaN must be rejected.
+	tests := []struct {
+		in     []byte // input bytes
+		strict bool   // whether to decode strictly
+		wantN  int    // expected number of bytes read
+		err    error  // expected error
+	}{
+		{encoded[:16], false, 16, nil},
+		{encoded[:15], false, 15, &UnmarshalError{ErrorCode: ErrIO}},
+		{hexBytes("7fff8000000000000000000000000000"), true, 16, nil},
+		{hexBytes("7fff8000000000000000000000000001"), false, 16, nil},
+		{hexBytes("7fff8000000000000000000000000001"), true, 16,
+			&UnmarshalError{ErrorCode: ErrNonCanonicalNaN}},
+		{hexBytes("ffff8000000000000000000000000000"), true, 16,
+			&UnmarshalError{ErrorCode: ErrNonCanonicalNaN}},
+	}
+
+	for i, test := range tests {
+		d := NewBytesDecoderWithOptions(test.in,
+			DecoderOptions{Strict: test.strict})
+		_, n, err := d.DecodeQuadruple()
+		testName := fmt.Sprintf("DecodeQuadruple #%d", i)
+		testExpectedURet(t, testName, n, test.wantN, err, test.err)
+	}
+}
+
+// hexBytes returns the bytes described by the passed hex string.  It panics
+// when the string is not valid hex so it must only be used with hard-coded
+// values.
+func hexBytes(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
@@ -0,0 +1,304 @@
+/*
+ * Copyright (c) 2014 Dave Collins <dave@davec.name>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package xdr
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+const (
+	// lastFragment is the bit of a record marking header which indicates
+	// the fragment is the last one of its record.
+	lastFragment = 1 << 31
+
+	// MaxFragmentSize is the maximum number of bytes a single record
+	// marking fragment can contain since its length is 31 bits.
+	MaxFragmentSize = 1<<31 - 1
+
+	// DefaultFragmentSize is the fragment size used by NewRecordWriter.
+	DefaultFragmentSize = 64 * 1024
+)
+
+// RecordWriter is an io.WriteCloser which frames the data written to it into
+// records using the record marking standard.  Data is buffered until either
+// a full fragment is available or the record is ended by Flush or Close, so
+// a RecordWriter can be passed directly to NewEncoder or Marshal and then
+// flushed to send the encoded data as a single record.
+//
+// Any error writing to the underlying writer is returned by all subsequent
+// calls since the stream can no longer be framed correctly.
+//
+// Reference:
+// 	RFC 5531 Section 11 - Record Marking Standard
+// 	4-byte header with the last-fragment bit and a 31-bit fragment length
+type RecordWriter struct {
+	w   io.Writer
+	err error
+
+	// buf houses the header followed by the data of the fragment being
+	// built.  The header is filled in when the fragment is written so the
+	// header and data are written to the underlying writer together.
+	buf      []byte
+	fragSize int
+
+	// started is whether or not any fragments of the current record have
+	// been written.
+	started bool
+}
+
+// writeFragment writes the buffered fragment to the underlying writer with
+// the last-fragment bit set according to the passed flag.
+func (rw *RecordWriter) writeFragment(last bool) error {
+	header := uint32(len(rw.buf) - 4)
+	if last {
+		header |= lastFragment
+	}
+	rw.buf[0] = byte(header >> 24)
+	rw.buf[1] = byte(header >> 16)
+	rw.buf[2] = byte(header >> 8)
+	rw.buf[3] = byte(header)
+
+	if _, err := rw.w.Write(rw.buf); err != nil {
+		rw.err = err
+		return err
+	}
+	rw.buf = rw.buf[:4]
+	rw.started = !last
+	return nil
+}
+
+// Write appends the passed data to the current record.  Full fragments are
+// written to the underlying writer as they become available, but the final
+// fragment of the record is not written until Flush or Close is called.
+//
+// This is part of the io.Writer interface.
+func (rw *RecordWriter) Write(p []byte) (int, error) {
+	if rw.err != nil {
+		return 0, rw.err
+	}
+
+	var n int
+	for len(p) > 0 {
+		// Only write a full fragment once there is more data for the
+		// record so it isn't followed by an empty final fragment.
+		if len(rw.buf)-4 == rw.fragSize {
+			if err := rw.writeFragment(false); err != nil {
+				return n, err
+			}
+		}
+
+		n2 := copy(rw.buf[len(rw.buf):4+rw.fragSize], p)
+		rw.buf = rw.buf[:len(rw.buf)+n2]
+		p = p[n2:]
+		n += n2
+	}
+	return n, nil
+}
+
+// Flush ends the current record by writing the buffered data to the
+// underlying writer as a fragment with the last-fragment bit set.  Data
+// written after Flush begins a new record.  Flush does nothing when no data
+// has been written since the previous record was ended.
+func (rw *RecordWriter) Flush() error {
+	if rw.err != nil {
+		return rw.err
+	}
+	if !rw.started && len(rw.buf) == 4 {
+		return nil
+	}
+	return rw.writeFragment(true)
+}
+
+// Close ends the current record the same as Flush.  It does not close the
+// underlying writer.
+//
+// This is part of the io.Closer interface.
+func (rw *RecordWriter) Close() error {
+	return rw.Flush()
+}
+
+// NewRecordWriter returns a RecordWriter which writes records to the passed
+// writer in fragments of up to DefaultFragmentSize bytes.
+func NewRecordWriter(w io.Writer) *RecordWriter {
+	return NewRecordWriterSize(w, DefaultFragmentSize)
+}
+
+// NewRecordWriterSize returns a RecordWriter which writes records to the
+// passed writer in fragments of up to the passed number of bytes.  Sizes that
+// are not positive or exceed MaxFragmentSize are replaced by
+// DefaultFragmentSize and MaxFragmentSize, respectively.
+func NewRecordWriterSize(w io.Writer, size int) *RecordWriter {
+	if size <= 0 {
+		size = DefaultFragmentSize
+	}
+	if size > MaxFragmentSize {
+		size = MaxFragmentSize
+	}
+
+	// Avoid allocating a huge buffer up front for large fragment sizes.
+	bufCap := size
+	if bufCap > DefaultFragmentSize {
+		bufCap = DefaultFragmentSize
+	}
+	buf := make([]byte, 4, 4+bufCap)
+	return &RecordWriter{w: w, buf: buf, fragSize: size}
+}
+
+// RecordReader reads records framed using the record marking standard from an
+// io.Reader.  Next advances to the next record after which the RecordReader
+// itself reads the data of that record and returns io.EOF at its end, so it
+// can be passed directly to NewDecoder or Unmarshal to decode the record.
+//
+// Any error reading from the underlying reader, including a record which
+// exceeds the maximum size, is returned by all subsequent calls since the
+// stream can no longer be deframed correctly.
+//
+// Reference:
+// 	RFC 5531 Section 11 - Record Marking Standard
+// 	4-byte header with the last-fragment bit and a 31-bit fragment length
+type RecordReader struct {
+	r   io.Reader
+	err error
+
+	// maxSize is the maximum number of bytes a record may contain.  0 is
+	// unlimited.
+	maxSize uint
+
+	// inRecord is whether or not Next has been called for the current
+	// record.  remaining is the number of bytes left in the current
+	// fragment, last is whether or not it is the last fragment of the
+	// record, and size is the total length of the fragments of the record
+	// read so far.
+	inRecord  bool
+	remaining uint32
+	last      bool
+	size      uint
+}
+
+// readHeader reads the next record marking header and updates the state of
+// the current fragment accordingly.
+func (rr *RecordReader) readHeader() error {
+	var buf [4]byte
+	if _, err := io.ReadFull(rr.r, buf[:]); err != nil {
+		// End of the stream is only expected between records.
+		if err == io.EOF && rr.inRecord {
+			err = io.ErrUnexpectedEOF
+		}
+		rr.err = err
+		return err
+	}
+
+	header := uint32(buf[0])<<24 | uint32(buf[1])<<16 |
+		uint32(buf[2])<<8 | uint32(buf[3])
+	rr.remaining = header &^ lastFragment
+	rr.last = header&lastFragment != 0
+	rr.size += uint(rr.remaining)
+	if rr.maxSize != 0 && rr.size > rr.maxSize {
+		msg := fmt.Sprintf("record exceeds max size of %d bytes",
+			rr.maxSize)
+		rr.err = unmarshalError("RecordReader", ErrOverflow, msg,
+			rr.size, nil)
+		return rr.err
+	}
+	return nil
+}
+
+// Next advances to the next record in the stream discarding any data which
+// has not been read from the current record.  It returns io.EOF when the
+// stream ends cleanly between records.
+//
+// An UnmarshalError with an error code of ErrOverflow is returned when the
+// record exceeds the maximum record size.
+func (rr *RecordReader) Next() error {
+	if rr.err != nil {
+		return rr.err
+	}
+
+	// Discard the rest of the current record.
+	if rr.inRecord {
+		if _, err := io.Copy(ioutil.Discard, rr); err != nil {
+			return err
+		}
+	}
+
+	rr.inRecord = false
+	rr.size = 0
+	if err := rr.readHeader(); err != nil {
+		return err
+	}
+	rr.inRecord = true
+	return nil
+}
+
+// Read reads data from the current record.  It returns io.EOF once all of the
+// data of the record has been read, or when Next has not been called, at
+// which point Next must be called to read the next record.
+//
+// This is part of the io.Reader interface.
+func (rr *RecordReader) Read(p []byte) (int, error) {
+	if rr.err != nil {
+		return 0, rr.err
+	}
+	if !rr.inRecord {
+		return 0, io.EOF
+	}
+
+	// Advance through the fragments of the record, including any which
+	// are empty, until one with data remaining is found.
+	for rr.remaining == 0 {
+		if rr.last {
+			return 0, io.EOF
+		}
+		if err := rr.readHeader(); err != nil {
+			return 0, err
+		}
+	}
+
+	if uint(len(p)) > uint(rr.remaining) {
+		p = p[:rr.remaining]
+	}
+	n, err := rr.r.Read(p)
+	rr.remaining -= uint32(n)
+	if err == io.EOF {
+		err = nil
+		if rr.remaining != 0 {
+			err = io.ErrUnexpectedEOF
+		}
+	}
+	if err != nil {
+		rr.err = err
+	}
+	return n, err
+}
+
+// ReadRecord advances to the next record the same as Next and returns all of
+// its data.
+func (rr *RecordReader) ReadRecord() ([]byte, error) {
+	if err := rr.Next(); err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(rr)
+}
+
+// NewRecordReader returns a RecordReader which reads records from the passed
+// reader.  Records with more than maxSize bytes of data are rejected.  A
+// maxSize of 0 is unlimited.
+func NewRecordReader(r io.Reader, maxSize uint) *RecordReader {
+	return &RecordReader{r: r, maxSize: maxSize}
+}
@@ -0,0 +1,246 @@
+/*
+ * Copyright (c) 2014 Dave Collins <dave@davec.name>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package xdr_test
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"testing"
+
+	. "github.com/davecgh/go-xdr/xdr3"
+)
+
+// TestRecordWriter ensures the RecordWriter frames records into fragments as
+// expected.
+func TestRecordWriter(t *testing.T) {
+	tests := []struct {
+		fragSize int      // Fragment size
+		writes   [][]byte // Data to write with a nil entry for Flush
+		want     []byte   // Expected output
+	}{
+		// Single fragment record.
+		{
+			4,
+			[][]byte{{1, 2, 3}, nil},
+			[]byte{0x80, 0x00, 0x00, 0x03, 1, 2, 3},
+		},
+		// Exactly one full fragment is not followed by an empty last
+		// fragment.
+		{
+			4,
+			[][]byte{{1, 2}, {3, 4}, nil},
+			[]byte{0x80, 0x00, 0x00, 0x04, 1, 2, 3, 4},
+		},
+		// Multiple fragments.
+		{
+			2,
+			[][]byte{{1, 2, 3, 4, 5}, nil},
+			[]byte{
+				0x00, 0x00, 0x00, 0x02, 1, 2,
+				0x00, 0x00, 0x00, 0x02, 3, 4,
+				0x80, 0x00, 0x00, 0x01, 5,
+			},
+		},
+		// Multiple records and redundant flushes.
+		{
+			0,
+			[][]byte{nil, {1}, nil, nil, {2}, nil},
+			[]byte{
+				0x80, 0x00, 0x00, 0x01, 1,
+				0x80, 0x00, 0x00, 0x01, 2,
+			},
+		},
+	}
+
+	for i, test := range tests {
+		var buf bytes.Buffer
+		rw := NewRecordWriterSize(&buf, test.fragSize)
+		for _, data := range test.writes {
+			if data == nil {
+				if err := rw.Flush(); err != nil {
+					t.Errorf("Flush #%d unexpected error: %v", i,
+						err)
+				}
+				continue
+			}
+			n, err := rw.Write(data)
+			if err != nil || n != len(data) {
+				t.Errorf("Write #%d got (%d, %v) want (%d, nil)",
+					i, n, err, len(data))
+			}
+		}
+		if !bytes.Equal(buf.Bytes(), test.want) {
+			t.Errorf("RecordWriter #%d\n got: %x\nwant: %x", i,
+				buf.Bytes(), test.want)
+			continue
+		}
+	}
+
+	// Ensure Close ends the record and write errors are sticky.
+	w := newFixedWriter(6)
+	rw := NewRecordWriterSize(w, 2)
+	if _, err := rw.Write([]byte{1, 2, 3}); err != nil {
+		t.Errorf("Write unexpected error: %v", err)
+	}
+	if err := rw.Close(); err != io.ErrShortWrite {
+		t.Errorf("Close got error %v want %v", err, io.ErrShortWrite)
+	}
+	if _, err := rw.Write([]byte{1}); err != io.ErrShortWrite {
+		t.Errorf("Write got error %v want %v", err, io.ErrShortWrite)
+	}
+}
+
+// TestRecordReader ensures the RecordReader reads records framed into
+// fragments as expected.
+func TestRecordReader(t *testing.T) {
+	tests := []struct {
+		in      []byte
+		maxSize uint
+		want    [][]byte // Expected records
+		err     error    // Expected error after the records
+	}{
+		// Single fragment record followed by the end of the stream.
+		{
+			[]byte{0x80, 0x00, 0x00, 0x03, 1, 2, 3},
+			0,
+			[][]byte{{1, 2, 3}},
+			io.EOF,
+		},
+		// Multiple fragments including an empty one and multiple
+		// records.
+		{
+			[]byte{
+				0x00, 0x00, 0x00, 0x02, 1, 2,
+				0x00, 0x00, 0x00, 0x00,
+				0x80, 0x00, 0x00, 0x01, 3,
+				0x80, 0x00, 0x00, 0x00,
+			},
+			3,
+			[][]byte{{1, 2, 3}, {}},
+			io.EOF,
+		},
+		// Stream ends inside a fragment header, inside fragment data,
+		// and between fragments of a record.
+		{[]byte{0x80, 0x00}, 0, nil, io.ErrUnexpectedEOF},
+		{[]byte{0x80, 0x00, 0x00, 0x02, 1}, 0, nil,
+			io.ErrUnexpectedEOF},
+		{[]byte{0x00, 0x00, 0x00, 0x01, 1}, 0, nil,
+			io.ErrUnexpectedEOF},
+	}
+
+	for i, test := range tests {
+		rr := NewRecordReader(bytes.NewReader(test.in), test.maxSize)
+		var got [][]byte
+		var err error
+		for {
+			var rec []byte
+			rec, err = rr.ReadRecord()
+			if err != nil {
+				break
+			}
+			got = append(got, rec)
+		}
+		if err != test.err {
+			t.Errorf("ReadRecord #%d got error %v want %v", i, err,
+				test.err)
+			continue
+		}
+		if len(got) != len(test.want) {
+			t.Errorf("ReadRecord #%d got %d records want %d", i,
+				len(got), len(test.want))
+			continue
+		}
+		for j := range got {
+			if !bytes.Equal(got[j], test.want[j]) {
+				t.Errorf("ReadRecord #%d record %d\n got: %x\n"+
+					"want: %x", i, j, got[j], test.want[j])
+			}
+		}
+	}
+
+	// Ensure records which exceed the maximum size are rejected even when
+	// the size is exceeded by a later fragment.
+	in := []byte{
+		0x00, 0x00, 0x00, 0x02, 1, 2,
+		0x80, 0x00, 0x00, 0x02, 3, 4,
+	}
+	rr := NewRecordReader(bytes.NewReader(in), 3)
+	_, err := rr.ReadRecord()
+	if e, ok := err.(*UnmarshalError); !ok || e.ErrorCode != ErrOverflow {
+		t.Errorf("ReadRecord got error %v want ErrOverflow", err)
+	}
+	if err2 := rr.Next(); err2 != err {
+		t.Errorf("Next got error %v want %v", err2, err)
+	}
+
+	// Ensure Read returns io.EOF before Next is called and Next discards
+	// the unread data of the current record.
+	in = []byte{
+		0x80, 0x00, 0x00, 0x02, 1, 2,
+		0x80, 0x00, 0x00, 0x01, 3,
+	}
+	rr = NewRecordReader(bytes.NewReader(in), 0)
+	if n, err := rr.Read(make([]byte, 1)); n != 0 || err != io.EOF {
+		t.Errorf("Read got (%d, %v) want (0, %v)", n, err, io.EOF)
+	}
+	if err := rr.Next(); err != nil {
+		t.Errorf("Next unexpected error: %v", err)
+	}
+	if err := rr.Next(); err != nil {
+		t.Errorf("Next unexpected error: %v", err)
+	}
+	rec, err := ioutil.ReadAll(rr)
+	if err != nil || !bytes.Equal(rec, []byte{3}) {
+		t.Errorf("ReadAll got (%x, %v) want (03, nil)", rec, err)
+	}
+}
+
+// TestRecordRoundTrip ensures values marshalled to a RecordWriter can be
+// unmarshalled from a RecordReader with a record per value.
+func TestRecordRoundTrip(t *testing.T) {
+	type msg struct {
+		ID   uint32
+		Body string
+	}
+	msgs := []msg{{1, "a"}, {2, "a longer message body"}, {3, ""}}
+
+	var buf bytes.Buffer
+	rw := NewRecordWriterSize(&buf, 8)
+	for i := range msgs {
+		if _, err := Marshal(rw, &msgs[i]); err != nil {
+			t.Fatalf("Marshal unexpected error: %v", err)
+		}
+		if err := rw.Flush(); err != nil {
+			t.Fatalf("Flush unexpected error: %v", err)
+		}
+	}
+
+	rr := NewRecordReader(&buf, 0)
+	var got []msg
+	for rr.Next() == nil {
+		var m msg
+		if _, err := Unmarshal(rr, &m); err != nil {
+			t.Fatalf("Unmarshal unexpected error: %v", err)
+		}
+		got = append(got, m)
+	}
+	if !reflect.DeepEqual(got, msgs) {
+		t.Errorf("round trip\n got: %+v\nwant: %+v", got, msgs)
+	}
+}
@@ -0,0 +1,489 @@
+/*
+ * Copyright (c) 2014 Dave Collins <dave@davec.name>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package xdr
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// structField describes an exported struct field, which may be promoted from
+// an embedded struct, along with the options parsed from its struct tags.
+type structField struct {
+	index    []int        // Index sequence of the field for FieldByIndex
+	name     string       // Name of the field
+	typ      reflect.Type // Type of the field
+	noOpaque bool         // Field has the `xdropaque:"false"` tag
+	optional bool         // Field is XDR optional-data
+	hyper    bool         // Integer field is an XDR hyper integer
+	inline   bool         // Embedded struct fields are promoted
+	order    int          // Wire position from the order option or -1
+
+	// Size bounds.  maxLen is the maximum length of a variable-length
+	// field from the max option and fixedLen is the exact length of a
+	// fixed-length field from the len option.  Both are -1 when the
+	// option is not present.
+	maxLen   int
+	fixedLen int
+
+	// Discriminated union handling.  A field with the union option is a
+	// discriminant and the fields with the unioncase or default options
+	// which follow it are its arms.  armOf is the index into the parsed
+	// fields of the discriminant an arm belongs to and is -1 for fields
+	// that are not union arms.
+	union     bool
+	cases     []int64
+	isDefault bool
+	armOf     int
+}
+
+// isArm returns whether or not the field is a discriminated union arm.
+func (f *structField) isArm() bool {
+	return f.armOf >= 0
+}
+
+// checkLen returns a description of how the passed length violates the size
+// bound of the field from its max or len option or an empty string when it
+// does not.
+func (f *structField) checkLen(l int) string {
+	if f.maxLen >= 0 && l > f.maxLen {
+		return fmt.Sprintf("field '%s' exceeds the maximum length of %d",
+			f.name, f.maxLen)
+	}
+	if f.fixedLen >= 0 && l != f.fixedLen {
+		return fmt.Sprintf("field '%s' does not have the fixed length "+
+			"of %d", f.name, f.fixedLen)
+	}
+	return ""
+}
+
+// parseFieldTag parses the options of the xdr struct tag into the passed
+// field.  Options are separated by commas.  Since the unioncase option takes a
+// comma separated list of values itself, any element which is a bare integer
+// is treated as an additional value for the preceding unioncase option.
+func parseFieldTag(f *structField, tag string) error {
+	lastCase := false
+	for _, opt := range strings.Split(tag, ",") {
+		opt = strings.TrimSpace(opt)
+		if opt == "" {
+			continue
+		}
+
+		key, val := opt, ""
+		if i := strings.Index(opt, "="); i >= 0 {
+			key, val = opt[:i], opt[i+1:]
+		}
+
+		// Additional unioncase values.
+		if lastCase && val == "" {
+			c, err := strconv.ParseInt(key, 0, 64)
+			if err == nil {
+				f.cases = append(f.cases, c)
+				continue
+			}
+		}
+		lastCase = false
+
+		switch key {
+		case "union":
+			f.union = true
+
+		case "unioncase":
+			c, err := strconv.ParseInt(val, 0, 64)
+			if err != nil {
+				return fmt.Errorf("invalid union case '%s'", val)
+			}
+			f.cases = append(f.cases, c)
+			lastCase = true
+
+		case "default":
+			f.isDefault = true
+
+		case "optional":
+			f.optional = true
+
+		case "hyper":
+			f.hyper = true
+
+		case "inline":
+			f.inline = true
+
+		case "max", "len", "order":
+			l, err := strconv.ParseUint(val, 0, 31)
+			if err != nil {
+				return fmt.Errorf("invalid %s '%s'", key, val)
+			}
+			switch key {
+			case "max":
+				f.maxLen = int(l)
+			case "len":
+				f.fixedLen = int(l)
+			default:
+				f.order = int(l)
+			}
+
+		default:
+			return fmt.Errorf("unknown option '%s'", opt)
+		}
+	}
+
+	return nil
+}
+
+// structFields returns the exported fields of the passed struct type in wire
+// order along with the options parsed from their struct tags.  The fields of
+// embedded structs with the inline option are promoted in place of them as
+// described by collectFields.
+//
+// An error describing the issue is returned when a struct tag is malformed or
+// the combination of options is not valid.
+func structFields(vt reflect.Type) ([]structField, error) {
+	all, err := collectFields(vt, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkPromotedNames(all); err != nil {
+		return nil, err
+	}
+
+	// disc is the index of the most recent discriminant and hasDefault is
+	// whether or not its union has a default arm.  inGroup is whether or
+	// not every field since the discriminant has been one of its arms.
+	fields := make([]structField, 0, len(all))
+	disc := -1
+	hasDefault, inGroup := false, false
+	for _, f := range all {
+		// An interface field without any union arm options which
+		// immediately follows a discriminant or its arms is the default
+		// arm when the union doesn't already have one.  Its concrete
+		// type is chosen via the types registered with RegisterUnion.
+		if inGroup && !hasDefault && f.typ.Kind() == reflect.Interface &&
+			!f.union && len(f.cases) == 0 && !f.isDefault {
+
+			f.isDefault = true
+		}
+
+		if f.optional && f.typ.Kind() != reflect.Ptr {
+			return nil, fmt.Errorf("field '%s': optional data must "+
+				"be a pointer", f.name)
+		}
+		if f.hyper && !isIntegerKind(indirectType(f.typ).Kind()) {
+			return nil, fmt.Errorf("field '%s': hyper option "+
+				"requires an integer", f.name)
+		}
+		if err := checkSizeBounds(&f); err != nil {
+			return nil, fmt.Errorf("field '%s': %v", f.name, err)
+		}
+
+		switch {
+		case f.union:
+			if len(f.cases) > 0 || f.isDefault || f.optional ||
+				f.hyper {
+
+				return nil, fmt.Errorf("field '%s': union "+
+					"discriminant can't also be a union arm, "+
+					"optional, or hyper", f.name)
+			}
+			if !isDiscriminantKind(indirectType(f.typ).Kind()) {
+				return nil, fmt.Errorf("field '%s': union "+
+					"discriminant must be an integer or bool",
+					f.name)
+			}
+			disc = len(fields)
+			hasDefault, inGroup = false, true
+
+		case len(f.cases) > 0 || f.isDefault:
+			if disc < 0 {
+				return nil, fmt.Errorf("field '%s': union arm "+
+					"without a preceding discriminant", f.name)
+			}
+			if f.isDefault {
+				if hasDefault {
+					return nil, fmt.Errorf("field '%s': "+
+						"multiple default union arms",
+						f.name)
+				}
+				hasDefault = true
+			}
+			f.armOf = disc
+
+		default:
+			// Any other field ends the group of arms which
+			// immediately follow the discriminant.
+			inGroup = false
+		}
+
+		fields = append(fields, f)
+	}
+
+	return fields, nil
+}
+
+// collectFields returns the fields of the passed struct type, which is found at
+// the passed index sequence within the struct being described, in wire order
+// along with the options parsed from their struct tags.
+//
+// Fields tagged with `xdr:"-"` and unexported fields are skipped.  Embedded
+// structs are fields the same as any other, so they are encoded as nested
+// structs and skipped when their type is unexported, unless they have the
+// inline option.  The exported fields of an embedded struct with the inline
+// option, even one of an unexported type, are promoted in place of it.  The
+// option is only allowed on embedded structs which are not time.Time and do
+// not have a custom encoding via the Marshaler or Unmarshaler interfaces.
+// When any field, or embedded struct, has the order option they all must and
+// they are placed in ascending order of it with the fields promoted from an
+// embedded struct kept together in their own order.
+func collectFields(vt reflect.Type, index []int) ([]structField, error) {
+	// entry is a field or the group of fields promoted from an embedded
+	// struct along with its order option.
+	type entry struct {
+		name   string
+		order  int
+		fields []structField
+	}
+
+	entries := make([]entry, 0, vt.NumField())
+	numOrdered := 0
+	for i := 0; i < vt.NumField(); i++ {
+		// Skip fields tagged to be skipped as well as unexported fields
+		// other than embedded ones which may have the inline option.
+		vtf := vt.Field(i)
+		tag := vtf.Tag.Get("xdr")
+		unexported := vtf.PkgPath != ""
+		if tag == "-" || unexported && !vtf.Anonymous {
+			continue
+		}
+
+		f := structField{
+			index:    append(index[:len(index):len(index)], i),
+			name:     vtf.Name,
+			typ:      vtf.Type,
+			noOpaque: vtf.Tag.Get("xdropaque") == "false",
+			order:    -1,
+			maxLen:   -1,
+			fixedLen: -1,
+			armOf:    -1,
+		}
+		if err := parseFieldTag(&f, tag); err != nil {
+			return nil, fmt.Errorf("field '%s': %v", f.name, err)
+		}
+		if unexported && !f.inline {
+			continue
+		}
+		e := entry{name: f.name, order: f.order,
+			fields: []structField{f}}
+		if f.inline {
+			if !vtf.Anonymous || vtf.Type.Kind() != reflect.Struct ||
+				hasCustomEncoding(vtf.Type) {
+
+				return nil, fmt.Errorf("field '%s': inline "+
+					"option requires an embedded struct",
+					f.name)
+			}
+			if f.union || len(f.cases) > 0 || f.isDefault ||
+				f.optional || f.hyper || f.maxLen >= 0 ||
+				f.fixedLen >= 0 || f.noOpaque {
+
+				return nil, fmt.Errorf("field '%s': inline "+
+					"option only allows the order option",
+					f.name)
+			}
+			promoted, err := collectFields(vtf.Type, f.index)
+			if err != nil {
+				return nil, err
+			}
+			e.fields = promoted
+		}
+		if e.order >= 0 {
+			numOrdered++
+		}
+		entries = append(entries, e)
+	}
+
+	// Place the fields in the order given by their order options.
+	if numOrdered > 0 {
+		orders := make(map[int]string, len(entries))
+		for _, e := range entries {
+			if e.order < 0 {
+				return nil, fmt.Errorf("field '%s': order "+
+					"option must be given for every field "+
+					"when any field has it", e.name)
+			}
+			if name, ok := orders[e.order]; ok {
+				return nil, fmt.Errorf("field '%s': order %d "+
+					"is already used by '%s'", e.name,
+					e.order, name)
+			}
+			orders[e.order] = e.name
+		}
+		sort.Slice(entries, func(i, j int) bool {
+			return entries[i].order < entries[j].order
+		})
+	}
+
+	var fields []structField
+	for _, e := range entries {
+		fields = append(fields, e.fields...)
+	}
+	return fields, nil
+}
+
+// checkPromotedNames returns an error when more than one of the passed fields,
+// which include those promoted from embedded structs with the inline option,
+// has the same name.  Go would hide all but the least deeply nested of them or
+// treat them as ambiguous, either of which would silently drop fields from the
+// wire format, so they are rejected instead.
+func checkPromotedNames(fields []structField) error {
+	seen := make(map[string]bool, len(fields))
+	for _, f := range fields {
+		if seen[f.name] {
+			return fmt.Errorf("field '%s': promoted field has the "+
+				"same name as another field", f.name)
+		}
+		seen[f.name] = true
+	}
+	return nil
+}
+
+// hasCustomEncoding returns whether or not values of the passed type are
+// encoded or decoded specially rather than as a struct, which is the case for
+// time.Time and types which implement the Marshaler or Unmarshaler interfaces
+// directly or via a pointer.
+func hasCustomEncoding(t reflect.Type) bool {
+	pt := reflect.PtrTo(t)
+	return t == timeType || pt.Implements(marshalerType) ||
+		pt.Implements(unmarshalerType)
+}
+
+// checkSizeBounds returns an error when the max or len options of the passed
+// field are not valid for its type.  The max option applies to strings,
+// slices, and maps which are variable-length data while the len option
+// applies to slices, which are then encoded as fixed-length data, and arrays
+// of the same length.
+func checkSizeBounds(f *structField) error {
+	if f.maxLen < 0 && f.fixedLen < 0 {
+		return nil
+	}
+	if f.maxLen >= 0 && f.fixedLen >= 0 {
+		return fmt.Errorf("max and len options are mutually exclusive")
+	}
+
+	t := indirectType(f.typ)
+	if f.maxLen >= 0 {
+		switch t.Kind() {
+		case reflect.String, reflect.Slice, reflect.Map:
+			return nil
+		}
+		return fmt.Errorf("max option requires a string, slice, or " +
+			"map")
+	}
+
+	switch t.Kind() {
+	case reflect.Slice:
+		return nil
+	case reflect.Array:
+		if t.Len() != f.fixedLen {
+			return fmt.Errorf("len option does not match the "+
+				"array length of %d", t.Len())
+		}
+		return nil
+	}
+	return fmt.Errorf("len option requires a slice or array")
+}
+
+// selectArm returns the index into the passed fields of the union arm the
+// discriminant value selects for the discriminant at index disc.  The default
+// arm is selected when none of the arms list the value as one of their cases.
+// It returns -1 when no arm is selected.
+func selectArm(fields []structField, disc int, value int64) int {
+	def := -1
+	for i := disc + 1; i < len(fields); i++ {
+		f := &fields[i]
+		if f.armOf != disc {
+			// Arms always follow their discriminant, so another
+			// discriminant ends the union.
+			if f.union {
+				break
+			}
+			continue
+		}
+		for _, c := range f.cases {
+			if c == value {
+				return i
+			}
+		}
+		if f.isDefault {
+			def = i
+		}
+	}
+	return def
+}
+
+// isDiscriminantKind returns whether or not the passed kind is allowed for the
+// discriminant of a union.  Per RFC 4506 a discriminant is an integer,
+// unsigned integer, or enumeration, of which bool is a special case.
+func isDiscriminantKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int,
+		reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint,
+		reflect.Bool:
+		return true
+	}
+	return false
+}
+
+// isIntegerKind returns whether or not the passed kind is a signed or unsigned
+// integer.
+func isIntegerKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int,
+		reflect.Int64, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+		reflect.Uint, reflect.Uint64:
+		return true
+	}
+	return false
+}
+
+// discriminant returns the value of the passed reflection value, which must be
+// one of the kinds allowed by isDiscriminantKind, as an int64 suitable for
+// comparing against union cases.
+func discriminant(v reflect.Value) int64 {
+	switch v.Kind() {
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int:
+		return v.Int()
+
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint:
+		return int64(v.Uint())
+
+	case reflect.Bool:
+		if v.Bool() {
+			return 1
+		}
+	}
+	return 0
+}
+
+// indirectType dereferences pointer types until it reaches a non-pointer.
+func indirectType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
@@ -0,0 +1,85 @@
+/*
+ * Copyright (c) 2014 Dave Collins <dave@davec.name>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package xdr
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+var (
+	// unionTypes houses the concrete types registered for interface typed
+	// union arms keyed by the interface type and then by discriminant.
+	unionTypes   = make(map[reflect.Type]map[int32]reflect.Type)
+	unionTypesMu sync.RWMutex
+)
+
+// RegisterUnion registers the concrete Go types that values of the passed
+// interface type take on when they are the arm of a discriminated union.  The
+// arms map is keyed by the discriminant value which selects each type.  A nil
+// type registers the discriminant value as a void arm.
+//
+// The interface type is typically obtained with an expression such as
+// reflect.TypeOf((*Message)(nil)).Elem().  Registering the same interface type
+// again replaces the previously registered types.
+//
+// Once registered, a union arm of the interface type is encoded by verifying
+// the concrete type of its value matches the type selected by the
+// discriminant and decoded by allocating a new value of the selected type.
+// This allows a nil interface to be decoded into.
+//
+// RegisterUnion panics if the passed type is not an interface or one of the
+// arm types does not implement it since that is a programming error.
+func RegisterUnion(ifaceType reflect.Type, arms map[int32]reflect.Type) {
+	if ifaceType == nil || ifaceType.Kind() != reflect.Interface {
+		panic(fmt.Sprintf("xdr: RegisterUnion of non-interface type %v",
+			ifaceType))
+	}
+
+	types := make(map[int32]reflect.Type, len(arms))
+	for disc, t := range arms {
+		if t != nil && !t.Implements(ifaceType) {
+			panic(fmt.Sprintf("xdr: RegisterUnion type %v for "+
+				"discriminant %d does not implement %v", t,
+				disc, ifaceType))
+		}
+		types[disc] = t
+	}
+
+	unionTypesMu.Lock()
+	unionTypes[ifaceType] = types
+	unionTypesMu.Unlock()
+}
+
+// unionArmType returns the concrete type registered for the passed interface
+// type and discriminant value.  The registered flag indicates whether or not
+// the interface type was registered with RegisterUnion while the ok flag
+// indicates whether or not the discriminant value has a registered type.
+func unionArmType(ifaceType reflect.Type, disc int64) (t reflect.Type, registered, ok bool) {
+	unionTypesMu.RLock()
+	types, registered := unionTypes[ifaceType]
+	unionTypesMu.RUnlock()
+	if !registered {
+		return nil, false, false
+	}
+
+	// Discriminants are XDR integers or unsigned integers, so truncating
+	// to 32 bits results in the value as it appears on the wire.
+	t, ok = types[int32(disc)]
+	return t, true, ok
+}
@@ -0,0 +1,138 @@
+/*
+ * Copyright (c) 2014 Dave Collins <dave@davec.name>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package xdr_test
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"testing"
+
+	. "github.com/davecgh/go-xdr/xdr3"
+)
+
+// shape is used to test handling of interface typed union arms.
+type shape interface {
+	sides() int
+}
+
+// square is a shape with a value receiver.
+type square struct {
+	Side uint32
+}
+
+func (s square) sides() int { return 4 }
+
+// triangle is a shape with a pointer receiver.
+type triangle struct {
+	A, B, C uint32
+}
+
+func (t *triangle) sides() int { return 3 }
+
+// shapeUnionTest is used to test handling of an interface typed union arm
+// whose concrete types are registered via RegisterUnion.
+type shapeUnionTest struct {
+	Kind  int32 `xdr:"union"`
+	Shape shape
+}
+
+func init() {
+	RegisterUnion(reflect.TypeOf((*shape)(nil)).Elem(),
+		map[int32]reflect.Type{
+			0: nil,
+			1: reflect.TypeOf(square{}),
+			2: reflect.TypeOf((*triangle)(nil)),
+		})
+}
+
+// TestRegisterUnion ensures RegisterUnion panics when given types that can't
+// be used for interface typed union arms.
+func TestRegisterUnion(t *testing.T) {
+	tests := []struct {
+		name  string
+		iface reflect.Type
+		arms  map[int32]reflect.Type
+	}{
+		{"nil interface type", nil, nil},
+		{"non-interface type", reflect.TypeOf(square{}), nil},
+		{"arm does not implement interface",
+			reflect.TypeOf((*shape)(nil)).Elem(),
+			map[int32]reflect.Type{1: reflect.TypeOf(triangle{})}},
+	}
+
+	for _, test := range tests {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: did not panic", test.name)
+				}
+			}()
+			RegisterUnion(test.iface, test.arms)
+		}()
+	}
+}
+
+// TestUnionGroup ensures only interface fields which immediately follow a
+// discriminant or its arms are treated as the default arm of the union while
+// interface fields after other fields are encoded as ordinary fields.
+func TestUnionGroup(t *testing.T) {
+	type explicitArms struct {
+		Kind  int32 `xdr:"union"`
+		Int   int32 `xdr:"unioncase=1"`
+		Note  string
+		Extra interface{}
+	}
+	type interfaceArm struct {
+		Kind  int32 `xdr:"union"`
+		Shape shape
+		Count uint32
+		Extra interface{}
+	}
+
+	tests := []struct {
+		in   interface{} // value to encode
+		want []byte      // expected encoding
+	}{
+		{explicitArms{Kind: 1, Int: 2, Note: "a", Extra: uint32(3)},
+			[]byte{
+				0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x02,
+				0x00, 0x00, 0x00, 0x01, 'a', 0x00, 0x00, 0x00,
+				0x00, 0x00, 0x00, 0x03,
+			}},
+		{interfaceArm{Kind: 1, Shape: square{4}, Count: 5,
+			Extra: uint32(6)},
+			[]byte{
+				0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x04,
+				0x00, 0x00, 0x00, 0x05, 0x00, 0x00, 0x00, 0x06,
+			}},
+	}
+
+	for i, test := range tests {
+		var buf bytes.Buffer
+		n, err := Marshal(&buf, test.in)
+		testName := fmt.Sprintf("Marshal #%d", i)
+		if !testExpectedMRet(t, testName, n, len(test.want), err, nil) {
+			continue
+		}
+		if !bytes.Equal(buf.Bytes(), test.want) {
+			t.Errorf("%s: unexpected result - got: %x want: %x",
+				testName, buf.Bytes(), test.want)
+			continue
+		}
+	}
+}